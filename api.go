@@ -15,9 +15,19 @@ const (
 	DeletionDelayAnnotation     = Domain + "/deletionDelay"
 	DeletionTimestampAnnotation = Domain + "/deletionTimestamp"
 
+	// See docs/design/cluster-scoped-objects.md
+	BundleUIDLabel   = Domain + "/bundleUID"
+	BundleAnnotation = Domain + "/bundle"
+
+	// See docs/design/managed-objects.md
+	ManagedByLabel      = "app.kubernetes.io/managed-by"
+	ManagedByLabelValue = Smith
+	ManagedBySelector   = ManagedByLabel + "=" + ManagedByLabelValue
+
 	EventReasonResourceInProgress = "ResourceInProgress"
 	EventReasonResourceReady      = "ResourceReady"
 	EventReasonResourceError      = "ResourceError"
+	EventReasonResourceRolledBack = "ResourceRolledBack"
 	EventReasonBundleInProgress   = "BundleInProgress"
 	EventReasonBundleReady        = "BundleReady"
 	EventReasonBundleError        = "BundleError"
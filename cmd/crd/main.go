@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"os"
 
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/atlassian/smith/pkg/crd"
 	"github.com/atlassian/smith/pkg/resources"
+	"github.com/pkg/errors"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func main() {
@@ -19,7 +24,36 @@ func main() {
 
 func innerMain() error {
 	printBundle := flag.String("print-bundle", "yaml", "Print Bundle CRD and exit (specify format: json or yaml)")
+	apiVersion := flag.String("api-version", apiext_v1.SchemeGroupVersion.Version,
+		"Version of apiextensions.k8s.io API to print Bundle CRD for (specify version: v1 or v1beta1)")
+	kind := flag.String("crd", smith_v1.BundleResourceSingular,
+		"CRD to print (specify: bundle, bundlepolicy or clusterbundlepolicy)")
 	flag.Parse()
 
-	return resources.PrintCleanedObject(os.Stdout, *printBundle, crd.BundleCrd())
+	var crdV1 *apiext_v1.CustomResourceDefinition
+	switch *kind {
+	case smith_v1.BundleResourceSingular:
+		crdV1 = crd.BundleCrd()
+	case smith_v1.BundlePolicyResourceSingular:
+		crdV1 = crd.BundlePolicyCrd()
+	case smith_v1.ClusterBundlePolicyResourceSingular:
+		crdV1 = crd.ClusterBundlePolicyCrd()
+	default:
+		return errors.Errorf("unsupported CRD %q", *kind)
+	}
+
+	var obj runtime.Object
+	switch *apiVersion {
+	case apiext_v1.SchemeGroupVersion.Version:
+		obj = crdV1
+	case apiext_v1b1.SchemeGroupVersion.Version:
+		var err error
+		obj, err = crd.ToV1beta1(crdV1)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.Errorf("unsupported API version %q", *apiVersion)
+	}
+	return resources.PrintCleanedObject(os.Stdout, *printBundle, obj)
 }
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"strings"
 	"time"
 
 	"github.com/atlassian/ctrl"
@@ -8,14 +9,19 @@ import (
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/atlassian/smith/pkg/client"
 	smithClientset "github.com/atlassian/smith/pkg/client/clientset_generated/clientset"
+	"github.com/atlassian/smith/pkg/client/multins"
 	"github.com/atlassian/smith/pkg/client/smart"
 	"github.com/atlassian/smith/pkg/controller/bundlec"
+	"github.com/atlassian/smith/pkg/crd"
 	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/plugin/builtin"
+	"github.com/atlassian/smith/pkg/plugin/remote"
 	"github.com/atlassian/smith/pkg/specchecker"
 	specchecker_builtin "github.com/atlassian/smith/pkg/specchecker/builtin"
 	"github.com/atlassian/smith/pkg/statuschecker"
 	statuschecker_builtin "github.com/atlassian/smith/pkg/statuschecker/builtin"
 	"github.com/atlassian/smith/pkg/store"
+	"github.com/atlassian/smith/pkg/webhook"
 	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
 	scClientset "github.com/kubernetes-sigs/service-catalog/pkg/client/clientset_generated/clientset"
 	sc_v1b1inf "github.com/kubernetes-sigs/service-catalog/pkg/client/informers_generated/externalversions/servicecatalog/v1beta1"
@@ -23,47 +29,118 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	apps_v1 "k8s.io/api/apps/v1"
 	autoscaling_v2b1 "k8s.io/api/autoscaling/v2beta1"
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1b1 "k8s.io/api/batch/v1beta1"
 	core_v1 "k8s.io/api/core/v1"
 	net_v1b1 "k8s.io/api/networking/v1beta1"
 	policy_v1 "k8s.io/api/policy/v1beta1"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	rbac_v1 "k8s.io/api/rbac/v1"
+	scheduling_v1 "k8s.io/api/scheduling/v1"
+	storage_v1 "k8s.io/api/storage/v1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiExtClientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
-	apiext_v1b1inf "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions/apiextensions/v1beta1"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
-	apps_v1inf "k8s.io/client-go/informers/apps/v1"
-	autoscaling_v2b1inf "k8s.io/client-go/informers/autoscaling/v2beta1"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	core_v1inf "k8s.io/client-go/informers/core/v1"
-	net_v1b1inf "k8s.io/client-go/informers/networking/v1beta1"
-	policy_v1b1inf "k8s.io/client-go/informers/policy/v1beta1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 )
 
+const (
+	referenceLookupCacheSize = 1000
+	// referenceLookupTTL is how long looked up objects are cached. Changes to objects that are not managed by Smith
+	// are not watched so they are noticed when the cached object expires and the Bundle is re-processed.
+	referenceLookupTTL = 1 * time.Minute
+
+	storageClassLookupCacheSize = 100
+)
+
+var (
+	apiServiceGVK = schema.GroupVersionKind{Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"}
+	apiServiceGVR = schema.GroupVersionResource{Group: "apiregistration.k8s.io", Version: "v1", Resource: "apiservices"}
+)
+
 type BundleControllerConstructor struct {
 	Plugins               []plugin.NewFunc
+	BuiltinPlugins        string
+	RemotePlugins         string
 	ServiceCatalogSupport bool
+	FailFast              bool
+	WebhookListenOn       string
+	WebhookTLSCertFile    string
+	WebhookTLSKeyFile     string
+	Impersonate           bool
+	AccessReview          bool
+	Policies              bool
+	ClusterScopedObjects  bool
+	Namespaces            string
+	NamespaceSelector     string
+	ManagedByLabel        bool
+	ManagedObjectsOnly    bool
+	DynamicInformers      bool
+	DiscoveryCacheTTL     time.Duration
+	PluginTimeout         time.Duration
+	PluginConcurrency     int
 
 	// To override things constructed by default. And for tests.
 	SmithClient  smithClientset.Interface
 	SCClient     scClientset.Interface
 	APIExtClient apiExtClientset.Interface
 	SmartClient  bundlec.SmartClient
+	RESTMapper   meta.RESTMapper
+	// Only used if Impersonate is true.
+	ImpersonatingSmartClient bundlec.ImpersonatingSmartClient
+	// Only used if AccessReview is true.
+	AccessReviewer bundlec.AccessReviewer
 }
 
 func (c *BundleControllerConstructor) AddFlags(flagset ctrl.FlagSet) {
+	flagset.StringVar(&c.BuiltinPlugins, "bundle-builtin-plugins", builtinPluginNames(), "Comma-separated list of built-in plugins to load. Empty to not load any. See docs/design/plugins.md")
+	flagset.StringVar(&c.RemotePlugins, "bundle-remote-plugins", "", "File with configuration of out-of-process plugins to load. See docs/design/plugins.md")
 	flagset.BoolVar(&c.ServiceCatalogSupport, "bundle-service-catalog", true, "Service Catalog support in Bundle controller. Enabled by default.")
+	flagset.BoolVar(&c.FailFast, "bundle-fail-fast", false, "Mark resources as failed as soon as an unrecoverable problem is detected (e.g. invalid image name of a Deployment) rather than waiting for a deadline to be exceeded.")
+	flagset.StringVar(&c.WebhookListenOn, "bundle-webhook-listen-on", "", "Address to serve Bundle admission webhooks on. Empty to disable")
+	flagset.StringVar(&c.WebhookTLSCertFile, "bundle-webhook-tls-cert-file", "", "File containing the TLS certificate for the Bundle admission webhooks server")
+	flagset.StringVar(&c.WebhookTLSKeyFile, "bundle-webhook-tls-key-file", "", "File containing the TLS private key for the Bundle admission webhooks server")
+	flagset.BoolVar(&c.Impersonate, "bundle-impersonate", false, "Impersonate authors of Bundles when managing their objects. Requires the mutating admission webhook to record authors of Bundles.")
+	flagset.BoolVar(&c.AccessReview, "bundle-access-review", false, "Check that authors of Bundles are allowed to create, update and delete objects of Bundles using SubjectAccessReviews. Requires the mutating admission webhook to record authors of Bundles.")
+	flagset.BoolVar(&c.Policies, "bundle-policies", false, "Enforce BundlePolicies and ClusterBundlePolicies. Requires their CRDs to be installed.")
+	flagset.BoolVar(&c.ClusterScopedObjects, "bundle-cluster-scoped-objects", false, "Allow Bundles to contain cluster-scoped objects (e.g. ClusterRoles, PriorityClasses and CRDs) if a policy that applies to them sets allowClusterScoped. Requires -bundle-policies. Disabled by default.")
+	flagset.StringVar(&c.Namespaces, "bundle-namespaces", "", "Comma-separated list of namespaces to watch. Cannot be combined with -namespace and -bundle-namespace-selector")
+	flagset.StringVar(&c.NamespaceSelector, "bundle-namespace-selector", "", "Label selector for namespaces to watch. Cannot be combined with -namespace and -bundle-namespaces")
+	flagset.BoolVar(&c.ManagedByLabel, "bundle-managed-by-label", false, "Label objects of Bundles with "+smith.ManagedBySelector)
+	flagset.BoolVar(&c.ManagedObjectsOnly, "bundle-managed-objects-only", false, "Only watch objects labelled with "+smith.ManagedBySelector+" to reduce memory usage. Requires -bundle-managed-by-label")
+	flagset.DurationVar(&c.DiscoveryCacheTTL, "bundle-discovery-cache-ttl", smart.DefaultDiscoveryCacheTTL, "How long to cache discovery information for. Cached information is also invalidated when CRDs and APIServices change")
+	flagset.DurationVar(&c.PluginTimeout, "bundle-plugin-timeout", bundlec.DefaultPluginTimeout, "Deadline for each plugin invocation. Plugins that do not return within the deadline fail the resource with a retriable error. Zero disables the deadline")
+	flagset.IntVar(&c.PluginConcurrency, "bundle-plugin-concurrency", bundlec.DefaultPluginConcurrency, "Maximum number of concurrent invocations of each plugin. A plugin is not invoked while an invocation that did not return within the deadline is still running")
+	flagset.BoolVar(&c.DynamicInformers, "bundle-dynamic-informers", false, "Start informers on demand for kinds that Bundles use but that are not watched otherwise (e.g. RBAC Roles). Informers are stopped when no Bundle uses the kind anymore")
 }
 
 func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Context) (*ctrl.Constructed, error) {
+	if c.ManagedObjectsOnly && !c.ManagedByLabel {
+		return nil, errors.New("watching only managed objects requires labelling of managed objects to be enabled")
+	}
 	// Plugins
 	pluginContainers, err := c.loadPlugins()
 	if err != nil {
 		return nil, err
 	}
+	constructed := false
+	defer func() {
+		// Out-of-process plugins are stopped by the controller once it has been constructed
+		if !constructed {
+			closePlugins(pluginContainers)
+		}
+	}()
 	for pluginName := range pluginContainers {
 		config.Logger.Sugar().Infof("Loaded plugin: %q", pluginName)
 	}
@@ -94,32 +171,66 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 			return nil, err
 		}
 	}
+	rm := c.RESTMapper
+	var discoveryRM *restmapper.DeferredDiscoveryRESTMapper
+	var discoveryCacheRequestsCounter *prometheus.CounterVec
+	if rm == nil {
+		discoveryCacheRequestsCounter = smart.NewDiscoveryCacheRequestsCounter(config.AppName)
+		discoveryRM = restmapper.NewDeferredDiscoveryRESTMapper(
+			smart.NewCachedDiscoveryClient(config.MainClient.Discovery(), c.DiscoveryCacheTTL, discoveryCacheRequestsCounter),
+		)
+		rm = discoveryRM
+	}
 	smartClient := c.SmartClient
 	if smartClient == nil {
-		rm := restmapper.NewDeferredDiscoveryRESTMapper(
-			&smart.CachedDiscoveryClient{
-				DiscoveryInterface: config.MainClient.Discovery(),
-			},
-		)
 		dynamicClient, err := dynamic.NewForConfig(config.RestConfig) // nolint: vetshadow
 		if err != nil {
 			return nil, err
 		}
 		smartClient = &smart.DynamicClient{
-			DynamicClient: dynamicClient,
-			RESTMapper:    rm,
+			DynamicClient:          dynamicClient,
+			RESTMapper:             rm,
+			DiscoveryCacheRequests: discoveryCacheRequestsCounter,
+		}
+	}
+	if c.Impersonate || c.AccessReview {
+		// Authors are only trustworthy if the webhook sets them on every change
+		if err = webhook.CheckBundleAuthorWebhook(config.MainClient.AdmissionregistrationV1()); err != nil {
+			return nil, err
+		}
+	}
+	var impersonatingSmartClient bundlec.ImpersonatingSmartClient
+	if c.Impersonate {
+		impersonatingSmartClient = c.ImpersonatingSmartClient
+		if impersonatingSmartClient == nil {
+			impersonatingSmartClient = smart.NewImpersonatingClient(config.RestConfig, rm)
+		}
+	}
+	var accessReviewer bundlec.AccessReviewer
+	if c.AccessReview {
+		accessReviewer = c.AccessReviewer
+		if accessReviewer == nil {
+			accessReviewer = &smart.AccessReviewer{
+				Client:     config.MainClient.AuthorizationV1(),
+				RESTMapper: rm,
+			}
 		}
 	}
 
 	// Informers
-	bundleInf, err := smithInformer(config, cctx, smithClient, smith_v1.BundleGVK, client.BundleInformer)
+	namespaces, err := c.namespaces(config, cctx)
+	if err != nil {
+		return nil, err
+	}
+	bundleInf, err := smithInformer(config, cctx, namespaces, smithClient, smith_v1.BundleGVK, client.BundleInformer)
 	if err != nil {
 		return nil, err
 	}
-	crdGVK := apiext_v1b1.SchemeGroupVersion.WithKind("CustomResourceDefinition")
+	crdGVK := apiext_v1.SchemeGroupVersion.WithKind("CustomResourceDefinition")
+	// Falls back to apiextensions.k8s.io/v1beta1 on servers that do not serve v1
 	crdInf, err := apiExtensionsInformer(config, cctx, apiExtClient,
 		crdGVK,
-		apiext_v1b1inf.NewCustomResourceDefinitionInformer)
+		crd.NewInformer)
 	if err != nil {
 		return nil, err
 	}
@@ -127,6 +238,18 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 	if err != nil {
 		return nil, err
 	}
+	if discoveryRM != nil {
+		// Discovery information is invalidated when the set of served kinds changes
+		apiServiceInf, err := c.apiServiceInformer(config, cctx) // nolint: vetshadow
+		if err != nil {
+			return nil, err
+		}
+		invalidationHandler := &smart.DiscoveryInvalidationHandler{
+			Reset: discoveryRM.Reset,
+		}
+		crdInf.AddEventHandler(invalidationHandler)
+		apiServiceInf.AddEventHandler(invalidationHandler)
+	}
 
 	var catalog *store.Catalog
 	if c.ServiceCatalogSupport {
@@ -144,8 +267,26 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 		return nil, err
 	}
 
+	// Policies
+	var policyStore bundlec.PolicyStore
+	var policyInf, clusterPolicyInf cache.SharedIndexInformer
+	if c.Policies {
+		policyInf, err = smithInformer(config, cctx, namespaces, smithClient, smith_v1.BundlePolicyGVK, client.BundlePolicyInformer)
+		if err != nil {
+			return nil, err
+		}
+		clusterPolicyInf, err = smithClusterInformer(config, cctx, smithClient, smith_v1.ClusterBundlePolicyGVK, client.ClusterBundlePolicyInformer)
+		if err != nil {
+			return nil, err
+		}
+		policyStore, err = store.NewPolicy(policyInf, clusterPolicyInf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Add resource informers to Multi store (not ServiceClass/Plan informers, ...)
-	resourceInfs, err := c.resourceInformers(config, cctx, scClient)
+	resourceInfs, err := c.resourceInformers(config, cctx, namespaces, scClient)
 	if err != nil {
 		return nil, err
 	}
@@ -156,6 +297,20 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 			return nil, errors.Errorf("failed to add informer for %s", gvk)
 		}
 	}
+	// StorageClasses are only needed to check PersistentVolumeClaims so they are not watched
+	storageClassGVK := storage_v1.SchemeGroupVersion.WithKind("StorageClass")
+	if err = multiStore.AddLookup(storageClassGVK, storageClassLookup(config.MainClient)); err != nil {
+		return nil, errors.Errorf("failed to add lookup for %s", storageClassGVK)
+	}
+	if c.ManagedObjectsOnly {
+		// ConfigMaps and Secrets referenced by Deployments, ServiceInstances and ServiceBindings
+		// may not be managed by Smith so they are fetched when they are not in the informers' caches.
+		for gvk, lookup := range referenceLookups(config.MainClient) {
+			if err = multiStore.AddLookup(gvk, lookup); err != nil {
+				return nil, errors.Errorf("failed to add lookup for %s", gvk)
+			}
+		}
+	}
 
 	// Metrics
 	bundleTransitionCounter := prometheus.NewCounterVec(
@@ -175,7 +330,13 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 		[]string{"namespace", "name", "resource", "type", "reason"},
 	)
 
-	allMetrics := []prometheus.Collector{bundleTransitionCounter, bundleResourceTransitionCounter}
+	pluginProcessDuration := bundlec.NewPluginProcessDurationHistogram(config.AppName)
+	pluginErrorCounter := bundlec.NewPluginErrorCounter(config.AppName)
+
+	allMetrics := []prometheus.Collector{bundleTransitionCounter, bundleResourceTransitionCounter, pluginProcessDuration, pluginErrorCounter}
+	if discoveryCacheRequestsCounter != nil {
+		allMetrics = append(allMetrics, discoveryCacheRequestsCounter)
+	}
 	for _, metric := range allMetrics {
 		err = config.Registry.Register(metric)
 		if err != nil {
@@ -188,7 +349,10 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 	if c.ServiceCatalogSupport {
 		readyTypes = append(readyTypes, statuschecker_builtin.ServiceCatalogKnownTypes)
 	}
-	rc, err := statuschecker.New(crdStore, readyTypes...)
+	rc, err := statuschecker.New(&statuschecker.Context{
+		Store:    multiStore,
+		FailFast: c.FailFast,
+	}, crdStore, readyTypes...)
 	if err != nil {
 		return nil, err
 	}
@@ -212,17 +376,30 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 		BundleClient:                    smithClient.SmithV1(),
 		BundleStore:                     bs,
 		SmartClient:                     smartClient,
+		ImpersonatingSmartClient:        impersonatingSmartClient,
+		AccessReviewer:                  accessReviewer,
+		PolicyStore:                     policyStore,
+		RESTMapper:                      rm,
+		ClusterScopedObjects:            c.ClusterScopedObjects,
+		ManagedByLabel:                  c.ManagedByLabel,
+		ManagedObjectsOnly:              c.ManagedObjectsOnly,
+		DynamicInformers:                c.DynamicInformers,
 		Rc:                              rc,
 		Store:                           multiStore,
 		SpecChecker:                     specChecker,
 		WorkQueue:                       cctx.WorkQueue,
 		CrdResyncPeriod:                 config.ResyncPeriod,
 		Namespace:                       config.Namespace,
+		Namespaces:                      namespaces,
 		PluginContainers:                pluginContainers,
+		PluginTimeout:                   c.PluginTimeout,
+		PluginConcurrency:               c.PluginConcurrency,
 		Scheme:                          scheme,
 		Catalog:                         catalog,
 		BundleTransitionCounter:         bundleTransitionCounter,
 		BundleResourceTransitionCounter: bundleResourceTransitionCounter,
+		PluginProcessDuration:           pluginProcessDuration,
+		PluginErrorCounter:              pluginErrorCounter,
 
 		Broadcaster: broadcaster,
 		Recorder:    recorder,
@@ -231,9 +408,36 @@ func (c *BundleControllerConstructor) New(config *ctrl.Config, cctx *ctrl.Contex
 	if err != nil {
 		return nil, err
 	}
+	if c.Policies {
+		cntrlr.PreparePolicies(policyInf, clusterPolicyInf)
+	}
+
+	// Webhooks
+	var server ctrl.Server
+	if c.WebhookListenOn != "" {
+		if c.WebhookTLSCertFile == "" || c.WebhookTLSKeyFile == "" {
+			return nil, errors.New("TLS certificate and key files must be specified to serve Bundle admission webhooks")
+		}
+		server = &webhook.Server{
+			Logger:     config.Logger,
+			Addr:       c.WebhookListenOn,
+			CertFile:   c.WebhookTLSCertFile,
+			KeyFile:    c.WebhookTLSKeyFile,
+			Middleware: cctx.Middleware,
+			BundleValidator: &bundlec.BundleValidator{
+				Logger:           config.Logger,
+				PluginContainers: pluginContainers,
+				Scheme:           scheme,
+				Catalog:          catalog,
+				Policies:         policyStore,
+			},
+		}
+	}
 
+	constructed = true
 	return &ctrl.Constructed{
 		Interface: cntrlr,
+		Server:    server,
 	}, nil
 }
 
@@ -244,14 +448,38 @@ func (c *BundleControllerConstructor) Describe() ctrl.Descriptor {
 }
 
 func (c *BundleControllerConstructor) loadPlugins() (map[smith_v1.PluginName]plugin.Container, error) {
-	pluginContainers := make(map[smith_v1.PluginName]plugin.Container, len(c.Plugins))
-	for _, p := range c.Plugins {
+	newFuncs := make([]plugin.NewFunc, 0, len(c.Plugins))
+	newFuncs = append(newFuncs, c.Plugins...)
+	if c.BuiltinPlugins != "" {
+		builtinPlugins := builtin.Plugins()
+		for _, name := range strings.Split(c.BuiltinPlugins, ",") {
+			newFunc, ok := builtinPlugins[smith_v1.PluginName(strings.TrimSpace(name))]
+			if !ok {
+				return nil, errors.Errorf("unknown built-in plugin %q", name)
+			}
+			newFuncs = append(newFuncs, newFunc)
+		}
+	}
+	if c.RemotePlugins != "" {
+		configs, err := remote.LoadConfigFile(c.RemotePlugins)
+		if err != nil {
+			return nil, err
+		}
+		for _, config := range configs {
+			newFuncs = append(newFuncs, remote.NewFunc(config))
+		}
+	}
+	pluginContainers := make(map[smith_v1.PluginName]plugin.Container, len(newFuncs))
+	for _, p := range newFuncs {
 		pluginContainer, err := plugin.NewContainer(p)
 		if err != nil {
+			closePlugins(pluginContainers)
 			return nil, err
 		}
 		description := pluginContainer.Plugin.Describe()
 		if _, ok := pluginContainers[description.Name]; ok {
+			pluginContainer.Close() // nolint: errcheck, gosec
+			closePlugins(pluginContainers)
 			return nil, errors.Errorf("plugins with same name found %q", description.Name)
 		}
 		pluginContainers[description.Name] = pluginContainer
@@ -259,36 +487,105 @@ func (c *BundleControllerConstructor) loadPlugins() (map[smith_v1.PluginName]plu
 	return pluginContainers, nil
 }
 
-func (c *BundleControllerConstructor) resourceInformers(config *ctrl.Config, cctx *ctrl.Context, scClient scClientset.Interface) (map[schema.GroupVersionKind]cache.SharedIndexInformer, error) {
-	coreInfs := map[schema.GroupVersionKind]func(kubernetes.Interface, string, time.Duration, cache.Indexers) cache.SharedIndexInformer{
-		// Core API types
-		net_v1b1.SchemeGroupVersion.WithKind("Ingress"):                         net_v1b1inf.NewIngressInformer,
-		core_v1.SchemeGroupVersion.WithKind("Service"):                          core_v1inf.NewServiceInformer,
-		core_v1.SchemeGroupVersion.WithKind("ConfigMap"):                        core_v1inf.NewConfigMapInformer,
-		core_v1.SchemeGroupVersion.WithKind("Secret"):                           core_v1inf.NewSecretInformer,
-		core_v1.SchemeGroupVersion.WithKind("ServiceAccount"):                   core_v1inf.NewServiceAccountInformer,
-		apps_v1.SchemeGroupVersion.WithKind("Deployment"):                       apps_v1inf.NewDeploymentInformer,
-		autoscaling_v2b1.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"): autoscaling_v2b1inf.NewHorizontalPodAutoscalerInformer,
-		policy_v1.SchemeGroupVersion.WithKind("PodDisruptionBudget"):            policy_v1b1inf.NewPodDisruptionBudgetInformer,
-	}
-	infs := make(map[schema.GroupVersionKind]cache.SharedIndexInformer, len(coreInfs)+2)
-	for gvk, coreInf := range coreInfs {
-		inf, err := cctx.MainInformer(config, gvk, coreInf)
+func closePlugins(pluginContainers map[smith_v1.PluginName]plugin.Container) {
+	for _, pluginContainer := range pluginContainers {
+		pluginContainer.Close() // nolint: errcheck, gosec
+	}
+}
+
+// builtinPluginNames returns names of all built-in plugins as a comma-separated list.
+func builtinPluginNames() string {
+	names := builtin.Names()
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, string(name))
+	}
+	return strings.Join(result, ",")
+}
+
+// namespaces returns the set of namespaces to watch or nil if a single namespace from config is watched.
+func (c *BundleControllerConstructor) namespaces(config *ctrl.Config, cctx *ctrl.Context) (*multins.Namespaces, error) {
+	if c.Namespaces == "" && c.NamespaceSelector == "" {
+		return nil, nil
+	}
+	if c.Namespaces != "" && c.NamespaceSelector != "" {
+		return nil, errors.New("list of namespaces and namespace selector cannot be specified at the same time")
+	}
+	if config.Namespace != meta_v1.NamespaceAll {
+		return nil, errors.New("namespace cannot be specified together with a list of namespaces or a namespace selector")
+	}
+	if c.Namespaces != "" {
+		var names []string
+		for _, name := range strings.Split(c.Namespaces, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, errors.New("list of namespaces is empty")
+		}
+		return multins.NewNamespaces(config.Logger, names...), nil
+	}
+	selector, err := labels.Parse(c.NamespaceSelector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid namespace selector")
+	}
+	namespaceInf, err := cctx.MainClusterInformer(config, core_v1.SchemeGroupVersion.WithKind("Namespace"), core_v1inf.NewNamespaceInformer)
+	if err != nil {
+		return nil, err
+	}
+	return multins.NewSelectedNamespaces(config.Logger, namespaceInf, selector), nil
+}
+
+func (c *BundleControllerConstructor) resourceInformers(config *ctrl.Config, cctx *ctrl.Context, namespaces *multins.Namespaces, scClient scClientset.Interface) (map[schema.GroupVersionKind]cache.SharedIndexInformer, error) {
+	tweakListOptions := c.tweakListOptions()
+	// ReplicaSets and Pods are created by Deployments rather than by Smith so they are never labelled
+	unlabelled := map[schema.GroupVersionKind]bool{
+		apps_v1.SchemeGroupVersion.WithKind("ReplicaSet"): true,
+		core_v1.SchemeGroupVersion.WithKind("Pod"):        true,
+	}
+	infs := make(map[schema.GroupVersionKind]cache.SharedIndexInformer, len(coreResourceTypes)+2)
+	// Core API types
+	for gvk, resType := range coreResourceTypes {
+		gvk := gvk
+		resType := resType
+		tweak := tweakListOptions
+		if unlabelled[gvk] {
+			tweak = nil
+		}
+		inf, err := mainInformer(config, cctx, namespaces, gvk, func(client kubernetes.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+			return newTransformingInformer(resType.listWatch(client, namespace), resType.objType, gvk, resyncPeriod, indexers, tweak)
+		})
 		if err != nil {
 			return nil, err
 		}
 		infs[gvk] = inf
 	}
 
+	// Cluster-scoped types
+	if c.ClusterScopedObjects {
+		for gvk, resType := range clusterResourceTypes {
+			gvk := gvk
+			resType := resType
+			inf, err := cctx.MainClusterInformer(config, gvk, func(client kubernetes.Interface, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+				return newTransformingInformer(resType.listWatch(client), resType.objType, gvk, resyncPeriod, indexers, tweakListOptions)
+			})
+			if err != nil {
+				return nil, err
+			}
+			infs[gvk] = inf
+		}
+	}
+
 	// Service Catalog types
 	if c.ServiceCatalogSupport {
-		scInfs := map[schema.GroupVersionKind]func(scClientset.Interface, string, time.Duration, cache.Indexers) cache.SharedIndexInformer{
-			// Service Catalog types
-			sc_v1b1.SchemeGroupVersion.WithKind("ServiceBinding"):  sc_v1b1inf.NewServiceBindingInformer,
-			sc_v1b1.SchemeGroupVersion.WithKind("ServiceInstance"): sc_v1b1inf.NewServiceInstanceInformer,
-		}
-		for gvk, scInf := range scInfs {
-			inf, err := svcCatInformer(config, cctx, scClient, gvk, scInf)
+		for gvk, resType := range svcCatResourceTypes {
+			gvk := gvk
+			resType := resType
+			inf, err := svcCatInformer(config, cctx, namespaces, scClient, gvk, func(client scClientset.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+				return newTransformingInformer(resType.listWatch(client, namespace), resType.objType, gvk, resyncPeriod, indexers, tweakListOptions)
+			})
 			if err != nil {
 				return nil, err
 			}
@@ -299,6 +596,65 @@ func (c *BundleControllerConstructor) resourceInformers(config *ctrl.Config, cct
 	return infs, nil
 }
 
+// newTransformingInformer returns an informer that applies tweakListOptions, if set, to list and watch
+// requests and strips objects with store.TransformFor before putting them into the cache.
+func newTransformingInformer(lw *cache.ListWatch, objType runtime.Object, gvk schema.GroupVersionKind, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions func(*meta_v1.ListOptions)) cache.SharedIndexInformer {
+	if tweakListOptions != nil {
+		listFunc, watchFunc := lw.ListFunc, lw.WatchFunc
+		lw.ListFunc = func(options meta_v1.ListOptions) (runtime.Object, error) {
+			tweakListOptions(&options)
+			return listFunc(options)
+		}
+		lw.WatchFunc = func(options meta_v1.ListOptions) (watch.Interface, error) {
+			tweakListOptions(&options)
+			return watchFunc(options)
+		}
+	}
+	return cache.NewSharedIndexInformer(store.NewTransformingListerWatcher(lw, store.TransformFor(gvk)), objType, resyncPeriod, indexers)
+}
+
+// tweakListOptions returns a function that restricts informers to objects managed by Smith
+// or nil if all objects should be watched.
+func (c *BundleControllerConstructor) tweakListOptions() func(*meta_v1.ListOptions) {
+	if !c.ManagedObjectsOnly {
+		return nil
+	}
+	return func(options *meta_v1.ListOptions) {
+		options.LabelSelector = smith.ManagedBySelector
+	}
+}
+
+// referenceLookups returns lookups for objects that can be referenced by managed objects.
+func referenceLookups(mainClient kubernetes.Interface) map[schema.GroupVersionKind]*store.Lookup {
+	return map[schema.GroupVersionKind]*store.Lookup{
+		core_v1.SchemeGroupVersion.WithKind("ConfigMap"): store.NewLookup(func(namespace, name string) (runtime.Object, bool, error) {
+			obj, err := mainClient.CoreV1().ConfigMaps(namespace).Get(name, meta_v1.GetOptions{})
+			return lookupResult(obj, err)
+		}, referenceLookupCacheSize, referenceLookupTTL),
+		core_v1.SchemeGroupVersion.WithKind("Secret"): store.NewLookup(func(namespace, name string) (runtime.Object, bool, error) {
+			obj, err := mainClient.CoreV1().Secrets(namespace).Get(name, meta_v1.GetOptions{})
+			return lookupResult(obj, err)
+		}, referenceLookupCacheSize, referenceLookupTTL),
+	}
+}
+
+func storageClassLookup(mainClient kubernetes.Interface) *store.Lookup {
+	return store.NewLookup(func(namespace, name string) (runtime.Object, bool, error) {
+		obj, err := mainClient.StorageV1().StorageClasses().Get(name, meta_v1.GetOptions{})
+		return lookupResult(obj, err)
+	}, storageClassLookupCacheSize, referenceLookupTTL)
+}
+
+func lookupResult(obj runtime.Object, err error) (runtime.Object, bool /*exists*/, error) {
+	if err != nil {
+		if api_errors.IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return obj, true, nil
+}
+
 func FullScheme(serviceCatalog bool) (*runtime.Scheme, error) {
 	scheme := runtime.NewScheme()
 	var sb runtime.SchemeBuilder
@@ -306,9 +662,13 @@ func FullScheme(serviceCatalog bool) (*runtime.Scheme, error) {
 	sb.Register(net_v1b1.SchemeBuilder...)
 	sb.Register(core_v1.SchemeBuilder...)
 	sb.Register(apps_v1.SchemeBuilder...)
-	sb.Register(apiext_v1b1.SchemeBuilder...)
+	sb.Register(apiext_v1.SchemeBuilder...)
 	sb.Register(autoscaling_v2b1.SchemeBuilder...)
 	sb.Register(policy_v1.SchemeBuilder...)
+	sb.Register(batch_v1.SchemeBuilder...)
+	sb.Register(batch_v1b1.SchemeBuilder...)
+	sb.Register(rbac_v1.SchemeBuilder...)
+	sb.Register(scheduling_v1.SchemeBuilder...)
 	if serviceCatalog {
 		sb.Register(sc_v1b1.SchemeBuilder...)
 	}
@@ -318,10 +678,16 @@ func FullScheme(serviceCatalog bool) (*runtime.Scheme, error) {
 	return scheme, nil
 }
 
-func smithInformer(config *ctrl.Config, cctx *ctrl.Context, smithClient smithClientset.Interface, gvk schema.GroupVersionKind, f func(smithClientset.Interface, string, time.Duration) cache.SharedIndexInformer) (cache.SharedIndexInformer, error) {
+// namespacedInformer returns an informer for the namespace from config or, if namespaces is set,
+// an informer that merges informers for each of the namespaces.
+func namespacedInformer(config *ctrl.Config, cctx *ctrl.Context, namespaces *multins.Namespaces, gvk schema.GroupVersionKind, f multins.NewInformerFunc) (cache.SharedIndexInformer, error) {
 	inf := cctx.Informers[gvk]
 	if inf == nil {
-		inf = f(smithClient, config.Namespace, config.ResyncPeriod)
+		if namespaces == nil {
+			inf = f(config.Namespace)
+		} else {
+			inf = namespaces.NewInformer(f)
+		}
 		err := cctx.RegisterInformer(gvk, inf)
 		if err != nil {
 			return nil, err
@@ -330,10 +696,22 @@ func smithInformer(config *ctrl.Config, cctx *ctrl.Context, smithClient smithCli
 	return inf, nil
 }
 
-func apiExtensionsInformer(config *ctrl.Config, cctx *ctrl.Context, apiExtClient apiExtClientset.Interface, gvk schema.GroupVersionKind, f func(apiExtClientset.Interface, time.Duration, cache.Indexers) cache.SharedIndexInformer) (cache.SharedIndexInformer, error) {
+func mainInformer(config *ctrl.Config, cctx *ctrl.Context, namespaces *multins.Namespaces, gvk schema.GroupVersionKind, f func(kubernetes.Interface, string, time.Duration, cache.Indexers) cache.SharedIndexInformer) (cache.SharedIndexInformer, error) {
+	return namespacedInformer(config, cctx, namespaces, gvk, func(namespace string) cache.SharedIndexInformer {
+		return f(config.MainClient, namespace, config.ResyncPeriod, cache.Indexers{})
+	})
+}
+
+func smithInformer(config *ctrl.Config, cctx *ctrl.Context, namespaces *multins.Namespaces, smithClient smithClientset.Interface, gvk schema.GroupVersionKind, f func(smithClientset.Interface, string, time.Duration) cache.SharedIndexInformer) (cache.SharedIndexInformer, error) {
+	return namespacedInformer(config, cctx, namespaces, gvk, func(namespace string) cache.SharedIndexInformer {
+		return f(smithClient, namespace, config.ResyncPeriod)
+	})
+}
+
+func smithClusterInformer(config *ctrl.Config, cctx *ctrl.Context, smithClient smithClientset.Interface, gvk schema.GroupVersionKind, f func(smithClientset.Interface, string, time.Duration) cache.SharedIndexInformer) (cache.SharedIndexInformer, error) {
 	inf := cctx.Informers[gvk]
 	if inf == nil {
-		inf = f(apiExtClient, config.ResyncPeriod, cache.Indexers{})
+		inf = f(smithClient, meta_v1.NamespaceAll, config.ResyncPeriod)
 		err := cctx.RegisterInformer(gvk, inf)
 		if err != nil {
 			return nil, err
@@ -342,11 +720,33 @@ func apiExtensionsInformer(config *ctrl.Config, cctx *ctrl.Context, apiExtClient
 	return inf, nil
 }
 
-func svcCatClusterInformer(config *ctrl.Config, cctx *ctrl.Context, scClient scClientset.Interface, gvk schema.GroupVersionKind, f func(scClientset.Interface, time.Duration, cache.Indexers) cache.SharedIndexInformer) (cache.SharedIndexInformer, error) {
+// apiServiceInformer returns an informer for APIServices. A dynamic client is used because
+// the typed client for APIServices lives in k8s.io/kube-aggregator.
+func (c *BundleControllerConstructor) apiServiceInformer(config *ctrl.Config, cctx *ctrl.Context) (cache.SharedIndexInformer, error) {
+	inf := cctx.Informers[apiServiceGVK]
+	if inf == nil {
+		dynamicClient, err := dynamic.NewForConfig(config.RestConfig)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		inf = dynamicinformer.NewFilteredDynamicInformer(dynamicClient, apiServiceGVR, meta_v1.NamespaceAll, config.ResyncPeriod, cache.Indexers{}, nil).Informer()
+		err = cctx.RegisterInformer(apiServiceGVK, inf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inf, nil
+}
+
+func apiExtensionsInformer(config *ctrl.Config, cctx *ctrl.Context, apiExtClient apiExtClientset.Interface, gvk schema.GroupVersionKind, f func(apiExtClientset.Interface, time.Duration, cache.Indexers) (cache.SharedIndexInformer, error)) (cache.SharedIndexInformer, error) {
 	inf := cctx.Informers[gvk]
 	if inf == nil {
-		inf = f(scClient, config.ResyncPeriod, cache.Indexers{})
-		err := cctx.RegisterInformer(gvk, inf)
+		var err error
+		inf, err = f(apiExtClient, config.ResyncPeriod, cache.Indexers{})
+		if err != nil {
+			return nil, err
+		}
+		err = cctx.RegisterInformer(gvk, inf)
 		if err != nil {
 			return nil, err
 		}
@@ -354,10 +754,10 @@ func svcCatClusterInformer(config *ctrl.Config, cctx *ctrl.Context, scClient scC
 	return inf, nil
 }
 
-func svcCatInformer(config *ctrl.Config, cctx *ctrl.Context, scClient scClientset.Interface, gvk schema.GroupVersionKind, f func(scClientset.Interface, string, time.Duration, cache.Indexers) cache.SharedIndexInformer) (cache.SharedIndexInformer, error) {
+func svcCatClusterInformer(config *ctrl.Config, cctx *ctrl.Context, scClient scClientset.Interface, gvk schema.GroupVersionKind, f func(scClientset.Interface, time.Duration, cache.Indexers) cache.SharedIndexInformer) (cache.SharedIndexInformer, error) {
 	inf := cctx.Informers[gvk]
 	if inf == nil {
-		inf = f(scClient, config.Namespace, config.ResyncPeriod, cache.Indexers{})
+		inf = f(scClient, config.ResyncPeriod, cache.Indexers{})
 		err := cctx.RegisterInformer(gvk, inf)
 		if err != nil {
 			return nil, err
@@ -366,6 +766,12 @@ func svcCatInformer(config *ctrl.Config, cctx *ctrl.Context, scClient scClientse
 	return inf, nil
 }
 
+func svcCatInformer(config *ctrl.Config, cctx *ctrl.Context, namespaces *multins.Namespaces, scClient scClientset.Interface, gvk schema.GroupVersionKind, f func(scClientset.Interface, string, time.Duration, cache.Indexers) cache.SharedIndexInformer) (cache.SharedIndexInformer, error) {
+	return namespacedInformer(config, cctx, namespaces, gvk, func(namespace string) cache.SharedIndexInformer {
+		return f(scClient, namespace, config.ResyncPeriod, cache.Indexers{})
+	})
+}
+
 func svcCatalog(config *ctrl.Config, cctx *ctrl.Context, scClient scClientset.Interface) (*store.Catalog, error) {
 	serviceClassInf, err := svcCatClusterInformer(config, cctx, scClient,
 		sc_v1b1.SchemeGroupVersion.WithKind("ClusterServiceClass"),
@@ -0,0 +1,267 @@
+package app
+
+import (
+	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
+	scClientset "github.com/kubernetes-sigs/service-catalog/pkg/client/clientset_generated/clientset"
+	apps_v1 "k8s.io/api/apps/v1"
+	autoscaling_v2b1 "k8s.io/api/autoscaling/v2beta1"
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1b1 "k8s.io/api/batch/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+	net_v1b1 "k8s.io/api/networking/v1beta1"
+	policy_v1 "k8s.io/api/policy/v1beta1"
+	rbac_v1 "k8s.io/api/rbac/v1"
+	scheduling_v1 "k8s.io/api/scheduling/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Typed informers from client-go cannot transform objects before they are cached so informers
+// for resources are constructed from ListWatches instead. See docs/design/informer-transforms.md
+
+// resourceType describes how to watch objects of a namespaced kind.
+type resourceType struct {
+	objType   runtime.Object
+	listWatch func(client kubernetes.Interface, namespace string) *cache.ListWatch
+}
+
+// clusterResourceType describes how to watch objects of a cluster-scoped kind.
+type clusterResourceType struct {
+	objType   runtime.Object
+	listWatch func(client kubernetes.Interface) *cache.ListWatch
+}
+
+// svcCatResourceType describes how to watch objects of a namespaced Service Catalog kind.
+type svcCatResourceType struct {
+	objType   runtime.Object
+	listWatch func(client scClientset.Interface, namespace string) *cache.ListWatch
+}
+
+var coreResourceTypes = map[schema.GroupVersionKind]resourceType{
+	net_v1b1.SchemeGroupVersion.WithKind("Ingress"):                         {&net_v1b1.Ingress{}, ingressListWatch},
+	core_v1.SchemeGroupVersion.WithKind("Service"):                          {&core_v1.Service{}, serviceListWatch},
+	core_v1.SchemeGroupVersion.WithKind("ConfigMap"):                        {&core_v1.ConfigMap{}, configMapListWatch},
+	core_v1.SchemeGroupVersion.WithKind("Secret"):                           {&core_v1.Secret{}, secretListWatch},
+	core_v1.SchemeGroupVersion.WithKind("ServiceAccount"):                   {&core_v1.ServiceAccount{}, serviceAccountListWatch},
+	core_v1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"):            {&core_v1.PersistentVolumeClaim{}, persistentVolumeClaimListWatch},
+	core_v1.SchemeGroupVersion.WithKind("Pod"):                              {&core_v1.Pod{}, podListWatch},
+	apps_v1.SchemeGroupVersion.WithKind("Deployment"):                       {&apps_v1.Deployment{}, deploymentListWatch},
+	apps_v1.SchemeGroupVersion.WithKind("ReplicaSet"):                       {&apps_v1.ReplicaSet{}, replicaSetListWatch},
+	autoscaling_v2b1.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"): {&autoscaling_v2b1.HorizontalPodAutoscaler{}, horizontalPodAutoscalerListWatch},
+	policy_v1.SchemeGroupVersion.WithKind("PodDisruptionBudget"):            {&policy_v1.PodDisruptionBudget{}, podDisruptionBudgetListWatch},
+	batch_v1.SchemeGroupVersion.WithKind("Job"):                             {&batch_v1.Job{}, jobListWatch},
+	batch_v1b1.SchemeGroupVersion.WithKind("CronJob"):                       {&batch_v1b1.CronJob{}, cronJobListWatch},
+}
+
+var clusterResourceTypes = map[schema.GroupVersionKind]clusterResourceType{
+	rbac_v1.SchemeGroupVersion.WithKind("ClusterRole"):         {&rbac_v1.ClusterRole{}, clusterRoleListWatch},
+	rbac_v1.SchemeGroupVersion.WithKind("ClusterRoleBinding"):  {&rbac_v1.ClusterRoleBinding{}, clusterRoleBindingListWatch},
+	scheduling_v1.SchemeGroupVersion.WithKind("PriorityClass"): {&scheduling_v1.PriorityClass{}, priorityClassListWatch},
+}
+
+var svcCatResourceTypes = map[schema.GroupVersionKind]svcCatResourceType{
+	sc_v1b1.SchemeGroupVersion.WithKind("ServiceBinding"):  {&sc_v1b1.ServiceBinding{}, serviceBindingListWatch},
+	sc_v1b1.SchemeGroupVersion.WithKind("ServiceInstance"): {&sc_v1b1.ServiceInstance{}, serviceInstanceListWatch},
+}
+
+func ingressListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.NetworkingV1beta1().Ingresses(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.NetworkingV1beta1().Ingresses(namespace).Watch(options)
+		},
+	}
+}
+
+func serviceListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Services(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Services(namespace).Watch(options)
+		},
+	}
+}
+
+func configMapListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ConfigMaps(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().ConfigMaps(namespace).Watch(options)
+		},
+	}
+}
+
+func secretListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Secrets(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Secrets(namespace).Watch(options)
+		},
+	}
+}
+
+func serviceAccountListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ServiceAccounts(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().ServiceAccounts(namespace).Watch(options)
+		},
+	}
+}
+
+func persistentVolumeClaimListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().PersistentVolumeClaims(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().PersistentVolumeClaims(namespace).Watch(options)
+		},
+	}
+}
+
+func podListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Pods(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Pods(namespace).Watch(options)
+		},
+	}
+}
+
+func deploymentListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.AppsV1().Deployments(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.AppsV1().Deployments(namespace).Watch(options)
+		},
+	}
+}
+
+func replicaSetListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.AppsV1().ReplicaSets(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.AppsV1().ReplicaSets(namespace).Watch(options)
+		},
+	}
+}
+
+func horizontalPodAutoscalerListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).Watch(options)
+		},
+	}
+}
+
+func podDisruptionBudgetListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.PolicyV1beta1().PodDisruptionBudgets(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.PolicyV1beta1().PodDisruptionBudgets(namespace).Watch(options)
+		},
+	}
+}
+
+func jobListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.BatchV1().Jobs(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.BatchV1().Jobs(namespace).Watch(options)
+		},
+	}
+}
+
+func cronJobListWatch(client kubernetes.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.BatchV1beta1().CronJobs(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.BatchV1beta1().CronJobs(namespace).Watch(options)
+		},
+	}
+}
+
+func clusterRoleListWatch(client kubernetes.Interface) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.RbacV1().ClusterRoles().List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.RbacV1().ClusterRoles().Watch(options)
+		},
+	}
+}
+
+func clusterRoleBindingListWatch(client kubernetes.Interface) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.RbacV1().ClusterRoleBindings().List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.RbacV1().ClusterRoleBindings().Watch(options)
+		},
+	}
+}
+
+func priorityClassListWatch(client kubernetes.Interface) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.SchedulingV1().PriorityClasses().List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.SchedulingV1().PriorityClasses().Watch(options)
+		},
+	}
+}
+
+func serviceBindingListWatch(client scClientset.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.ServicecatalogV1beta1().ServiceBindings(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.ServicecatalogV1beta1().ServiceBindings(namespace).Watch(options)
+		},
+	}
+}
+
+func serviceInstanceListWatch(client scClientset.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.ServicecatalogV1beta1().ServiceInstances(namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.ServicecatalogV1beta1().ServiceInstances(namespace).Watch(options)
+		},
+	}
+}
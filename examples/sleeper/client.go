@@ -4,7 +4,7 @@ import (
 	"github.com/atlassian/smith"
 	sleeper_v1 "github.com/atlassian/smith/examples/sleeper/pkg/apis/sleeper/v1"
 	core_v1 "k8s.io/api/core/v1"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -34,8 +34,9 @@ func Client(cfg *rest.Config) (*rest.RESTClient, error) {
 	return rest.RESTClientFor(&config)
 }
 
-func Crd() *apiext_v1b1.CustomResourceDefinition {
-	return &apiext_v1b1.CustomResourceDefinition{
+func Crd() *apiext_v1.CustomResourceDefinition {
+	preserveUnknownFields := true
+	return &apiext_v1.CustomResourceDefinition{
 		ObjectMeta: meta_v1.ObjectMeta{
 			Name: sleeper_v1.SleeperResourceName,
 			Annotations: map[string]string{
@@ -44,18 +45,25 @@ func Crd() *apiext_v1b1.CustomResourceDefinition {
 				smith.CrdSupportEnabled:      "true",
 			},
 		},
-		Spec: apiext_v1b1.CustomResourceDefinitionSpec{
+		Spec: apiext_v1.CustomResourceDefinitionSpec{
 			Group: sleeper_v1.GroupName,
-			Names: apiext_v1b1.CustomResourceDefinitionNames{
+			Names: apiext_v1.CustomResourceDefinitionNames{
 				Plural:   sleeper_v1.SleeperResourcePlural,
 				Singular: sleeper_v1.SleeperResourceSingular,
 				Kind:     sleeper_v1.SleeperResourceKind,
 			},
-			Versions: []apiext_v1b1.CustomResourceDefinitionVersion{
+			Scope: apiext_v1.NamespaceScoped,
+			Versions: []apiext_v1.CustomResourceDefinitionVersion{
 				{
 					Name:    sleeper_v1.SleeperResourceVersion,
 					Served:  true,
 					Storage: true,
+					Schema: &apiext_v1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiext_v1.JSONSchemaProps{
+							Type:                   "object",
+							XPreserveUnknownFields: &preserveUnknownFields,
+						},
+					},
 				},
 			},
 		},
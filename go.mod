@@ -7,7 +7,7 @@ require (
 	github.com/atlassian/ctrl v0.0.0-20190816021437-9632032e4bf6
 	github.com/evanphx/json-patch v4.5.0+incompatible // indirect
 	github.com/golang/groupcache v0.0.0-20180513044358-24b0969c4cb7 // indirect
-	github.com/googleapis/gnostic v0.3.1 // indirect
+	github.com/googleapis/gnostic v0.3.1
 	github.com/imdario/mergo v0.3.6 // indirect
 	github.com/kubernetes-sigs/service-catalog v0.3.0-beta.1
 	github.com/pkg/errors v0.8.1
@@ -32,8 +32,8 @@ import (
 	apps_v1 "k8s.io/api/apps/v1"
 	core_v1 "k8s.io/api/core/v1"
 	apiExtClientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
-	apiext_v1b1inf "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions/apiextensions/v1beta1"
-	apiext_v1b1list "k8s.io/apiextensions-apiserver/pkg/client/listers/apiextensions/v1beta1"
+	apiext_v1inf "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions/apiextensions/v1"
+	apiext_v1list "k8s.io/apiextensions-apiserver/pkg/client/listers/apiextensions/v1"
 	api_errors "k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -304,7 +304,7 @@ func SetupApp(t *testing.T, bundle *smith_v1.Bundle, serviceCatalog, createBundl
 	apiExtClient, err := apiExtClientset.NewForConfig(config)
 	require.NoError(t, err)
 
-	crdInf := apiext_v1b1inf.NewCustomResourceDefinitionInformer(apiExtClient, 0, cache.Indexers{})
+	crdInf := apiext_v1inf.NewCustomResourceDefinitionInformer(apiExtClient, 0, cache.Indexers{})
 	stage := stgr.NextStage()
 	stage.StartWithChannel(crdInf.Run)
 
@@ -314,7 +314,7 @@ func SetupApp(t *testing.T, bundle *smith_v1.Bundle, serviceCatalog, createBundl
 		t.Fatal("wait for CRD Informer was cancelled")
 	}
 
-	crdLister := apiext_v1b1list.NewCustomResourceDefinitionLister(crdInf.GetIndexer())
+	crdLister := apiext_v1list.NewCustomResourceDefinitionLister(crdInf.GetIndexer())
 	require.NoError(t, resources.EnsureCrdExistsAndIsEstablished(ctxTest, logger, apiExtClient, crdLister, sleeper.Crd()))
 	require.NoError(t, resources.EnsureCrdExistsAndIsEstablished(ctxTest, logger, apiExtClient, crdLister, crd.BundleCrd()))
 
@@ -0,0 +1,138 @@
+package v1
+
+import (
+	"github.com/atlassian/smith/pkg/apis/smith"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	BundlePolicyResourceSingular = "bundlepolicy"
+	BundlePolicyResourcePlural   = "bundlepolicies"
+	BundlePolicyResourceKind     = "BundlePolicy"
+	BundlePolicyResourceName     = BundlePolicyResourcePlural + "." + smith.GroupName
+
+	ClusterBundlePolicyResourceSingular = "clusterbundlepolicy"
+	ClusterBundlePolicyResourcePlural   = "clusterbundlepolicies"
+	ClusterBundlePolicyResourceKind     = "ClusterBundlePolicy"
+	ClusterBundlePolicyResourceName     = ClusterBundlePolicyResourcePlural + "." + smith.GroupName
+
+	// KindSelectorWildcard matches any group or kind.
+	KindSelectorWildcard = "*"
+)
+
+var (
+	BundlePolicyGVK        = SchemeGroupVersion.WithKind(BundlePolicyResourceKind)
+	ClusterBundlePolicyGVK = SchemeGroupVersion.WithKind(ClusterBundlePolicyResourceKind)
+)
+
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type BundlePolicyList struct {
+	meta_v1.TypeMeta `json:",inline"`
+	// Standard list metadata.
+	meta_v1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is a list of Bundle policies.
+	Items []BundlePolicy `json:"items"`
+}
+
+// +genclient
+
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// BundlePolicy restricts what Bundles in its namespace can contain.
+type BundlePolicy struct {
+	meta_v1.TypeMeta `json:",inline"`
+
+	// Standard object metadata
+	meta_v1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the specification of the policy.
+	Spec BundlePolicySpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type ClusterBundlePolicyList struct {
+	meta_v1.TypeMeta `json:",inline"`
+	// Standard list metadata.
+	meta_v1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is a list of cluster Bundle policies.
+	Items []ClusterBundlePolicy `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// ClusterBundlePolicy restricts what Bundles in all namespaces can contain.
+type ClusterBundlePolicy struct {
+	meta_v1.TypeMeta `json:",inline"`
+
+	// Standard object metadata
+	meta_v1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the specification of the policy.
+	Spec BundlePolicySpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+// BundlePolicySpec describes restrictions on Bundles. All policies that apply to a Bundle must be satisfied.
+type BundlePolicySpec struct {
+	// AllowedKinds is a list of kinds of objects that Bundles may contain. All kinds are allowed if empty.
+	AllowedKinds []KindSelector `json:"allowedKinds,omitempty"`
+	// DeniedKinds is a list of kinds of objects that Bundles must not contain. Takes precedence over AllowedKinds.
+	DeniedKinds []KindSelector `json:"deniedKinds,omitempty"`
+	// MaxResources is the maximum number of resources in a Bundle. Not limited if not set.
+	MaxResources *int32 `json:"maxResources,omitempty"`
+	// AllowedPlugins is a list of plugins that Bundles may use. All plugins are allowed if empty.
+	AllowedPlugins []PluginName `json:"allowedPlugins,omitempty"`
+	// ForbiddenFields is a list of fields that objects of Bundles must not set.
+	ForbiddenFields []ForbiddenField `json:"forbiddenFields,omitempty"`
+	// AllowClusterScoped allows Bundles to contain cluster-scoped objects if their management is enabled.
+	// Bundles can only contain cluster-scoped objects if at least one policy that applies to them allows it.
+	AllowClusterScoped bool `json:"allowClusterScoped,omitempty"`
+}
+
+// KindSelector selects objects by their group, version and kind.
+type KindSelector struct {
+	// Group of the object. "*" matches any group.
+	Group string `json:"group"`
+	// Version of the object. Any version is matched if empty.
+	Version string `json:"version,omitempty"`
+	// Kind of the object. "*" matches any kind.
+	Kind string `json:"kind" crd:"minLength=1"`
+}
+
+// Matches returns true if the GVK is selected by the selector.
+func (s *KindSelector) Matches(gvk schema.GroupVersionKind) bool {
+	return (s.Group == KindSelectorWildcard || s.Group == gvk.Group) &&
+		(s.Version == "" || s.Version == gvk.Version) &&
+		(s.Kind == KindSelectorWildcard || s.Kind == gvk.Kind)
+}
+
+// +k8s:deepcopy-gen=true
+// ForbiddenField describes a field that objects must not set.
+type ForbiddenField struct {
+	// Kinds is a list of kinds of objects the field is forbidden for. Field is forbidden for all kinds if empty.
+	Kinds []KindSelector `json:"kinds,omitempty"`
+	// Path is a JSONPath expression used to find the field in the object e.g. "{.spec.hostNetwork}".
+	Path string `json:"path" crd:"minLength=1"`
+	// Value the field must not be set to. Field must not be set to any value if not specified.
+	Value interface{} `json:"value,omitempty" crd:"preserveUnknownFields"`
+}
+
+// DeepCopyInto is an deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ForbiddenField) DeepCopyInto(out *ForbiddenField) {
+	*out = *in
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]KindSelector, len(*in))
+		copy(*out, *in)
+	}
+	out.Value = runtime.DeepCopyJSONValue(in.Value)
+}
@@ -27,6 +27,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Bundle{},
 		&BundleList{},
+		&BundlePolicy{},
+		&BundlePolicyList{},
+		&ClusterBundlePolicy{},
+		&ClusterBundlePolicyList{},
 	)
 	meta_v1.AddToGroupVersion(scheme, SchemeGroupVersion)
 
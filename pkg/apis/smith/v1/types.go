@@ -5,9 +5,11 @@ import (
 
 	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
 	"github.com/atlassian/smith/pkg/apis/smith"
+	auth_v1 "k8s.io/api/authentication/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	k8s_json "k8s.io/apimachinery/pkg/util/json"
 )
 
@@ -40,6 +42,8 @@ const (
 
 	ResourceReasonTerminalError  = "TerminalError"
 	ResourceReasonRetriableError = "RetriableError"
+	// ResourceReasonForbidden means the author of the Bundle is not allowed to manage the object.
+	ResourceReasonForbidden = "Forbidden"
 )
 
 type PluginStatusStr string
@@ -47,6 +51,8 @@ type PluginStatusStr string
 const (
 	PluginStatusOk           PluginStatusStr = "Ok"
 	PluginStatusNoSuchPlugin PluginStatusStr = "NoSuchPlugin"
+	// PluginStatusInvocationFailed means the plugin panicked or did not return within the deadline.
+	PluginStatusInvocationFailed PluginStatusStr = "InvocationFailed"
 )
 
 const (
@@ -60,6 +66,13 @@ const (
 	BundleResourceName = BundleResourcePlural + "." + smith.GroupName
 
 	ReferenceModifierBindSecret = "bindsecret"
+	// ReferenceModifierAddress makes the reference traverse the address of the load balancer of a Service or
+	// an Ingress rather than the object itself. Available fields are "address", "hostname" and "ip".
+	ReferenceModifierAddress = "address"
+	// ReferenceModifierSecret makes the reference resolve to a value of a key of a Secret or of the Secret of a
+	// ServiceBinding. Path must be "data.<key>". The value is never placed into the body of a non-Secret object,
+	// see docs/design/field-references.md.
+	ReferenceModifierSecret = "secret"
 )
 
 var BundleGVK = SchemeGroupVersion.WithKind(BundleResourceKind)
@@ -96,14 +109,25 @@ type Bundle struct {
 // +k8s:deepcopy-gen=true
 type BundleSpec struct {
 	Resources []Resource `json:"resources"`
+	// Author is the identity of the last user who changed the spec of the Bundle. It is set by the mutating
+	// admission webhook and cannot be set by the user. It changes whenever another user changes the spec
+	// because objects of the Bundle are managed on behalf of this user if impersonation or access review
+	// is enabled. Otherwise a user could make Smith act with permissions of the original creator.
+	Author *auth_v1.UserInfo `json:"author,omitempty"`
 }
 
 type PluginStatus struct {
-	Name    PluginName      `json:"name"`
-	Group   string          `json:"group"`
-	Version string          `json:"version"`
-	Kind    string          `json:"kind"`
-	Status  PluginStatusStr `json:"status,omitempty"`
+	Name PluginName `json:"name" crd:"minLength=1"`
+	// Group, Version and Kind of objects the plugin produces.
+	// The first kind is reported for plugins that produce multiple objects.
+	Group   string `json:"group"`
+	Version string `json:"version" crd:"minLength=1"`
+	Kind    string `json:"kind" crd:"minLength=1"`
+	// PluginVersion is the version the plugin declared, if any.
+	PluginVersion string          `json:"pluginVersion,omitempty"`
+	Status        PluginStatusStr `json:"status,omitempty"`
+	// Message describes why the plugin invocation failed if Status is InvocationFailed.
+	Message string `json:"message,omitempty"`
 }
 
 // +k8s:deepcopy-gen=true
@@ -117,6 +141,10 @@ type BundleStatus struct {
 	ObjectsToDelete    []ObjectToDelete    `json:"objectsToDelete,omitempty"`
 	// PluginStatuses is a list of statuses for Smith plugins used in the Bundle.
 	PluginStatuses []PluginStatus `json:"pluginStatuses,omitempty"`
+	// ResourcesTotal is the number of resources in the Bundle.
+	ResourcesTotal int32 `json:"resourcesTotal" crd:"optional"`
+	// ResourcesReady is the number of resources in the Bundle that are Ready.
+	ResourcesReady int32 `json:"resourcesReady" crd:"optional"`
 }
 
 func (bs *BundleStatus) String() string {
@@ -158,7 +186,7 @@ type PluginName string
 // Resource describes an object that should be provisioned.
 type Resource struct {
 	// Name of the resource for references.
-	Name ResourceName `json:"name"`
+	Name ResourceName `json:"name" crd:"dnsSubdomain"`
 
 	// Explicit dependencies.
 	References []Reference `json:"references,omitempty"`
@@ -169,11 +197,13 @@ type Resource struct {
 // +k8s:deepcopy-gen=true
 // Refer to a part of another object
 type Reference struct {
-	Name     ReferenceName `json:"name,omitempty"`
-	Resource ResourceName  `json:"resource"`
-	Path     string        `json:"path,omitempty"`
-	Example  interface{}   `json:"example,omitempty"`
-	Modifier string        `json:"modifier,omitempty"`
+	Name     ReferenceName `json:"name,omitempty" crd:"referenceName"`
+	Resource ResourceName  `json:"resource" crd:"dnsSubdomain"`
+	// Path is a JSONPath expression used to extract data from the resource.
+	Path string `json:"path,omitempty"`
+	// Example of how the reference is expected to resolve. Used for validation.
+	Example  interface{} `json:"example,omitempty" crd:"preserveUnknownFields"`
+	Modifier string      `json:"modifier,omitempty" crd:"dnsSubdomain"`
 }
 
 // DeepCopyInto is an deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -190,8 +220,8 @@ func (in *Reference) Ref() string {
 // +k8s:deepcopy-gen=true
 // ResourceSpec is a union type - either object of plugin can be specified.
 type ResourceSpec struct {
-	Object runtime.Object `json:"object,omitempty"`
-	Plugin *PluginSpec    `json:"plugin,omitempty"`
+	Object runtime.Object `json:"object,omitempty" crd:"oneOf,embeddedResource"`
+	Plugin *PluginSpec    `json:"plugin,omitempty" crd:"oneOf"`
 }
 
 func (rs *ResourceSpec) UnmarshalJSON(data []byte) error {
@@ -216,9 +246,9 @@ func (rs *ResourceSpec) UnmarshalJSON(data []byte) error {
 // +k8s:deepcopy-gen=true
 // PluginSpec holds the specification for a plugin.
 type PluginSpec struct {
-	Name       PluginName             `json:"name"`
-	ObjectName string                 `json:"objectName"`
-	Spec       map[string]interface{} `json:"spec,omitempty"`
+	Name       PluginName             `json:"name" crd:"dnsSubdomain"`
+	ObjectName string                 `json:"objectName,omitempty" crd:"dnsSubdomain"`
+	Spec       map[string]interface{} `json:"spec,omitempty" crd:"preserveUnknownFields"`
 }
 
 // DeepCopyInto is an deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -229,21 +259,51 @@ func (in *PluginSpec) DeepCopyInto(out *PluginSpec) {
 
 // +k8s:deepcopy-gen=true
 type ResourceStatus struct {
-	Name               ResourceName `json:"name"`
+	Name               ResourceName `json:"name" crd:"minLength=1"`
 	ResourceStatusData `json:",inline"`
 }
 
 // +k8s:deepcopy-gen=true
 type ResourceStatusData struct {
 	Conditions []cond_v1.Condition `json:"conditions,omitempty"`
+	// Objects are the objects produced by a plugin that produces multiple objects.
+	// They are recorded to track objects that the plugin may stop producing.
+	Objects []ResourceObject `json:"objects,omitempty"`
+	// Lookups are the objects the plugin of the resource looked up when it was last invoked.
+	Lookups []LookedUpObject `json:"lookups,omitempty"`
+}
+
+// ResourceObject is an object produced by a resource.
+type ResourceObject struct {
+	Group   string `json:"group"`
+	Version string `json:"version" crd:"minLength=1"`
+	Kind    string `json:"kind" crd:"minLength=1"`
+	Name    string `json:"name" crd:"minLength=1"`
+}
+
+func (o *ResourceObject) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{
+		Group:   o.Group,
+		Version: o.Version,
+		Kind:    o.Kind,
+	}
+}
+
+// LookedUpObject is an object a plugin looked up. The Bundle is re-processed when the object changes.
+type LookedUpObject struct {
+	Group     string `json:"group"`
+	Version   string `json:"version" crd:"minLength=1"`
+	Kind      string `json:"kind" crd:"minLength=1"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name" crd:"minLength=1"`
 }
 
 type ObjectToDelete struct {
 	// GVK of the object.
 
 	Group   string `json:"group"`
-	Version string `json:"version"`
-	Kind    string `json:"kind"`
+	Version string `json:"version" crd:"minLength=1"`
+	Kind    string `json:"kind" crd:"minLength=1"`
 	// Name of the object.
-	Name string `json:"name"`
+	Name string `json:"name" crd:"minLength=1"`
 }
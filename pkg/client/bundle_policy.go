@@ -0,0 +1,46 @@
+package client
+
+import (
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	smithClientset "github.com/atlassian/smith/pkg/client/clientset_generated/clientset"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func BundlePolicyInformer(smithClient smithClientset.Interface, namespace string, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	policiesAPI := smithClient.SmithV1().BundlePolicies(namespace)
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return policiesAPI.List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return policiesAPI.Watch(options)
+			},
+		},
+		&smith_v1.BundlePolicy{},
+		resyncPeriod,
+		cache.Indexers{})
+}
+
+// ClusterBundlePolicyInformer returns an informer for cluster-scoped policies.
+// Namespace is ignored, it is only accepted for consistency with other informer constructors.
+func ClusterBundlePolicyInformer(smithClient smithClientset.Interface, namespace string, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	policiesAPI := smithClient.SmithV1().ClusterBundlePolicies()
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return policiesAPI.List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return policiesAPI.Watch(options)
+			},
+		},
+		&smith_v1.ClusterBundlePolicy{},
+		resyncPeriod,
+		cache.Indexers{})
+}
@@ -18,6 +18,14 @@ func (c *FakeSmithV1) Bundles(namespace string) v1.BundleInterface {
 	return &FakeBundles{c, namespace}
 }
 
+func (c *FakeSmithV1) BundlePolicies(namespace string) v1.BundlePolicyInterface {
+	return &FakeBundlePolicies{c, namespace}
+}
+
+func (c *FakeSmithV1) ClusterBundlePolicies() v1.ClusterBundlePolicyInterface {
+	return &FakeClusterBundlePolicies{c}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeSmithV1) RESTClient() rest.Interface {
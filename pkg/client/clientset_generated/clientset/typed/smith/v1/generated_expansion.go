@@ -5,3 +5,7 @@
 package v1
 
 type BundleExpansion interface{}
+
+type BundlePolicyExpansion interface{}
+
+type ClusterBundlePolicyExpansion interface{}
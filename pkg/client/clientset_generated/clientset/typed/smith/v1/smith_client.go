@@ -13,6 +13,8 @@ import (
 type SmithV1Interface interface {
 	RESTClient() rest.Interface
 	BundlesGetter
+	BundlePoliciesGetter
+	ClusterBundlePoliciesGetter
 }
 
 // SmithV1Client is used to interact with features provided by the smith.atlassian.com group.
@@ -24,6 +26,14 @@ func (c *SmithV1Client) Bundles(namespace string) BundleInterface {
 	return newBundles(c, namespace)
 }
 
+func (c *SmithV1Client) BundlePolicies(namespace string) BundlePolicyInterface {
+	return newBundlePolicies(c, namespace)
+}
+
+func (c *SmithV1Client) ClusterBundlePolicies() ClusterBundlePolicyInterface {
+	return newClusterBundlePolicies(c)
+}
+
 // NewForConfig creates a new SmithV1Client for the given config.
 func NewForConfig(c *rest.Config) (*SmithV1Client, error) {
 	config := *c
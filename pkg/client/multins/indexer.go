@@ -0,0 +1,134 @@
+package multins
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+var errReadOnly = errors.New("indexer of a multi-namespace informer is read-only")
+
+// indexer merges Indexers of per-namespace informers.
+type indexer struct {
+	informer *Informer
+}
+
+func (x *indexer) Add(obj interface{}) error {
+	return errReadOnly
+}
+
+func (x *indexer) Update(obj interface{}) error {
+	return errReadOnly
+}
+
+func (x *indexer) Delete(obj interface{}) error {
+	return errReadOnly
+}
+
+func (x *indexer) Replace([]interface{}, string) error {
+	return errReadOnly
+}
+
+func (x *indexer) Resync() error {
+	return errReadOnly
+}
+
+func (x *indexer) List() []interface{} {
+	var result []interface{}
+	_ = x.informer.forEach(func(idx cache.Indexer) error {
+		result = append(result, idx.List()...)
+		return nil
+	})
+	return result
+}
+
+func (x *indexer) ListKeys() []string {
+	var result []string
+	_ = x.informer.forEach(func(idx cache.Indexer) error {
+		result = append(result, idx.ListKeys()...)
+		return nil
+	})
+	return result
+}
+
+func (x *indexer) Get(obj interface{}) (interface{}, bool, error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	return x.GetByKey(key)
+}
+
+func (x *indexer) GetByKey(key string) (interface{}, bool, error) {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	idx := x.informer.indexerFor(namespace)
+	if idx == nil {
+		return nil, false, nil
+	}
+	return idx.GetByKey(key)
+}
+
+func (x *indexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	var result []interface{}
+	err := x.informer.forEach(func(idx cache.Indexer) error {
+		objs, err := idx.Index(indexName, obj)
+		if err != nil {
+			return err
+		}
+		result = append(result, objs...)
+		return nil
+	})
+	return result, err
+}
+
+func (x *indexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	var result []string
+	err := x.informer.forEach(func(idx cache.Indexer) error {
+		keys, err := idx.IndexKeys(indexName, indexedValue)
+		if err != nil {
+			return err
+		}
+		result = append(result, keys...)
+		return nil
+	})
+	return result, err
+}
+
+func (x *indexer) ListIndexFuncValues(indexName string) []string {
+	result := sets.NewString()
+	_ = x.informer.forEach(func(idx cache.Indexer) error {
+		result.Insert(idx.ListIndexFuncValues(indexName)...)
+		return nil
+	})
+	return result.List()
+}
+
+func (x *indexer) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	var result []interface{}
+	err := x.informer.forEach(func(idx cache.Indexer) error {
+		objs, err := idx.ByIndex(indexName, indexedValue)
+		if err != nil {
+			return err
+		}
+		result = append(result, objs...)
+		return nil
+	})
+	return result, err
+}
+
+func (x *indexer) GetIndexers() cache.Indexers {
+	x.informer.mx.RLock()
+	defer x.informer.mx.RUnlock()
+	result := make(cache.Indexers, len(x.informer.indexers))
+	for name, indexFunc := range x.informer.indexers {
+		result[name] = indexFunc
+	}
+	return result
+}
+
+func (x *indexer) AddIndexers(newIndexers cache.Indexers) error {
+	return x.informer.AddIndexers(newIndexers)
+}
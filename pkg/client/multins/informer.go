@@ -0,0 +1,214 @@
+package multins
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewInformerFunc creates an informer for objects in a namespace.
+type NewInformerFunc func(namespace string) cache.SharedIndexInformer
+
+// defaultResync is used to remember handlers added without an explicit resync period.
+const defaultResync time.Duration = -1
+
+type eventHandler struct {
+	handler      cache.ResourceEventHandler
+	resyncPeriod time.Duration
+}
+
+type namespaceInformer struct {
+	informer cache.SharedIndexInformer
+	stop     chan struct{}
+}
+
+// Informer is a SharedIndexInformer that is backed by an informer per namespace.
+// Objects from all namespaces are available via a single Indexer and event handlers receive events
+// for objects in all namespaces. Namespaces can be added and removed at any time.
+type Informer struct {
+	newInformer NewInformerFunc
+	namespaces  *Namespaces
+
+	mx        sync.RWMutex
+	informers map[string]*namespaceInformer // namespace -> informer
+	handlers  []eventHandler
+	indexers  cache.Indexers
+	// stopCh is nil until the informer is started.
+	stopCh   <-chan struct{}
+	stopping bool
+	wg       wait.Group
+}
+
+func newInformer(newInf NewInformerFunc, namespaces *Namespaces) *Informer {
+	return &Informer{
+		newInformer: newInf,
+		namespaces:  namespaces,
+		informers:   make(map[string]*namespaceInformer),
+		indexers:    cache.Indexers{},
+	}
+}
+
+// AddNamespace starts watching objects in the namespace.
+func (i *Informer) AddNamespace(namespace string) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if _, ok := i.informers[namespace]; ok || i.stopping {
+		return nil
+	}
+	inf := i.newInformer(namespace)
+	if err := inf.AddIndexers(i.indexers); err != nil {
+		return errors.Wrapf(err, "failed to add indexers to informer for namespace %q", namespace)
+	}
+	for _, h := range i.handlers {
+		addEventHandler(inf, h)
+	}
+	nsInf := &namespaceInformer{
+		informer: inf,
+		stop:     make(chan struct{}),
+	}
+	i.informers[namespace] = nsInf
+	if i.stopCh != nil {
+		i.wg.StartWithChannel(nsInf.stop, inf.Run)
+	}
+	return nil
+}
+
+// RemoveNamespace stops watching objects in the namespace.
+// Objects from the namespace are removed from the Indexer. Event handlers are not notified about that.
+func (i *Informer) RemoveNamespace(namespace string) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	nsInf, ok := i.informers[namespace]
+	if !ok {
+		return
+	}
+	delete(i.informers, namespace)
+	if i.stopCh != nil {
+		close(nsInf.stop)
+	}
+}
+
+func (i *Informer) AddEventHandler(handler cache.ResourceEventHandler) {
+	i.AddEventHandlerWithResyncPeriod(handler, defaultResync)
+}
+
+func (i *Informer) AddEventHandlerWithResyncPeriod(handler cache.ResourceEventHandler, resyncPeriod time.Duration) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	h := eventHandler{
+		handler:      handler,
+		resyncPeriod: resyncPeriod,
+	}
+	i.handlers = append(i.handlers, h)
+	for _, nsInf := range i.informers {
+		addEventHandler(nsInf.informer, h)
+	}
+}
+
+func (i *Informer) GetStore() cache.Store {
+	return i.GetIndexer()
+}
+
+// GetController returns the Informer itself. Per-namespace informers are not exposed.
+func (i *Informer) GetController() cache.Controller {
+	return i
+}
+
+// Run starts informers for all namespaces and blocks until stopCh is closed.
+func (i *Informer) Run(stopCh <-chan struct{}) {
+	defer i.wg.Wait()
+	func() {
+		i.mx.Lock()
+		defer i.mx.Unlock()
+		i.stopCh = stopCh
+		for _, nsInf := range i.informers {
+			i.wg.StartWithChannel(nsInf.stop, nsInf.informer.Run)
+		}
+	}()
+	<-stopCh
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	i.stopping = true
+	for namespace, nsInf := range i.informers {
+		close(nsInf.stop)
+		delete(i.informers, namespace)
+	}
+}
+
+// HasSynced returns true if the set of namespaces is known and informers for all of them have synced.
+func (i *Informer) HasSynced() bool {
+	if !i.namespaces.HasSynced() {
+		return false
+	}
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	for _, nsInf := range i.informers {
+		if !nsInf.informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// LastSyncResourceVersion always returns an empty string because resource versions of
+// different informers cannot be compared.
+func (i *Informer) LastSyncResourceVersion() string {
+	return ""
+}
+
+func (i *Informer) AddIndexers(indexers cache.Indexers) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	for name := range indexers {
+		if _, ok := i.indexers[name]; ok {
+			return errors.Errorf("indexer conflict: %q", name)
+		}
+	}
+	for namespace, nsInf := range i.informers {
+		if err := nsInf.informer.AddIndexers(indexers); err != nil {
+			return errors.Wrapf(err, "failed to add indexers to informer for namespace %q", namespace)
+		}
+	}
+	for name, indexFunc := range indexers {
+		i.indexers[name] = indexFunc
+	}
+	return nil
+}
+
+// GetIndexer returns a read-only Indexer that merges Indexers of informers for all namespaces.
+func (i *Informer) GetIndexer() cache.Indexer {
+	return &indexer{informer: i}
+}
+
+// forEach calls f with the Indexer of each namespace while holding the read lock.
+func (i *Informer) forEach(f func(cache.Indexer) error) error {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	for _, nsInf := range i.informers {
+		if err := f(nsInf.informer.GetIndexer()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Informer) indexerFor(namespace string) cache.Indexer {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	nsInf, ok := i.informers[namespace]
+	if !ok {
+		return nil
+	}
+	return nsInf.informer.GetIndexer()
+}
+
+func addEventHandler(inf cache.SharedIndexInformer, h eventHandler) {
+	if h.resyncPeriod == defaultResync {
+		inf.AddEventHandler(h.handler)
+	} else {
+		inf.AddEventHandlerWithResyncPeriod(h.handler, h.resyncPeriod)
+	}
+}
@@ -0,0 +1,189 @@
+package multins
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	core_v1inf "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+const byDataIndex = "byData"
+
+func configMap(namespace, name string) *core_v1.ConfigMap {
+	return &core_v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Data: map[string]string{
+			"key": "value",
+		},
+	}
+}
+
+func namespace(name string, lbls map[string]string) *core_v1.Namespace {
+	return &core_v1.Namespace{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:   name,
+			Labels: lbls,
+		},
+	}
+}
+
+func configMapInformerFunc(client kubernetes.Interface) NewInformerFunc {
+	return func(namespace string) cache.SharedIndexInformer {
+		return core_v1inf.NewConfigMapInformer(client, namespace, 0, cache.Indexers{})
+	}
+}
+
+// addedKeys records keys of added objects.
+type addedKeys struct {
+	mx   sync.Mutex
+	keys sets.String
+}
+
+func (a *addedKeys) OnAdd(obj interface{}) {
+	key, _ := cache.MetaNamespaceKeyFunc(obj)
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.keys.Insert(key)
+}
+
+func (a *addedKeys) OnUpdate(oldObj, newObj interface{}) {
+}
+
+func (a *addedKeys) OnDelete(obj interface{}) {
+}
+
+func (a *addedKeys) list() []string {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	return a.keys.List()
+}
+
+func keysOf(objs []interface{}) []string {
+	keys := sets.NewString()
+	for _, obj := range objs {
+		key, _ := cache.MetaNamespaceKeyFunc(obj)
+		keys.Insert(key)
+	}
+	return keys.List()
+}
+
+// eventually waits for the condition to become true.
+func eventually(t *testing.T, condition func() bool) {
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return condition(), nil
+	})
+	require.NoError(t, err)
+}
+
+func runInformers(t *testing.T, infs ...cache.SharedIndexInformer) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg wait.Group
+	hasSynced := make([]cache.InformerSynced, 0, len(infs))
+	for _, inf := range infs {
+		wg.StartWithChannel(ctx.Done(), inf.Run)
+		hasSynced = append(hasSynced, inf.HasSynced)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), hasSynced...) {
+		cancel()
+		wg.Wait()
+		t.Fatal("informers failed to sync")
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func TestInformerMergesNamespaces(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset(
+		configMap("ns1", "cm1"),
+		configMap("ns2", "cm2"),
+		configMap("ns3", "cm3"),
+	)
+	namespaces := NewNamespaces(zaptest.NewLogger(t), "ns1", "ns2")
+	inf := namespaces.NewInformer(configMapInformerFunc(client))
+	require.NoError(t, inf.AddIndexers(cache.Indexers{
+		byDataIndex: func(obj interface{}) ([]string, error) {
+			return []string{obj.(*core_v1.ConfigMap).Data["key"]}, nil
+		},
+	}))
+	handler := &addedKeys{keys: sets.NewString()}
+	inf.AddEventHandler(handler)
+
+	stop := runInformers(t, inf)
+	defer stop()
+
+	indexer := inf.GetIndexer()
+	assert.Equal(t, []string{"ns1/cm1", "ns2/cm2"}, keysOf(indexer.List()))
+	byIndex, err := indexer.ByIndex(byDataIndex, "value")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ns1/cm1", "ns2/cm2"}, keysOf(byIndex))
+	_, exists, err := indexer.GetByKey("ns2/cm2")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	_, exists, err = indexer.GetByKey("ns3/cm3")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	eventually(t, func() bool {
+		return len(handler.list()) == 2
+	})
+	assert.Equal(t, []string{"ns1/cm1", "ns2/cm2"}, handler.list())
+	assert.Equal(t, errReadOnly, indexer.Add(configMap("ns1", "cm4")))
+}
+
+func TestInformerFollowsNamespaceSelector(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset(
+		namespace("ns1", map[string]string{"tenant": "a"}),
+		namespace("ns2", map[string]string{"tenant": "b"}),
+		configMap("ns1", "cm1"),
+		configMap("ns2", "cm2"),
+	)
+	namespaceInf := core_v1inf.NewNamespaceInformer(client, 0, cache.Indexers{})
+	namespaces := NewSelectedNamespaces(zaptest.NewLogger(t), namespaceInf, labels.SelectorFromSet(labels.Set{"tenant": "a"}))
+	inf := namespaces.NewInformer(configMapInformerFunc(client))
+	handler := &addedKeys{keys: sets.NewString()}
+	inf.AddEventHandler(handler)
+
+	stop := runInformers(t, namespaceInf, inf)
+	defer stop()
+
+	assert.True(t, namespaces.Matches("ns1"))
+	assert.False(t, namespaces.Matches("ns2"))
+	assert.Equal(t, []string{"ns1/cm1"}, keysOf(inf.GetIndexer().List()))
+
+	// Namespace starts matching the selector
+	_, err := client.CoreV1().Namespaces().Update(namespace("ns2", map[string]string{"tenant": "a"}))
+	require.NoError(t, err)
+	eventually(t, func() bool {
+		return len(inf.GetIndexer().List()) == 2
+	})
+	eventually(t, func() bool {
+		return len(handler.list()) == 2
+	})
+
+	// Namespace stops matching the selector
+	_, err = client.CoreV1().Namespaces().Update(namespace("ns1", nil))
+	require.NoError(t, err)
+	eventually(t, func() bool {
+		return !namespaces.Matches("ns1")
+	})
+	assert.Equal(t, []string{"ns2/cm2"}, keysOf(inf.GetIndexer().List()))
+}
@@ -0,0 +1,173 @@
+package multins
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Namespaces is a set of namespaces to watch. It is either a fixed list of namespaces or
+// all namespaces matching a label selector. Informers created by Namespaces watch objects in all
+// namespaces from the set and are updated when namespaces start or stop matching the selector.
+type Namespaces struct {
+	logger *zap.Logger
+	// namespaceInf and selector are only set if namespaces are selected using a label selector.
+	namespaceInf cache.SharedIndexInformer
+	selector     labels.Selector
+
+	mx        sync.RWMutex
+	names     sets.String
+	informers map[*Informer]struct{}
+}
+
+// NewNamespaces returns a fixed set of namespaces.
+func NewNamespaces(logger *zap.Logger, names ...string) *Namespaces {
+	return &Namespaces{
+		logger:    logger,
+		names:     sets.NewString(names...),
+		informers: make(map[*Informer]struct{}),
+	}
+}
+
+// NewSelectedNamespaces returns a set of namespaces that match the selector.
+// namespaceInf must be an informer for Namespace objects in all namespaces.
+func NewSelectedNamespaces(logger *zap.Logger, namespaceInf cache.SharedIndexInformer, selector labels.Selector) *Namespaces {
+	n := &Namespaces{
+		logger:       logger,
+		namespaceInf: namespaceInf,
+		selector:     selector,
+		names:        sets.NewString(),
+		informers:    make(map[*Informer]struct{}),
+	}
+	namespaceInf.AddEventHandler(&namespaceEventHandler{namespaces: n})
+	return n
+}
+
+// Matches returns true if the namespace is in the set.
+func (n *Namespaces) Matches(namespace string) bool {
+	n.mx.RLock()
+	defer n.mx.RUnlock()
+	return n.names.Has(namespace)
+}
+
+// List returns a sorted list of namespaces in the set.
+func (n *Namespaces) List() []string {
+	n.mx.RLock()
+	defer n.mx.RUnlock()
+	return n.names.List()
+}
+
+// HasSynced returns true if the set of namespaces is known.
+func (n *Namespaces) HasSynced() bool {
+	if n.namespaceInf == nil {
+		return true
+	}
+	if !n.namespaceInf.HasSynced() {
+		return false
+	}
+	// Event handlers are notified asynchronously so matching namespaces may not have been added to the set yet
+	n.mx.RLock()
+	defer n.mx.RUnlock()
+	for _, obj := range n.namespaceInf.GetStore().List() {
+		ns := obj.(*core_v1.Namespace)
+		if n.selector.Matches(labels.Set(ns.Labels)) && !n.names.Has(ns.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewInformer returns an informer for objects in all namespaces in the set.
+// newInf is called to create an informer for each namespace.
+func (n *Namespaces) NewInformer(newInf NewInformerFunc) *Informer {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	inf := newInformer(newInf, n)
+	for namespace := range n.names {
+		if err := inf.AddNamespace(namespace); err != nil {
+			n.logger.Error("Failed to add namespace to informer", zap.String("namespace", namespace), zap.Error(err))
+		}
+	}
+	n.informers[inf] = struct{}{}
+	return inf
+}
+
+// ForgetInformer stops updating the informer when the set of namespaces changes.
+// Should be called for informers that are not used anymore.
+func (n *Namespaces) ForgetInformer(inf *Informer) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	delete(n.informers, inf)
+}
+
+func (n *Namespaces) add(namespace string) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if n.names.Has(namespace) {
+		return
+	}
+	n.logger.Info("Starting to watch namespace", zap.String("namespace", namespace))
+	n.names.Insert(namespace)
+	for inf := range n.informers {
+		if err := inf.AddNamespace(namespace); err != nil {
+			n.logger.Error("Failed to add namespace to informer", zap.String("namespace", namespace), zap.Error(err))
+		}
+	}
+}
+
+func (n *Namespaces) remove(namespace string) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if !n.names.Has(namespace) {
+		return
+	}
+	n.logger.Info("Stopping to watch namespace", zap.String("namespace", namespace))
+	n.names.Delete(namespace)
+	for inf := range n.informers {
+		inf.RemoveNamespace(namespace)
+	}
+}
+
+// namespaceEventHandler updates the set of namespaces when Namespace objects change.
+type namespaceEventHandler struct {
+	namespaces *Namespaces
+}
+
+func (h *namespaceEventHandler) OnAdd(obj interface{}) {
+	h.handle(obj.(*core_v1.Namespace))
+}
+
+func (h *namespaceEventHandler) OnUpdate(oldObj, newObj interface{}) {
+	h.handle(newObj.(*core_v1.Namespace))
+}
+
+func (h *namespaceEventHandler) OnDelete(obj interface{}) {
+	ns, ok := obj.(*core_v1.Namespace)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			h.namespaces.logger.Sugar().Errorf("Delete event with unrecognized object type: %T", obj)
+			return
+		}
+		ns, ok = tombstone.Obj.(*core_v1.Namespace)
+		if !ok {
+			h.namespaces.logger.Sugar().Errorf("Delete tombstone with unrecognized object type: %T", tombstone.Obj)
+			return
+		}
+	}
+	h.namespaces.remove(ns.Name)
+}
+
+// handle adds or removes the namespace depending on whether it matches the selector.
+// Terminating namespaces are still watched so that Bundles in them can be finalized.
+func (h *namespaceEventHandler) handle(ns *core_v1.Namespace) {
+	if h.namespaces.selector.Matches(labels.Set(ns.Labels)) {
+		h.namespaces.add(ns.Name)
+	} else {
+		h.namespaces.remove(ns.Name)
+	}
+}
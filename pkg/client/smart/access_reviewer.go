@@ -0,0 +1,53 @@
+package smart
+
+import (
+	"github.com/pkg/errors"
+	auth_v1 "k8s.io/api/authentication/v1"
+	authz_v1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	authzClient_v1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+// AccessReviewer checks if a user is allowed to perform an action on an object using SubjectAccessReviews.
+type AccessReviewer struct {
+	Client     authzClient_v1.SubjectAccessReviewsGetter
+	RESTMapper meta.RESTMapper
+}
+
+// ReviewAccess returns true if the user is allowed to perform the verb on the named object of the GVK in the namespace.
+// Namespace is ignored for cluster-scoped objects.
+// If the user is not allowed, the reason may be returned.
+func (r *AccessReviewer) ReviewAccess(user *auth_v1.UserInfo, verb string, gvk schema.GroupVersionKind, namespace, name string) (bool /*allowed*/, string /*reason*/, error) {
+	rm, err := r.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, "", errors.Wrapf(err, "failed to get rest mapping for %s", gvk)
+	}
+	var extra map[string]authz_v1.ExtraValue
+	if len(user.Extra) > 0 {
+		extra = make(map[string]authz_v1.ExtraValue, len(user.Extra))
+		for k, v := range user.Extra {
+			extra[k] = authz_v1.ExtraValue(v)
+		}
+	}
+	review, err := r.Client.SubjectAccessReviews().Create(&authz_v1.SubjectAccessReview{
+		Spec: authz_v1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authz_v1.ResourceAttributes{
+				Namespace: namespaceFor(rm, namespace),
+				Verb:      verb,
+				Group:     rm.Resource.Group,
+				Version:   rm.Resource.Version,
+				Resource:  rm.Resource.Resource,
+				Name:      name,
+			},
+			User:   user.Username,
+			Groups: user.Groups,
+			Extra:  extra,
+			UID:    user.UID,
+		},
+	})
+	if err != nil {
+		return false, "", errors.Wrap(err, "failed to create SubjectAccessReview")
+	}
+	return review.Status.Allowed && !review.Status.Denied, review.Status.Reason, nil
+}
@@ -1,18 +1,211 @@
 package smart
 
-import "k8s.io/client-go/discovery"
+import (
+	"sync"
+	"time"
 
-// TODO implement caching and invalidation
+	"github.com/prometheus/client_golang/prometheus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/discovery"
+)
 
+const (
+	// DefaultDiscoveryCacheTTL is how long discovery information is cached for by default.
+	DefaultDiscoveryCacheTTL = 10 * time.Minute
+
+	// DefaultNoMatchResetInterval is the minimum interval between discovery cache resets caused by
+	// kinds that are not found by default.
+	DefaultNoMatchResetInterval = 10 * time.Second
+
+	discoveryCacheHit  = "hit"
+	discoveryCacheMiss = "miss"
+	// discoveryCacheReset is recorded when cached information is discarded because a kind was not found.
+	discoveryCacheReset = "reset"
+	// discoveryCacheResetThrottled is recorded when a kind was not found but cached information
+	// has been discarded too recently to do it again.
+	discoveryCacheResetThrottled = "reset_throttled"
+)
+
+// CachedDiscoveryClient caches discovery information in memory.
+// Cached information is used for up to TTL, after that it is fetched again.
+// It should also be invalidated when the set of served kinds changes, see DiscoveryInvalidationHandler.
+// The zero value with DiscoveryInterface set is usable and caches information for DefaultDiscoveryCacheTTL.
 type CachedDiscoveryClient struct {
 	discovery.DiscoveryInterface
+	// TTL is how long cached information is used for. DefaultDiscoveryCacheTTL is used if zero.
+	TTL time.Duration
+	// Requests counts lookups in the cache by result. May be nil.
+	Requests *prometheus.CounterVec
+
+	clock clock.Clock
+
+	mx        sync.Mutex
+	groups    *meta_v1.APIGroupList
+	resources map[string]*meta_v1.APIResourceList
+	// fetched is when the oldest cached information was fetched. Zero if nothing is cached.
+	fetched time.Time
+}
+
+// NewCachedDiscoveryClient returns a client that caches information from delegate for ttl.
+// requests is optional. If set, it must have a single "result" label and it is incremented
+// with "hit" or "miss" on each lookup in the cache. The same counter can be passed to DynamicClient
+// to count resets caused by kinds that are not found.
+func NewCachedDiscoveryClient(delegate discovery.DiscoveryInterface, ttl time.Duration, requests *prometheus.CounterVec) *CachedDiscoveryClient {
+	return &CachedDiscoveryClient{
+		DiscoveryInterface: delegate,
+		TTL:                ttl,
+		Requests:           requests,
+	}
+}
+
+// NewDiscoveryCacheRequestsCounter returns a counter to pass to NewCachedDiscoveryClient.
+func NewDiscoveryCacheRequestsCounter(namespace string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "discovery_cache_requests_total",
+			Help:      "Records the number of lookups in the discovery cache and resets caused by kinds that are not found by result",
+		},
+		[]string{"result"},
+	)
+}
+
+func (c *CachedDiscoveryClient) ServerGroups() (*meta_v1.APIGroupList, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.expireLocked()
+	if c.groups != nil {
+		c.record(discoveryCacheHit)
+		return c.groups, nil
+	}
+	c.record(discoveryCacheMiss)
+	groups, err := c.DiscoveryInterface.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+	c.groups = groups
+	c.fetchedLocked()
+	return groups, nil
 }
 
-// Fresh returns true if no cached data was used that had been retrieved before the instantiation.
+func (c *CachedDiscoveryClient) ServerResourcesForGroupVersion(groupVersion string) (*meta_v1.APIResourceList, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.expireLocked()
+	if resources, ok := c.resources[groupVersion]; ok {
+		c.record(discoveryCacheHit)
+		return resources, nil
+	}
+	c.record(discoveryCacheMiss)
+	// Errors are not cached because they are often transient e.g. an aggregated API server being unavailable
+	resources, err := c.DiscoveryInterface.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return nil, err
+	}
+	if c.resources == nil {
+		c.resources = make(map[string]*meta_v1.APIResourceList)
+	}
+	c.resources[groupVersion] = resources
+	c.fetchedLocked()
+	return resources, nil
+}
+
+func (c *CachedDiscoveryClient) ServerResources() ([]*meta_v1.APIResourceList, error) {
+	return discovery.ServerResources(c)
+}
+
+func (c *CachedDiscoveryClient) ServerGroupsAndResources() ([]*meta_v1.APIGroup, []*meta_v1.APIResourceList, error) {
+	return discovery.ServerGroupsAndResources(c)
+}
+
+func (c *CachedDiscoveryClient) ServerPreferredResources() ([]*meta_v1.APIResourceList, error) {
+	return discovery.ServerPreferredResources(c)
+}
+
+func (c *CachedDiscoveryClient) ServerPreferredNamespacedResources() ([]*meta_v1.APIResourceList, error) {
+	return discovery.ServerPreferredNamespacedResources(c)
+}
+
+// Fresh returns true if cached information has been fetched less than TTL ago.
+// restmapper.DeferredDiscoveryRESTMapper invalidates the cache and retries if a kind is not found and
+// the cache is not fresh.
 func (c *CachedDiscoveryClient) Fresh() bool {
-	return true
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.fetched.IsZero() || c.clockOrDefault().Since(c.fetched) < c.ttl()
 }
 
 // Invalidate enforces that no cached data is used in the future that is older than the current time.
 func (c *CachedDiscoveryClient) Invalidate() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.invalidateLocked()
+}
+
+func (c *CachedDiscoveryClient) invalidateLocked() {
+	c.groups = nil
+	c.resources = nil
+	c.fetched = time.Time{}
+}
+
+func (c *CachedDiscoveryClient) expireLocked() {
+	if !c.fetched.IsZero() && c.clockOrDefault().Since(c.fetched) >= c.ttl() {
+		c.invalidateLocked()
+	}
+}
+
+func (c *CachedDiscoveryClient) fetchedLocked() {
+	if c.fetched.IsZero() {
+		c.fetched = c.clockOrDefault().Now()
+	}
+}
+
+func (c *CachedDiscoveryClient) ttl() time.Duration {
+	if c.TTL == 0 {
+		return DefaultDiscoveryCacheTTL
+	}
+	return c.TTL
+}
+
+func (c *CachedDiscoveryClient) clockOrDefault() clock.Clock {
+	if c.clock == nil {
+		return clock.RealClock{}
+	}
+	return c.clock
+}
+
+func (c *CachedDiscoveryClient) record(result string) {
+	recordDiscoveryCacheRequest(c.Requests, result)
+}
+
+func recordDiscoveryCacheRequest(requests *prometheus.CounterVec, result string) {
+	if requests != nil {
+		requests.WithLabelValues(result).Inc()
+	}
+}
+
+// DiscoveryInvalidationHandler resets cached discovery information when objects that change the set of
+// served kinds, i.e. CustomResourceDefinitions and APIServices, are added, updated or deleted.
+type DiscoveryInvalidationHandler struct {
+	// Reset discards cached discovery information e.g. restmapper.DeferredDiscoveryRESTMapper.Reset.
+	Reset func()
+}
+
+func (h *DiscoveryInvalidationHandler) OnAdd(obj interface{}) {
+	h.Reset()
+}
+
+func (h *DiscoveryInvalidationHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldMeta, ok1 := oldObj.(meta_v1.Object)
+	newMeta, ok2 := newObj.(meta_v1.Object)
+	if ok1 && ok2 && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+		// Periodic resync
+		return
+	}
+	h.Reset()
+}
+
+func (h *DiscoveryInvalidationHandler) OnDelete(obj interface{}) {
+	h.Reset()
 }
@@ -1,7 +1,209 @@
 package smart
 
-import "k8s.io/client-go/discovery"
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/discovery"
+	fake_discovery "k8s.io/client-go/discovery/fake"
+	fake_dynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/restmapper"
+	kube_testing "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
 
 var (
 	_ discovery.CachedDiscoveryInterface = &CachedDiscoveryClient{}
+	_ cache.ResourceEventHandler         = &DiscoveryInvalidationHandler{}
 )
+
+func configMapResources() *meta_v1.APIResourceList {
+	return &meta_v1.APIResourceList{
+		GroupVersion: "v1",
+		APIResources: []meta_v1.APIResource{
+			{
+				Name:       "configmaps",
+				Namespaced: true,
+				Kind:       "ConfigMap",
+			},
+		},
+	}
+}
+
+func newTestCachedDiscoveryClient(resources ...*meta_v1.APIResourceList) (*CachedDiscoveryClient, *fake_discovery.FakeDiscovery, *clock.FakeClock) {
+	delegate := &fake_discovery.FakeDiscovery{
+		Fake: &kube_testing.Fake{
+			Resources: resources,
+		},
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	c := NewCachedDiscoveryClient(delegate, time.Minute, NewDiscoveryCacheRequestsCounter("test"))
+	c.clock = fakeClock
+	return c, delegate, fakeClock
+}
+
+func TestCachedDiscoveryClientCachesUntilTTL(t *testing.T) {
+	t.Parallel()
+	c, delegate, fakeClock := newTestCachedDiscoveryClient(configMapResources())
+
+	for i := 0; i < 2; i++ {
+		groups, err := c.ServerGroups()
+		require.NoError(t, err)
+		require.Len(t, groups.Groups, 1)
+		resources, err := c.ServerResourcesForGroupVersion("v1")
+		require.NoError(t, err)
+		require.Len(t, resources.APIResources, 1)
+	}
+	assert.Len(t, delegate.Actions(), 2)
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.Requests.WithLabelValues(discoveryCacheMiss)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.Requests.WithLabelValues(discoveryCacheHit)))
+	assert.True(t, c.Fresh())
+
+	// Cached information expires
+	fakeClock.Step(time.Minute)
+	assert.False(t, c.Fresh())
+	_, err := c.ServerGroups()
+	require.NoError(t, err)
+	assert.Len(t, delegate.Actions(), 3)
+	assert.True(t, c.Fresh())
+}
+
+func TestCachedDiscoveryClientDoesNotCacheErrors(t *testing.T) {
+	t.Parallel()
+	c, delegate, _ := newTestCachedDiscoveryClient()
+
+	_, err := c.ServerResourcesForGroupVersion("v1")
+	require.Error(t, err)
+	delegate.Resources = []*meta_v1.APIResourceList{configMapResources()}
+	_, err = c.ServerResourcesForGroupVersion("v1")
+	require.NoError(t, err)
+	assert.Len(t, delegate.Actions(), 2)
+}
+
+func TestCachedDiscoveryClientInvalidate(t *testing.T) {
+	t.Parallel()
+	c, delegate, _ := newTestCachedDiscoveryClient(configMapResources())
+
+	_, err := c.ServerGroups()
+	require.NoError(t, err)
+	c.Invalidate()
+	_, err = c.ServerGroups()
+	require.NoError(t, err)
+	assert.Len(t, delegate.Actions(), 2)
+}
+
+func TestDiscoveryInvalidationHandlerIgnoresResyncs(t *testing.T) {
+	t.Parallel()
+	resets := 0
+	h := &DiscoveryInvalidationHandler{
+		Reset: func() {
+			resets++
+		},
+	}
+	obj := &meta_v1.ObjectMeta{ResourceVersion: "1"}
+	h.OnAdd(obj)
+	h.OnUpdate(obj, obj)
+	assert.Equal(t, 1, resets)
+	h.OnUpdate(obj, &meta_v1.ObjectMeta{ResourceVersion: "2"})
+	h.OnDelete(obj)
+	assert.Equal(t, 3, resets)
+}
+
+func TestDynamicClientRetriesAfterNoKindMatch(t *testing.T) {
+	t.Parallel()
+	c, delegate, _ := newTestCachedDiscoveryClient(configMapResources())
+	dc := &DynamicClient{
+		DynamicClient: fake_dynamic.NewSimpleDynamicClient(runtime.NewScheme()),
+		RESTMapper:    restmapper.NewDeferredDiscoveryRESTMapper(c),
+	}
+	_, err := dc.ForGVK(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "ns")
+	require.NoError(t, err)
+
+	// A CRD is installed after discovery information has been cached
+	delegate.Resources = append(delegate.Resources, &meta_v1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []meta_v1.APIResource{
+			{
+				Name:       "widgets",
+				Namespaced: true,
+				Kind:       "Widget",
+			},
+		},
+	})
+	_, err = dc.ForGVK(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, "ns")
+	require.NoError(t, err)
+
+	// Kinds that do not exist are still not found
+	_, err = dc.ForGVK(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"}, "ns")
+	require.Error(t, err)
+}
+
+func TestDynamicClientThrottlesResetsAfterNoKindMatch(t *testing.T) {
+	t.Parallel()
+	c, delegate, fakeClock := newTestCachedDiscoveryClient(configMapResources())
+	dc := &DynamicClient{
+		DynamicClient:          fake_dynamic.NewSimpleDynamicClient(runtime.NewScheme()),
+		RESTMapper:             restmapper.NewDeferredDiscoveryRESTMapper(c),
+		NoMatchResetInterval:   time.Second,
+		DiscoveryCacheRequests: c.Requests,
+		clock:                  fakeClock,
+	}
+	widget := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	_, err := dc.ForGVK(widget, "ns")
+	require.Error(t, err)
+	actions := len(delegate.Actions())
+
+	// Discovery information is not fetched again within the interval
+	delegate.Resources = append(delegate.Resources, &meta_v1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []meta_v1.APIResource{
+			{
+				Name:       "widgets",
+				Namespaced: true,
+				Kind:       "Widget",
+			},
+		},
+	})
+	_, err = dc.ForGVK(widget, "ns")
+	require.Error(t, err)
+	assert.Len(t, delegate.Actions(), actions)
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.Requests.WithLabelValues(discoveryCacheReset)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.Requests.WithLabelValues(discoveryCacheResetThrottled)))
+
+	// Discovery information is fetched again after the interval
+	fakeClock.Step(time.Second)
+	_, err = dc.ForGVK(widget, "ns")
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.Requests.WithLabelValues(discoveryCacheReset)))
+}
+
+func TestCachedDiscoveryClientZeroValue(t *testing.T) {
+	t.Parallel()
+	delegate := &fake_discovery.FakeDiscovery{
+		Fake: &kube_testing.Fake{
+			Resources: []*meta_v1.APIResourceList{configMapResources()},
+		},
+	}
+	c := &CachedDiscoveryClient{
+		DiscoveryInterface: delegate,
+	}
+	for i := 0; i < 2; i++ {
+		resources, err := c.ServerResourcesForGroupVersion("v1")
+		require.NoError(t, err)
+		require.Len(t, resources.APIResources, 1)
+	}
+	assert.Len(t, delegate.Actions(), 1)
+	assert.True(t, c.Fresh())
+
+	c.Invalidate()
+	_, err := c.ServerResourcesForGroupVersion("v1")
+	require.NoError(t, err)
+	assert.Len(t, delegate.Actions(), 2)
+}
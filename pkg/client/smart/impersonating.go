@@ -0,0 +1,83 @@
+package smart
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	auth_v1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultImpersonatingClientCacheSize = 128
+	defaultImpersonatingClientCacheTTL  = 30 * time.Minute
+)
+
+// ImpersonatingClient returns clients that perform requests on behalf of the provided user.
+// Clients are cached per user identity.
+type ImpersonatingClient struct {
+	restConfig *rest.Config
+	restMapper meta.RESTMapper
+	clients    *cache.LRUExpireCache
+	ttl        time.Duration
+}
+
+func NewImpersonatingClient(restConfig *rest.Config, restMapper meta.RESTMapper) *ImpersonatingClient {
+	return &ImpersonatingClient{
+		restConfig: restConfig,
+		restMapper: restMapper,
+		clients:    cache.NewLRUExpireCache(defaultImpersonatingClientCacheSize),
+		ttl:        defaultImpersonatingClientCacheTTL,
+	}
+}
+
+// ForGVKAs returns a client for the GVK in the namespace that impersonates the user.
+func (c *ImpersonatingClient) ForGVKAs(user *auth_v1.UserInfo, gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	dynamicClient, err := c.dynamicClientFor(user)
+	if err != nil {
+		return nil, err
+	}
+	rm, err := c.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get rest mapping for %s", gvk)
+	}
+	return dynamicClient.Resource(rm.Resource).Namespace(namespaceFor(rm, namespace)), nil
+}
+
+func (c *ImpersonatingClient) dynamicClientFor(user *auth_v1.UserInfo) (dynamic.Interface, error) {
+	if user.Username == "" {
+		return nil, errors.New("cannot impersonate user without a username")
+	}
+	// JSON encoding is deterministic (map keys are sorted) so it can be used as a key
+	keyBytes, err := json.Marshal(user)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	key := string(keyBytes)
+	if dynamicClient, ok := c.clients.Get(key); ok {
+		return dynamicClient.(dynamic.Interface), nil
+	}
+	config := rest.CopyConfig(c.restConfig)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: user.Username,
+		Groups:   user.Groups,
+	}
+	if len(user.Extra) > 0 {
+		config.Impersonate.Extra = make(map[string][]string, len(user.Extra))
+		for k, v := range user.Extra {
+			config.Impersonate.Extra[k] = v
+		}
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create client impersonating %q", user.Username)
+	}
+	// Concurrent requests may create more than one client for the same user, that is ok
+	c.clients.Add(key, dynamicClient, c.ttl)
+	return dynamicClient, nil
+}
@@ -0,0 +1,64 @@
+package smart
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	auth_v1 "k8s.io/api/authentication/v1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestImpersonatingClient(t *testing.T) {
+	t.Parallel()
+	var lock sync.Mutex
+	var headers []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		headers = append(headers, r.Header)
+		lock.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"ns"}}`)) // nolint: errcheck
+	}))
+	defer srv.Close()
+
+	rm := meta.NewDefaultRESTMapper(nil)
+	rm.Add(core_v1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	c := NewImpersonatingClient(&rest.Config{Host: srv.URL}, rm)
+
+	user := &auth_v1.UserInfo{
+		Username: "alice",
+		Groups:   []string{"devs", "system:authenticated"},
+		Extra: map[string]auth_v1.ExtraValue{
+			"scopes": {"a", "b"},
+		},
+	}
+	client, err := c.ForGVKAs(user, core_v1.SchemeGroupVersion.WithKind("ConfigMap"), "ns")
+	require.NoError(t, err)
+	_, err = client.Get("cm", meta_v1.GetOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, headers, 1)
+	assert.Equal(t, "alice", headers[0].Get("Impersonate-User"))
+	assert.Equal(t, []string{"devs", "system:authenticated"}, headers[0]["Impersonate-Group"])
+	assert.Equal(t, []string{"a", "b"}, headers[0]["Impersonate-Extra-Scopes"])
+
+	// Clients are cached per user
+	client1, err := c.dynamicClientFor(user)
+	require.NoError(t, err)
+	client2, err := c.dynamicClientFor(user.DeepCopy())
+	require.NoError(t, err)
+	assert.True(t, client1 == client2)
+	client3, err := c.dynamicClientFor(&auth_v1.UserInfo{Username: "bob"})
+	require.NoError(t, err)
+	assert.False(t, client1 == client3)
+
+	_, err = c.ForGVKAs(&auth_v1.UserInfo{}, core_v1.SchemeGroupVersion.WithKind("ConfigMap"), "ns")
+	assert.EqualError(t, err, "cannot impersonate user without a username")
+}
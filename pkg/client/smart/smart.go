@@ -1,21 +1,86 @@
 package smart
 
 import (
+	"sync"
+	"time"
+
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/dynamic"
 )
 
+// resettableRESTMapper is a RESTMapper that can discard cached mappings e.g. restmapper.DeferredDiscoveryRESTMapper.
+type resettableRESTMapper interface {
+	meta.RESTMapper
+	Reset()
+}
+
 type DynamicClient struct {
 	DynamicClient dynamic.Interface
 	RESTMapper    meta.RESTMapper
+	// NoMatchResetInterval is the minimum interval between resets of the RESTMapper caused by kinds that
+	// are not found. DefaultNoMatchResetInterval is used if zero.
+	NoMatchResetInterval time.Duration
+	// DiscoveryCacheRequests counts resets of the RESTMapper caused by kinds that are not found
+	// with "reset" or "reset_throttled" result. May be nil. See NewDiscoveryCacheRequestsCounter.
+	DiscoveryCacheRequests *prometheus.CounterVec
+
+	clock     clock.Clock
+	mx        sync.Mutex
+	lastReset time.Time
 }
 
+// ForGVK returns a client for the GVK in the namespace.
+// If the kind is not found and the RESTMapper can be reset, it is reset and the mapping is retried once
+// because the kind may have been added after mappings were cached e.g. by a new CRD.
+// Resets happen at most once per NoMatchResetInterval so that objects of kinds that do not exist
+// do not cause discovery information to be fetched on each lookup.
 func (c *DynamicClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
 	rm, err := c.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		if resettable, ok := c.RESTMapper.(resettableRESTMapper); ok && c.allowReset() {
+			resettable.Reset()
+			rm, err = c.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get rest mapping for %s", gvk)
 	}
-	return c.DynamicClient.Resource(rm.Resource).Namespace(namespace), nil
+	return c.DynamicClient.Resource(rm.Resource).Namespace(namespaceFor(rm, namespace)), nil
+}
+
+// allowReset returns true and records the reset if the RESTMapper has not been reset
+// within NoMatchResetInterval.
+func (c *DynamicClient) allowReset() bool {
+	interval := c.NoMatchResetInterval
+	if interval == 0 {
+		interval = DefaultNoMatchResetInterval
+	}
+	clk := c.clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	now := clk.Now()
+	if !c.lastReset.IsZero() && now.Sub(c.lastReset) < interval {
+		recordDiscoveryCacheRequest(c.DiscoveryCacheRequests, discoveryCacheResetThrottled)
+		return false
+	}
+	c.lastReset = now
+	recordDiscoveryCacheRequest(c.DiscoveryCacheRequests, discoveryCacheReset)
+	return true
+}
+
+// namespaceFor returns the namespace objects of the mapping should be accessed in.
+// Namespace is ignored for cluster-scoped objects.
+func namespaceFor(rm *meta.RESTMapping, namespace string) string {
+	if rm.Scope.Name() == meta.RESTScopeNameRoot {
+		return meta_v1.NamespaceNone
+	}
+	return namespace
 }
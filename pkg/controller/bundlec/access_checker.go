@@ -0,0 +1,76 @@
+package bundlec
+
+import (
+	"github.com/pkg/errors"
+	auth_v1 "k8s.io/api/authentication/v1"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	verbCreate = "create"
+	verbUpdate = "update"
+	verbDelete = "delete"
+)
+
+type accessRequest struct {
+	verb      string
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+type accessDecision struct {
+	allowed bool
+	reason  string
+}
+
+// accessChecker checks if the author of a Bundle is allowed to manage objects of the Bundle.
+// Decisions are cached for the duration of a single Bundle processing iteration.
+type accessChecker struct {
+	reviewer  AccessReviewer
+	user      *auth_v1.UserInfo
+	decisions map[accessRequest]accessDecision
+}
+
+func newAccessChecker(reviewer AccessReviewer, user *auth_v1.UserInfo) *accessChecker {
+	return &accessChecker{
+		reviewer:  reviewer,
+		user:      user,
+		decisions: make(map[accessRequest]accessDecision),
+	}
+}
+
+// checkAccess returns a Forbidden error if the user is not allowed to perform the verb on the object.
+// A nil checker allows everything.
+func (c *accessChecker) checkAccess(verb string, gvk schema.GroupVersionKind, namespace, name string) error {
+	if c == nil {
+		return nil
+	}
+	req := accessRequest{
+		verb:      verb,
+		gvk:       gvk,
+		namespace: namespace,
+		name:      name,
+	}
+	decision, ok := c.decisions[req]
+	if !ok {
+		allowed, reason, err := c.reviewer.ReviewAccess(c.user, verb, gvk, namespace, name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if %q is allowed to %s %s", c.user.Username, verb, gvk)
+		}
+		decision = accessDecision{
+			allowed: allowed,
+			reason:  reason,
+		}
+		c.decisions[req] = decision
+	}
+	if decision.allowed {
+		return nil
+	}
+	msg := errors.Errorf("author of the Bundle %q is not allowed to %s it", c.user.Username, verb)
+	if decision.reason != "" {
+		msg = errors.Errorf("author of the Bundle %q is not allowed to %s it: %s", c.user.Username, verb, decision.reason)
+	}
+	return api_errors.NewForbidden(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, name, msg)
+}
@@ -0,0 +1,51 @@
+package bundlec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	auth_v1 "k8s.io/api/authentication/v1"
+	core_v1 "k8s.io/api/core/v1"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeAccessReviewer struct {
+	allowedVerbs map[string]bool
+	reviews      int
+}
+
+func (r *fakeAccessReviewer) ReviewAccess(user *auth_v1.UserInfo, verb string, gvk schema.GroupVersionKind, namespace, name string) (bool, string, error) {
+	r.reviews++
+	return r.allowedVerbs[verb], "", nil
+}
+
+func TestAccessCheckerCachesDecisions(t *testing.T) {
+	t.Parallel()
+	reviewer := &fakeAccessReviewer{
+		allowedVerbs: map[string]bool{
+			verbUpdate: true,
+		},
+	}
+	checker := newAccessChecker(reviewer, &auth_v1.UserInfo{Username: "alice"})
+	gvk := core_v1.SchemeGroupVersion.WithKind("ConfigMap")
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, checker.checkAccess(verbUpdate, gvk, "ns", "cm"))
+		err := checker.checkAccess(verbDelete, gvk, "ns", "cm")
+		require.Error(t, err)
+		assert.True(t, api_errors.IsForbidden(err))
+		assert.EqualError(t, err, `ConfigMap "cm" is forbidden: author of the Bundle "alice" is not allowed to delete it`)
+	}
+	assert.Equal(t, 2, reviewer.reviews)
+
+	require.NoError(t, checker.checkAccess(verbUpdate, gvk, "ns", "cm2"))
+	assert.Equal(t, 3, reviewer.reviews)
+}
+
+func TestNilAccessCheckerAllowsEverything(t *testing.T) {
+	t.Parallel()
+	var checker *accessChecker
+	assert.NoError(t, checker.checkAccess(verbDelete, core_v1.SchemeGroupVersion.WithKind("ConfigMap"), "ns", "cm"))
+}
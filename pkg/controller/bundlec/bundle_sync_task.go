@@ -1,6 +1,7 @@
 package bundlec
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sort"
@@ -12,6 +13,7 @@ import (
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	smithClient_v1 "github.com/atlassian/smith/pkg/client/clientset_generated/clientset/typed/smith/v1"
 	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/policy"
 	"github.com/atlassian/smith/pkg/resources"
 	"github.com/atlassian/smith/pkg/statuschecker"
 	"github.com/atlassian/smith/pkg/store"
@@ -23,6 +25,7 @@ import (
 	"go.uber.org/zap"
 	core_v1 "k8s.io/api/core/v1"
 	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -37,6 +40,14 @@ type bundleSyncTask struct {
 	logger                          *zap.Logger
 	bundleClient                    smithClient_v1.BundlesGetter
 	smartClient                     SmartClient
+	restMapper                      meta.RESTMapper
+	clusterScopedObjects            bool
+	managedByLabel                  bool
+	managedObjectsOnly              bool
+	watchesNamespace                func(namespace string) bool
+	dynamicInformers                *dynamicInformers
+	accessChecker                   *accessChecker
+	policyStore                     PolicyStore
 	checker                         statuschecker.Interface
 	store                           Store
 	specChecker                     SpecChecker
@@ -46,13 +57,21 @@ type bundleSyncTask struct {
 	catalog                         *store.Catalog
 	bundleTransitionCounter         *prometheus.CounterVec
 	bundleResourceTransitionCounter *prometheus.CounterVec
-	recorder                        record.EventRecorder
+	pluginProcessDuration           *prometheus.HistogramVec
+	pluginErrorCounter              *prometheus.CounterVec
+	pluginTimeout                   time.Duration
+	pluginLimiters                  map[smith_v1.PluginName]*pluginLimiter
+	// ctx is done when the controller is shutting down.
+	ctx      context.Context
+	recorder record.EventRecorder
 
 	// Outputs
 
 	processedResources map[smith_v1.ResourceName]*resourceInfo
 	objectsToDelete    map[objectRef]runtime.Object
 	newFinalizers      []string
+	// pluginFailures records plugins that panicked or timed out.
+	pluginFailures map[smith_v1.PluginName]error
 }
 
 // Parse bundle, build resource graph, traverse graph, assert each resource exists.
@@ -84,6 +103,21 @@ func (st *bundleSyncTask) processNormal() (externalError bool, retriableError bo
 		resourceMap[res.Name] = res
 	}
 
+	// Check policies that apply to the Bundle
+	var policies []policy.Policy
+	if st.policyStore != nil {
+		var err error
+		policies, err = st.policyStore.PoliciesFor(st.bundle.Namespace)
+		if err != nil {
+			return false, true, errors.Wrap(err, "failed to get policies")
+		}
+		for i := range policies {
+			if err = policies[i].CheckResourceCount(len(st.bundle.Spec.Resources)); err != nil {
+				return true, false, err
+			}
+		}
+	}
+
 	// Build the graph and topologically sort it
 	_, sorted, sortErr := sortBundle(st.bundle)
 	if sortErr != nil {
@@ -91,7 +125,14 @@ func (st *bundleSyncTask) processNormal() (externalError bool, retriableError bo
 		return true, false, errors.Wrap(sortErr, "topological sort of resources failed")
 	}
 
+	// Check plugin inputs of all resources before any of them is processed so that
+	// a Bundle with mismatched inputs is not partially applied
+	if err := checkBundlePluginInputs(st.bundle.Spec.Resources, st.pluginContainers); err != nil {
+		return true, false, err
+	}
+
 	st.processedResources = make(map[smith_v1.ResourceName]*resourceInfo, len(st.bundle.Spec.Resources))
+	st.pluginFailures = make(map[smith_v1.PluginName]error)
 
 	// Visit vertices in sorted order
 	for _, resName := range sorted {
@@ -100,18 +141,36 @@ func (st *bundleSyncTask) processNormal() (externalError bool, retriableError bo
 		logger := st.logger.With(logz.Resource(resourceName))
 		res := resourceMap[resourceName]
 		rst := resourceSyncTask{
-			logger:             logger,
-			smartClient:        st.smartClient,
-			checker:            st.checker,
-			store:              st.store,
-			specChecker:        st.specChecker,
-			bundle:             st.bundle,
-			processedResources: st.processedResources,
-			pluginContainers:   st.pluginContainers,
-			scheme:             st.scheme,
-			catalog:            st.catalog,
+			logger:               logger,
+			smartClient:          st.smartClient,
+			restMapper:           st.restMapper,
+			clusterScopedObjects: st.clusterScopedObjects,
+			managedByLabel:       st.managedByLabel,
+			managedObjectsOnly:   st.managedObjectsOnly,
+			watchesNamespace:     st.watchesNamespace,
+			dynamicInformers:     st.dynamicInformers,
+			accessChecker:        st.accessChecker,
+			policies:             policies,
+			checker:              st.checker,
+			store:                st.store,
+			specChecker:          st.specChecker,
+			bundle:               st.bundle,
+			processedResources:   st.processedResources,
+			pluginContainers:     st.pluginContainers,
+			scheme:               st.scheme,
+			catalog:              st.catalog,
+			recorder:             st.recorder,
+
+			pluginProcessDuration: st.pluginProcessDuration,
+			pluginErrorCounter:    st.pluginErrorCounter,
+			pluginTimeout:         st.pluginTimeout,
+			pluginLimiters:        st.pluginLimiters,
+			ctx:                   st.ctx,
+			pluginFailures:        st.pluginFailures,
 		}
 		resInfo := rst.processResource(&res)
+		resInfo.lookups = rst.lookups
+		resInfo.status = rst.redactor.redactStatus(resInfo.status)
 		resErr := resInfo.fetchError()
 		if resErr != nil {
 			if api_errors.IsConflict(errors.Cause(resErr.err)) {
@@ -155,22 +214,24 @@ func (st *bundleSyncTask) processNormal() (externalError bool, retriableError bo
 // TODO: remove this method after https://github.com/kubernetes/kubernetes/issues/59850 is fixed
 func (st *bundleSyncTask) processDeleted() (externalError bool, retriableError bool, e error) {
 	if hasDeleteResourcesFinalizer(st.bundle) {
-		if !resources.HasFinalizer(st.bundle, meta_v1.FinalizerDeleteDependents) {
-			// If "foregroundDeletion" finalizer was not set, perform manual cascade deletion
-			retrieable, err := st.deleteAllResources()
-			if err != nil {
-				return false, retrieable, err
-			}
+		// If "foregroundDeletion" finalizer was not set, perform manual cascade deletion.
+		// Otherwise only cluster-scoped objects are deleted because garbage collector
+		// does not know they belong to the Bundle.
+		clusterScopedOnly := resources.HasFinalizer(st.bundle, meta_v1.FinalizerDeleteDependents)
+		retrieable, err := st.deleteAllResources(clusterScopedOnly)
+		if err != nil {
+			return false, retrieable, err
 		}
 
-		// If the "foregroundDeletion" finalizer is set, or the manual deletion
-		// of resources has succeeded, remove the "deleteResources" finalizer
+		// If the manual deletion of resources has succeeded, remove the "deleteResources" finalizer
 		st.newFinalizers = removeDeleteResourcesFinalizer(st.bundle.GetFinalizers())
 	}
 	return false, false, nil
 }
 
-func (st *bundleSyncTask) deleteAllResources() (retriableError bool, e error) {
+// deleteAllResources deletes objects that are controlled by the Bundle.
+// Namespaced objects are skipped if clusterScopedOnly is true.
+func (st *bundleSyncTask) deleteAllResources(clusterScopedOnly bool) (retriableError bool, e error) {
 	objs, err := st.store.ObjectsControlledBy(st.bundle.Namespace, st.bundle.UID)
 	if err != nil {
 		return false, err
@@ -182,6 +243,9 @@ func (st *bundleSyncTask) deleteAllResources() (retriableError bool, e error) {
 	policy := meta_v1.DeletePropagationForeground
 	for _, obj := range objs {
 		m := obj.(meta_v1.Object)
+		if clusterScopedOnly && m.GetNamespace() != meta_v1.NamespaceNone {
+			continue
+		}
 		gvk := obj.GetObjectKind().GroupVersionKind()
 		name := m.GetName()
 		ref := objectRef{
@@ -245,6 +309,15 @@ func (st *bundleSyncTask) findObjectsToDelete() (bool /*external*/, bool /*retri
 		}
 		st.objectsToDelete[ref] = obj
 	}
+	keepObject := func(gvk schema.GroupVersionKind, name string) {
+		for ref := range st.objectsToDelete {
+			// Objects are compared by group and kind because the Bundle may use
+			// a different served version than the one objects are watched with
+			if ref.GroupVersionKind.GroupKind() == gvk.GroupKind() && ref.Name == name {
+				delete(st.objectsToDelete, ref)
+			}
+		}
+	}
 	for _, res := range st.bundle.Spec.Resources {
 		var gvk schema.GroupVersionKind
 		var name string
@@ -263,7 +336,14 @@ func (st *bundleSyncTask) findObjectsToDelete() (bool /*external*/, bool /*retri
 			if !ok {
 				return true, false, errors.Errorf("plugin %q is not a valid plugin", res.Spec.Plugin.Name)
 			}
-			gvk = plugin.Plugin.Describe().GVK
+			description := plugin.Plugin.Describe()
+			if description.IsMultiObject() {
+				for _, obj := range st.producedObjects(res.Name) {
+					keepObject(obj.GroupVersionKind(), obj.Name)
+				}
+				continue
+			}
+			gvk = description.GVK
 			name = res.Spec.Plugin.ObjectName
 		default:
 			// neither "object" nor "plugin" field is specified. This shouldn't really happen (schema), so we
@@ -271,14 +351,46 @@ func (st *bundleSyncTask) findObjectsToDelete() (bool /*external*/, bool /*retri
 			return true, false, errors.New("resource is neither object nor plugin")
 		}
 
-		delete(st.objectsToDelete, objectRef{
-			GroupVersionKind: gvk,
-			Name:             name,
-		})
+		keepObject(gvk, name)
 	}
 	return false, false, nil
 }
 
+// lookedUpObjects returns objects looked up by the plugin of the resource.
+// Objects recorded in the Bundle status are returned if the plugin has not been invoked.
+func (st *bundleSyncTask) lookedUpObjects(resName smith_v1.ResourceName) []smith_v1.LookedUpObject {
+	if resInfo := st.processedResources[resName]; resInfo != nil && resInfo.lookups != nil {
+		return resInfo.lookups
+	}
+	_, resStatus := st.bundle.Status.GetResourceStatus(resName)
+	if resStatus == nil {
+		return nil
+	}
+	return resStatus.Lookups
+}
+
+// isMultiObjectResource returns true if the resource is a plugin that produces multiple objects.
+func (st *bundleSyncTask) isMultiObjectResource(res *smith_v1.Resource) bool {
+	if res.Spec.Plugin == nil {
+		return false
+	}
+	pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]
+	return ok && pluginContainer.Plugin.Describe().IsMultiObject()
+}
+
+// producedObjects returns objects produced by a resource of a plugin that produces multiple objects.
+// Objects recorded in the Bundle status are returned if the plugin has not been invoked.
+func (st *bundleSyncTask) producedObjects(resName smith_v1.ResourceName) []smith_v1.ResourceObject {
+	if resInfo := st.processedResources[resName]; resInfo != nil && resInfo.producedObjects != nil {
+		return resInfo.producedObjects
+	}
+	_, resStatus := st.bundle.Status.GetResourceStatus(resName)
+	if resStatus == nil {
+		return nil
+	}
+	return resStatus.Objects
+}
+
 func (st *bundleSyncTask) deleteRemovedResources() (retriableError bool, e error) {
 	var firstErr error
 	retriable := true
@@ -302,6 +414,16 @@ func (st *bundleSyncTask) deleteRemovedResources() (retriableError bool, e error
 			continue
 		}
 
+		err = st.accessChecker.checkAccess(verbDelete, ref.GroupVersionKind, st.bundle.Namespace, ref.Name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			} else {
+				logger.Warn("Not allowed to delete object", zap.Error(err))
+			}
+			continue
+		}
+
 		readyToDelete, retriableErr, err := st.preDelete(logger, ref.Name, obj, resClient)
 		if err != nil {
 			if retriableErr {
@@ -442,9 +564,14 @@ func (st *bundleSyncTask) handleNormalStatusUpdate(retriable bool, processErr er
 	resourceStatuses := make([]smith_v1.ResourceStatus, 0, len(st.processedResources))
 	var failedResources []smith_v1.ResourceName
 	retriableResourceErr := false
+	var resourcesReady int32
 	for _, res := range st.bundle.Spec.Resources { // Deterministic iteration order
 		blockedCond, inProgressCond, readyCond, errorCond := st.resourceConditions(res)
 
+		if readyCond.Status == cond_v1.ConditionTrue {
+			resourcesReady++
+		}
+
 		if errorCond.Status == cond_v1.ConditionTrue {
 			failedResources = append(failedResources, res.Name)
 			retriableResourceErr = retriableResourceErr || errorCond.Reason == smith_v1.ResourceReasonRetriableError // Must continue if at least one error is retriable
@@ -455,10 +582,35 @@ func (st *bundleSyncTask) handleNormalStatusUpdate(retriable bool, processErr er
 		bundleStatusUpdated = st.checkResourceConditionNeedsUpdate(res.Name, &readyCond) || bundleStatusUpdated
 		bundleStatusUpdated = st.checkResourceConditionNeedsUpdate(res.Name, &errorCond) || bundleStatusUpdated
 
+		var objects []smith_v1.ResourceObject
+		if st.isMultiObjectResource(&res) {
+			objects = st.producedObjects(res.Name)
+			if len(objects) == 0 {
+				objects = nil
+			}
+			var oldObjects []smith_v1.ResourceObject
+			if _, oldStatus := st.bundle.Status.GetResourceStatus(res.Name); oldStatus != nil {
+				oldObjects = oldStatus.Objects
+			}
+			bundleStatusUpdated = bundleStatusUpdated || !reflect.DeepEqual(oldObjects, objects)
+		}
+
+		lookups := st.lookedUpObjects(res.Name)
+		if len(lookups) == 0 {
+			lookups = nil
+		}
+		var oldLookups []smith_v1.LookedUpObject
+		if _, oldStatus := st.bundle.Status.GetResourceStatus(res.Name); oldStatus != nil {
+			oldLookups = oldStatus.Lookups
+		}
+		bundleStatusUpdated = bundleStatusUpdated || !reflect.DeepEqual(oldLookups, lookups)
+
 		resourceStatuses = append(resourceStatuses, smith_v1.ResourceStatus{
 			Name: res.Name,
 			ResourceStatusData: smith_v1.ResourceStatusData{
 				Conditions: []cond_v1.Condition{blockedCond, inProgressCond, readyCond, errorCond},
+				Objects:    objects,
+				Lookups:    lookups,
 			},
 		})
 	}
@@ -499,6 +651,13 @@ func (st *bundleSyncTask) handleNormalStatusUpdate(retriable bool, processErr er
 	bundleStatusUpdated = bundleStatusUpdated || !reflect.DeepEqual(st.bundle.Status.PluginStatuses, pluginStatuses)
 	st.bundle.Status.PluginStatuses = pluginStatuses
 
+	// Resource counts
+	resourcesTotal := int32(len(st.bundle.Spec.Resources))
+	bundleStatusUpdated = bundleStatusUpdated ||
+		st.bundle.Status.ResourcesTotal != resourcesTotal || st.bundle.Status.ResourcesReady != resourcesReady
+	st.bundle.Status.ResourcesTotal = resourcesTotal
+	st.bundle.Status.ResourcesReady = resourcesReady
+
 	// Update the bundle status
 	if bundleStatusUpdated {
 		st.bundle.Status.ResourceStatuses = resourceStatuses
@@ -612,16 +771,32 @@ func (st *bundleSyncTask) pluginStatuses() []smith_v1.PluginStatus {
 		name2status[pluginName] = struct{}{}
 		var pluginStatus smith_v1.PluginStatus
 		pluginContainer, ok := st.pluginContainers[pluginName]
-		if ok {
-			describe := pluginContainer.Plugin.Describe()
+		pluginErr := st.pluginFailures[pluginName]
+		switch {
+		case ok && pluginErr != nil:
+			description := pluginContainer.Plugin.Describe()
+			gvk := description.OutputGVKs()[0]
 			pluginStatus = smith_v1.PluginStatus{
-				Name:    pluginName,
-				Group:   describe.GVK.Group,
-				Version: describe.GVK.Version,
-				Kind:    describe.GVK.Kind,
-				Status:  smith_v1.PluginStatusOk,
+				Name:          pluginName,
+				Group:         gvk.Group,
+				Version:       gvk.Version,
+				Kind:          gvk.Kind,
+				PluginVersion: description.Version,
+				Status:        smith_v1.PluginStatusInvocationFailed,
+				Message:       pluginErr.Error(),
 			}
-		} else {
+		case ok:
+			description := pluginContainer.Plugin.Describe()
+			gvk := description.OutputGVKs()[0]
+			pluginStatus = smith_v1.PluginStatus{
+				Name:          pluginName,
+				Group:         gvk.Group,
+				Version:       gvk.Version,
+				Kind:          gvk.Kind,
+				PluginVersion: description.Version,
+				Status:        smith_v1.PluginStatusOk,
+			}
+		default:
 			pluginStatus = smith_v1.PluginStatus{
 				Name:   pluginName,
 				Status: smith_v1.PluginStatusNoSuchPlugin,
@@ -661,10 +836,16 @@ func (st *bundleSyncTask) resourceConditions(res smith_v1.Resource) (
 		case resourceStatusError:
 			errorCond.Status = cond_v1.ConditionTrue
 			errorCond.Message = resStatus.err.Error()
-			if resStatus.isRetriableError {
+			switch {
+			case api_errors.IsForbidden(errors.Cause(resStatus.err)):
+				errorCond.Reason = smith_v1.ResourceReasonForbidden
+				if resStatus.isRetriableError {
+					inProgressCond.Status = cond_v1.ConditionTrue
+				}
+			case resStatus.isRetriableError:
 				errorCond.Reason = smith_v1.ResourceReasonRetriableError
 				inProgressCond.Status = cond_v1.ConditionTrue
-			} else {
+			default:
 				errorCond.Reason = smith_v1.ResourceReasonTerminalError
 			}
 		default:
@@ -0,0 +1,192 @@
+package bundlec
+
+import (
+	"fmt"
+	"sort"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/policy"
+	"github.com/atlassian/smith/pkg/store"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/atlassian/smith/pkg/util/logz"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// BundleValidator performs the checks that do not require any of the Bundle's resources to be processed.
+// The same checks are performed by the controller while processing the Bundle. BundleValidator is used
+// by the admission webhook to reject invalid Bundles before they are persisted.
+type BundleValidator struct {
+	Logger           *zap.Logger
+	PluginContainers map[smith_v1.PluginName]plugin.Container
+	Scheme           *runtime.Scheme
+	Catalog          *store.Catalog
+	// Policies is optional. If set, Bundles must satisfy policies that apply to them.
+	Policies PolicyStore
+}
+
+// omittedValue is used as a bad value in field errors when the value itself is too big to be included into the
+// error message.
+type omittedValue struct{}
+
+func (omittedValue) String() string {
+	return "..."
+}
+
+// Validate validates the Bundle and returns all found problems.
+func (v *BundleValidator) Validate(bundle *smith_v1.Bundle) field.ErrorList {
+	var allErrs field.ErrorList
+	resourcesPath := field.NewPath("spec", "resources")
+
+	var policies []policy.Policy
+	if v.Policies != nil {
+		var err error
+		policies, err = v.Policies.PoliciesFor(bundle.Namespace)
+		if err != nil {
+			// Not a problem with the Bundle. Policies are checked by the controller anyway
+			v.Logger.Error("Failed to get policies, admitting the Bundle without checking them", zap.Error(err))
+			policies = nil
+		}
+	}
+	for i := range policies {
+		if err := policies[i].CheckResourceCount(len(bundle.Spec.Resources)); err != nil {
+			allErrs = append(allErrs, field.Forbidden(resourcesPath, err.Error()))
+		}
+	}
+
+	resourceNames := make(map[smith_v1.ResourceName]struct{}, len(bundle.Spec.Resources))
+	for i, res := range bundle.Spec.Resources {
+		if _, ok := resourceNames[res.Name]; ok {
+			allErrs = append(allErrs, field.Duplicate(resourcesPath.Index(i).Child("name"), res.Name))
+			continue
+		}
+		resourceNames[res.Name] = struct{}{}
+	}
+	for i := range bundle.Spec.Resources {
+		allErrs = append(allErrs, v.validateResource(bundle, &bundle.Spec.Resources[i], resourceNames, policies, resourcesPath.Index(i))...)
+	}
+	if len(allErrs) == 0 {
+		// Cycles can only be detected reliably once all references point to existing resources
+		if _, _, err := sortBundle(bundle); err != nil {
+			allErrs = append(allErrs, field.Forbidden(resourcesPath, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func (v *BundleValidator) validateResource(bundle *smith_v1.Bundle, res *smith_v1.Resource, resourceNames map[smith_v1.ResourceName]struct{}, policies []policy.Policy, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	declaredReferences := sets.NewString()
+	for i, reference := range res.References {
+		if _, ok := resourceNames[reference.Resource]; !ok {
+			allErrs = append(allErrs, field.NotFound(path.Child("references").Index(i).Child("resource"), reference.Resource))
+		}
+		if reference.Name != "" {
+			declaredReferences.Insert(string(reference.Name))
+		}
+	}
+
+	allErrs = append(allErrs, validateSecretReferences(res, v.PluginContainers, path)...)
+
+	specPath := path.Child("spec")
+	var prevalidatePath *field.Path
+	switch {
+	case res.Spec.Object != nil:
+		prevalidatePath = specPath.Child("object")
+		allErrs = append(allErrs, v.validateObjectSpec(bundle.Namespace, res.Spec.Object, declaredReferences, policies, prevalidatePath)...)
+	case res.Spec.Plugin != nil:
+		prevalidatePath = specPath.Child("plugin", "spec")
+		if pluginContainer, ok := v.PluginContainers[res.Spec.Plugin.Name]; ok {
+			description := pluginContainer.Plugin.Describe()
+			if err := checkPluginObjectName(res.Spec.Plugin, description); err != nil {
+				allErrs = append(allErrs, field.Required(specPath.Child("plugin", "objectName"), ""))
+			}
+			if err := checkPluginInputs(res, description, bundle.Spec.Resources, v.PluginContainers); err != nil {
+				allErrs = append(allErrs, field.Invalid(path.Child("references"), omittedValue{}, err.Error()))
+			}
+		} else {
+			allErrs = append(allErrs, field.NotFound(specPath.Child("plugin", "name"), res.Spec.Plugin.Name))
+		}
+		if err := checkPluginPolicies(policies, res.Spec.Plugin.Name); err != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("plugin", "name"), err.Error()))
+		}
+		allErrs = append(allErrs, undeclaredReferences(res.Spec.Plugin.Spec, declaredReferences, prevalidatePath)...)
+	default:
+		return append(allErrs, field.Required(specPath, `either "object" or "plugin" field must be specified`))
+	}
+	if len(allErrs) > 0 {
+		// Schema validation would fail with less specific errors
+		return allErrs
+	}
+
+	status := prevalidate(v.Logger, res, v.PluginContainers, v.Scheme, v.Catalog)
+	if statusErr, ok := status.(resourceStatusError); ok {
+		if statusErr.isExternalError {
+			allErrs = append(allErrs, field.Invalid(prevalidatePath, omittedValue{}, statusErr.err.Error()))
+		} else {
+			// Not a problem with the Bundle. The controller reports the error if it persists
+			v.Logger.Error("Failed to validate resource spec, admitting the Bundle",
+				logz.Resource(res.Name), zap.Error(statusErr.err))
+		}
+	}
+	return allErrs
+}
+
+// validateObjectSpec performs the same checks for the object as the controller does after evaluating the spec.
+// Policies are checked again by the controller once references are resolved.
+func (v *BundleValidator) validateObjectSpec(namespace string, object runtime.Object, declaredReferences sets.String, policies []policy.Policy, path *field.Path) field.ErrorList {
+	obj, err := util.RuntimeToUnstructured(object)
+	if err != nil {
+		// Not a problem with the Bundle. The controller reports the error if it persists
+		v.Logger.Error("Failed to convert object, admitting the Bundle", zap.Error(err))
+		return nil
+	}
+	allErrs := undeclaredReferences(obj.Object, declaredReferences, path)
+
+	metaPath := path.Child("metadata")
+	if ns := obj.GetNamespace(); !isBundleNamespace(ns, namespace) {
+		allErrs = append(allErrs, field.Invalid(metaPath.Child("namespace"), ns,
+			fmt.Sprintf("must be empty or equal to the Bundle namespace %q", namespace)))
+	}
+	for _, key := range prohibitedAnnotationKeys(obj.GetAnnotations()) {
+		allErrs = append(allErrs, field.Forbidden(metaPath.Child("annotations").Key(key), "annotation cannot be set by the user"))
+	}
+	if i := controllerOwnerReferenceIndex(obj.GetOwnerReferences()); i >= 0 {
+		allErrs = append(allErrs, field.Forbidden(metaPath.Child("ownerReferences").Index(i).Child("controller"),
+			"cannot create resource with controller owner reference"))
+	}
+	if err := checkObjectPolicies(policies, obj); err != nil {
+		allErrs = append(allErrs, field.Forbidden(path, err.Error()))
+	}
+	return allErrs
+}
+
+// undeclaredReferences finds references that are used in the value but are not declared in the references block.
+func undeclaredReferences(value interface{}, declaredReferences sets.String, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch v := value.(type) {
+	case string:
+		match := reference.FindStringSubmatch(v)
+		if match != nil && !declaredReferences.Has(match[2]) {
+			allErrs = append(allErrs, field.Invalid(path, v, "reference does not exist in resource references block"))
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // for deterministic order of errors
+		for _, key := range keys {
+			allErrs = append(allErrs, undeclaredReferences(v[key], declaredReferences, path.Child(key))...)
+		}
+	case []interface{}:
+		for i, val := range v {
+			allErrs = append(allErrs, undeclaredReferences(val, declaredReferences, path.Index(i))...)
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,540 @@
+package bundlec
+
+import (
+	"testing"
+
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/policy"
+	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	validatorTestPlugin       smith_v1.PluginName = "validatorTestPlugin"
+	validatorInputsTestPlugin smith_v1.PluginName = "validatorInputsTestPlugin"
+)
+
+type validatorPlugin struct{}
+
+func (p *validatorPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: validatorTestPlugin,
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		SpecSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"p1": {
+					"type": "string"
+				}
+			}
+		}`),
+	}
+}
+
+func (p *validatorPlugin) Process(map[string]interface{}, *plugin.Context) plugin.ProcessResult {
+	return &plugin.ProcessResultFailure{
+		Error: errors.New("not implemented"),
+	}
+}
+
+type validatorInputsPlugin struct {
+	validatorPlugin
+}
+
+func (p *validatorInputsPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: validatorInputsTestPlugin,
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		Inputs: []plugin.Input{
+			{
+				Name:    "binding",
+				GVKs:    []schema.GroupVersionKind{sc_v1b1.SchemeGroupVersion.WithKind("ServiceBinding")},
+				Outputs: []schema.GroupVersionKind{core_v1.SchemeGroupVersion.WithKind("Secret")},
+			},
+			{
+				Name:     "optional",
+				Optional: true,
+			},
+		},
+	}
+}
+
+func TestBundleValidator(t *testing.T) {
+	t.Parallel()
+	pluginContainer, err := plugin.NewContainer(func() (plugin.Plugin, error) {
+		return &validatorPlugin{}, nil
+	})
+	require.NoError(t, err)
+	inputsPluginContainer, err := plugin.NewContainer(func() (plugin.Plugin, error) {
+		return &validatorInputsPlugin{}, nil
+	})
+	require.NoError(t, err)
+	v := &BundleValidator{
+		Logger: zap.NewNop(),
+		PluginContainers: map[smith_v1.PluginName]plugin.Container{
+			validatorTestPlugin:       pluginContainer,
+			validatorInputsTestPlugin: inputsPluginContainer,
+		},
+		Scheme: runtime.NewScheme(),
+	}
+
+	testCases := []struct {
+		name      string
+		resources []smith_v1.Resource
+		errors    []string
+	}{
+		{
+			name: "valid",
+			resources: []smith_v1.Resource{
+				configMapResource("a", nil),
+				{
+					Name: "b",
+					References: []smith_v1.Reference{
+						{Name: "aName", Resource: "a", Path: "metadata.name", Example: "name"},
+					},
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       validatorTestPlugin,
+							ObjectName: "b",
+							Spec: map[string]interface{}{
+								"p1": "!{aName}",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "duplicate names",
+			resources: []smith_v1.Resource{
+				configMapResource("a", nil),
+				configMapResource("a", nil),
+			},
+			errors: []string{
+				`spec.resources[1].name: Duplicate value: "a"`,
+			},
+		},
+		{
+			name: "unknown reference target",
+			resources: []smith_v1.Resource{
+				configMapResource("a", []smith_v1.Reference{{Resource: "x"}}),
+			},
+			errors: []string{
+				`spec.resources[0].references[0].resource: Not found: "x"`,
+			},
+		},
+		{
+			name: "cycle",
+			resources: []smith_v1.Resource{
+				configMapResource("a", []smith_v1.Reference{{Resource: "b"}}),
+				configMapResource("b", []smith_v1.Reference{{Resource: "a"}}),
+			},
+			errors: []string{
+				`spec.resources: Forbidden: cycle error: [a b a]`,
+			},
+		},
+		{
+			name: "undeclared reference",
+			resources: []smith_v1.Resource{
+				configMapResource("a", nil),
+				{
+					Name: "b",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "b",
+							},
+							Data: map[string]string{
+								"x": "!{aName}",
+							},
+						},
+					},
+				},
+			},
+			errors: []string{
+				`spec.resources[1].spec.object.data.x: Invalid value: "!{aName}": reference does not exist in resource references block`,
+			},
+		},
+		{
+			name: "wrong namespace and prohibited annotation",
+			resources: []smith_v1.Resource{
+				{
+					Name: "a",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name:      "a",
+								Namespace: "other",
+								Annotations: map[string]string{
+									smith.DeletionTimestampAnnotation: "now",
+								},
+							},
+						},
+					},
+				},
+			},
+			errors: []string{
+				`spec.resources[0].spec.object.metadata.namespace: Invalid value: "other": must be empty or equal to the Bundle namespace "ns"`,
+				`spec.resources[0].spec.object.metadata.annotations[` + smith.DeletionTimestampAnnotation + `]: Forbidden: annotation cannot be set by the user`,
+			},
+		},
+		{
+			name: "unknown plugin",
+			resources: []smith_v1.Resource{
+				{
+					Name: "a",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "unknown",
+							ObjectName: "a",
+						},
+					},
+				},
+			},
+			errors: []string{
+				`spec.resources[0].spec.plugin.name: Not found: "unknown"`,
+			},
+		},
+		{
+			name: "plugin inputs satisfied",
+			resources: []smith_v1.Resource{
+				serviceBindingResource("a"),
+				inputsPluginResource("b", []smith_v1.Reference{{Name: "binding", Resource: "a"}}),
+			},
+		},
+		{
+			name: "plugin input missing",
+			resources: []smith_v1.Resource{
+				serviceBindingResource("a"),
+				inputsPluginResource("b", []smith_v1.Reference{{Name: "optional", Resource: "a"}}),
+			},
+			errors: []string{
+				`spec.resources[1].references: Invalid value: ...: plugin "validatorInputsTestPlugin" requires reference "binding"`,
+			},
+		},
+		{
+			name: "plugin input of unexpected kind",
+			resources: []smith_v1.Resource{
+				configMapResource("a", nil),
+				inputsPluginResource("b", []smith_v1.Reference{{Name: "binding", Resource: "a"}}),
+			},
+			errors: []string{
+				`spec.resources[1].references: Invalid value: ...: reference "binding" of plugin "validatorInputsTestPlugin" must refer to a resource of kind [servicecatalog.k8s.io/v1beta1, Kind=ServiceBinding], got /v1, Kind=ConfigMap`,
+			},
+		},
+		{
+			name: "plugin object name",
+			resources: []smith_v1.Resource{
+				{
+					Name: "a",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name: validatorTestPlugin,
+						},
+					},
+				},
+			},
+			errors: []string{
+				`spec.resources[0].spec.plugin.objectName: Required value`,
+			},
+		},
+		{
+			name: "plugin spec schema",
+			resources: []smith_v1.Resource{
+				{
+					Name: "a",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       validatorTestPlugin,
+							ObjectName: "a",
+							Spec: map[string]interface{}{
+								"p1": true,
+							},
+						},
+					},
+				},
+			},
+			errors: []string{
+				`spec.resources[0].spec.plugin.spec: Invalid value: ...: spec failed validation against schema: p1: Invalid type. Expected: string, given: boolean`,
+			},
+		},
+		{
+			name: "secret reference in a Secret",
+			resources: []smith_v1.Resource{
+				serviceBindingResource("binding"),
+				secretResource("a", map[string]string{
+					"password": "!{password}",
+				}),
+			},
+		},
+		{
+			name: "secret reference in a ConfigMap",
+			resources: []smith_v1.Resource{
+				serviceBindingResource("binding"),
+				{
+					Name:       "a",
+					References: []smith_v1.Reference{secretReference("data.password")},
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "a",
+							},
+							Data: map[string]string{
+								"x": "!{password}",
+							},
+						},
+					},
+				},
+			},
+			errors: []string{
+				`spec.resources[1].spec.object.data.x: Forbidden: secret reference !{password} cannot be used here: ` + secretPlacementMessage,
+			},
+		},
+		{
+			name: "secret reference in a spec of a plugin that does not produce Secrets",
+			resources: []smith_v1.Resource{
+				serviceBindingResource("binding"),
+				{
+					Name:       "a",
+					References: []smith_v1.Reference{secretReference("data.password")},
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       validatorTestPlugin,
+							ObjectName: "a",
+							Spec: map[string]interface{}{
+								"p1": "!{password}",
+							},
+						},
+					},
+				},
+			},
+			errors: []string{
+				`spec.resources[1].spec.plugin.spec.p1: Forbidden: secret reference !{password} cannot be used here: ` + secretPlacementMessage,
+			},
+		},
+		{
+			name: "secret reference path",
+			resources: []smith_v1.Resource{
+				serviceBindingResource("binding"),
+				{
+					Name:       "a",
+					References: []smith_v1.Reference{secretReference("status.password")},
+					Spec:       secretResource("a", nil).Spec,
+				},
+			},
+			errors: []string{
+				`spec.resources[1].references[0].path: Invalid value: "status.password": path of "secret" reference "password" must be "data." followed by a key of the Secret`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			allErrs := v.Validate(&smith_v1.Bundle{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "bundle",
+					Namespace: "ns",
+				},
+				Spec: smith_v1.BundleSpec{
+					Resources: tc.resources,
+				},
+			})
+			var errs []string
+			for _, err := range allErrs {
+				errs = append(errs, err.Error())
+			}
+			assert.Equal(t, tc.errors, errs)
+		})
+	}
+}
+
+func configMapResource(name smith_v1.ResourceName, references []smith_v1.Reference) smith_v1.Resource {
+	return smith_v1.Resource{
+		Name:       name,
+		References: references,
+		Spec: smith_v1.ResourceSpec{
+			Object: &core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: string(name),
+				},
+			},
+		},
+	}
+}
+
+type fakePolicyStore []policy.Policy
+
+func (s fakePolicyStore) PoliciesFor(namespace string) ([]policy.Policy, error) {
+	return s, nil
+}
+
+func TestBundleValidatorPolicies(t *testing.T) {
+	t.Parallel()
+	pluginContainer, err := plugin.NewContainer(func() (plugin.Plugin, error) {
+		return &validatorPlugin{}, nil
+	})
+	require.NoError(t, err)
+	maxResources := int32(1)
+	v := &BundleValidator{
+		Logger: zap.NewNop(),
+		PluginContainers: map[smith_v1.PluginName]plugin.Container{
+			validatorTestPlugin: pluginContainer,
+		},
+		Scheme: runtime.NewScheme(),
+		Policies: fakePolicyStore{
+			policy.FromBundlePolicy(&smith_v1.BundlePolicy{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "p",
+					Namespace: "ns",
+				},
+				Spec: smith_v1.BundlePolicySpec{
+					DeniedKinds: []smith_v1.KindSelector{
+						{Group: "", Kind: "ConfigMap"},
+					},
+					MaxResources:   &maxResources,
+					AllowedPlugins: []smith_v1.PluginName{"other"},
+				},
+			}),
+		},
+	}
+	allErrs := v.Validate(&smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle",
+			Namespace: "ns",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				configMapResource("a", nil),
+				{
+					Name: "b",
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       validatorTestPlugin,
+							ObjectName: "b",
+						},
+					},
+				},
+			},
+		},
+	})
+	var errs []string
+	for _, err := range allErrs {
+		errs = append(errs, err.Error())
+	}
+	assert.Equal(t, []string{
+		`spec.resources: Forbidden: Bundle has 2 resources but BundlePolicy "ns/p" allows at most 1`,
+		`spec.resources[0].spec.object: Forbidden: /v1, Kind=ConfigMap is denied by BundlePolicy "ns/p"`,
+		`spec.resources[1].spec.plugin.name: Forbidden: plugin "validatorTestPlugin" is not allowed by BundlePolicy "ns/p"`,
+	}, errs)
+}
+
+type failingPolicyStore struct{}
+
+func (failingPolicyStore) PoliciesFor(namespace string) ([]policy.Policy, error) {
+	return nil, errors.New("informer is not synced")
+}
+
+func TestBundleValidatorAdmitsOnInternalError(t *testing.T) {
+	t.Parallel()
+	v := &BundleValidator{
+		Logger:   zap.NewNop(),
+		Scheme:   runtime.NewScheme(),
+		Policies: failingPolicyStore{},
+	}
+	allErrs := v.Validate(&smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "bundle",
+			Namespace: "ns",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				configMapResource("a", nil),
+			},
+		},
+	})
+	assert.Empty(t, allErrs)
+}
+
+func serviceBindingResource(name smith_v1.ResourceName) smith_v1.Resource {
+	return smith_v1.Resource{
+		Name: name,
+		Spec: smith_v1.ResourceSpec{
+			Object: &sc_v1b1.ServiceBinding{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "ServiceBinding",
+					APIVersion: sc_v1b1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: string(name),
+				},
+			},
+		},
+	}
+}
+
+func inputsPluginResource(name smith_v1.ResourceName, references []smith_v1.Reference) smith_v1.Resource {
+	return smith_v1.Resource{
+		Name:       name,
+		References: references,
+		Spec: smith_v1.ResourceSpec{
+			Plugin: &smith_v1.PluginSpec{
+				Name:       validatorInputsTestPlugin,
+				ObjectName: string(name),
+			},
+		},
+	}
+}
+
+func secretResource(name smith_v1.ResourceName, stringData map[string]string) smith_v1.Resource {
+	return smith_v1.Resource{
+		Name:       name,
+		References: []smith_v1.Reference{secretReference("data.password")},
+		Spec: smith_v1.ResourceSpec{
+			Object: &core_v1.Secret{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "Secret",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: string(name),
+				},
+				StringData: stringData,
+			},
+		},
+	}
+}
+
+func secretReference(path string) smith_v1.Reference {
+	return smith_v1.Reference{
+		Name:     "password",
+		Resource: "binding",
+		Path:     path,
+		Modifier: smith_v1.ReferenceModifierSecret,
+	}
+}
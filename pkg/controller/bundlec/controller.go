@@ -2,6 +2,7 @@ package bundlec
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,8 +10,10 @@ import (
 	"github.com/atlassian/ctrl"
 	"github.com/atlassian/ctrl/handlers"
 	"github.com/atlassian/ctrl/logz"
+	"github.com/atlassian/smith"
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	smithClient_v1 "github.com/atlassian/smith/pkg/client/clientset_generated/clientset/typed/smith/v1"
+	"github.com/atlassian/smith/pkg/client/multins"
 	"github.com/atlassian/smith/pkg/plugin"
 	"github.com/atlassian/smith/pkg/statuschecker"
 	"github.com/atlassian/smith/pkg/store"
@@ -20,6 +23,7 @@ import (
 	"go.uber.org/zap"
 	apps_v1 "k8s.io/api/apps/v1"
 	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -34,6 +38,12 @@ const (
 	bySecretNamespaceNameIndexName    = "BySecret"
 )
 
+var (
+	deploymentGVK = apps_v1.SchemeGroupVersion.WithKind("Deployment")
+	replicaSetGVK = apps_v1.SchemeGroupVersion.WithKind("ReplicaSet")
+	podGVK        = core_v1.SchemeGroupVersion.WithKind("Pod")
+)
+
 type byIndexFunc func(indexName, indexKey string) ([]interface{}, error)
 type indexKeyFunc func(namespace, name string) string
 
@@ -42,6 +52,8 @@ type Controller struct {
 	// to start an Informer. This is a data race. This mutex is used to ensure ordering.
 	// See https://github.com/atlassian/smith/issues/156
 	// See https://github.com/golang/go/blob/fbc8973a6bc88b50509ea738f475b36ef756bf90/src/sync/waitgroup.go#L123-L126
+	// Lock order: dynamicInformers.mx must not be acquired while holding wgLock because dynamicInformers
+	// acquires wgLock while holding its mx to start informers.
 	wgLock   sync.Mutex
 	wg       wait.Group
 	stopping bool
@@ -49,6 +61,8 @@ type Controller struct {
 	crdContext       context.Context
 	crdContextCancel context.CancelFunc
 
+	dynamicInformers *dynamicInformers
+
 	Logger *zap.Logger
 
 	ReadyForWork func()
@@ -61,18 +75,52 @@ type Controller struct {
 	SpecChecker  SpecChecker
 	WorkQueue    ctrl.WorkQueueProducer
 
+	// ImpersonatingSmartClient is optional. If set, objects of Bundles are managed on behalf of Bundles' authors.
+	ImpersonatingSmartClient ImpersonatingSmartClient
+	// AccessReviewer is optional. If set, authors of Bundles must be allowed to create, update and delete
+	// objects of Bundles. Access is checked before each operation.
+	AccessReviewer AccessReviewer
+	// PolicyStore is optional. If set, Bundles must satisfy policies that apply to them.
+	PolicyStore PolicyStore
+	// RESTMapper is optional. If set, it is used to determine if objects are namespaced or cluster-scoped.
+	// All objects are considered namespaced if it is not set.
+	RESTMapper meta.RESTMapper
+	// ClusterScopedObjects enables management of cluster-scoped objects. Requires RESTMapper and PolicyStore.
+	// Bundles can only contain cluster-scoped objects if a policy that applies to them allows it.
+	ClusterScopedObjects bool
+	// ManagedByLabel enables labelling of all managed objects with smith.ManagedByLabel.
+	ManagedByLabel bool
+	// ManagedObjectsOnly restricts informers for custom resources to objects labelled with smith.ManagedByLabel.
+	ManagedObjectsOnly bool
+	// DynamicInformers enables starting informers on demand for kinds that Bundles use but that are not
+	// watched by informers passed to Prepare. Requires RESTMapper.
+	DynamicInformers bool
+
 	// CRD
 	CrdResyncPeriod time.Duration
 	Namespace       string
+	// Namespaces is optional. If set, the controller watches a set of namespaces rather than Namespace
+	// and Bundles in other namespaces are ignored.
+	Namespaces *multins.Namespaces
 
 	PluginContainers map[smith_v1.PluginName]plugin.Container
-	Scheme           *runtime.Scheme
+	// PluginTimeout is the deadline for each plugin invocation. Plugins that do not return within the deadline
+	// fail the resource with a retriable error. Zero means no deadline.
+	PluginTimeout time.Duration
+	// PluginConcurrency is the maximum number of concurrent invocations of each plugin.
+	// DefaultPluginConcurrency is used if not set.
+	PluginConcurrency int
+	pluginLimiters    map[smith_v1.PluginName]*pluginLimiter
+	Scheme            *runtime.Scheme
 
 	Catalog *store.Catalog
 
 	// Metrics
 	BundleTransitionCounter         *prometheus.CounterVec
 	BundleResourceTransitionCounter *prometheus.CounterVec
+	// PluginProcessDuration and PluginErrorCounter are optional.
+	PluginProcessDuration *prometheus.HistogramVec
+	PluginErrorCounter    *prometheus.CounterVec
 
 	Broadcaster record.EventBroadcaster
 	Recorder    record.EventRecorder
@@ -80,12 +128,36 @@ type Controller struct {
 
 // Prepare prepares the controller to be run.
 func (c *Controller) Prepare(crdInf cache.SharedIndexInformer, resourceInfs map[schema.GroupVersionKind]cache.SharedIndexInformer) error {
+	if c.DynamicInformers && c.RESTMapper == nil {
+		return errors.New("dynamic informers require a REST mapper")
+	}
+	if c.ClusterScopedObjects && c.PolicyStore == nil {
+		return errors.New("management of cluster-scoped objects requires policies")
+	}
+	bundleInf, ok := resourceInfs[smith_v1.BundleGVK]
+	if !ok {
+		return errors.New("informer for Bundles is required")
+	}
+	// Informers for served versions of CRDs are started on demand even if dynamic informers are disabled
+	c.dynamicInformers = newDynamicInformers(c, c.DynamicInformers)
+	// Informers used by a Bundle are released when it is deleted
+	bundleInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: c.dynamicInformers.onBundleDelete,
+	})
 	c.crdContext, c.crdContextCancel = context.WithCancel(context.Background())
+	pluginConcurrency := c.PluginConcurrency
+	if pluginConcurrency <= 0 {
+		pluginConcurrency = DefaultPluginConcurrency
+	}
+	c.pluginLimiters = make(map[smith_v1.PluginName]*pluginLimiter, len(c.PluginContainers))
+	for name := range c.PluginContainers {
+		c.pluginLimiters[name] = newPluginLimiter(pluginConcurrency)
+	}
 	crdInf.AddEventHandler(&crdEventHandler{
 		controller: c,
 		watchers:   make(map[string]watchState),
 	})
-	deploymentInf := resourceInfs[apps_v1.SchemeGroupVersion.WithKind("Deployment")]
+	deploymentInf := resourceInfs[deploymentGVK]
 	err := deploymentInf.AddIndexers(cache.Indexers{
 		byConfigMapNamespaceNameIndexName: deploymentByConfigMapNamespaceNameIndex,
 		bySecretNamespaceNameIndexName:    deploymentBySecretNamespaceNameIndex,
@@ -112,6 +184,20 @@ func (c *Controller) Prepare(crdInf cache.SharedIndexInformer, resourceInfs map[
 		Gvk:       secretGVK,
 		Lookup:    c.lookupBundleByObjectByIndex(deploymentByIndex, bySecretNamespaceNameIndexName, byNamespaceNameIndexKey),
 	})
+	// ReplicaSet -> Deployment -> Bundle and Pod -> ReplicaSet -> Deployment -> Bundle event propagation
+	// so that status of Deployments is re-checked when their ReplicaSets/Pods change.
+	for _, gvk := range []schema.GroupVersionKind{replicaSetGVK, podGVK} {
+		inf, ok := resourceInfs[gvk]
+		if !ok {
+			continue
+		}
+		inf.AddEventHandler(&handlers.LookupHandler{
+			Logger:    c.Logger,
+			WorkQueue: c.WorkQueue,
+			Gvk:       gvk,
+			Lookup:    c.lookupBundleByDeploymentControlledObject,
+		})
+	}
 	serviceInstanceInf, ok := resourceInfs[sc_v1b1.SchemeGroupVersion.WithKind("ServiceInstance")]
 	if ok { // Service Catalog support is enabled
 		// Secret -> ServiceInstance -> Bundle event propagation
@@ -146,20 +232,42 @@ func (c *Controller) Prepare(crdInf cache.SharedIndexInformer, resourceInfs map[
 	}
 	// Standard handler
 	for gvk, resourceInf := range resourceInfs {
-		resourceInf.AddEventHandler(&handlers.ControlledResourceHandler{
-			Logger:          c.Logger,
-			WorkQueue:       c.WorkQueue,
-			ControllerIndex: &controllerIndexAdapter{bundleStore: c.BundleStore},
-			ControllerGvk:   smith_v1.BundleGVK,
-			Gvk:             gvk,
-		})
+		c.addResourceHandlers(gvk, resourceInf)
 	}
 	return nil
 }
 
+// addResourceHandlers adds handlers that re-process Bundles when objects they manage change.
+func (c *Controller) addResourceHandlers(gvk schema.GroupVersionKind, inf cache.SharedIndexInformer) {
+	inf.AddEventHandler(&handlers.ControlledResourceHandler{
+		Logger:          c.Logger,
+		WorkQueue:       c.WorkQueue,
+		ControllerIndex: &controllerIndexAdapter{bundleStore: c.BundleStore},
+		ControllerGvk:   smith_v1.BundleGVK,
+		Gvk:             gvk,
+	})
+	// Objects looked up by plugins
+	inf.AddEventHandler(&handlers.LookupHandler{
+		Logger:    c.Logger,
+		WorkQueue: c.WorkQueue,
+		Gvk:       gvk,
+		Lookup:    c.lookupBundlesByLookedUpObject(gvk.GroupKind()),
+	})
+	if c.ClusterScopedObjects {
+		// Cluster-scoped objects do not have controller owner references to Bundles
+		inf.AddEventHandler(&handlers.LookupHandler{
+			Logger:    c.Logger,
+			WorkQueue: c.WorkQueue,
+			Gvk:       gvk,
+			Lookup:    c.lookupBundleByClusterScopedObject,
+		})
+	}
+}
+
 // Run begins watching and syncing.
 // All informers must be synced before this method is invoked.
 func (c *Controller) Run(ctx context.Context) {
+	defer c.closePlugins()
 	defer c.wg.Wait()
 	defer c.crdContextCancel() // should be executed after stopping is set to true
 	defer func() {
@@ -182,6 +290,84 @@ func (c *Controller) Run(ctx context.Context) {
 	<-ctx.Done()
 }
 
+// closePlugins stops out-of-process plugins once the controller has stopped.
+func (c *Controller) closePlugins() {
+	for name, pluginContainer := range c.PluginContainers {
+		if err := pluginContainer.Close(); err != nil {
+			c.Logger.Warn("Failed to close plugin", zap.String("plugin", string(name)), zap.Error(err))
+		}
+	}
+}
+
+// PreparePolicies makes the controller re-process Bundles when policies that apply to them change.
+// Should be called after Prepare if PolicyStore is set.
+func (c *Controller) PreparePolicies(policyInf, clusterPolicyInf cache.SharedIndexInformer) {
+	policyInf.AddEventHandler(&handlers.LookupHandler{
+		Logger:    c.Logger,
+		WorkQueue: c.WorkQueue,
+		Gvk:       smith_v1.BundlePolicyGVK,
+		Lookup:    c.lookupBundlesByPolicy,
+	})
+	clusterPolicyInf.AddEventHandler(&handlers.LookupHandler{
+		Logger:    c.Logger,
+		WorkQueue: c.WorkQueue,
+		Gvk:       smith_v1.ClusterBundlePolicyGVK,
+		Lookup:    c.lookupBundlesByPolicy,
+	})
+}
+
+// lookupBundlesByPolicy returns Bundles the policy applies to.
+// Cluster-scoped policies do not have a namespace so they apply to all Bundles.
+func (c *Controller) lookupBundlesByPolicy(obj runtime.Object) ([]runtime.Object, error) {
+	bundles, err := c.BundleStore.GetBundlesByNamespace(obj.(meta_v1.Object).GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	result := make([]runtime.Object, 0, len(bundles))
+	for _, bundle := range bundles {
+		result = append(result, bundle)
+	}
+	return result, nil
+}
+
+// lookupBundlesByLookedUpObject returns a function that looks up Bundles with plugins that looked up the object.
+func (c *Controller) lookupBundlesByLookedUpObject(gk schema.GroupKind) func(runtime.Object) ([]runtime.Object, error) {
+	return func(obj runtime.Object) ([]runtime.Object /*bundles*/, error) {
+		objMeta := obj.(meta_v1.Object)
+		bundles, err := c.BundleStore.GetBundlesByLookup(gk, objMeta.GetNamespace(), objMeta.GetName())
+		if err != nil {
+			return nil, err
+		}
+		result := make([]runtime.Object, 0, len(bundles))
+		for _, bundle := range bundles {
+			result = append(result, bundle)
+		}
+		return result, nil
+	}
+}
+
+// lookupBundleByClusterScopedObject returns the Bundle that manages the cluster-scoped object.
+// The Bundle is identified by the annotation and the label set on the object.
+func (c *Controller) lookupBundleByClusterScopedObject(obj runtime.Object) ([]runtime.Object /*bundles*/, error) {
+	objMeta := obj.(meta_v1.Object)
+	if objMeta.GetNamespace() != meta_v1.NamespaceNone {
+		return nil, nil
+	}
+	uid, ok := objMeta.GetLabels()[smith.BundleUIDLabel]
+	if !ok {
+		return nil, nil
+	}
+	namespaceAndName := strings.SplitN(objMeta.GetAnnotations()[smith.BundleAnnotation], "/", 2)
+	if len(namespaceAndName) != 2 {
+		return nil, nil
+	}
+	bundle, err := c.BundleStore.Get(namespaceAndName[0], namespaceAndName[1])
+	if err != nil || bundle == nil || string(bundle.UID) != uid {
+		return nil, err
+	}
+	return []runtime.Object{bundle}, nil
+}
+
 // lookupBundleByObjectByIndex returns a function that can be used to perform lookups of Bundles that contain
 // objects returned from an index.
 func (c *Controller) lookupBundleByObjectByIndex(byIndex byIndexFunc, indexName string, indexKey indexKeyFunc) func(runtime.Object) ([]runtime.Object, error) {
@@ -230,6 +416,32 @@ func (c *Controller) lookupBundleByObjectByIndex(byIndex byIndexFunc, indexName
 	}
 }
 
+// lookupBundleByDeploymentControlledObject looks up Bundles that contain a Deployment which (transitively)
+// controls the object. The object can be a ReplicaSet or a Pod.
+func (c *Controller) lookupBundleByDeploymentControlledObject(obj runtime.Object) ([]runtime.Object /*bundles*/, error) {
+	objMeta := obj.(meta_v1.Object)
+	ref := meta_v1.GetControllerOf(objMeta)
+	if ref != nil && ref.Kind == replicaSetGVK.Kind && ref.APIVersion == replicaSetGVK.GroupVersion().String() {
+		rs, exists, err := c.Store.GetReadOnly(replicaSetGVK, objMeta.GetNamespace(), ref.Name)
+		if err != nil || !exists {
+			return nil, err
+		}
+		ref = meta_v1.GetControllerOf(rs.(meta_v1.Object))
+	}
+	if ref == nil || ref.Kind != deploymentGVK.Kind || ref.APIVersion != deploymentGVK.GroupVersion().String() {
+		return nil, nil
+	}
+	bundlesForObject, err := c.BundleStore.GetBundlesByObject(deploymentGVK.GroupKind(), objMeta.GetNamespace(), ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	bundles := make([]runtime.Object, 0, len(bundlesForObject))
+	for _, bundle := range bundlesForObject {
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
 type controllerIndexAdapter struct {
 	bundleStore BundleStore
 }
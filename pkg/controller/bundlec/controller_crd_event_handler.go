@@ -4,22 +4,31 @@ import (
 	"context"
 
 	"github.com/atlassian/ctrl"
-	"github.com/atlassian/ctrl/handlers"
 	ctrlLogz "github.com/atlassian/ctrl/logz"
 	"github.com/atlassian/smith"
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/client/multins"
 	"github.com/atlassian/smith/pkg/resources"
+	"github.com/atlassian/smith/pkg/store"
 	"go.uber.org/zap"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
 )
 
 type watchState struct {
 	cancel context.CancelFunc
+	// gvk is the GVK custom resources are watched with.
+	gvk schema.GroupVersionKind
+	// versions are served versions of the CRD. Custom resources of versions other than the one in gvk are
+	// watched by dynamic informers started on demand.
+	versions []string
+	// nsInformer is the informer if a set of namespaces is watched. nil otherwise.
+	nsInformer *multins.Informer
 }
 
 // crdEventHandler handles events for objects with Kind: CustomResourceDefinition.
@@ -33,7 +42,7 @@ type crdEventHandler struct {
 // Any CRDs that are not established and/or haven't had their names accepted are ignored.
 // This is necessary to wait until a CRD has been processed by the CRD controller. Also see OnUpdate.
 func (h *crdEventHandler) OnAdd(obj interface{}) {
-	crd := obj.(*apiext_v1b1.CustomResourceDefinition)
+	crd := obj.(*apiext_v1.CustomResourceDefinition)
 	logger := h.loggerForCRD(crd)
 	if !supportEnabled(crd) {
 		logger.Sugar().Debugf("Not setting up watch for CRD because %s annotation is not set to 'true'", smith.CrdSupportEnabled)
@@ -52,7 +61,7 @@ func (h *crdEventHandler) OnAdd(obj interface{}) {
 // then a watch is established. This is necessary to wait until a CRD has been processed by the CRD controller and
 // to pick up fixes for invalid/conflicting CRDs.
 func (h *crdEventHandler) OnUpdate(oldObj, newObj interface{}) {
-	newCrd := newObj.(*apiext_v1b1.CustomResourceDefinition)
+	newCrd := newObj.(*apiext_v1.CustomResourceDefinition)
 	logger := h.loggerForCRD(newCrd)
 	if !supportEnabled(newCrd) {
 		h.ensureNoWatch(logger, newCrd)
@@ -64,14 +73,14 @@ func (h *crdEventHandler) OnUpdate(oldObj, newObj interface{}) {
 }
 
 func (h *crdEventHandler) OnDelete(obj interface{}) {
-	crd, ok := obj.(*apiext_v1b1.CustomResourceDefinition)
+	crd, ok := obj.(*apiext_v1.CustomResourceDefinition)
 	if !ok {
 		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
 		if !ok {
 			h.controller.Logger.Sugar().Errorf("Delete event with unrecognized object type: %T", obj)
 			return
 		}
-		crd, ok = tombstone.Obj.(*apiext_v1b1.CustomResourceDefinition)
+		crd, ok = tombstone.Obj.(*apiext_v1.CustomResourceDefinition)
 		if !ok {
 			h.controller.Logger.Sugar().Errorf("Delete tombstone with unrecognized object type: %T", tombstone.Obj)
 			return
@@ -85,85 +94,114 @@ func (h *crdEventHandler) OnDelete(obj interface{}) {
 }
 
 // ensureWatch ensures there is a watch for CRs of a CRD.
+// Custom resources are watched with the storage version if it is served or with the preferred served version
+// otherwise. Informers for other served versions are started when a Bundle uses them.
+// If served versions of the CRD have changed since the watch was set up, the watch is re-established.
 // Returns true if a watch was found or set up successfully and false if there is no watch and it was not set up for
 // some reason.
-func (h *crdEventHandler) ensureWatch(logger *zap.Logger, crd *apiext_v1b1.CustomResourceDefinition) bool {
+func (h *crdEventHandler) ensureWatch(logger *zap.Logger, crd *apiext_v1.CustomResourceDefinition) bool {
 	if crd.Name == smith_v1.BundleResourceName {
 		return false
 	}
-	if _, ok := h.watchers[crd.Name]; ok {
-		return true
+	version := resources.CrdWatchVersion(crd)
+	gvk := schema.GroupVersionKind{
+		Group:   crd.Spec.Group,
+		Version: version,
+		Kind:    crd.Spec.Names.Kind,
+	}
+	versions := resources.CrdServedVersions(crd)
+	if crdWatch, ok := h.watchers[crd.Name]; ok {
+		if crdWatch.gvk == gvk && equalStrings(crdWatch.versions, versions) {
+			return true
+		}
+		logger.Info("Versions of CRD have changed, re-establishing watch",
+			zap.Stringer("old_gvk", crdWatch.gvk), zap.Stringer("new_gvk", gvk))
+		h.removeWatch(crd.Name, crdWatch)
+	}
+	if version == "" {
+		logger.Info("Not adding a watch for CRD because it does not have any served versions")
+		return false
 	}
-	if !resources.IsCrdConditionTrue(crd, apiext_v1b1.Established) {
+	if !resources.IsCrdConditionTrue(crd, apiext_v1.Established) {
 		logger.Info("Not adding a watch for CRD because it hasn't been established")
 		return false
 	}
-	if !resources.IsCrdConditionTrue(crd, apiext_v1b1.NamesAccepted) {
+	if !resources.IsCrdConditionTrue(crd, apiext_v1.NamesAccepted) {
 		logger.Info("Not adding a watch for CRD because its names haven't been accepted")
 		return false
 	}
-	gvk := schema.GroupVersionKind{
-		Group:   crd.Spec.Group,
-		Version: crd.Spec.Versions[0].Name,
-		Kind:    crd.Spec.Names.Kind,
-	}
-	logger.Info("Configuring watch for CRD")
-	res, err := h.controller.SmartClient.ForGVK(gvk, h.controller.Namespace)
+	logger.Info("Configuring watch for CRD", zap.Stringer("gvk", gvk))
+	// Objects of the CRD may have been watched by a dynamic informer before support was enabled
+	h.controller.dynamicInformers.stop(gvk)
+	crdInf, nsInf, err := h.controller.newResourceInformer(gvk, crd.Spec.Scope == apiext_v1.ClusterScoped)
 	if err != nil {
 		logger.Error("Failed to get client for CRD", zap.Error(err))
 		return false
 	}
-	crdInf := cache.NewSharedIndexInformer(&cache.ListWatch{
-		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
-			return res.List(options)
-		},
-		WatchFunc: res.Watch,
-	}, &unstructured.Unstructured{}, h.controller.CrdResyncPeriod, cache.Indexers{})
+	if !h.startWatch(logger, crd, gvk, versions, crdInf, nsInf) {
+		return false
+	}
+	otherVersions := make([]string, 0, len(versions))
+	for _, v := range versions {
+		if v != version {
+			otherVersions = append(otherVersions, v)
+		}
+	}
+	// Must be called without holding wgLock, see Controller.wgLock
+	h.controller.dynamicInformers.setCRDVersions(gvk.GroupKind(), otherVersions, crd.Spec.Scope == apiext_v1.ClusterScoped)
+	return true
+}
+
+// startWatch registers and starts the informer for CRs of a CRD.
+// Returns false if the controller is stopping or the informer cannot be registered.
+func (h *crdEventHandler) startWatch(logger *zap.Logger, crd *apiext_v1.CustomResourceDefinition, gvk schema.GroupVersionKind, versions []string, crdInf cache.SharedIndexInformer, nsInf *multins.Informer) bool {
 	h.controller.wgLock.Lock()
 	defer h.controller.wgLock.Unlock()
 	if h.controller.stopping {
+		h.controller.forgetInformer(nsInf)
 		return false
 	}
-	resourceHandler := &handlers.ControlledResourceHandler{
-		Logger:          h.controller.Logger,
-		WorkQueue:       h.controller.WorkQueue,
-		ControllerIndex: &controllerIndexAdapter{bundleStore: h.controller.BundleStore},
-		ControllerGvk:   smith_v1.BundleGVK,
-		Gvk:             gvk,
-	}
-	crdInf.AddEventHandler(resourceHandler)
-	err = h.controller.Store.AddInformer(gvk, crdInf)
+	h.controller.addResourceHandlers(gvk, crdInf)
+	err := h.controller.Store.AddInformer(gvk, crdInf)
 	if err != nil {
+		h.controller.forgetInformer(nsInf)
 		logger.Error("Failed to add informer for CRD to multisore", zap.Error(err))
 		return false
 	}
 	ctx, cancel := context.WithCancel(h.controller.crdContext)
-	h.watchers[crd.Name] = watchState{cancel: cancel}
+	h.watchers[crd.Name] = watchState{
+		cancel:     cancel,
+		gvk:        gvk,
+		versions:   versions,
+		nsInformer: nsInf,
+	}
 	h.controller.wg.StartWithChannel(ctx.Done(), crdInf.Run)
 	return true
 }
 
 // ensureNoWatch ensures there is no watch for CRs of a CRD.
 // Returns true if a watch was found and terminated and false if there was no watch already.
-func (h *crdEventHandler) ensureNoWatch(logger *zap.Logger, crd *apiext_v1b1.CustomResourceDefinition) bool {
+func (h *crdEventHandler) ensureNoWatch(logger *zap.Logger, crd *apiext_v1.CustomResourceDefinition) bool {
 	crdWatch, ok := h.watchers[crd.Name]
 	if !ok {
 		// Nothing to do. This can happen if there was an error adding a watch
 		return false
 	}
 	logger.Info("Removing watch for CRD")
-	crdWatch.cancel()
-	delete(h.watchers, crd.Name)
-	gvk := schema.GroupVersionKind{
-		Group:   crd.Spec.Group,
-		Version: crd.Spec.Versions[0].Name,
-		Kind:    crd.Spec.Names.Kind,
-	}
-	h.controller.Store.RemoveInformer(gvk)
+	h.removeWatch(crd.Name, crdWatch)
 	return true
 }
 
-func (h *crdEventHandler) rebuildBundles(logger *zap.Logger, crd *apiext_v1b1.CustomResourceDefinition, addUpdateDelete string) {
+func (h *crdEventHandler) removeWatch(crdName string, crdWatch watchState) {
+	crdWatch.cancel()
+	h.controller.forgetInformer(crdWatch.nsInformer)
+	delete(h.watchers, crdName)
+	// Version the CRD is watched with may be different from the one in the current CRD object
+	h.controller.Store.RemoveInformer(crdWatch.gvk)
+	h.controller.dynamicInformers.removeCRD(crdWatch.gvk.GroupKind())
+}
+
+func (h *crdEventHandler) rebuildBundles(logger *zap.Logger, crd *apiext_v1.CustomResourceDefinition, addUpdateDelete string) {
 	bundles, err := h.controller.BundleStore.GetBundlesByCrd(crd)
 	if err != nil {
 		logger.Error("Failed to get bundles by CRD name", zap.Error(err))
@@ -173,7 +211,7 @@ func (h *crdEventHandler) rebuildBundles(logger *zap.Logger, crd *apiext_v1b1.Cu
 		logger.With(
 			ctrlLogz.Namespace(bundle),
 			ctrlLogz.Object(bundle),
-			ctrlLogz.ObjectGk(apiext_v1b1.SchemeGroupVersion.WithKind("CustomResourceDefinition").GroupKind()),
+			ctrlLogz.ObjectGk(apiext_v1.SchemeGroupVersion.WithKind("CustomResourceDefinition").GroupKind()),
 		).Sugar().Infof("Rebuilding bundle because CRD was %s", addUpdateDelete)
 		h.controller.WorkQueue.Add(ctrl.QueueKey{
 			Namespace: bundle.Namespace,
@@ -182,12 +220,102 @@ func (h *crdEventHandler) rebuildBundles(logger *zap.Logger, crd *apiext_v1b1.Cu
 	}
 }
 
-func supportEnabled(crd *apiext_v1b1.CustomResourceDefinition) bool {
+// resourceListerWatcher is the subset of dynamic.ResourceInterface used by informers.
+type resourceListerWatcher interface {
+	List(opts meta_v1.ListOptions) (*unstructured.UnstructuredList, error)
+	Watch(opts meta_v1.ListOptions) (watch.Interface, error)
+}
+
+// newResourceInformer returns an informer for objects of the GVK in the watched namespaces.
+// If a set of namespaces is watched and objects are namespaced, the informer is also returned as nsInf
+// and it must be passed to forgetInformer when it is not used anymore.
+func (c *Controller) newResourceInformer(gvk schema.GroupVersionKind, clusterScoped bool) (inf cache.SharedIndexInformer, nsInf *multins.Informer, e error) {
+	if c.Namespaces == nil || clusterScoped {
+		res, err := c.SmartClient.ForGVK(gvk, c.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		return c.newInformer(res), nil, nil
+	}
+	nsInf = c.Namespaces.NewInformer(func(namespace string) cache.SharedIndexInformer {
+		return c.newInformer(&lazyResourceClient{
+			smartClient: c.SmartClient,
+			gvk:         gvk,
+			namespace:   namespace,
+		})
+	})
+	return nsInf, nsInf, nil
+}
+
+func (c *Controller) newInformer(res resourceListerWatcher) cache.SharedIndexInformer {
+	managedObjectsOnly := c.ManagedObjectsOnly
+	lw := store.NewTransformingListerWatcher(&cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			if managedObjectsOnly {
+				options.LabelSelector = smith.ManagedBySelector
+			}
+			return res.List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			if managedObjectsOnly {
+				options.LabelSelector = smith.ManagedBySelector
+			}
+			return res.Watch(options)
+		},
+	}, store.StripManagedFields)
+	return cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, c.CrdResyncPeriod, cache.Indexers{})
+}
+
+// forgetInformer makes sure the informer is not updated when the set of watched namespaces changes.
+func (c *Controller) forgetInformer(nsInf *multins.Informer) {
+	if nsInf != nil {
+		c.Namespaces.ForgetInformer(nsInf)
+	}
+}
+
+// lazyResourceClient gets a client for a namespace on each List and Watch call.
+// Namespaces are added after the watch for a CRD has been set up so errors getting a client
+// are returned to the informer to be retried rather than handled upfront.
+type lazyResourceClient struct {
+	smartClient SmartClient
+	gvk         schema.GroupVersionKind
+	namespace   string
+}
+
+func (c *lazyResourceClient) List(opts meta_v1.ListOptions) (*unstructured.UnstructuredList, error) {
+	res, err := c.smartClient.ForGVK(c.gvk, c.namespace)
+	if err != nil {
+		return nil, err
+	}
+	return res.List(opts)
+}
+
+func (c *lazyResourceClient) Watch(opts meta_v1.ListOptions) (watch.Interface, error) {
+	res, err := c.smartClient.ForGVK(c.gvk, c.namespace)
+	if err != nil {
+		return nil, err
+	}
+	return res.Watch(opts)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func supportEnabled(crd *apiext_v1.CustomResourceDefinition) bool {
 	return crd.Annotations[smith.CrdSupportEnabled] == "true"
 }
 
-func (h *crdEventHandler) loggerForCRD(obj *apiext_v1b1.CustomResourceDefinition) *zap.Logger {
+func (h *crdEventHandler) loggerForCRD(obj *apiext_v1.CustomResourceDefinition) *zap.Logger {
 	// No namespace
 	return h.controller.Logger.With(ctrlLogz.Object(obj),
-		ctrlLogz.ObjectGk(apiext_v1b1.SchemeGroupVersion.WithKind("CustomResourceDefinition").GroupKind()))
+		ctrlLogz.ObjectGk(apiext_v1.SchemeGroupVersion.WithKind("CustomResourceDefinition").GroupKind()))
 }
@@ -0,0 +1,264 @@
+package bundlec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/atlassian/ctrl"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/client/multins"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+// dynamicInformer is an informer that was started because a Bundle uses objects of its GVK.
+type dynamicInformer struct {
+	cancel     context.CancelFunc
+	informer   cache.SharedIndexInformer
+	nsInformer *multins.Informer
+	// users are Bundles that use objects of the GVK.
+	users map[ctrl.QueueKey]struct{}
+	// waiting are Bundles that should be re-processed once the informer has synced.
+	waiting map[ctrl.QueueKey]struct{}
+}
+
+// crdVersions are served versions of a CRD other than the one its custom resources are watched with.
+type crdVersions struct {
+	versions      sets.String
+	clusterScoped bool
+}
+
+// dynamicInformers starts informers for kinds that are not watched by informers configured upfront.
+// An informer is started when a Bundle needs to look up an object of its kind for the first time and
+// is stopped once no Bundle uses that kind anymore.
+// Informers are always started for served versions of watched CRDs because the API server converts
+// custom resources between versions. Informers for any other kind are only started if allKinds is true.
+// See docs/design/dynamic-informers.md
+type dynamicInformers struct {
+	controller *Controller
+	allKinds   bool
+	// mx is acquired before Controller.wgLock, never while holding it.
+	mx        sync.Mutex
+	informers map[schema.GroupVersionKind]*dynamicInformer
+	crds      map[schema.GroupKind]crdVersions
+}
+
+func newDynamicInformers(c *Controller, allKinds bool) *dynamicInformers {
+	return &dynamicInformers{
+		controller: c,
+		allKinds:   allKinds,
+		informers:  make(map[schema.GroupVersionKind]*dynamicInformer),
+		crds:       make(map[schema.GroupKind]crdVersions),
+	}
+}
+
+// setCRDVersions records served versions of a watched CRD other than the one its custom resources are watched
+// with. Informers for versions that are not served anymore are stopped.
+func (d *dynamicInformers) setCRDVersions(gk schema.GroupKind, versions []string, clusterScoped bool) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	served := sets.NewString(versions...)
+	d.crds[gk] = crdVersions{
+		versions:      served,
+		clusterScoped: clusterScoped,
+	}
+	for gvk, inf := range d.informers {
+		if gvk.GroupKind() == gk && !served.Has(gvk.Version) {
+			d.stopLocked(gvk, inf)
+		}
+	}
+}
+
+// removeCRD stops informers for all versions of a CRD that is not watched anymore.
+func (d *dynamicInformers) removeCRD(gk schema.GroupKind) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	delete(d.crds, gk)
+	for gvk, inf := range d.informers {
+		if gvk.GroupKind() == gk {
+			d.stopLocked(gvk, inf)
+		}
+	}
+}
+
+// ensure makes sure objects of the GVK can be looked up in the Store.
+// Returns a non-nil status if the informer for the GVK has not synced yet. The Bundle is re-processed once it has.
+func (d *dynamicInformers) ensure(logger *zap.Logger, gvk schema.GroupVersionKind, clusterScoped bool, bundle *smith_v1.Bundle) resourceStatus {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	inf, ok := d.informers[gvk]
+	if !ok {
+		if d.controller.Store.HasInformer(gvk) {
+			// Configured upfront or started for a CRD
+			return nil
+		}
+		if crd, ok := d.crds[gvk.GroupKind()]; ok && crd.versions.Has(gvk.Version) {
+			clusterScoped = crd.clusterScoped
+		} else if !d.allKinds {
+			// Not watched, the Store reports the error
+			return nil
+		}
+		var err error
+		inf, err = d.start(logger, gvk, clusterScoped)
+		if err != nil {
+			return resourceStatusError{
+				err:              err,
+				isRetriableError: true,
+			}
+		}
+	}
+	key := ctrl.QueueKey{
+		Namespace: bundle.Namespace,
+		Name:      bundle.Name,
+	}
+	inf.users[key] = struct{}{}
+	if inf.informer.HasSynced() {
+		return nil
+	}
+	inf.waiting[key] = struct{}{}
+	return resourceStatusInProgress{
+		message: fmt.Sprintf("Waiting for informer for %s to sync", gvk),
+	}
+}
+
+// start must be called with d.mx held.
+func (d *dynamicInformers) start(logger *zap.Logger, gvk schema.GroupVersionKind, clusterScoped bool) (*dynamicInformer, error) {
+	c := d.controller
+	inf, nsInf, err := c.newResourceInformer(gvk, clusterScoped)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create informer for %s", gvk)
+	}
+	c.wgLock.Lock()
+	defer c.wgLock.Unlock()
+	if c.stopping {
+		c.forgetInformer(nsInf)
+		return nil, errors.New("controller is stopping")
+	}
+	c.addResourceHandlers(gvk, inf)
+	err = c.Store.AddInformer(gvk, inf)
+	if err != nil {
+		c.forgetInformer(nsInf)
+		return nil, errors.Wrapf(err, "failed to add informer for %s to the Store", gvk)
+	}
+	ctx, cancel := context.WithCancel(c.crdContext)
+	di := &dynamicInformer{
+		cancel:     cancel,
+		informer:   inf,
+		nsInformer: nsInf,
+		users:      make(map[ctrl.QueueKey]struct{}),
+		waiting:    make(map[ctrl.QueueKey]struct{}),
+	}
+	d.informers[gvk] = di
+	logger.Info("Starting informer", zap.Stringer("gvk", gvk))
+	c.wg.StartWithChannel(ctx.Done(), inf.Run)
+	c.wg.StartWithChannel(ctx.Done(), func(stopCh <-chan struct{}) {
+		if cache.WaitForCacheSync(stopCh, inf.HasSynced) {
+			d.synced(gvk, di)
+		}
+	})
+	return di, nil
+}
+
+// synced re-processes Bundles that were waiting for the informer to sync.
+func (d *dynamicInformers) synced(gvk schema.GroupVersionKind, inf *dynamicInformer) {
+	d.mx.Lock()
+	if d.informers[gvk] != inf {
+		// Stopped already
+		d.mx.Unlock()
+		return
+	}
+	waiting := inf.waiting
+	inf.waiting = make(map[ctrl.QueueKey]struct{})
+	d.mx.Unlock()
+	for key := range waiting {
+		d.controller.WorkQueue.Add(key)
+	}
+}
+
+// release records that the Bundle only uses the GVKs in used, which may be nil if the Bundle has been deleted.
+// Informers for GVKs that are not used by any Bundle anymore are stopped.
+func (d *dynamicInformers) release(bundleKey ctrl.QueueKey, used map[schema.GroupVersionKind]struct{}) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	for gvk, inf := range d.informers {
+		if _, ok := used[gvk]; ok {
+			continue
+		}
+		delete(inf.users, bundleKey)
+		delete(inf.waiting, bundleKey)
+		if len(inf.users) == 0 {
+			d.stopLocked(gvk, inf)
+		}
+	}
+}
+
+// stop stops the informer for the GVK if there is one.
+func (d *dynamicInformers) stop(gvk schema.GroupVersionKind) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if inf, ok := d.informers[gvk]; ok {
+		d.stopLocked(gvk, inf)
+	}
+}
+
+func (d *dynamicInformers) stopLocked(gvk schema.GroupVersionKind, inf *dynamicInformer) {
+	d.controller.Logger.Info("Stopping informer", zap.Stringer("gvk", gvk))
+	inf.cancel()
+	d.controller.forgetInformer(inf.nsInformer)
+	d.controller.Store.RemoveInformer(gvk)
+	delete(d.informers, gvk)
+}
+
+// onBundleDelete releases all informers used by a deleted Bundle.
+func (d *dynamicInformers) onBundleDelete(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		d.controller.Logger.Error("Failed to get key for deleted Bundle", zap.Error(err))
+		return
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		d.controller.Logger.Error("Failed to split key of deleted Bundle", zap.Error(err))
+		return
+	}
+	d.release(ctrl.QueueKey{
+		Namespace: namespace,
+		Name:      name,
+	}, nil)
+}
+
+// usedGVKs returns GVKs of objects the Bundle defines or still has to delete.
+func (st *bundleSyncTask) usedGVKs() map[schema.GroupVersionKind]struct{} {
+	used := make(map[schema.GroupVersionKind]struct{})
+	for _, res := range st.bundle.Spec.Resources {
+		switch {
+		case res.Spec.Object != nil:
+			used[res.Spec.Object.GetObjectKind().GroupVersionKind()] = struct{}{}
+		case res.Spec.Plugin != nil:
+			if pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]; ok {
+				description := pluginContainer.Plugin.Describe()
+				for _, gvk := range description.OutputGVKs() {
+					used[gvk] = struct{}{}
+				}
+				for _, lookup := range description.Lookups {
+					used[lookup.GVK] = struct{}{}
+				}
+			}
+		}
+	}
+	for _, obj := range st.bundle.Status.ObjectsToDelete {
+		used[schema.GroupVersionKind{
+			Group:   obj.Group,
+			Version: obj.Version,
+			Kind:    obj.Kind,
+		}] = struct{}{}
+	}
+	for ref := range st.objectsToDelete {
+		used[ref.GroupVersionKind] = struct{}{}
+	}
+	return used
+}
@@ -7,7 +7,11 @@ import (
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	auth_v1 "k8s.io/api/authentication/v1"
 	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 )
 
 func (c *Controller) Process(pctx *ctrl.ProcessContext) (bool /*external*/, bool /*retriable*/, error) {
@@ -16,28 +20,51 @@ func (c *Controller) Process(pctx *ctrl.ProcessContext) (bool /*external*/, bool
 
 // ProcessBundle is only visible for testing purposes. Should not be called directly.
 func (c *Controller) ProcessBundle(logger *zap.Logger, bundle *smith_v1.Bundle) (bool /*external*/, bool /*retriable*/, error) {
+	if c.Namespaces != nil && !c.Namespaces.Matches(bundle.Namespace) {
+		// Bundle may have been queued before its namespace stopped matching
+		logger.Debug("Ignoring Bundle because its namespace is not watched")
+		return false, false, nil
+	}
 	st := bundleSyncTask{
 		logger:                          logger,
 		bundleClient:                    c.BundleClient,
-		smartClient:                     c.SmartClient,
+		restMapper:                      c.RESTMapper,
+		clusterScopedObjects:            c.ClusterScopedObjects,
+		managedByLabel:                  c.ManagedByLabel,
+		managedObjectsOnly:              c.ManagedObjectsOnly,
+		watchesNamespace:                c.watchesNamespace,
+		dynamicInformers:                c.dynamicInformers,
 		checker:                         c.Rc,
 		store:                           c.Store,
 		specChecker:                     c.SpecChecker,
+		policyStore:                     c.PolicyStore,
 		bundle:                          bundle,
 		pluginContainers:                c.PluginContainers,
 		scheme:                          c.Scheme,
 		catalog:                         c.Catalog,
 		bundleTransitionCounter:         c.BundleTransitionCounter,
 		bundleResourceTransitionCounter: c.BundleResourceTransitionCounter,
+		pluginProcessDuration:           c.PluginProcessDuration,
+		pluginErrorCounter:              c.PluginErrorCounter,
+		pluginTimeout:                   c.PluginTimeout,
+		pluginLimiters:                  c.pluginLimiters,
+		ctx:                             c.crdContext,
 		recorder:                        c.Recorder,
 	}
 
 	var external bool
 	var retriable bool
 	var err error
-	if st.bundle.DeletionTimestamp != nil {
+	st.smartClient, err = c.smartClientFor(bundle)
+	if err == nil {
+		st.accessChecker, err = c.accessCheckerFor(bundle)
+	}
+	switch {
+	case err != nil:
+		external = true
+	case st.bundle.DeletionTimestamp != nil:
 		external, retriable, err = st.processDeleted()
-	} else {
+	default:
 		external, retriable, err = st.processNormal()
 	}
 	if err != nil {
@@ -52,6 +79,11 @@ func (c *Controller) ProcessBundle(logger *zap.Logger, bundle *smith_v1.Bundle)
 	// Updates bundle status
 	handleProcessRetriable, handleProcessErr := st.handleProcessResult(retriable, err)
 
+	c.dynamicInformers.release(ctrl.QueueKey{
+		Namespace: bundle.Namespace,
+		Name:      bundle.Name,
+	}, st.usedGVKs())
+
 	// Inspect the resources for failures. They can fail for many different reasons.
 	// The priority of errors to bubble up to the ctrl layer are:
 	//  1. processDeleted/processNormal errors
@@ -95,3 +127,52 @@ func (c *Controller) ProcessBundle(logger *zap.Logger, bundle *smith_v1.Bundle)
 	// Otherwise, return the result from handleProcessResult
 	return false, handleProcessRetriable, handleProcessErr
 }
+
+// smartClientFor returns the client to manage objects of the Bundle with.
+func (c *Controller) smartClientFor(bundle *smith_v1.Bundle) (SmartClient, error) {
+	if c.ImpersonatingSmartClient == nil {
+		return c.SmartClient, nil
+	}
+	if bundle.Spec.Author == nil {
+		if bundle.DeletionTimestamp != nil {
+			// Only objects controlled by the Bundle are deleted, no need to impersonate anyone
+			return c.SmartClient, nil
+		}
+		return nil, errors.New("author of the Bundle is not set but is required for impersonation (is the mutating admission webhook configured?)")
+	}
+	return &impersonatingSmartClient{
+		client: c.ImpersonatingSmartClient,
+		user:   bundle.Spec.Author,
+	}, nil
+}
+
+// accessCheckerFor returns the checker for operations on objects of the Bundle.
+// nil is returned if access should not be checked.
+func (c *Controller) accessCheckerFor(bundle *smith_v1.Bundle) (*accessChecker, error) {
+	if c.AccessReviewer == nil || bundle.DeletionTimestamp != nil {
+		// Only objects controlled by the Bundle are deleted, no need to check access
+		return nil, nil
+	}
+	if bundle.Spec.Author == nil {
+		return nil, errors.New("author of the Bundle is not set but is required for access review (is the mutating admission webhook configured?)")
+	}
+	return newAccessChecker(c.AccessReviewer, bundle.Spec.Author), nil
+}
+
+// impersonatingSmartClient is a SmartClient that performs requests on behalf of a particular user.
+type impersonatingSmartClient struct {
+	client ImpersonatingSmartClient
+	user   *auth_v1.UserInfo
+}
+
+func (c *impersonatingSmartClient) ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	return c.client.ForGVKAs(c.user, gvk, namespace)
+}
+
+// watchesNamespace returns true if objects in the namespace are watched.
+func (c *Controller) watchesNamespace(namespace string) bool {
+	if c.Namespaces != nil {
+		return c.Namespaces.Matches(namespace)
+	}
+	return c.Namespace == meta_v1.NamespaceAll || c.Namespace == namespace
+}
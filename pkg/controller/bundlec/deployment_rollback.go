@@ -0,0 +1,187 @@
+package bundlec
+
+import (
+	"encoding/json"
+
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/specchecker"
+	"github.com/atlassian/smith/pkg/specchecker/builtin"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	deploymentProgressDeadlineExceededReason = "ProgressDeadlineExceeded"
+)
+
+func isDeploymentWithRollbackPolicy(obj *unstructured.Unstructured) bool {
+	return obj.GroupVersionKind().GroupKind() == deploymentGVK.GroupKind() &&
+		obj.GetAnnotations()[builtin.RollbackPolicyAnnotation] == builtin.RollbackPolicyLastReady
+}
+
+// checkDeploymentRolledBack returns an error status if the pod template of the Deployment from the Bundle
+// has been rolled back. Bundle stays in the error condition until the pod template is changed.
+func checkDeploymentRolledBack(actual *unstructured.Unstructured) resourceStatus {
+	if actual.GroupVersionKind().GroupKind() != deploymentGVK.GroupKind() ||
+		actual.GetAnnotations()[builtin.RolledBackTemplateHashAnnotation] == "" {
+		return nil
+	}
+	return resourceStatusError{
+		err:             errors.New("new pod template failed to roll out and has been rolled back to the last Ready one, update the pod template to try again"),
+		isExternalError: true,
+	}
+}
+
+// maybeRecordReadyTemplate stores the pod template of a Ready Deployment in an annotation if the rollback policy
+// is enabled for it.
+func (st *resourceSyncTask) maybeRecordReadyTemplate(actual *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if !isDeploymentWithRollbackPolicy(actual) {
+		return actual, nil
+	}
+	template, err := podTemplateJSON(actual)
+	if err != nil {
+		return nil, err
+	}
+	annotations := actual.GetAnnotations()
+	if annotations[builtin.LastReadyTemplateAnnotation] == template {
+		return actual, nil
+	}
+	updated := actual.DeepCopy()
+	annotations[builtin.LastReadyTemplateAnnotation] = template
+	updated.SetAnnotations(annotations)
+	updated, err = st.updateDeployment(updated)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to record last Ready pod template")
+	}
+	st.logger.Info("Recorded last Ready pod template")
+	return updated, nil
+}
+
+// maybeRollbackDeployment rolls the Deployment back to the last Ready pod template if it has exceeded its
+// progress deadline and the rollback policy is enabled for it.
+// spec must be the evaluated specification from the Bundle, not the object returned by the server, because the
+// rollback marker is compared with the hash of the specification on subsequent syncs.
+// Returns false if rollback has not been performed.
+func (st *resourceSyncTask) maybeRollbackDeployment(resName smith_v1.ResourceName, spec, actual *unstructured.Unstructured, statusErr error) (resourceInfo, bool /*rolledBack*/) {
+	if !isDeploymentWithRollbackPolicy(actual) {
+		return resourceInfo{}, false
+	}
+	var deployment apps_v1.Deployment
+	if err := util.ConvertType(st.scheme, actual, &deployment); err != nil {
+		return resourceInfo{
+			status: resourceStatusError{
+				err: err,
+			},
+		}, true
+	}
+	if !deploymentProgressDeadlineExceeded(&deployment) {
+		return resourceInfo{}, false
+	}
+	lastReadyTemplate := deployment.Annotations[builtin.LastReadyTemplateAnnotation]
+	if lastReadyTemplate == "" {
+		// Has never been Ready, nothing to roll back to
+		return resourceInfo{}, false
+	}
+	currentTemplate, err := podTemplateJSON(actual)
+	if err != nil {
+		return resourceInfo{
+			status: resourceStatusError{
+				err: err,
+			},
+		}, true
+	}
+	if currentTemplate == lastReadyTemplate {
+		// Last Ready pod template is failing, rollback would not help
+		return resourceInfo{}, false
+	}
+	var template map[string]interface{}
+	if err = json.Unmarshal([]byte(lastReadyTemplate), &template); err != nil {
+		return resourceInfo{
+			status: resourceStatusError{
+				err:             errors.Wrapf(err, "failed to parse annotation %q", builtin.LastReadyTemplateAnnotation),
+				isExternalError: true,
+			},
+		}, true
+	}
+	specTemplateHash, err := builtin.SpecPodTemplateHash(&specchecker.Context{
+		Logger: st.logger,
+		Store:  st.store,
+	}, spec)
+	if err != nil {
+		return resourceInfo{
+			status: resourceStatusError{
+				err: err,
+			},
+		}, true
+	}
+
+	updated := actual.DeepCopy()
+	if err = unstructured.SetNestedMap(updated.Object, template, "spec", "template"); err != nil {
+		return resourceInfo{
+			status: resourceStatusError{
+				err: errors.WithStack(err),
+			},
+		}, true
+	}
+	annotations := updated.GetAnnotations()
+	annotations[builtin.RolledBackTemplateHashAnnotation] = specTemplateHash
+	updated.SetAnnotations(annotations)
+	updated, err = st.updateDeployment(updated)
+	if err != nil {
+		return resourceInfo{
+			status: resourceStatusError{
+				err:              errors.Wrap(err, "failed to roll back to the last Ready pod template"),
+				isRetriableError: true,
+				isExternalError:  true,
+			},
+		}, true
+	}
+	st.logger.Info("Rolled back to the last Ready pod template")
+	st.recorder.AnnotatedEventf(st.bundle, map[string]string{
+		smith.EventAnnotationResourceName: string(resName),
+		smith.EventAnnotationReason:       deploymentProgressDeadlineExceededReason,
+	}, core_v1.EventTypeWarning, smith.EventReasonResourceRolledBack,
+		"Deployment %q exceeded its progress deadline and has been rolled back to the last Ready pod template", deployment.Name)
+
+	return resourceInfo{
+		actual: updated,
+		status: resourceStatusError{
+			err:             errors.Wrap(statusErr, "pod template has been rolled back to the last Ready one"),
+			isExternalError: true,
+		},
+	}, true
+}
+
+func (st *resourceSyncTask) updateDeployment(deployment *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	resClient, err := st.smartClient.ForGVK(deployment.GroupVersionKind(), st.bundle.Namespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the client for %s", deployment.GroupVersionKind())
+	}
+	return resClient.Update(deployment, meta_v1.UpdateOptions{})
+}
+
+func deploymentProgressDeadlineExceeded(deployment *apps_v1.Deployment) bool {
+	for _, cond := range deployment.Status.Conditions {
+		if cond.Type == apps_v1.DeploymentProgressing {
+			return cond.Reason == deploymentProgressDeadlineExceededReason
+		}
+	}
+	return false
+}
+
+func podTemplateJSON(obj *unstructured.Unstructured) (string, error) {
+	template, _, err := unstructured.NestedMap(obj.Object, "spec", "template")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,220 @@
+package bundlec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// processMultiObjectResource processes a resource of a plugin that produces multiple objects.
+// Each object is synced individually. The status of the resource aggregates statuses of the objects:
+// it is an error if any object failed, in progress if any object is not ready yet and ready otherwise.
+func (st *resourceSyncTask) processMultiObjectResource(res *smith_v1.Resource, pluginContainer plugin.Container) resourceInfo {
+	// Objects produced previously are passed to the plugin
+	actualObjects, status := st.getProducedObjects(res.Name)
+	if status != nil {
+		return resourceInfo{
+			status:      status,
+			multiObject: true,
+		}
+	}
+
+	specs, status := st.evalMultiObjectSpec(res, pluginContainer.Plugin.Describe(), actualObjects)
+	if status != nil {
+		return resourceInfo{
+			status:      status,
+			multiObject: true,
+		}
+	}
+
+	producedObjects := make([]smith_v1.ResourceObject, 0, len(specs))
+	objects := make([]*unstructured.Unstructured, 0, len(specs))
+	var errorStatus *resourceStatusError
+	var inProgress []string
+	for _, spec := range specs {
+		gvk := spec.GroupVersionKind()
+		name := spec.GetName()
+		producedObjects = append(producedObjects, smith_v1.ResourceObject{
+			Group:   gvk.Group,
+			Version: gvk.Version,
+			Kind:    gvk.Kind,
+			Name:    name,
+		})
+		var objInfo resourceInfo
+		actual, status := st.getObject(gvk, name)
+		if status == nil {
+			objInfo = st.syncObject(res, spec, actual)
+		} else {
+			objInfo = resourceInfo{
+				status: status,
+			}
+		}
+		switch s := objInfo.status.(type) {
+		case resourceStatusReady:
+			objects = append(objects, objInfo.actual)
+		case resourceStatusError:
+			if errorStatus == nil {
+				s.err = errors.Wrapf(s.err, "%s %q", gvk.Kind, name)
+				errorStatus = &s
+			}
+		case resourceStatusInProgress:
+			inProgress = append(inProgress, fmt.Sprintf("%s %q: %s", gvk.Kind, name, s.message))
+		default:
+			inProgress = append(inProgress, fmt.Sprintf("%s %q is not ready", gvk.Kind, name))
+		}
+	}
+
+	resInfo := resourceInfo{
+		multiObject:     true,
+		producedObjects: producedObjects,
+	}
+	switch {
+	case errorStatus != nil:
+		resInfo.status = *errorStatus
+	case len(inProgress) > 0:
+		resInfo.status = resourceStatusInProgress{
+			message: strings.Join(inProgress, "; "),
+		}
+	default:
+		resInfo.objects = objects
+		resInfo.actual = objectList(objects)
+		resInfo.status = resourceStatusReady{}
+	}
+	return resInfo
+}
+
+// getProducedObjects returns copies of objects that the resource produced previously and that still exist.
+// Objects that cannot be used (e.g. are marked for deletion) are skipped.
+func (st *resourceSyncTask) getProducedObjects(resName smith_v1.ResourceName) ([]runtime.Object, resourceStatus) {
+	_, resStatus := st.bundle.Status.GetResourceStatus(resName)
+	if resStatus == nil {
+		return nil, nil
+	}
+	actualObjects := make([]runtime.Object, 0, len(resStatus.Objects))
+	for _, obj := range resStatus.Objects {
+		actual, status := st.getObject(obj.GroupVersionKind(), obj.Name)
+		switch status.(type) {
+		case nil:
+		case resourceStatusError:
+			st.logger.Sugar().Debugf("Not passing previously produced %s %q to the plugin: %v", obj.Kind, obj.Name, status)
+			continue
+		default:
+			return nil, status
+		}
+		if actual != nil {
+			actualObjects = append(actualObjects, actual.DeepCopyObject()) // Pass a copy to the plugin to insulate from it
+		}
+	}
+	return actualObjects, nil
+}
+
+// evalMultiObjectSpec evaluates the specification of a resource of a plugin that produces multiple objects.
+// Returned objects are sorted by group, kind and name.
+func (st *resourceSyncTask) evalMultiObjectSpec(res *smith_v1.Resource, description *plugin.Description, actualObjects []runtime.Object) ([]*unstructured.Unstructured, resourceStatus) {
+	res = res.DeepCopy() // Spec processor mutates in place
+
+	// Process references
+	sp, err := newSpec(st.processedResources, res.References)
+	if err != nil {
+		return nil, resourceStatusError{
+			err:             err,
+			isExternalError: true,
+		}
+	}
+	st.redactor = newRedactor(sp.secretValues())
+	sp.target = pluginTarget(description)
+	if err = sp.ProcessObject(res.Spec.Plugin.Spec); err != nil {
+		return nil, resourceStatusError{
+			err:             err,
+			isExternalError: true,
+		}
+	}
+
+	result, _, status := st.invokePlugin(res, &plugin.Context{
+		ActualObjects: actualObjects,
+	})
+	if status != nil {
+		return nil, status
+	}
+
+	allowedGVKs := make(map[schema.GroupVersionKind]struct{}, len(description.GVKs))
+	for _, gvk := range description.GVKs {
+		allowedGVKs[gvk] = struct{}{}
+	}
+	seen := make(map[objectRef]struct{}, len(result.Objects))
+	specs := make([]*unstructured.Unstructured, 0, len(result.Objects))
+	for _, obj := range result.Objects {
+		// Make sure plugin is returning us something that obeys the declared GVKs.
+		object, err := util.RuntimeToUnstructured(obj)
+		if err != nil {
+			return nil, resourceStatusError{
+				err: errors.Wrap(err, "plugin output cannot be converted from runtime.Object"),
+			}
+		}
+		gvk := object.GroupVersionKind()
+		if _, ok := allowedGVKs[gvk]; !ok {
+			return nil, resourceStatusError{
+				err: errors.Errorf("unexpected GVK from plugin (wanted one of %s, got %s)", description.GVKs, gvk),
+			}
+		}
+		name := object.GetName()
+		if name == "" {
+			return nil, resourceStatusError{
+				err: errors.Errorf("plugin returned %s without a name", gvk.Kind),
+			}
+		}
+		ref := objectRef{
+			GroupVersionKind: gvk,
+			Name:             name,
+		}
+		if _, ok := seen[ref]; ok {
+			return nil, resourceStatusError{
+				err: errors.Errorf("plugin returned %s %q more than once", gvk.Kind, name),
+			}
+		}
+		seen[ref] = struct{}{}
+		if status := st.validatePluginOutput(res.Spec.Plugin.Name, object); status != nil {
+			return nil, status
+		}
+		object, status := st.setObjectOwnership(res, object)
+		if status != nil {
+			return nil, status
+		}
+		specs = append(specs, object)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		gvkI, gvkJ := specs[i].GroupVersionKind(), specs[j].GroupVersionKind()
+		if gvkI.Group != gvkJ.Group {
+			return gvkI.Group < gvkJ.Group
+		}
+		if gvkI.Kind != gvkJ.Kind {
+			return gvkI.Kind < gvkJ.Kind
+		}
+		return specs[i].GetName() < specs[j].GetName()
+	})
+	return specs, nil
+}
+
+// objectList returns a List of the objects. References to a resource of a plugin that produces multiple objects
+// are resolved against the List.
+func objectList(objects []*unstructured.Unstructured) *unstructured.Unstructured {
+	items := make([]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		items = append(items, obj.Object)
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"items":      items,
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package bundlec
+
+import (
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
+	"github.com/pkg/errors"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// dependencyOutputGVKs returns kinds of output objects that are passed to plugins for a dependency of the kind.
+// Must be kept in sync with prepareDependencies.
+func dependencyOutputGVKs(gvk schema.GroupVersionKind) []schema.GroupVersionKind {
+	if gvk == sc_v1b1.SchemeGroupVersion.WithKind("ServiceBinding") {
+		return []schema.GroupVersionKind{core_v1.SchemeGroupVersion.WithKind("Secret")}
+	}
+	return nil
+}
+
+// resourceGVKs returns kinds of objects the resource produces.
+// nil is returned if the kinds are not known (e.g. the plugin does not exist).
+func resourceGVKs(res *smith_v1.Resource, pluginContainers map[smith_v1.PluginName]plugin.Container) []schema.GroupVersionKind {
+	switch {
+	case res.Spec.Object != nil:
+		return []schema.GroupVersionKind{res.Spec.Object.GetObjectKind().GroupVersionKind()}
+	case res.Spec.Plugin != nil:
+		pluginContainer, ok := pluginContainers[res.Spec.Plugin.Name]
+		if !ok {
+			return nil
+		}
+		return pluginContainer.Plugin.Describe().OutputGVKs()
+	default:
+		return nil
+	}
+}
+
+// checkBundlePluginInputs checks that references of all plugin resources of the Bundle satisfy inputs declared
+// by their plugins. Resources of unknown plugins are skipped, they are reported when they are processed.
+// Returned error is an external error.
+func checkBundlePluginInputs(resources []smith_v1.Resource, pluginContainers map[smith_v1.PluginName]plugin.Container) error {
+	for i := range resources {
+		res := &resources[i]
+		if res.Spec.Plugin == nil {
+			continue
+		}
+		pluginContainer, ok := pluginContainers[res.Spec.Plugin.Name]
+		if !ok {
+			continue
+		}
+		if err := checkPluginInputs(res, pluginContainer.Plugin.Describe(), resources, pluginContainers); err != nil {
+			return errors.Wrapf(err, "resource %q", res.Name)
+		}
+	}
+	return nil
+}
+
+// checkPluginInputs checks that references of the resource satisfy inputs declared by the plugin.
+// Returned error is an external error.
+func checkPluginInputs(res *smith_v1.Resource, description *plugin.Description, resources []smith_v1.Resource, pluginContainers map[smith_v1.PluginName]plugin.Container) error {
+	if len(description.Inputs) == 0 {
+		return nil
+	}
+	references := make(map[smith_v1.ReferenceName]smith_v1.ResourceName, len(res.References))
+	for _, reference := range res.References {
+		if reference.Name == "" {
+			continue
+		}
+		if _, ok := references[reference.Name]; !ok {
+			references[reference.Name] = reference.Resource
+		}
+	}
+	for _, input := range description.Inputs {
+		resName, ok := references[input.Name]
+		if !ok {
+			if input.Optional {
+				continue
+			}
+			return errors.Errorf("plugin %q requires reference %q", description.Name, input.Name)
+		}
+		dependency := findResource(resources, resName)
+		if dependency == nil {
+			// Dangling references are reported elsewhere
+			continue
+		}
+		gvks := resourceGVKs(dependency, pluginContainers)
+		for _, gvk := range gvks {
+			if len(input.GVKs) > 0 && !containsGVK(input.GVKs, gvk) {
+				return errors.Errorf("reference %q of plugin %q must refer to a resource of kind %s, got %s",
+					input.Name, description.Name, input.GVKs, gvk)
+			}
+			outputGVKs := dependencyOutputGVKs(gvk)
+			for _, output := range input.Outputs {
+				if !containsGVK(outputGVKs, output) {
+					return errors.Errorf("reference %q of plugin %q must refer to a resource that produces %s, %s does not",
+						input.Name, description.Name, output, gvk)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func findResource(resources []smith_v1.Resource, resName smith_v1.ResourceName) *smith_v1.Resource {
+	for i := range resources {
+		if resources[i].Name == resName {
+			return &resources[i]
+		}
+	}
+	return nil
+}
+
+func containsGVK(gvks []schema.GroupVersionKind, gvk schema.GroupVersionKind) bool {
+	for _, g := range gvks {
+		if g == gvk {
+			return true
+		}
+	}
+	return false
+}
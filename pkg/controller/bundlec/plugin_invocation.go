@@ -0,0 +1,227 @@
+package bundlec
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultPluginTimeout is the default deadline for plugin invocations.
+	DefaultPluginTimeout = 30 * time.Second
+	// DefaultPluginConcurrency is the default maximum number of concurrent invocations of each plugin.
+	DefaultPluginConcurrency = 10
+
+	// Values of the "result" label of plugin metrics
+	pluginResultSuccess = "success"
+	pluginResultFailure = "failure"
+	pluginResultPanic   = "panic"
+	pluginResultTimeout = "timeout"
+	pluginResultRefused = "refused"
+)
+
+// NewPluginProcessDurationHistogram returns a histogram for latency of plugin invocations.
+func NewPluginProcessDurationHistogram(namespace string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "plugin_process_duration_seconds",
+			Help:      "Records how long plugin invocations take",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"plugin", "result"},
+	)
+}
+
+// NewPluginErrorCounter returns a counter for failed plugin invocations.
+func NewPluginErrorCounter(namespace string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "plugin_errors_total",
+			Help:      "Records the number of plugin invocations that failed, panicked, timed out or were refused",
+		},
+		[]string{"plugin", "result"},
+	)
+}
+
+type pluginInvocation struct {
+	result plugin.ProcessResult
+	panic  interface{}
+	stack  []byte
+}
+
+// pluginLimiter limits concurrent invocations of a plugin. Invocations that did not return within the deadline
+// keep their slot until the plugin returns and no new invocations are made meanwhile, so that a hung plugin cannot
+// accumulate goroutines.
+type pluginLimiter struct {
+	slots chan struct{}
+
+	mx sync.Mutex
+	// timedOut is the number of invocations that did not return within the deadline and are still running.
+	timedOut int
+}
+
+// invocationState is protected by pluginLimiter.mx.
+type invocationState struct {
+	returned bool
+	timedOut bool
+}
+
+func newPluginLimiter(concurrency int) *pluginLimiter {
+	return &pluginLimiter{
+		slots: make(chan struct{}, concurrency),
+	}
+}
+
+// acquire waits for a free slot until ctx is done. Fails immediately if an invocation that timed out is still running.
+func (l *pluginLimiter) acquire(ctx context.Context) error {
+	l.mx.Lock()
+	timedOut := l.timedOut
+	l.mx.Unlock()
+	if timedOut > 0 {
+		return errors.Errorf("%d invocation(s) that did not return within the deadline are still running", timedOut)
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errors.Errorf("all %d concurrent invocations are in use", cap(l.slots))
+	}
+}
+
+// returned releases the slot of the invocation once the plugin has returned.
+func (l *pluginLimiter) returned(state *invocationState) {
+	l.mx.Lock()
+	state.returned = true
+	if state.timedOut {
+		l.timedOut--
+	}
+	l.mx.Unlock()
+	<-l.slots
+}
+
+// abandoned records that the invocation did not return within the deadline.
+func (l *pluginLimiter) abandoned(state *invocationState) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if !state.returned {
+		state.timedOut = true
+		l.timedOut++
+	}
+}
+
+// processPlugin invokes the plugin on a separate goroutine so that a panicking or hung plugin
+// fails just the resource rather than the whole worker.
+// If the plugin does not return within the timeout, the context passed to it via plugin.Context is canceled and the goroutine is
+// abandoned. The plugin is not invoked again until the abandoned invocation returns.
+func (st *resourceSyncTask) processPlugin(name smith_v1.PluginName, p plugin.Plugin, spec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	start := time.Now()
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if st.pluginTimeout > 0 {
+		ctx, cancel = context.WithTimeout(st.ctx, st.pluginTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(st.ctx)
+	}
+	defer cancel()
+
+	var result plugin.ProcessResult
+	var resultLabel string
+	limiter := st.pluginLimiters[name]
+	state := &invocationState{}
+	if err := limiter.acquire(ctx); err != nil {
+		st.logger.Warn("Plugin invocation refused",
+			zap.String("plugin", string(name)),
+			zap.Error(err))
+		err = errors.Wrapf(err, "plugin %q cannot be invoked", name)
+		st.pluginFailures[name] = err
+		result = &plugin.ProcessResultFailure{
+			Error:            err,
+			IsRetriableError: true,
+		}
+		resultLabel = pluginResultRefused
+	} else {
+		result, resultLabel = st.invokePluginAsync(ctx, name, p, spec, pctx, limiter, state)
+	}
+
+	if st.pluginProcessDuration != nil {
+		st.pluginProcessDuration.
+			WithLabelValues(string(name), resultLabel).
+			Observe(time.Since(start).Seconds())
+	}
+	if st.pluginErrorCounter != nil && resultLabel != pluginResultSuccess {
+		st.pluginErrorCounter.
+			WithLabelValues(string(name), resultLabel).
+			Inc()
+	}
+	return result
+}
+
+// invokePluginAsync must be called with a slot acquired from the limiter. The slot is released when the plugin returns.
+func (st *resourceSyncTask) invokePluginAsync(ctx context.Context, name smith_v1.PluginName, p plugin.Plugin, spec map[string]interface{}, pctx *plugin.Context, limiter *pluginLimiter, state *invocationState) (plugin.ProcessResult, string /*resultLabel*/) {
+	invocationCh := make(chan pluginInvocation, 1) // Buffered so that an abandoned goroutine does not block forever
+	pctxCopy := *pctx
+	pctxCopy.Context = ctx
+	go func() {
+		defer limiter.returned(state)
+		defer func() {
+			if r := recover(); r != nil {
+				invocationCh <- pluginInvocation{
+					panic: r,
+					stack: debug.Stack(),
+				}
+			}
+		}()
+		invocationCh <- pluginInvocation{
+			result: p.Process(spec, &pctxCopy),
+		}
+	}()
+
+	select {
+	case invocation := <-invocationCh:
+		switch {
+		case invocation.panic != nil:
+			st.logger.Error("Plugin panicked",
+				zap.String("plugin", string(name)),
+				zap.Any("panic", invocation.panic),
+				zap.ByteString("stack", invocation.stack))
+			err := errors.Errorf("plugin %q panicked: %v", name, invocation.panic)
+			st.pluginFailures[name] = err
+			return &plugin.ProcessResultFailure{
+				Error: err,
+			}, pluginResultPanic
+		case invocation.result == nil:
+			return &plugin.ProcessResultFailure{
+				Error: errors.Errorf("plugin %q returned no result", name),
+			}, pluginResultFailure
+		case invocation.result.StatusType() == plugin.ProcessResultFailureType:
+			return invocation.result, pluginResultFailure
+		default:
+			return invocation.result, pluginResultSuccess
+		}
+	case <-ctx.Done():
+		limiter.abandoned(state)
+		var err error
+		if ctx.Err() == context.DeadlineExceeded {
+			st.logger.Error("Plugin did not return within the deadline",
+				zap.String("plugin", string(name)),
+				zap.Duration("timeout", st.pluginTimeout))
+			err = errors.Errorf("plugin %q did not return within %s", name, st.pluginTimeout)
+		} else {
+			err = errors.Errorf("plugin %q invocation canceled", name)
+		}
+		st.pluginFailures[name] = err
+		return &plugin.ProcessResultFailure{
+			Error:            err,
+			IsRetriableError: true,
+		}, pluginResultTimeout
+	}
+}
@@ -0,0 +1,51 @@
+package bundlec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginLimiterCapsConcurrentInvocations(t *testing.T) {
+	t.Parallel()
+
+	l := newPluginLimiter(2)
+	first := &invocationState{}
+	require.NoError(t, l.acquire(context.Background()))
+	require.NoError(t, l.acquire(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.EqualError(t, l.acquire(ctx), "all 2 concurrent invocations are in use")
+
+	l.returned(first)
+	assert.NoError(t, l.acquire(context.Background()))
+}
+
+func TestPluginLimiterRefusesWhileTimedOutInvocationRuns(t *testing.T) {
+	t.Parallel()
+
+	l := newPluginLimiter(2)
+	state := &invocationState{}
+	require.NoError(t, l.acquire(context.Background()))
+	l.abandoned(state)
+	assert.EqualError(t, l.acquire(context.Background()), "1 invocation(s) that did not return within the deadline are still running")
+
+	l.returned(state)
+	assert.NoError(t, l.acquire(context.Background()))
+}
+
+func TestPluginLimiterIgnoresReturnedInvocationOnTimeout(t *testing.T) {
+	t.Parallel()
+
+	l := newPluginLimiter(1)
+	state := &invocationState{}
+	require.NoError(t, l.acquire(context.Background()))
+	// Plugin returned at the same time as the deadline was reached
+	l.returned(state)
+	l.abandoned(state)
+	assert.NoError(t, l.acquire(context.Background()))
+}
@@ -0,0 +1,173 @@
+package bundlec
+
+import (
+	"sort"
+	"sync"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type allowedLookup struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	// names is nil if any object of the kind in the namespace may be looked up.
+	names sets.String
+}
+
+// pluginLookup is the plugin.ObjectLookup passed to plugins. It only allows access to objects the plugin
+// declared and records which objects were looked up so that the Bundle is re-processed when they change.
+// It may be used concurrently by a plugin that did not return within the deadline.
+type pluginLookup struct {
+	pluginName smith_v1.PluginName
+	store      Store
+	scheme     *runtime.Scheme
+	allowed    []allowedLookup
+
+	mx       sync.Mutex
+	lookedUp map[smith_v1.LookedUpObject]struct{}
+}
+
+// newPluginLookup returns a lookup for the objects the plugin declared.
+// It makes sure the objects can be looked up in the Store. A non-nil status is returned if they cannot
+// be looked up yet, in which case the Bundle is re-processed once they can.
+// Lookups are rejected if changes to the objects would not be noticed, i.e. if only objects managed by Smith are
+// watched (objects that are not managed are not in the informers' caches or are fetched and cached for a while) or
+// if the objects are in a namespace that is not watched.
+func (st *resourceSyncTask) newPluginLookup(description *plugin.Description) (*pluginLookup, resourceStatus) {
+	if len(description.Lookups) > 0 && st.managedObjectsOnly {
+		return nil, resourceStatusError{
+			err:             errors.Errorf("plugin %q declared lookups but only objects managed by Smith are watched", description.Name),
+			isExternalError: true,
+		}
+	}
+	l := &pluginLookup{
+		pluginName: description.Name,
+		store:      st.store,
+		scheme:     st.scheme,
+		allowed:    make([]allowedLookup, 0, len(description.Lookups)),
+		lookedUp:   make(map[smith_v1.LookedUpObject]struct{}),
+	}
+	for _, lookup := range description.Lookups {
+		clusterScoped := false
+		if st.restMapper != nil {
+			var err error
+			clusterScoped, err = isClusterScoped(st.restMapper, lookup.GVK)
+			if err != nil {
+				return nil, resourceStatusError{
+					err:              err,
+					isRetriableError: true,
+				}
+			}
+		}
+		if status := st.dynamicInformers.ensure(st.logger, lookup.GVK, clusterScoped, st.bundle); status != nil {
+			return nil, status
+		}
+		if !st.store.HasInformer(lookup.GVK) {
+			return nil, resourceStatusError{
+				err: errors.Errorf("plugin %q declared lookups of %s but objects of that kind are not watched", description.Name, lookup.GVK),
+			}
+		}
+		namespace := lookup.Namespace
+		switch {
+		case clusterScoped:
+			namespace = meta_v1.NamespaceNone
+		case namespace == "":
+			namespace = st.bundle.Namespace
+		case !st.watchesNamespace(namespace):
+			return nil, resourceStatusError{
+				err:             errors.Errorf("plugin %q declared lookups of %s in namespace %q that is not watched", description.Name, lookup.GVK, namespace),
+				isExternalError: true,
+			}
+		}
+		var names sets.String
+		if len(lookup.Names) > 0 {
+			names = sets.NewString(lookup.Names...)
+		}
+		l.allowed = append(l.allowed, allowedLookup{
+			gvk:       lookup.GVK,
+			namespace: namespace,
+			names:     names,
+		})
+	}
+	return l, nil
+}
+
+func (l *pluginLookup) Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, error) {
+	if !l.isAllowed(gvk, namespace, name) {
+		return nil, errors.Errorf("plugin %q did not declare lookups of %s %q in namespace %q", l.pluginName, gvk, name, namespace)
+	}
+	l.record(gvk, namespace, name)
+	obj, exists, err := l.store.Get(gvk, namespace, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to look up %s %q", gvk.Kind, name)
+	}
+	if !exists {
+		return nil, nil
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || !l.scheme.Recognizes(gvk) {
+		return obj, nil
+	}
+	// Convert to typed object if we recognize the type, like it is done for dependencies
+	typed, err := l.scheme.ConvertToVersion(u, gvk.GroupVersion())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	typed.GetObjectKind().SetGroupVersionKind(gvk)
+	return typed, nil
+}
+
+func (l *pluginLookup) isAllowed(gvk schema.GroupVersionKind, namespace, name string) bool {
+	for _, allowed := range l.allowed {
+		if allowed.gvk == gvk && allowed.namespace == namespace && (allowed.names == nil || allowed.names.Has(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *pluginLookup) record(gvk schema.GroupVersionKind, namespace, name string) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.lookedUp[smith_v1.LookedUpObject{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: namespace,
+		Name:      name,
+	}] = struct{}{}
+}
+
+// lookedUpObjects returns the objects that were looked up, sorted.
+func (l *pluginLookup) lookedUpObjects() []smith_v1.LookedUpObject {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	result := make([]smith_v1.LookedUpObject, 0, len(l.lookedUp))
+	for obj := range l.lookedUp {
+		result = append(result, obj)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	return result
+}
@@ -1,18 +1,25 @@
 package bundlec
 
 import (
+	"context"
+	"time"
+
 	ctrlLogz "github.com/atlassian/ctrl/logz"
 	"github.com/atlassian/smith"
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/policy"
+	"github.com/atlassian/smith/pkg/specchecker"
 	"github.com/atlassian/smith/pkg/statuschecker"
 	"github.com/atlassian/smith/pkg/store"
 	"github.com/atlassian/smith/pkg/util"
 	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	core_v1 "k8s.io/api/core/v1"
 	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -22,6 +29,7 @@ import (
 	k8s_json "k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 )
 
 type ResourceStatusType string
@@ -112,11 +120,29 @@ func (r resourceStatusError) StatusType() ResourceStatusType {
 }
 
 type resourceInfo struct {
+	// actual is a List of objects if the resource is a plugin that produces multiple objects.
 	actual *unstructured.Unstructured
 	status resourceStatus
 
 	// if actual is a ServiceBinding, we resolve the secret once it's been processed.
 	serviceBindingSecret *core_v1.Secret
+
+	// multiObject is true if the resource is a plugin that produces multiple objects.
+	multiObject bool
+	// objects are the actual objects produced by a plugin that produces multiple objects.
+	objects []*unstructured.Unstructured
+	// producedObjects are the objects the plugin produced. nil if the plugin has not been invoked.
+	producedObjects []smith_v1.ResourceObject
+	// lookups are the objects the plugin looked up. nil if the plugin has not been invoked.
+	lookups []smith_v1.LookedUpObject
+}
+
+// actualObjects returns the actual objects of the resource.
+func (ri *resourceInfo) actualObjects() []*unstructured.Unstructured {
+	if ri.multiObject {
+		return ri.objects
+	}
+	return []*unstructured.Unstructured{ri.actual}
 }
 
 func (ri *resourceInfo) isReady() bool {
@@ -132,16 +158,39 @@ func (ri *resourceInfo) fetchError() *resourceStatusError {
 }
 
 type resourceSyncTask struct {
-	logger             *zap.Logger
-	smartClient        SmartClient
-	checker            statuschecker.Interface
-	store              Store
-	specChecker        SpecChecker
-	bundle             *smith_v1.Bundle
-	processedResources map[smith_v1.ResourceName]*resourceInfo
-	pluginContainers   map[smith_v1.PluginName]plugin.Container
-	scheme             *runtime.Scheme
-	catalog            *store.Catalog
+	logger               *zap.Logger
+	smartClient          SmartClient
+	restMapper           meta.RESTMapper
+	clusterScopedObjects bool
+	managedByLabel       bool
+	managedObjectsOnly   bool
+	watchesNamespace     func(namespace string) bool
+	dynamicInformers     *dynamicInformers
+	accessChecker        *accessChecker
+	policies             []policy.Policy
+	checker              statuschecker.Interface
+	store                Store
+	specChecker          SpecChecker
+	bundle               *smith_v1.Bundle
+	processedResources   map[smith_v1.ResourceName]*resourceInfo
+	pluginContainers     map[smith_v1.PluginName]plugin.Container
+	scheme               *runtime.Scheme
+	catalog              *store.Catalog
+	recorder             record.EventRecorder
+
+	pluginProcessDuration *prometheus.HistogramVec
+	pluginErrorCounter    *prometheus.CounterVec
+	pluginTimeout         time.Duration
+	pluginLimiters        map[smith_v1.PluginName]*pluginLimiter
+	// ctx is done when the controller is shutting down.
+	ctx context.Context
+	// pluginFailures is shared with the bundleSyncTask to report failed invocations in PluginStatuses.
+	pluginFailures map[smith_v1.PluginName]error
+	// lookups are the objects the plugin looked up. nil if the plugin has not been invoked.
+	lookups []smith_v1.LookedUpObject
+	// redactor replaces values of secret references of the resource in logged diffs and errors.
+	// nil until references are resolved.
+	redactor *redactor
 }
 
 func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo {
@@ -171,6 +220,24 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 		}
 	}
 
+	if res.Spec.Plugin != nil {
+		pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]
+		if ok {
+			description := pluginContainer.Plugin.Describe()
+			if description.IsMultiObject() {
+				return st.processMultiObjectResource(res, pluginContainer)
+			}
+			if err := checkPluginObjectName(res.Spec.Plugin, description); err != nil {
+				return resourceInfo{
+					status: resourceStatusError{
+						err:             err,
+						isExternalError: true,
+					},
+				}
+			}
+		}
+	}
+
 	// Try to get the resource. We do a read first to avoid generating unnecessary events.
 	actual, status := st.getActualObject(res)
 	if status != nil {
@@ -187,13 +254,13 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 		}
 	}
 
+	return st.syncObject(res, spec, actual)
+}
+
+// syncObject validates the spec, creates or updates the object and checks its status.
+func (st *resourceSyncTask) syncObject(res *smith_v1.Resource, spec *unstructured.Unstructured, actual runtime.Object) resourceInfo {
 	// Validate spec
-	status = st.validateSpec(spec)
-	if status != nil {
-		return resourceInfo{
-			status: status,
-		}
-	}
+	status := st.validateSpec(res, spec)
 	if status != nil {
 		return resourceInfo{
 			status: status,
@@ -205,6 +272,15 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 	if err != nil {
 		cause := errors.Cause(err)
 
+		if specchecker.IsRecreateRequired(cause) {
+			// Object has been deleted, it will be created on the next iteration
+			return resourceInfo{
+				status: resourceStatusInProgress{
+					message: err.Error(),
+				},
+			}
+		}
+
 		for _, isInternalErr := range isCreateOrUpdateInternal {
 			if isInternalErr(cause) {
 				return resourceInfo{
@@ -258,7 +334,7 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 		}
 	case !match:
 		// We use reflect diff here instead of the returned json diff to see the types
-		difference := diff.ObjectReflectDiff(updatedSpec.Object, resUpdated.Object)
+		difference := st.redactor.redact(diff.ObjectReflectDiff(updatedSpec.Object, resUpdated.Object))
 		st.logger.Sugar().Errorf("Objects are different after specification re-check (`a` is what we've sent and `b` is what Kubernetes persisted and returned):\n%s", difference)
 		return resourceInfo{
 			status: resourceStatusError{
@@ -267,6 +343,14 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 		}
 	}
 
+	// Check if the specification has been rolled back
+	if rolledBackStatus := checkDeploymentRolledBack(resUpdated); rolledBackStatus != nil {
+		return resourceInfo{
+			actual: resUpdated,
+			status: rolledBackStatus,
+		}
+	}
+
 	// Check if resource is ready
 	statusResult := st.checker.CheckStatus(resUpdated)
 	switch s := statusResult.(type) {
@@ -278,6 +362,9 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 			},
 		}
 	case statuschecker.ObjectStatusError:
+		if resInfo, rolledBack := st.maybeRollbackDeployment(res.Name, spec, resUpdated, s.Error); rolledBack {
+			return resInfo
+		}
 		return resourceInfo{
 			actual: resUpdated,
 			status: resourceStatusError{
@@ -294,6 +381,16 @@ func (st *resourceSyncTask) processResource(res *smith_v1.Resource) resourceInfo
 			},
 		}
 	case statuschecker.ObjectStatusReady:
+		resUpdated, err = st.maybeRecordReadyTemplate(resUpdated)
+		if err != nil {
+			return resourceInfo{
+				status: resourceStatusError{
+					err:              err,
+					isRetriableError: true,
+				},
+			}
+		}
+
 		// Augment with binding output (used for references)
 		bindingSecret, err := st.maybeExtractBindingSecret(resUpdated)
 		if err != nil {
@@ -381,7 +478,24 @@ func (st *resourceSyncTask) getActualObject(res *smith_v1.Resource) (runtime.Obj
 			isExternalError: true,
 		}
 	}
-	actual, exists, err := st.store.Get(gvk, st.bundle.Namespace, name)
+	return st.getObject(gvk, name)
+}
+
+// getObject gets the object from the Store and checks that it is managed by the Bundle.
+// nil is returned if the object does not exist.
+func (st *resourceSyncTask) getObject(gvk schema.GroupVersionKind, name string) (runtime.Object, resourceStatus) {
+	clusterScoped, status := st.isClusterScoped(gvk)
+	if status != nil {
+		return nil, status
+	}
+	if status = st.dynamicInformers.ensure(st.logger, gvk, clusterScoped, st.bundle); status != nil {
+		return nil, status
+	}
+	namespace := st.bundle.Namespace
+	if clusterScoped {
+		namespace = meta_v1.NamespaceNone
+	}
+	actual, exists, err := st.store.Get(gvk, namespace, name)
 	if err != nil {
 		// internal error - something is up with our stores
 		return nil, resourceStatusError{
@@ -391,43 +505,106 @@ func (st *resourceSyncTask) getActualObject(res *smith_v1.Resource) (runtime.Obj
 	if !exists {
 		return nil, nil
 	}
-	actualMeta := actual.(meta_v1.Object)
-
-	// Check that the object is not marked for deletion
-	if actualMeta.GetDeletionTimestamp() != nil {
+	if err = st.checkActualObject(actual.(meta_v1.Object), clusterScoped); err != nil {
 		return nil, resourceStatusError{
-			err:             errors.New("object is marked for deletion"),
+			err:             err,
 			isExternalError: true,
 		}
 	}
+	return actual, nil
+}
+
+// checkActualObject returns an error if the object is marked for deletion or is not managed by the Bundle.
+func (st *resourceSyncTask) checkActualObject(actualMeta meta_v1.Object, clusterScoped bool) error {
+	// Check that the object is not marked for deletion
+	if actualMeta.GetDeletionTimestamp() != nil {
+		return errors.New("object is marked for deletion")
+	}
 
 	// Check that this bundle controls the object
-	if !meta_v1.IsControlledBy(actualMeta, st.bundle) {
-		ref := meta_v1.GetControllerOf(actualMeta)
-		var err error
-		if ref == nil {
-			err = errors.New("object is not controlled by the Bundle and does not have a controller at all")
-		} else {
-			err = errors.Errorf("object is controlled by apiVersion=%s, kind=%s, name=%s, uid=%s, not by the Bundle (uid=%s)",
-				ref.APIVersion, ref.Kind, ref.Name, ref.UID, st.bundle.UID)
+	if clusterScoped {
+		return st.checkManagedByBundle(actualMeta)
+	}
+	return st.checkControlledByBundle(actualMeta)
+}
+
+// checkControlledByBundle returns an error if the namespaced object is not controlled by the Bundle.
+func (st *resourceSyncTask) checkControlledByBundle(actualMeta meta_v1.Object) error {
+	if meta_v1.IsControlledBy(actualMeta, st.bundle) {
+		return nil
+	}
+	ref := meta_v1.GetControllerOf(actualMeta)
+	if ref == nil {
+		return errors.New("object is not controlled by the Bundle and does not have a controller at all")
+	}
+	return errors.Errorf("object is controlled by apiVersion=%s, kind=%s, name=%s, uid=%s, not by the Bundle (uid=%s)",
+		ref.APIVersion, ref.Kind, ref.Name, ref.UID, st.bundle.UID)
+}
+
+// checkManagedByBundle returns an error if the cluster-scoped object is not managed by the Bundle.
+// Namespaced Bundle cannot be a controller of a cluster-scoped object so the UID label is checked instead.
+func (st *resourceSyncTask) checkManagedByBundle(actualMeta meta_v1.Object) error {
+	uid, ok := actualMeta.GetLabels()[smith.BundleUIDLabel]
+	switch {
+	case !ok:
+		return errors.New("cluster-scoped object is not managed by the Bundle and is not managed by any Bundle at all")
+	case uid != string(st.bundle.UID):
+		return errors.Errorf("cluster-scoped object is managed by Bundle %q (uid=%s), not by the Bundle (uid=%s)",
+			actualMeta.GetAnnotations()[smith.BundleAnnotation], uid, st.bundle.UID)
+	}
+	return nil
+}
+
+// isClusterScoped returns true if objects of the GVK are cluster-scoped.
+// An error status is returned for cluster-scoped objects if their management is not enabled or
+// no policy allows them.
+func (st *resourceSyncTask) isClusterScoped(gvk schema.GroupVersionKind) (bool, resourceStatus) {
+	if st.restMapper == nil {
+		return false, nil
+	}
+	clusterScoped, err := isClusterScoped(st.restMapper, gvk)
+	if err != nil {
+		return false, resourceStatusError{
+			err:              err,
+			isRetriableError: true,
 		}
-		return nil, resourceStatusError{
-			err:             err,
+	}
+	if clusterScoped && !st.clusterScopedObjects {
+		return false, resourceStatusError{
+			err:             errors.Errorf("%s is cluster-scoped but management of cluster-scoped objects is not enabled", gvk),
 			isExternalError: true,
 		}
 	}
-	return actual, nil
+	if clusterScoped && !policy.ClusterScopedAllowed(st.policies) {
+		return false, resourceStatusError{
+			err:             errors.Errorf("%s is cluster-scoped but no policy that applies to the Bundle allows cluster-scoped objects", gvk),
+			isExternalError: true,
+		}
+	}
+	return clusterScoped, nil
 }
 
 // prevalidate does as much validation as possible before doing any real work.
 func (st *resourceSyncTask) prevalidate(res *smith_v1.Resource) resourceStatus {
+	if errs := validateSecretReferences(res, st.pluginContainers, nil); len(errs) > 0 {
+		return resourceStatusError{
+			err:             errs.ToAggregate(),
+			isExternalError: true,
+		}
+	}
+	return prevalidate(st.logger, res, st.pluginContainers, st.scheme, st.catalog)
+}
+
+// prevalidate validates the resource specification against the schema using reference examples.
+// It does not need any dependencies to be processed so it is also used by the admission webhook.
+func prevalidate(logger *zap.Logger, res *smith_v1.Resource, pluginContainers map[smith_v1.PluginName]plugin.Container, scheme *runtime.Scheme, catalog *store.Catalog) resourceStatus {
 	sp, err := newExamplesSpec(res.References)
 	if err != nil {
 		if isNoExampleError(errors.Cause(err)) {
 			// a noExampleError occurs when an example wasn't provided
 			// by the user in one of the references. For now, we assume this
 			// is intentional and don't error out.
-			st.logger.Debug("Not validating against schema due to missing examples", zap.Error(err))
+			logger.Debug("Not validating against schema due to missing examples", zap.Error(err))
 			return nil
 		}
 		return resourceStatusError{err: err}
@@ -437,18 +614,24 @@ func (st *resourceSyncTask) prevalidate(res *smith_v1.Resource) resourceStatus {
 
 	if res.Spec.Object != nil {
 		if res.Spec.Object.GetObjectKind().GroupVersionKind() == serviceInstanceGvk {
-			if st.catalog == nil {
+			if catalog == nil {
 				// can't do anything, since service catalog wasn't enabled.
 				return nil
 			}
-			actual, err := st.scheme.ConvertToVersion(res.Spec.Object, serviceInstanceGvk.GroupVersion())
+			actual, err := scheme.ConvertToVersion(res.Spec.Object, serviceInstanceGvk.GroupVersion())
 			if err != nil {
 				return resourceStatusError{err: errors.WithStack(err)}
 			}
 			serviceInstance := actual.(*sc_v1b1.ServiceInstance)
 
 			if len(serviceInstance.Spec.ParametersFrom) > 0 {
-				st.logger.Debug("Not validating against schema due to parametersFrom block")
+				logger.Debug("Not validating against schema due to parametersFrom block")
+				return nil
+			}
+
+			if isSecretReference(res, serviceInstance.Spec.Parameters) {
+				// Parameters are turned into a parametersFrom block
+				logger.Debug("Not validating against schema due to parameters from a secret reference")
 				return nil
 			}
 
@@ -474,7 +657,7 @@ func (st *resourceSyncTask) prevalidate(res *smith_v1.Resource) resourceStatus {
 				}
 			}
 
-			validationResult, err := st.catalog.ValidateServiceInstanceSpec(&serviceInstance.Spec)
+			validationResult, err := catalog.ValidateServiceInstanceSpec(&serviceInstance.Spec)
 			if err != nil {
 				return resourceStatusError{err: err}
 			}
@@ -496,13 +679,14 @@ func (st *resourceSyncTask) prevalidate(res *smith_v1.Resource) resourceStatus {
 				}
 			}
 		}
-		pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]
+		pluginContainer, ok := pluginContainers[res.Spec.Plugin.Name]
 		if !ok {
 			return resourceStatusError{
 				err:             errors.Errorf("plugin %q does not exist", res.Spec.Plugin.Name),
 				isExternalError: true,
 			}
 		}
+		res.Spec.Plugin.Spec, _ = pluginContainer.ApplyDefaults(res.Spec.Plugin.Spec)
 		validationResult, err := pluginContainer.ValidateSpec(res.Spec.Plugin.Spec)
 		if err != nil {
 			return resourceStatusError{err: err}
@@ -550,6 +734,8 @@ func (st *resourceSyncTask) evalSpec(res *smith_v1.Resource, actual runtime.Obje
 			isExternalError: true,
 		}
 	}
+	st.redactor = newRedactor(sp.secretValues())
+	sp.target, _ = resourceTarget(res, st.pluginContainers)
 	if err := sp.ProcessObject(objectOrPluginSpec); err != nil {
 		return nil, resourceStatusError{
 			err:             err,
@@ -576,28 +762,41 @@ func (st *resourceSyncTask) evalSpec(res *smith_v1.Resource, actual runtime.Obje
 		}
 	}
 
+	return st.setObjectOwnership(res, obj)
+}
+
+// setObjectOwnership sets owner references, labels, annotations and the namespace of the object
+// to make it managed by the Bundle.
+func (st *resourceSyncTask) setObjectOwnership(res *smith_v1.Resource, obj *unstructured.Unstructured) (*unstructured.Unstructured, resourceStatus) {
+	clusterScoped, status := st.isClusterScoped(obj.GroupVersionKind())
+	if status != nil {
+		return nil, status
+	}
+
 	// Update OwnerReferences
 	trueRef := true
 	refs := obj.GetOwnerReferences()
-	for i, ref := range refs {
-		if ref.Controller != nil && *ref.Controller {
-			// user (or plugin) tried to create a resource with controller owner reference
-			return nil, resourceStatusError{
-				err:             errors.Errorf("cannot create resource with controller owner reference %v", ref),
-				isExternalError: res.Spec.Plugin == nil,
-			}
+	if i := controllerOwnerReferenceIndex(refs); i >= 0 {
+		// user (or plugin) tried to create a resource with controller owner reference
+		return nil, resourceStatusError{
+			err:             errors.Errorf("cannot create resource with controller owner reference %v", refs[i]),
+			isExternalError: res.Spec.Plugin == nil,
 		}
+	}
+	for i := range refs {
 		refs[i].BlockOwnerDeletion = &trueRef
 	}
-	// Hardcode APIVersion/Kind because of https://github.com/kubernetes/client-go/issues/60
-	refs = append(refs, meta_v1.OwnerReference{
-		APIVersion:         smith_v1.BundleResourceGroupVersion,
-		Kind:               smith_v1.BundleResourceKind,
-		Name:               st.bundle.Name,
-		UID:                st.bundle.UID,
-		Controller:         &trueRef,
-		BlockOwnerDeletion: &trueRef,
-	})
+	if !clusterScoped {
+		// Hardcode APIVersion/Kind because of https://github.com/kubernetes/client-go/issues/60
+		refs = append(refs, meta_v1.OwnerReference{
+			APIVersion:         smith_v1.BundleResourceGroupVersion,
+			Kind:               smith_v1.BundleResourceKind,
+			Name:               st.bundle.Name,
+			UID:                st.bundle.UID,
+			Controller:         &trueRef,
+			BlockOwnerDeletion: &trueRef,
+		})
+	}
 	setRefs := make(map[smith_v1.ResourceName]struct{}, len(res.References))
 	for _, dep := range res.References {
 		if _, ok := setRefs[dep.Resource]; ok {
@@ -605,44 +804,87 @@ func (st *resourceSyncTask) evalSpec(res *smith_v1.Resource, actual runtime.Obje
 			continue
 		}
 		setRefs[dep.Resource] = struct{}{}
-		processedObj := st.processedResources[dep.Resource].actual // this is ok because we've checked earlier that resources contains all dependencies
-		refs = append(refs, meta_v1.OwnerReference{
-			APIVersion:         processedObj.GetAPIVersion(),
-			Kind:               processedObj.GetKind(),
-			Name:               processedObj.GetName(),
-			UID:                processedObj.GetUID(),
-			BlockOwnerDeletion: &trueRef,
-		})
+		// this is ok because we've checked earlier that resources contains all dependencies
+		for _, processedObj := range st.processedResources[dep.Resource].actualObjects() {
+			if clusterScoped && processedObj.GetNamespace() != meta_v1.NamespaceNone {
+				// Cluster-scoped objects cannot be owned by namespaced objects
+				continue
+			}
+			refs = append(refs, meta_v1.OwnerReference{
+				APIVersion:         processedObj.GetAPIVersion(),
+				Kind:               processedObj.GetKind(),
+				Name:               processedObj.GetName(),
+				UID:                processedObj.GetUID(),
+				BlockOwnerDeletion: &trueRef,
+			})
+		}
 	}
 	obj.SetOwnerReferences(refs)
-	switch obj.GetNamespace() {
-	case "":
-		obj.SetNamespace(st.bundle.Namespace)
-	case st.bundle.Namespace:
-	default:
+
+	if st.managedByLabel {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string, 1)
+		}
+		labels[smith.ManagedByLabel] = smith.ManagedByLabelValue
+		obj.SetLabels(labels)
+	}
+
+	if clusterScoped {
+		if obj.GetNamespace() != meta_v1.NamespaceNone {
+			// the plugin or user created an object template with the namespace set
+			return nil, resourceStatusError{
+				err:             errors.Errorf("namespace was %q but %s is cluster-scoped", obj.GetNamespace(), obj.GroupVersionKind()),
+				isExternalError: res.Spec.Plugin == nil,
+			}
+		}
+		// Namespaced Bundle cannot own a cluster-scoped object so a label and an annotation are used instead
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string, 1)
+		}
+		labels[smith.BundleUIDLabel] = string(st.bundle.UID)
+		obj.SetLabels(labels)
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string, 1)
+		}
+		annotations[smith.BundleAnnotation] = st.bundle.Namespace + "/" + st.bundle.Name
+		obj.SetAnnotations(annotations)
+		return obj, nil
+	}
+
+	if !isBundleNamespace(obj.GetNamespace(), st.bundle.Namespace) {
 		// the plugin or user created an object template with the namespace set to something wrong
 		return nil, resourceStatusError{
 			err:             errors.Errorf("namespace was %q which is different from the bundle namespace %q", obj.GetNamespace(), st.bundle.Namespace),
 			isExternalError: res.Spec.Plugin == nil,
 		}
 	}
+	obj.SetNamespace(st.bundle.Namespace)
 
 	return obj, nil
 }
 
 // validateSpec enforces constraints on the desires object spec
-// e.g. prohibits Smith-managed annotations
-func (st *resourceSyncTask) validateSpec(spec *unstructured.Unstructured) resourceStatus {
-	annotations := spec.GetAnnotations()
-	if len(annotations) > 0 {
-		for key := range prohibitedAnnotations {
-			if _, ok := annotations[key]; ok {
-				return resourceStatusError{
-					err:              errors.Errorf("annotation %q cannot be set by the user", key),
-					isRetriableError: false,
-					isExternalError:  true,
-				}
-			}
+// e.g. prohibits Smith-managed annotations and checks policies that apply to the Bundle
+func (st *resourceSyncTask) validateSpec(res *smith_v1.Resource, spec *unstructured.Unstructured) resourceStatus {
+	if keys := prohibitedAnnotationKeys(spec.GetAnnotations()); len(keys) > 0 {
+		return resourceStatusError{
+			err:              errors.Errorf("annotation %q cannot be set by the user", keys[0]),
+			isRetriableError: false,
+			isExternalError:  true,
+		}
+	}
+	err := checkObjectPolicies(st.policies, spec)
+	if err == nil && res.Spec.Plugin != nil {
+		err = checkPluginPolicies(st.policies, res.Spec.Plugin.Name)
+	}
+	if err != nil {
+		return resourceStatusError{
+			err:              err,
+			isRetriableError: false,
+			isExternalError:  true,
 		}
 	}
 	return nil
@@ -650,19 +892,77 @@ func (st *resourceSyncTask) validateSpec(spec *unstructured.Unstructured) resour
 
 // evalPluginSpec evaluates the plugin resource specification and returns the result.
 func (st *resourceSyncTask) evalPluginSpec(res *smith_v1.Resource, actual runtime.Object) (*unstructured.Unstructured, resourceStatus) {
+	result, description, status := st.invokePlugin(res, &plugin.Context{
+		Actual: actual,
+	})
+	if status != nil {
+		return nil, status
+	}
+
+	// Make sure plugin is returning us something that obeys the PluginSpec.
+	object, err := util.RuntimeToUnstructured(result.Object)
+	if err != nil {
+		return nil, resourceStatusError{
+			err: errors.Wrap(err, "plugin output cannot be converted from runtime.Object"),
+		}
+	}
+	expectedGVK := description.GVK
+	if object.GroupVersionKind() != expectedGVK {
+		return nil, resourceStatusError{
+			err: errors.Errorf("unexpected GVK from plugin (wanted %s, got %s)", expectedGVK, object.GroupVersionKind()),
+		}
+	}
+	// We are in charge of naming.
+	object.SetName(res.Spec.Plugin.ObjectName)
+
+	if status := st.validatePluginOutput(res.Spec.Plugin.Name, object); status != nil {
+		return nil, status
+	}
+
+	return object, nil
+}
+
+// validatePluginOutput validates an object produced by the plugin against the output schema of the plugin.
+// Output that does not match the schema is a bug in the plugin so it is an internal error.
+func (st *resourceSyncTask) validatePluginOutput(pluginName smith_v1.PluginName, object *unstructured.Unstructured) resourceStatus {
+	pluginContainer := st.pluginContainers[pluginName]
+	validationResult, err := pluginContainer.ValidateOutput(object.Object)
+	if err != nil {
+		return resourceStatusError{err: err}
+	}
+	if len(validationResult.Errors) > 0 {
+		return resourceStatusError{
+			err: errors.Wrapf(k8s_errors.NewAggregate(validationResult.Errors), "output of plugin %q failed validation against schema", pluginName),
+		}
+	}
+	return nil
+}
+
+// invokePlugin applies defaults to the plugin spec, validates it and invokes the plugin.
+// Namespace and Dependencies of the passed context are populated.
+func (st *resourceSyncTask) invokePlugin(res *smith_v1.Resource, pctx *plugin.Context) (*plugin.ProcessResultSuccess, *plugin.Description, resourceStatus) {
 	pluginContainer, ok := st.pluginContainers[res.Spec.Plugin.Name]
 	if !ok {
-		return nil, resourceStatusError{
+		return nil, nil, resourceStatusError{
 			err:             errors.Errorf("no such plugin %q", res.Spec.Plugin.Name),
 			isExternalError: true,
 		}
 	}
+	spec, defaults := pluginContainer.ApplyDefaults(res.Spec.Plugin.Spec)
+	if len(defaults) > 0 {
+		// References have been resolved by now so the redactor knows all values of Secrets the spec may contain
+		st.logger.Debug("Applied defaults to plugin spec",
+			zap.String("plugin", string(res.Spec.Plugin.Name)),
+			zap.Any("defaults", defaults),
+			zap.Any("spec", st.redactor.redactObject(spec)))
+	}
+	res.Spec.Plugin.Spec = spec
 	validationResult, err := pluginContainer.ValidateSpec(res.Spec.Plugin.Spec)
 	if err != nil {
-		return nil, resourceStatusError{err: err}
+		return nil, nil, resourceStatusError{err: err}
 	}
 	if len(validationResult.Errors) > 0 {
-		return nil, resourceStatusError{
+		return nil, nil, resourceStatusError{
 			err:             errors.Wrap(k8s_errors.NewAggregate(validationResult.Errors), "spec failed validation against schema"),
 			isExternalError: true,
 		}
@@ -673,49 +973,43 @@ func (st *resourceSyncTask) evalPluginSpec(res *smith_v1.Resource, actual runtim
 	if err != nil {
 		// there should be no error in processing dependencies. If there is, this
 		// is an internal issue.
-		return nil, resourceStatusError{
+		return nil, nil, resourceStatusError{
 			err: err,
 		}
 	}
 
-	result := pluginContainer.Plugin.Process(res.Spec.Plugin.Spec, &plugin.Context{
-		Namespace:    st.bundle.Namespace,
-		Actual:       actual,
-		Dependencies: dependencies,
-	})
-	var pluginObj runtime.Object
+	pctx.Namespace = st.bundle.Namespace
+	pctx.Dependencies = dependencies
+	description := pluginContainer.Plugin.Describe()
+	var lookup *pluginLookup
+	if len(description.Lookups) > 0 {
+		var status resourceStatus
+		lookup, status = st.newPluginLookup(description)
+		if status != nil {
+			return nil, nil, status
+		}
+		pctx.Lookup = lookup
+	}
+	result := st.processPlugin(res.Spec.Plugin.Name, pluginContainer.Plugin, res.Spec.Plugin.Spec, pctx)
+	if lookup != nil {
+		st.lookups = lookup.lookedUpObjects()
+	} else {
+		st.lookups = []smith_v1.LookedUpObject{}
+	}
 	switch res := result.(type) {
 	case *plugin.ProcessResultSuccess:
-		pluginObj = res.Object
+		return res, description, nil
 	case *plugin.ProcessResultFailure:
-		return nil, resourceStatusError{
+		return nil, nil, resourceStatusError{
 			err:              res.Error,
 			isRetriableError: res.IsRetriableError,
 			isExternalError:  res.IsExternalError,
 		}
 	default:
-		return nil, resourceStatusError{
+		return nil, nil, resourceStatusError{
 			err: errors.Errorf("unexpected plugin result type %q", res.StatusType()),
 		}
 	}
-
-	// Make sure plugin is returning us something that obeys the PluginSpec.
-	object, err := util.RuntimeToUnstructured(pluginObj)
-	if err != nil {
-		return nil, resourceStatusError{
-			err: errors.Wrap(err, "plugin output cannot be converted from runtime.Object"),
-		}
-	}
-	expectedGVK := pluginContainer.Plugin.Describe().GVK
-	if object.GroupVersionKind() != expectedGVK {
-		return nil, resourceStatusError{
-			err: errors.Errorf("unexpected GVK from plugin (wanted %s, got %s)", expectedGVK, object.GroupVersionKind()),
-		}
-	}
-	// We are in charge of naming.
-	object.SetName(res.Spec.Plugin.ObjectName)
-
-	return object, nil
 }
 
 func (st *resourceSyncTask) prepareDependencies(references []smith_v1.Reference) (map[smith_v1.ResourceName]plugin.Dependency, error) {
@@ -725,19 +1019,24 @@ func (st *resourceSyncTask) prepareDependencies(references []smith_v1.Reference)
 			// References could refer to the same resource as a previous one.
 			continue
 		}
-		unstructuredActual := st.processedResources[reference.Resource].actual.DeepCopy() // Pass a copy to the plugin to insulate from it
-		gvk := unstructuredActual.GroupVersionKind()
-		var actual runtime.Object
-		if st.scheme.Recognizes(gvk) {
-			// Convert to typed object if we recognize the type
-			var err error
-			actual, err = st.scheme.ConvertToVersion(unstructuredActual, gvk.GroupVersion())
-			if err != nil {
-				return nil, errors.WithStack(err)
+		resInfo := st.processedResources[reference.Resource]
+		if resInfo.multiObject {
+			objects := make([]runtime.Object, 0, len(resInfo.objects))
+			for _, obj := range resInfo.objects {
+				object, err := st.toTypedObject(obj)
+				if err != nil {
+					return nil, err
+				}
+				objects = append(objects, object)
+			}
+			dependencies[reference.Resource] = plugin.Dependency{
+				Objects: objects,
 			}
-			actual.GetObjectKind().SetGroupVersionKind(gvk)
-		} else {
-			actual = unstructuredActual
+			continue
+		}
+		actual, err := st.toTypedObject(resInfo.actual)
+		if err != nil {
+			return nil, err
 		}
 		dependency := plugin.Dependency{
 			Actual: actual,
@@ -753,6 +1052,23 @@ func (st *resourceSyncTask) prepareDependencies(references []smith_v1.Reference)
 	return dependencies, nil
 }
 
+// toTypedObject returns a copy of the object to pass to a plugin.
+// The object is converted to a typed object if its kind is recognized.
+func (st *resourceSyncTask) toTypedObject(obj *unstructured.Unstructured) (runtime.Object, error) {
+	unstructuredActual := obj.DeepCopy() // Pass a copy to the plugin to insulate from it
+	gvk := unstructuredActual.GroupVersionKind()
+	if !st.scheme.Recognizes(gvk) {
+		return unstructuredActual, nil
+	}
+	// Convert to typed object if we recognize the type
+	actual, err := st.scheme.ConvertToVersion(unstructuredActual, gvk.GroupVersion())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	actual.GetObjectKind().SetGroupVersionKind(gvk)
+	return actual, nil
+}
+
 func (st *resourceSyncTask) prepareServiceBindingDependency(dependency *plugin.Dependency, obj *sc_v1b1.ServiceBinding) error {
 	secret, exists, err := st.store.Get(core_v1.SchemeGroupVersion.WithKind("Secret"), obj.Namespace, obj.Spec.SecretName)
 	if err != nil {
@@ -784,14 +1100,18 @@ func (st *resourceSyncTask) createOrUpdate(spec *unstructured.Unstructured, actu
 	}
 	switch actual {
 	case nil:
+		err = st.accessChecker.checkAccess(verbCreate, gvk, st.bundle.Namespace, spec.GetName())
+		if err != nil {
+			return nil, false, err
+		}
 		return st.createResource(resClient, spec)
 	default:
 		return st.updateResource(resClient, spec, actual)
 	}
 }
 
-func (st *resourceSyncTask) createResource(resClient dynamic.ResourceInterface, spec *unstructured.Unstructured) (actualRet *unstructured.Unstructured, retriableError bool, e error) {
-	spec, err := st.specChecker.BeforeCreate(st.logger, spec)
+func (st *resourceSyncTask) createResource(resClient dynamic.ResourceInterface, originalSpec *unstructured.Unstructured) (actualRet *unstructured.Unstructured, retriableError bool, e error) {
+	spec, err := st.specChecker.BeforeCreate(st.logger, originalSpec)
 	if err != nil {
 		return nil, false, errors.Wrap(err, "object specification pre-processing failed")
 	}
@@ -802,6 +1122,11 @@ func (st *resourceSyncTask) createResource(resClient dynamic.ResourceInterface,
 		st.logger.Info("Object created", ctrlLogz.ObjectGk(gvk.GroupKind()), ctrlLogz.Object(spec))
 		return response, false, nil
 	}
+	if api_errors.IsAlreadyExists(err) && st.managedObjectsOnly {
+		// Objects without the managed-by label are not watched so the object may never appear in the Store.
+		// Fetch it and update it instead, which labels it.
+		return st.updateExistingResource(resClient, originalSpec)
+	}
 	if api_errors.IsAlreadyExists(err) {
 		// We let the next processKey() iteration, triggered by someone else creating the resource, to finish the work.
 		err = api_errors.NewConflict(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, spec.GetName(), err)
@@ -816,12 +1141,35 @@ func (st *resourceSyncTask) createResource(resClient dynamic.ResourceInterface,
 	return nil, true, errors.WithStack(err)
 }
 
+// updateExistingResource fetches the object that was not found in the Store and updates it if it is managed
+// by the Bundle.
+func (st *resourceSyncTask) updateExistingResource(resClient dynamic.ResourceInterface, spec *unstructured.Unstructured) (actualRet *unstructured.Unstructured, retriableError bool, e error) {
+	actual, err := resClient.Get(spec.GetName(), meta_v1.GetOptions{})
+	if err != nil {
+		if api_errors.IsNotFound(err) {
+			// Deleted since the create call, the next iteration will create it again
+			gvk := spec.GroupVersionKind()
+			err = api_errors.NewConflict(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, spec.GetName(), err)
+			return nil, false, errors.Wrap(err, "object found, but was deleted before it could be fetched (will re-process)")
+		}
+		return nil, true, errors.Wrap(err, "failed to fetch existing object")
+	}
+	if err = st.checkActualObject(actual, actual.GetNamespace() == meta_v1.NamespaceNone); err != nil {
+		return nil, false, err
+	}
+	st.logger.Info("Object found but not in Store, updating it", ctrlLogz.ObjectGk(spec.GroupVersionKind().GroupKind()), ctrlLogz.Object(spec))
+	return st.updateResource(resClient, spec, actual)
+}
+
 // Mutates spec and actual.
 func (st *resourceSyncTask) updateResource(resClient dynamic.ResourceInterface, spec *unstructured.Unstructured, actual runtime.Object) (actualRet *unstructured.Unstructured, retriableError bool, e error) {
 	st.logger.Debug("Object found, checking spec", ctrlLogz.ObjectGk(spec.GroupVersionKind().GroupKind()), ctrlLogz.Object(spec))
 	// Compare spec and existing resource
 	updated, match, difference, err := st.specChecker.CompareActualVsSpec(st.logger, spec, actual)
 	if err != nil {
+		if cause := errors.Cause(err); specchecker.IsRecreateRequired(cause) {
+			return st.deleteForRecreate(resClient, spec.GroupVersionKind(), actual, cause)
+		}
 		return nil, false, errors.Wrap(err, "specification check failed")
 	}
 
@@ -837,9 +1185,13 @@ func (st *resourceSyncTask) updateResource(resClient dynamic.ResourceInterface,
 		st.logger.Debug("Object has correct spec", ctrlLogz.Object(spec))
 		return updated, false, nil
 	}
-	st.logger.Sugar().Infof("Objects are different (`a` is specification and `b` is the actual object): %s", difference)
+	st.logger.Sugar().Infof("Objects are different (`a` is specification and `b` is the actual object): %s", st.redactor.redact(difference))
 
 	// Update if different
+	err = st.accessChecker.checkAccess(verbUpdate, updated.GroupVersionKind(), st.bundle.Namespace, updated.GetName())
+	if err != nil {
+		return nil, false, err
+	}
 	updated, err = resClient.Update(updated, meta_v1.UpdateOptions{})
 	if err != nil {
 		if api_errors.IsConflict(err) {
@@ -857,3 +1209,40 @@ func (st *resourceSyncTask) updateResource(resClient dynamic.ResourceInterface,
 	st.logger.Info("Object updated", ctrlLogz.Object(spec))
 	return updated, false, nil
 }
+
+// deleteForRecreate deletes the actual object so that it can be created again using the new specification.
+// recreateErr is returned back if the object was deleted successfully.
+func (st *resourceSyncTask) deleteForRecreate(resClient dynamic.ResourceInterface, gvk schema.GroupVersionKind, actual runtime.Object, recreateErr error) (actualRet *unstructured.Unstructured, retriableError bool, e error) {
+	actualMeta := actual.(meta_v1.Object)
+	err := st.accessChecker.checkAccess(verbDelete, gvk, st.bundle.Namespace, actualMeta.GetName())
+	if err != nil {
+		return nil, false, err
+	}
+	st.logger.Info("Deleting object to re-create it", zap.Error(recreateErr))
+	uid := actualMeta.GetUID()
+	// Background propagation is used so that the object goes away immediately and can be re-created
+	policy := meta_v1.DeletePropagationBackground
+	err = resClient.Delete(actualMeta.GetName(), &meta_v1.DeleteOptions{
+		Preconditions: &meta_v1.Preconditions{
+			UID: &uid,
+		},
+		PropagationPolicy: &policy,
+	})
+	if err != nil && !api_errors.IsNotFound(err) {
+		if api_errors.IsConflict(err) {
+			// We let the next processKey() iteration, triggered by someone else re-creating the resource, finish the work.
+			return nil, false, errors.Wrap(err, "object deletion resulted in conflict (will re-process)")
+		}
+		return nil, true, errors.Wrap(err, "failed to delete object to re-create it")
+	}
+	return nil, false, recreateErr
+}
+
+// isClusterScoped returns true if objects of the GVK are cluster-scoped according to the REST mapper.
+func isClusterScoped(restMapper meta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	rm, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get rest mapping for %s", gvk)
+	}
+	return rm.Scope.Name() == meta.RESTScopeNameRoot, nil
+}
@@ -0,0 +1,419 @@
+package bundlec
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/util"
+	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
+	"github.com/pkg/errors"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// secretReferencePathPrefix is the prefix of paths of secret references, the rest of the path is the key.
+	secretReferencePathPrefix = "data."
+
+	// secretPlacementMessage explains where values of secret references can be used.
+	secretPlacementMessage = "values of Secrets can only be used in data or stringData of a Secret, " +
+		"as a value of an environment variable of a container, as parameters of a ServiceInstance or " +
+		"a ServiceBinding or in a spec of a plugin that only produces Secrets"
+
+	redactedValue = "<redacted>"
+)
+
+var (
+	secretGK          = schema.GroupKind{Group: core_v1.GroupName, Kind: "Secret"}
+	serviceInstanceGK = sc_v1b1.SchemeGroupVersion.WithKind("ServiceInstance").GroupKind()
+	serviceBindingGK  = sc_v1b1.SchemeGroupVersion.WithKind("ServiceBinding").GroupKind()
+)
+
+// secretPlacement is how a value of a secret reference is placed into a spec.
+type secretPlacement int
+
+const (
+	// secretPlacementForbidden means the value cannot be used at the path.
+	secretPlacementForbidden secretPlacement = iota
+	// secretPlacementData means the value is base64 encoded and placed into data of a Secret.
+	secretPlacementData
+	// secretPlacementStringData means the value is placed into stringData of a Secret.
+	secretPlacementStringData
+	// secretPlacementEnv means the value of the environment variable is replaced with a secretKeyRef.
+	secretPlacementEnv
+	// secretPlacementParameters means parameters of a ServiceInstance or a ServiceBinding are replaced with
+	// a parametersFrom entry.
+	secretPlacementParameters
+	// secretPlacementPluginSpec means the value is placed into a spec of a plugin that only produces Secrets.
+	secretPlacementPluginSpec
+)
+
+// specTarget describes the spec references are resolved in. It determines where values of secret
+// references may be used.
+type specTarget struct {
+	// gk is the kind of the object. Empty if the spec is a plugin spec.
+	gk schema.GroupKind
+	// pluginSpec is true if the spec is a plugin spec.
+	pluginSpec bool
+	// secretPlugin is true if the plugin only produces Secrets.
+	secretPlugin bool
+}
+
+func pluginTarget(description *plugin.Description) specTarget {
+	secretPlugin := true
+	for _, gvk := range description.OutputGVKs() {
+		if gvk.GroupKind() != secretGK {
+			secretPlugin = false
+			break
+		}
+	}
+	return specTarget{
+		pluginSpec:   true,
+		secretPlugin: secretPlugin,
+	}
+}
+
+// resourceTarget returns the target for the spec of the resource. false is returned if it cannot be determined.
+func resourceTarget(res *smith_v1.Resource, pluginContainers map[smith_v1.PluginName]plugin.Container) (specTarget, bool) {
+	switch {
+	case res.Spec.Object != nil:
+		gvk := res.Spec.Object.GetObjectKind().GroupVersionKind()
+		return specTarget{gk: gvk.GroupKind()}, true
+	case res.Spec.Plugin != nil:
+		pluginContainer, ok := pluginContainers[res.Spec.Plugin.Name]
+		if !ok {
+			return specTarget{}, false
+		}
+		return pluginTarget(pluginContainer.Plugin.Describe()), true
+	default:
+		return specTarget{}, false
+	}
+}
+
+// secretPlacement returns how a value of a secret reference is placed at the path.
+// Path has the same format as the path passed to specProcessor.ProcessValue().
+func (t specTarget) secretPlacement(path []string) secretPlacement {
+	if t.pluginSpec {
+		if t.secretPlugin {
+			return secretPlacementPluginSpec
+		}
+		return secretPlacementForbidden
+	}
+	n := len(path)
+	switch {
+	case t.gk == secretGK && n == 2 && path[0] == "data":
+		return secretPlacementData
+	case t.gk == secretGK && n == 2 && path[0] == "stringData":
+		return secretPlacementStringData
+	case (t.gk == serviceInstanceGK || t.gk == serviceBindingGK) && n == 2 && path[0] == "spec" && path[1] == "parameters":
+		return secretPlacementParameters
+	case n >= 5 && path[n-1] == "value" && isIndex(path[n-2]) && path[n-3] == "env" && isIndex(path[n-4]) &&
+		(path[n-5] == "containers" || path[n-5] == "initContainers"):
+		return secretPlacementEnv
+	default:
+		return secretPlacementForbidden
+	}
+}
+
+func isIndex(pathElement string) bool {
+	return strings.HasPrefix(pathElement, "[") && strings.HasSuffix(pathElement, "]")
+}
+
+// formatPath formats the path for error messages, e.g. "spec.containers[0].env[1].value".
+func formatPath(path []string) string {
+	var sb strings.Builder
+	for _, element := range path {
+		if sb.Len() > 0 && !isIndex(element) {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(element)
+	}
+	return sb.String()
+}
+
+// secretValue is a resolved value of a secret reference. It is kept separately from other resolved values
+// so that it is only placed where it does not end up in the body of a non-Secret object.
+type secretValue struct {
+	reference  smith_v1.ReferenceName
+	secretName string
+	key        string
+	value      []byte
+}
+
+func (sv *secretValue) String() (string, error) {
+	if !utf8.Valid(sv.value) {
+		return "", errors.Errorf("cannot expand non-UTF8 value of key %q of Secret %q", sv.key, sv.secretName)
+	}
+	return string(sv.value), nil
+}
+
+func (sv *secretValue) secretKeyRef() map[string]interface{} {
+	return map[string]interface{}{
+		"secretKeyRef": map[string]interface{}{
+			"name": sv.secretName,
+			"key":  sv.key,
+		},
+	}
+}
+
+// secretReferenceKey returns the key of the Secret the secret reference points to.
+func secretReferenceKey(reference smith_v1.Reference) (string, error) {
+	if !strings.HasPrefix(reference.Path, secretReferencePathPrefix) {
+		return "", errors.Errorf("path of %q reference %q must be %q followed by a key of the Secret",
+			smith_v1.ReferenceModifierSecret, reference.Name, secretReferencePathPrefix)
+	}
+	key := strings.TrimPrefix(reference.Path, secretReferencePathPrefix)
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return "", errors.Errorf("path of %q reference %q contains an invalid key %q: %s",
+			smith_v1.ReferenceModifierSecret, reference.Name, key, strings.Join(errs, ", "))
+	}
+	return key, nil
+}
+
+// resolveSecretReference resolves a secret reference against a Secret or the Secret of a ServiceBinding.
+func resolveSecretReference(resInfo *resourceInfo, reference smith_v1.Reference) (*secretValue, error) {
+	key, err := secretReferenceKey(reference)
+	if err != nil {
+		return nil, err
+	}
+	var secret *core_v1.Secret
+	switch {
+	case resInfo.serviceBindingSecret != nil:
+		secret = resInfo.serviceBindingSecret
+	case resInfo.actual != nil && !resInfo.multiObject && util.IsSecret(resInfo.actual):
+		secret = &core_v1.Secret{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(resInfo.actual.Object, secret); err != nil {
+			return nil, errors.Wrapf(err, "failed to process reference %q", reference.Name)
+		}
+	default:
+		return nil, errors.Errorf("%q requested, but %q is not a Secret or a ServiceBinding",
+			smith_v1.ReferenceModifierSecret, reference.Resource)
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return nil, errors.Errorf("failed to process reference %q: key %q not found in Secret %q", reference.Name, key, secret.Name)
+	}
+	return &secretValue{
+		reference:  reference.Name,
+		secretName: secret.Name,
+		key:        key,
+		value:      value,
+	}, nil
+}
+
+// placeSecretValue places the value of a secret reference into obj under key.
+// Path is the path of the key.
+func placeSecretValue(target specTarget, obj map[string]interface{}, key string, sv *secretValue, path []string) error {
+	switch target.secretPlacement(path) {
+	case secretPlacementData:
+		obj[key] = base64.StdEncoding.EncodeToString(sv.value)
+	case secretPlacementStringData, secretPlacementPluginSpec:
+		value, err := sv.String()
+		if err != nil {
+			return err
+		}
+		obj[key] = value
+	case secretPlacementEnv:
+		if _, ok := obj["valueFrom"]; ok {
+			return errors.Errorf("secret reference %q cannot be used at %s because valueFrom is set", sv.reference, formatPath(path))
+		}
+		delete(obj, key)
+		obj["valueFrom"] = sv.secretKeyRef()
+	case secretPlacementParameters:
+		var parameters map[string]interface{}
+		if err := json.Unmarshal(sv.value, &parameters); err != nil {
+			// Error is not included because it may contain parts of the value
+			return errors.Errorf("secret reference %q is used as parameters but key %q of Secret %q does not contain a JSON object",
+				sv.reference, sv.key, sv.secretName)
+		}
+		var parametersFrom []interface{}
+		if existing, ok := obj["parametersFrom"]; ok {
+			if parametersFrom, ok = existing.([]interface{}); !ok {
+				return errors.Errorf("parametersFrom must be a list to use secret reference %q as parameters", sv.reference)
+			}
+		}
+		delete(obj, key)
+		obj["parametersFrom"] = append(parametersFrom, sv.secretKeyRef())
+	default:
+		return misplacedSecretReferenceError(sv.reference, path)
+	}
+	return nil
+}
+
+func misplacedSecretReferenceError(name smith_v1.ReferenceName, path []string) error {
+	return errors.Errorf("secret reference %q cannot be used at %s: %s", name, formatPath(path), secretPlacementMessage)
+}
+
+// secretReferenceNames returns names of secret references of the resource.
+func secretReferenceNames(res *smith_v1.Resource) sets.String {
+	names := sets.NewString()
+	for _, reference := range res.References {
+		if reference.Modifier == smith_v1.ReferenceModifierSecret && reference.Name != "" {
+			names.Insert(string(reference.Name))
+		}
+	}
+	return names
+}
+
+// misplacedSecretReferences finds secret references that are used where their values would be exposed.
+// Path has the same format as the path passed to specProcessor.ProcessValue(), fieldPath is the same path
+// for the returned errors.
+func misplacedSecretReferences(value interface{}, target specTarget, secretReferences sets.String, path []string, fieldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch v := value.(type) {
+	case string:
+		match := reference.FindStringSubmatch(v)
+		if match != nil && secretReferences.Has(match[2]) && target.secretPlacement(path) == secretPlacementForbidden {
+			allErrs = append(allErrs, field.Forbidden(fieldPath, "secret reference "+v+" cannot be used here: "+secretPlacementMessage))
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // for deterministic order of errors
+		for _, key := range keys {
+			allErrs = append(allErrs, misplacedSecretReferences(v[key], target, secretReferences, appendPath(path, key), fieldPath.Child(key))...)
+		}
+	case []interface{}:
+		for i, val := range v {
+			allErrs = append(allErrs, misplacedSecretReferences(val, target, secretReferences, appendPath(path, indexPathElement(i)), fieldPath.Index(i))...)
+		}
+	}
+	return allErrs
+}
+
+// validateSecretReferences checks paths of secret references of the resource and that they are only used where
+// their values are not exposed.
+func validateSecretReferences(res *smith_v1.Resource, pluginContainers map[smith_v1.PluginName]plugin.Container, path *field.Path) field.ErrorList {
+	secretReferences := secretReferenceNames(res)
+	if secretReferences.Len() == 0 {
+		return nil
+	}
+	var allErrs field.ErrorList
+	for i, reference := range res.References {
+		if reference.Modifier != smith_v1.ReferenceModifierSecret {
+			continue
+		}
+		if _, err := secretReferenceKey(reference); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("references").Index(i).Child("path"), reference.Path, err.Error()))
+		}
+	}
+	target, ok := resourceTarget(res, pluginContainers)
+	if !ok {
+		return allErrs
+	}
+	specPath := path.Child("spec")
+	switch {
+	case res.Spec.Object != nil:
+		obj, err := util.RuntimeToUnstructured(res.Spec.Object)
+		if err != nil {
+			return append(allErrs, field.InternalError(specPath.Child("object"), err))
+		}
+		allErrs = append(allErrs, misplacedSecretReferences(obj.Object, target, secretReferences, nil, specPath.Child("object"))...)
+	case res.Spec.Plugin != nil:
+		allErrs = append(allErrs, misplacedSecretReferences(res.Spec.Plugin.Spec, target, secretReferences, nil, specPath.Child("plugin", "spec"))...)
+	}
+	return allErrs
+}
+
+// redactor replaces values of Secrets in strings.
+type redactor struct {
+	values []string
+}
+
+func newRedactor(secretValues []*secretValue) *redactor {
+	values := make([]string, 0, 2*len(secretValues))
+	for _, sv := range secretValues {
+		if len(sv.value) == 0 {
+			continue
+		}
+		// The base64 encoded form is what is placed into data of a Secret
+		values = append(values, string(sv.value), base64.StdEncoding.EncodeToString(sv.value))
+	}
+	// Longer values first so that a value containing another value is fully redacted
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	return &redactor{
+		values: values,
+	}
+}
+
+// redact replaces values of Secrets in the string. nil redactor does not replace anything.
+func (r *redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, value := range r.values {
+		s = strings.Replace(s, value, redactedValue, -1)
+	}
+	return s
+}
+
+// redactObject returns a copy of the object with values of Secrets replaced in all strings.
+// nil redactor does not replace anything.
+func (r *redactor) redactObject(obj interface{}) interface{} {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(o))
+		for k, v := range o {
+			result[k] = r.redactObject(v)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(o))
+		for i, v := range o {
+			result[i] = r.redactObject(v)
+		}
+		return result
+	case string:
+		return r.redact(o)
+	default:
+		return obj
+	}
+}
+
+// redactStatus replaces values of Secrets in the error of the status.
+// The original error is kept if it does not contain any values so that its cause is preserved.
+func (r *redactor) redactStatus(status resourceStatus) resourceStatus {
+	statusErr, ok := status.(resourceStatusError)
+	if !ok || statusErr.err == nil {
+		return status
+	}
+	msg := statusErr.err.Error()
+	redacted := r.redact(msg)
+	if redacted == msg {
+		return status
+	}
+	statusErr.err = errors.New(redacted)
+	return statusErr
+}
+
+func appendPath(path []string, element string) []string {
+	result := make([]string, len(path), len(path)+1)
+	copy(result, path)
+	return append(result, element)
+}
+
+// isSecretReference returns true if the raw value is a reference to a secret reference of the resource.
+func isSecretReference(res *smith_v1.Resource, raw *runtime.RawExtension) bool {
+	if raw == nil {
+		return false
+	}
+	var value string
+	if err := json.Unmarshal(raw.Raw, &value); err != nil {
+		return false
+	}
+	match := reference.FindStringSubmatch(value)
+	return match != nil && secretReferenceNames(res).Has(match[2])
+}
@@ -0,0 +1,88 @@
+package bundlec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSecretPlacement(t *testing.T) {
+	t.Parallel()
+	deploymentTarget := specTarget{gk: schema.GroupKind{Group: "apps", Kind: "Deployment"}}
+	inputs := []struct {
+		name     string
+		target   specTarget
+		path     []string
+		expected secretPlacement
+	}{
+		{"Secret data", specTarget{gk: secretGK}, []string{"data", "key"}, secretPlacementData},
+		{"Secret stringData", specTarget{gk: secretGK}, []string{"stringData", "key"}, secretPlacementStringData},
+		{"Secret metadata", specTarget{gk: secretGK}, []string{"metadata", "name"}, secretPlacementForbidden},
+		{"ConfigMap data", specTarget{gk: schema.GroupKind{Kind: "ConfigMap"}}, []string{"data", "key"}, secretPlacementForbidden},
+		{"ServiceInstance parameters", specTarget{gk: serviceInstanceGK}, []string{"spec", "parameters"}, secretPlacementParameters},
+		{"ServiceBinding parameters", specTarget{gk: serviceBindingGK}, []string{"spec", "parameters"}, secretPlacementParameters},
+		{"ServiceInstance parameter", specTarget{gk: serviceInstanceGK}, []string{"spec", "parameters", "key"}, secretPlacementForbidden},
+		{"container env", deploymentTarget, []string{"spec", "template", "spec", "containers", "[0]", "env", "[1]", "value"}, secretPlacementEnv},
+		{"init container env", deploymentTarget, []string{"spec", "template", "spec", "initContainers", "[0]", "env", "[1]", "value"}, secretPlacementEnv},
+		{"container env name", deploymentTarget, []string{"spec", "template", "spec", "containers", "[0]", "env", "[1]", "name"}, secretPlacementForbidden},
+		{"container args", deploymentTarget, []string{"spec", "template", "spec", "containers", "[0]", "args", "[1]"}, secretPlacementForbidden},
+		{"plugin that produces Secrets", specTarget{pluginSpec: true, secretPlugin: true}, []string{"key"}, secretPlacementPluginSpec},
+		{"plugin that produces other objects", specTarget{pluginSpec: true}, []string{"key"}, secretPlacementForbidden},
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, input.expected, input.target.secretPlacement(input.path))
+		})
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	t.Parallel()
+	r := newRedactor([]*secretValue{
+		{value: []byte("pass")},
+		{value: []byte("password")},
+		{value: []byte{}},
+	})
+	assert.Equal(t, "a: <redacted>, b: <redacted>, c: <redacted>", r.redact("a: password, b: pass, c: cGFzc3dvcmQ="))
+
+	var nilRedactor *redactor
+	assert.Equal(t, "password", nilRedactor.redact("password"))
+
+	status := resourceStatusError{
+		err:             errors.New("invalid value password"),
+		isExternalError: true,
+	}
+	redacted, ok := r.redactStatus(status).(resourceStatusError)
+	if assert.True(t, ok) {
+		assert.EqualError(t, redacted.err, "invalid value <redacted>")
+		assert.True(t, redacted.isExternalError)
+	}
+
+	// Errors without values of Secrets are kept as is to preserve their cause
+	status.err = errors.New("no values")
+	assert.Equal(t, status, r.redactStatus(status))
+
+	obj := map[string]interface{}{
+		"a": "password",
+		"b": []interface{}{"x pass", int64(1)},
+		"c": map[string]interface{}{
+			"d": "no values",
+			"e": true,
+		},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"a": "<redacted>",
+		"b": []interface{}{"x <redacted>", int64(1)},
+		"c": map[string]interface{}{
+			"d": "no values",
+			"e": true,
+		},
+	}, r.redactObject(obj))
+	// Object itself is not mutated
+	assert.Equal(t, "password", obj["a"])
+	assert.Equal(t, obj, nilRedactor.redactObject(obj))
+}
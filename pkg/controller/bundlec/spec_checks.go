@@ -0,0 +1,69 @@
+package bundlec
+
+import (
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/policy"
+	"github.com/pkg/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Checks in this file are shared by the controller and BundleValidator so that the admission webhook
+// rejects exactly what the controller would fail to process.
+
+// isBundleNamespace returns true if an object with the namespace can be created by the Bundle in bundleNamespace.
+// Empty namespace is allowed, it is set to the Bundle namespace by the controller.
+func isBundleNamespace(namespace, bundleNamespace string) bool {
+	return namespace == meta_v1.NamespaceNone || namespace == bundleNamespace
+}
+
+// prohibitedAnnotationKeys returns sorted keys of annotations that cannot be set by the user.
+func prohibitedAnnotationKeys(annotations map[string]string) []string {
+	var keys []string
+	for _, key := range prohibitedAnnotations.List() {
+		if _, ok := annotations[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// controllerOwnerReferenceIndex returns the index of the first controller owner reference or -1 if there is none.
+func controllerOwnerReferenceIndex(refs []meta_v1.OwnerReference) int {
+	for i, ref := range refs {
+		if ref.Controller != nil && *ref.Controller {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkPluginObjectName returns an error if the object name is not specified for a plugin that produces a single object.
+// Only plugins that produce multiple objects name objects themselves.
+func checkPluginObjectName(spec *smith_v1.PluginSpec, description *plugin.Description) error {
+	if spec.ObjectName == "" && !description.IsMultiObject() {
+		return errors.Errorf("objectName must be specified for plugin %q", spec.Name)
+	}
+	return nil
+}
+
+// checkObjectPolicies returns the first error of a policy that does not allow the object.
+func checkObjectPolicies(policies []policy.Policy, obj *unstructured.Unstructured) error {
+	for i := range policies {
+		if err := policies[i].CheckObject(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPluginPolicies returns the first error of a policy that does not allow the plugin.
+func checkPluginPolicies(policies []policy.Policy, name smith_v1.PluginName) error {
+	for i := range policies {
+		if err := policies[i].CheckPlugin(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
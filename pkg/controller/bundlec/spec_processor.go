@@ -9,16 +9,25 @@ import (
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/atlassian/smith/pkg/resources"
 	"github.com/pkg/errors"
+	core_v1 "k8s.io/api/core/v1"
+	net_v1b1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 var (
 	// ?s allows us to match multiline expressions.
 	reference = regexp.MustCompile(`(?s)^(!+)\{(.+)}$`)
+
+	serviceGK = schema.GroupKind{Group: core_v1.GroupName, Kind: "Service"}
+	ingressGK = schema.GroupKind{Group: net_v1b1.GroupName, Kind: "Ingress"}
 )
 
 type specProcessor struct {
 	variables map[smith_v1.ReferenceName]interface{}
+	// target determines where values of secret references may be used.
+	target specTarget
 }
 
 // noExampleError occurs when we try to process the spec with examples rather
@@ -108,15 +117,33 @@ func resolveAllReferences(
 
 func (sp *specProcessor) ProcessObject(obj map[string]interface{}, path ...string) error {
 	for key, value := range obj {
-		v, err := sp.ProcessValue(value, append(path, key)...)
+		valuePath := append(path, key)
+		v, err := sp.ProcessValue(value, valuePath...)
 		if err != nil {
 			return err
 		}
+		if sv, ok := v.(*secretValue); ok {
+			if err = placeSecretValue(sp.target, obj, key, sv, valuePath); err != nil {
+				return err
+			}
+			continue
+		}
 		obj[key] = v
 	}
 	return nil
 }
 
+// secretValues returns resolved values of secret references.
+func (sp *specProcessor) secretValues() []*secretValue {
+	var result []*secretValue
+	for _, v := range sp.variables {
+		if sv, ok := v.(*secretValue); ok {
+			result = append(result, sv)
+		}
+	}
+	return result
+}
+
 func (sp *specProcessor) ProcessValue(value interface{}, path ...string) (interface{}, error) {
 	switch v := value.(type) {
 	case string:
@@ -137,10 +164,20 @@ func (sp *specProcessor) ProcessValue(value interface{}, path ...string) (interf
 		// slice may have mixed types.
 		result := make([]interface{}, length)
 		for i := 0; i < length; i++ {
-			res, err := sp.ProcessValue(rv.Index(i).Interface(), append(path, fmt.Sprintf("[%d]", i))...)
+			elemPath := append(path, indexPathElement(i))
+			res, err := sp.ProcessValue(rv.Index(i).Interface(), elemPath...)
 			if err != nil {
 				return nil, err
 			}
+			if sv, ok := res.(*secretValue); ok {
+				// Only plugin specs can have values of secret references in lists
+				if sp.target.secretPlacement(elemPath) != secretPlacementPluginSpec {
+					return nil, misplacedSecretReferenceError(sv.reference, elemPath)
+				}
+				if res, err = sv.String(); err != nil {
+					return nil, err
+				}
+			}
 			result[i] = res
 		}
 		value = result
@@ -179,6 +216,14 @@ func jsonPathReferenceResolver(resInfos map[smith_v1.ResourceName]*resourceInfo,
 			return nil, errors.Errorf("%q requested, but %q is not a ServiceBinding", smith_v1.ReferenceModifierBindSecret, reference.Resource)
 		}
 		objToTraverse = resInfo.serviceBindingSecret
+	case smith_v1.ReferenceModifierSecret:
+		return resolveSecretReference(resInfo, reference)
+	case smith_v1.ReferenceModifierAddress:
+		address, err := loadBalancerAddress(resInfo.actual)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to process reference %q", reference.Name)
+		}
+		objToTraverse = address
 	default:
 		return nil, errors.Errorf("reference modifier %q not understood for %q", reference.Modifier, reference.Resource)
 	}
@@ -205,3 +250,43 @@ func jsonPathReferenceResolver(resInfos map[smith_v1.ResourceName]*resourceInfo,
 
 	return fieldValue, nil
 }
+
+func indexPathElement(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
+
+// loadBalancerAddress extracts the address of the first load balancer ingress point of a Service or an Ingress.
+// Returned object has "hostname" and "ip" fields as published by the load balancer and an "address" field
+// which is the hostname if it is set and the ip otherwise.
+func loadBalancerAddress(obj *unstructured.Unstructured) (map[string]interface{}, error) {
+	gk := obj.GroupVersionKind().GroupKind()
+	if gk != serviceGK && gk != ingressGK {
+		return nil, errors.Errorf("%q modifier is only supported for Service and Ingress objects, got %q",
+			smith_v1.ReferenceModifierAddress, gk.Kind)
+	}
+	lbIngresses, _, err := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	if err != nil {
+		return nil, err
+	}
+	for _, lbIngress := range lbIngresses {
+		lbIngressMap, ok := lbIngress.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hostname, _ := lbIngressMap["hostname"].(string)
+		ip, _ := lbIngressMap["ip"].(string)
+		address := hostname
+		if address == "" {
+			address = ip
+		}
+		if address == "" {
+			continue
+		}
+		return map[string]interface{}{
+			"address":  address,
+			"hostname": hostname,
+			"ip":       ip,
+		}, nil
+	}
+	return nil, errors.New("load balancer address has not been assigned yet")
+}
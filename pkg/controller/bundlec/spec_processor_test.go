@@ -8,7 +8,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestSpecProcessor(t *testing.T) {
@@ -209,6 +211,256 @@ func TestSpecProcessorBindSecretWithJsonField(t *testing.T) {
 	assert.Equal(t, expected, obj)
 }
 
+func TestSpecProcessorSecret(t *testing.T) {
+	t.Parallel()
+	references := []smith_v1.Reference{
+		{
+			Name:     "password",
+			Resource: "resbinding",
+			Path:     "data.password",
+			Modifier: "secret",
+		},
+		{
+			Name:     "parameters",
+			Resource: "resbinding",
+			Path:     "data.parameters",
+			Modifier: "secret",
+		},
+		{
+			Name:     "token",
+			Resource: "ressecret",
+			Path:     "data.token",
+			Modifier: "secret",
+		},
+	}
+	inputs := []struct {
+		name     string
+		target   specTarget
+		obj      map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:   "Secret",
+			target: specTarget{gk: secretGK},
+			obj: map[string]interface{}{
+				"data": map[string]interface{}{
+					"password": "!{password}",
+				},
+				"stringData": map[string]interface{}{
+					"token": "!{token}",
+				},
+			},
+			expected: map[string]interface{}{
+				"data": map[string]interface{}{
+					"password": "c2VjcmV0",
+				},
+				"stringData": map[string]interface{}{
+					"token": "token",
+				},
+			},
+		},
+		{
+			name:   "environment variable",
+			target: specTarget{gk: schema.GroupKind{Group: "apps", Kind: "Deployment"}},
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{
+									"env": []interface{}{
+										map[string]interface{}{
+											"name":  "TOKEN",
+											"value": "!{token}",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{
+									"env": []interface{}{
+										map[string]interface{}{
+											"name": "TOKEN",
+											"valueFrom": map[string]interface{}{
+												"secretKeyRef": map[string]interface{}{
+													"name": "secret1",
+													"key":  "token",
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "ServiceInstance parameters",
+			target: specTarget{gk: serviceInstanceGK},
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"parameters": "!{parameters}",
+				},
+			},
+			expected: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"parametersFrom": []interface{}{
+						map[string]interface{}{
+							"secretKeyRef": map[string]interface{}{
+								"name": "binding-secret",
+								"key":  "parameters",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "plugin that produces Secrets",
+			target: specTarget{pluginSpec: true, secretPlugin: true},
+			obj: map[string]interface{}{
+				"password": "!{password}",
+				"list":     []interface{}{"!{token}"},
+			},
+			expected: map[string]interface{}{
+				"password": "secret",
+				"list":     []interface{}{"token"},
+			},
+		},
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input.name, func(t *testing.T) {
+			t.Parallel()
+			sp, err := newSpec(processedResources(), references)
+			require.NoError(t, err)
+			sp.target = input.target
+			require.NoError(t, sp.ProcessObject(input.obj))
+			assert.Equal(t, input.expected, input.obj)
+		})
+	}
+}
+
+func TestSpecProcessorSecretMisplaced(t *testing.T) {
+	t.Parallel()
+	inputs := []struct {
+		name   string
+		target specTarget
+		obj    map[string]interface{}
+		err    string
+	}{
+		{
+			name:   "ConfigMap",
+			target: specTarget{gk: schema.GroupKind{Kind: "ConfigMap"}},
+			obj: map[string]interface{}{
+				"data": map[string]interface{}{
+					"password": "!{password}",
+				},
+			},
+			err: `secret reference "password" cannot be used at data.password: ` + secretPlacementMessage,
+		},
+		{
+			name:   "list in Secret",
+			target: specTarget{gk: secretGK},
+			obj: map[string]interface{}{
+				"data": []interface{}{"!{password}"},
+			},
+			err: `secret reference "password" cannot be used at data[0]: ` + secretPlacementMessage,
+		},
+		{
+			name:   "nested ServiceInstance parameter",
+			target: specTarget{gk: serviceInstanceGK},
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"parameters": map[string]interface{}{
+						"password": "!{password}",
+					},
+				},
+			},
+			err: `secret reference "password" cannot be used at spec.parameters.password: ` + secretPlacementMessage,
+		},
+		{
+			name:   "ServiceInstance parameters that are not an object",
+			target: specTarget{gk: serviceInstanceGK},
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"parameters": "!{password}",
+				},
+			},
+			err: `secret reference "password" is used as parameters but key "password" of Secret "binding-secret" does not contain a JSON object`,
+		},
+		{
+			name:   "plugin that produces a ConfigMap",
+			target: specTarget{pluginSpec: true},
+			obj: map[string]interface{}{
+				"password": "!{password}",
+			},
+			err: `secret reference "password" cannot be used at password: ` + secretPlacementMessage,
+		},
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input.name, func(t *testing.T) {
+			t.Parallel()
+			sp, err := newSpec(processedResources(), []smith_v1.Reference{
+				{
+					Name:     "password",
+					Resource: "resbinding",
+					Path:     "data.password",
+					Modifier: "secret",
+				},
+			})
+			require.NoError(t, err)
+			sp.target = input.target
+			assert.EqualError(t, sp.ProcessObject(input.obj), input.err)
+		})
+	}
+}
+
+func TestSpecProcessorAddress(t *testing.T) {
+	t.Parallel()
+	sp, err := newSpec(processedResources(), []smith_v1.Reference{
+		{
+			Name:     "host",
+			Resource: "resservice",
+			Path:     "address",
+			Modifier: "address",
+		},
+		{
+			Name:     "ip",
+			Resource: "resingress",
+			Path:     "ip",
+			Modifier: "address",
+		},
+	})
+	require.NoError(t, err)
+	obj := map[string]interface{}{
+		"ref": map[string]interface{}{
+			"host": "!{host}",
+			"ip":   "!{ip}",
+		},
+	}
+	expected := map[string]interface{}{
+		"ref": map[string]interface{}{
+			"host": "lb.example.com",
+			"ip":   "10.0.0.1",
+		},
+	}
+
+	require.NoError(t, sp.ProcessObject(obj))
+	assert.Equal(t, expected, obj)
+}
+
 func TestSpecProcessorExamples(t *testing.T) {
 	t.Parallel()
 	sp, err := newExamplesSpec([]smith_v1.Reference{
@@ -313,6 +565,51 @@ func TestSpecProcessorErrors(t *testing.T) {
 			err:          `no example value provided in reference "password"`,
 			examplesOnly: true,
 		},
+		{
+			reference: smith_v1.Reference{
+				Name:     "x",
+				Resource: "res1",
+				Path:     "data.password",
+				Modifier: "secret",
+			},
+			err: `"secret" requested, but "res1" is not a Secret or a ServiceBinding`,
+		},
+		{
+			reference: smith_v1.Reference{
+				Name:     "x",
+				Resource: "ressecret",
+				Path:     "data.password",
+				Modifier: "secret",
+			},
+			err: `failed to process reference "x": key "password" not found in Secret "secret1"`,
+		},
+		{
+			reference: smith_v1.Reference{
+				Name:     "x",
+				Resource: "ressecret",
+				Path:     "token",
+				Modifier: "secret",
+			},
+			err: `path of "secret" reference "x" must be "data." followed by a key of the Secret`,
+		},
+		{
+			reference: smith_v1.Reference{
+				Name:     "x",
+				Resource: "res1",
+				Path:     "address",
+				Modifier: "address",
+			},
+			err: `failed to process reference "x": "address" modifier is only supported for Service and Ingress objects, got ""`,
+		},
+		{
+			reference: smith_v1.Reference{
+				Name:     "x",
+				Resource: "resservicepending",
+				Path:     "address",
+				Modifier: "address",
+			},
+			err: `failed to process reference "x": load balancer address has not been assigned yet`,
+		},
 	}
 	for i, input := range inputs {
 		input := input
@@ -362,12 +659,76 @@ func processedResources() map[smith_v1.ResourceName]*resourceInfo {
 			actual: &unstructured.Unstructured{},
 			status: resourceStatusReady{},
 			serviceBindingSecret: &core_v1.Secret{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: "binding-secret",
+				},
 				Data: map[string][]byte{
-					"password": []byte("secret"),
-					"nonutf8":  {255, 254, 255},
+					"password":   []byte("secret"),
+					"nonutf8":    {255, 254, 255},
+					"parameters": []byte(`{"password":"secret"}`),
 				},
 			},
 		},
+		"ressecret": {
+			actual: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Secret",
+					"metadata": map[string]interface{}{
+						"name": "secret1",
+					},
+					"data": map[string]interface{}{
+						"token": "dG9rZW4=", // "token"
+					},
+				},
+			},
+			status: resourceStatusReady{},
+		},
+		"resservice": {
+			actual: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Service",
+					"status": map[string]interface{}{
+						"loadBalancer": map[string]interface{}{
+							"ingress": []interface{}{
+								map[string]interface{}{
+									"hostname": "lb.example.com",
+								},
+							},
+						},
+					},
+				},
+			},
+			status: resourceStatusReady{},
+		},
+		"resservicepending": {
+			actual: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Service",
+				},
+			},
+			status: resourceStatusReady{},
+		},
+		"resingress": {
+			actual: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "networking.k8s.io/v1beta1",
+					"kind":       "Ingress",
+					"status": map[string]interface{}{
+						"loadBalancer": map[string]interface{}{
+							"ingress": []interface{}{
+								map[string]interface{}{
+									"ip": "10.0.0.1",
+								},
+							},
+						},
+					},
+				},
+			},
+			status: resourceStatusReady{},
+		},
 		"resX": {
 			actual: &unstructured.Unstructured{
 				Object: map[string]interface{}{
@@ -2,8 +2,10 @@ package bundlec
 
 import (
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/policy"
 	"go.uber.org/zap"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	auth_v1 "k8s.io/api/authentication/v1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -20,20 +22,45 @@ type SpecChecker interface {
 
 type Store interface {
 	Get(gvk schema.GroupVersionKind, namespace, name string) (obj runtime.Object, exists bool, err error)
+	// GetReadOnly is like Get but does not copy the object so it must not be modified.
+	GetReadOnly(gvk schema.GroupVersionKind, namespace, name string) (obj runtime.Object, exists bool, err error)
 	ObjectsControlledBy(namespace string, uid types.UID) ([]runtime.Object, error)
 	AddInformer(schema.GroupVersionKind, cache.SharedIndexInformer) error
 	RemoveInformer(schema.GroupVersionKind) bool
+	// HasInformer returns true if objects of the GVK can be looked up in the Store.
+	HasInformer(schema.GroupVersionKind) bool
 }
 
 type BundleStore interface {
 	// Get returns Bundle based on its namespace and name.
 	Get(namespace, bundleName string) (*smith_v1.Bundle, error)
 	// GetBundlesByCrd returns Bundles which have a resource defined by CRD.
-	GetBundlesByCrd(*apiext_v1b1.CustomResourceDefinition) ([]*smith_v1.Bundle, error)
+	GetBundlesByCrd(*apiext_v1.CustomResourceDefinition) ([]*smith_v1.Bundle, error)
 	// GetBundlesByObject returns Bundles which have a resource of a particular group/kind with a name in a namespace.
 	GetBundlesByObject(gk schema.GroupKind, namespace, name string) ([]*smith_v1.Bundle, error)
+	// GetBundlesByLookup returns Bundles with plugins that looked up the object of a particular group/kind
+	// with a name in a namespace.
+	GetBundlesByLookup(gk schema.GroupKind, namespace, name string) ([]*smith_v1.Bundle, error)
+	// GetBundlesByNamespace returns Bundles in the namespace. All Bundles are returned if the namespace is empty.
+	GetBundlesByNamespace(namespace string) ([]*smith_v1.Bundle, error)
 }
 
 type SmartClient interface {
 	ForGVK(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error)
 }
+
+// ImpersonatingSmartClient is a SmartClient that performs requests on behalf of the provided user.
+type ImpersonatingSmartClient interface {
+	ForGVKAs(user *auth_v1.UserInfo, gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error)
+}
+
+// AccessReviewer checks if a user is allowed to perform an action on an object.
+type AccessReviewer interface {
+	ReviewAccess(user *auth_v1.UserInfo, verb string, gvk schema.GroupVersionKind, namespace, name string) (bool /*allowed*/, string /*reason*/, error)
+}
+
+// PolicyStore provides policies that apply to Bundles.
+type PolicyStore interface {
+	// PoliciesFor returns policies that apply to Bundles in the namespace.
+	PoliciesFor(namespace string) ([]policy.Policy, error)
+}
@@ -0,0 +1,175 @@
+package bundlec_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	auth_v1 "k8s.io/api/authentication/v1"
+	authz_v1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kube_testing "k8s.io/client-go/testing"
+)
+
+type accessReviews struct {
+	lock    sync.Mutex
+	reviews []authz_v1.SubjectAccessReviewSpec
+}
+
+// reaction responds to SubjectAccessReviews with the provided decision and records them.
+func (r *accessReviews) reaction(allowed bool, reason string) reaction {
+	return reaction{
+		verb:     "create",
+		resource: "subjectaccessreviews",
+		reactor: func(t *testing.T) kube_testing.ReactionFunc {
+			return func(action kube_testing.Action) (bool, runtime.Object, error) {
+				review := action.(kube_testing.CreateAction).GetObject().(*authz_v1.SubjectAccessReview)
+				r.lock.Lock()
+				r.reviews = append(r.reviews, review.Spec)
+				r.lock.Unlock()
+				result := review.DeepCopy()
+				result.Status = authz_v1.SubjectAccessReviewStatus{
+					Allowed: allowed,
+					Denied:  !allowed,
+					Reason:  reason,
+				}
+				return true, result, nil
+			}
+		},
+	}
+}
+
+func (r *accessReviews) get() []authz_v1.SubjectAccessReviewSpec {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]authz_v1.SubjectAccessReviewSpec(nil), r.reviews...)
+}
+
+// Should not create objects the author of the Bundle is not allowed to create
+func TestAccessReviewDeniesCreate(t *testing.T) {
+	t.Parallel()
+	reviews := &accessReviews{}
+	bundle := configMapBundle()
+	bundle.Spec.Author = &auth_v1.UserInfo{
+		Username: bundleAuthor,
+		Groups:   []string{"system:authenticated"},
+	}
+	tc := testCase{
+		appName:      testAppName,
+		namespace:    testNamespace,
+		accessReview: true,
+		bundle:       bundle,
+		mainReactors: []reaction{
+			reviews.reaction(false, "no RBAC policy matched"),
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["resM1"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			smith_testing.AssertCondition(t, bundle, smith_v1.BundleError, cond_v1.ConditionTrue)
+			resCond := smith_testing.AssertResourceCondition(t, bundle, "resM1", smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, smith_v1.ResourceReasonForbidden, resCond.Reason)
+				assert.Equal(t, `ConfigMap "m1" is forbidden: author of the Bundle "alice" is not allowed to create it: no RBAC policy matched`, resCond.Message)
+			}
+			smith_testing.AssertResourceCondition(t, bundle, "resM1", smith_v1.ResourceInProgress, cond_v1.ConditionFalse)
+
+			specs := reviews.get()
+			require.Len(t, specs, 1)
+			assert.Equal(t, bundleAuthor, specs[0].User)
+			assert.Equal(t, []string{"system:authenticated"}, specs[0].Groups)
+			assert.Equal(t, &authz_v1.ResourceAttributes{
+				Namespace: testNamespace,
+				Verb:      "create",
+				Version:   "v1",
+				Resource:  "configmaps",
+				Name:      m1,
+			}, specs[0].ResourceAttributes)
+		},
+	}
+	tc.run(t)
+}
+
+// Should create objects the author of the Bundle is allowed to create
+func TestAccessReviewAllowsCreate(t *testing.T) {
+	t.Parallel()
+	reviews := &accessReviews{}
+	bundle := configMapBundle()
+	bundle.Spec.Author = &auth_v1.UserInfo{
+		Username: bundleAuthor,
+	}
+	tc := testCase{
+		appName:      testAppName,
+		namespace:    testNamespace,
+		accessReview: true,
+		bundle:       bundle,
+		mainReactors: []reaction{
+			reviews.reaction(true, ""),
+		},
+		expectedActions: sets.NewString(
+			"POST=/api/v1/namespaces/" + testNamespace + "/configmaps",
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "POST",
+					path:   "/api/v1/namespaces/" + testNamespace + "/configmaps",
+				}: {
+					statusCode: http.StatusCreated,
+					content: []byte(`{
+							"apiVersion": "v1",
+							"kind": "ConfigMap",
+							"metadata": {
+								"name": "` + m1 + `",
+								"namespace": "` + testNamespace + `",
+								"uid": "` + string(mapNeedsAnUpdateUid) + `",
+								"ownerReferences": [
+									{
+										"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+										"kind": "` + smith_v1.BundleResourceKind + `",
+										"name": "` + bundle1 + `",
+										"uid": "` + string(bundle1uid) + `",
+										"controller": true,
+										"blockOwnerDeletion": true
+									}
+								] }
+							}`),
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			_, _, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.NoError(t, err)
+			assert.Len(t, reviews.get(), 1)
+		},
+	}
+	tc.run(t)
+}
+
+// Should not process Bundle without an author if access review is enabled
+func TestBundleWithoutAuthorNotProcessedWithAccessReview(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		appName:      testAppName,
+		namespace:    testNamespace,
+		accessReview: true,
+		bundle:       configMapBundle(),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, "author of the Bundle is not set but is required for access review (is the mutating admission webhook configured?)")
+			assert.True(t, external)
+			assert.False(t, retriable)
+		},
+	}
+	tc.run(t)
+}
@@ -0,0 +1,271 @@
+package bundlec_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbac_v1 "k8s.io/api/rbac/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	resCr1           = "resCr1"
+	cr1              = "cr1"
+	cr1uid types.UID = "cr1-uid"
+
+	clusterRolesPath = "/apis/rbac.authorization.k8s.io/v1/clusterroles"
+)
+
+func clusterRoleBundle() *smith_v1.Bundle {
+	return &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       bundle1,
+			Namespace:  testNamespace,
+			UID:        bundle1uid,
+			Finalizers: []string{bundlec.FinalizerDeleteResources},
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: resCr1,
+					Spec: smith_v1.ResourceSpec{
+						Object: &rbac_v1.ClusterRole{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ClusterRole",
+								APIVersion: rbac_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: cr1,
+							},
+							Rules: []rbac_v1.PolicyRule{
+								{
+									Verbs:     []string{"get"},
+									APIGroups: []string{""},
+									Resources: []string{"nodes"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func clusterRoleManagedBy(bundleUID types.UID) *rbac_v1.ClusterRole {
+	return &rbac_v1.ClusterRole{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ClusterRole",
+			APIVersion: rbac_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: cr1,
+			UID:  cr1uid,
+			Labels: map[string]string{
+				smith.BundleUIDLabel: string(bundleUID),
+			},
+			Annotations: map[string]string{
+				smith.BundleAnnotation: testNamespace + "/" + bundle1,
+			},
+		},
+	}
+}
+
+// Should create cluster-scoped objects without a namespace and controller owner reference
+func TestClusterScopedObjectCreated(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		appName:              testAppName,
+		namespace:            testNamespace,
+		clusterScopedObjects: true,
+		policies:             true,
+		smithClientObjects:   []runtime.Object{clusterScopedPolicy()},
+		bundle:               clusterRoleBundle(),
+		expectedActions: sets.NewString(
+			"POST=" + clusterRolesPath,
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "POST",
+					path:   clusterRolesPath,
+				}: {
+					statusCode: http.StatusCreated,
+					content: []byte(`{
+							"apiVersion": "rbac.authorization.k8s.io/v1",
+							"kind": "ClusterRole",
+							"metadata": {
+								"name": "` + cr1 + `",
+								"uid": "` + string(cr1uid) + `",
+								"labels": {
+									"` + smith.BundleUIDLabel + `": "` + string(bundle1uid) + `"
+								},
+								"annotations": {
+									"` + smith.BundleAnnotation + `": "` + testNamespace + "/" + bundle1 + `"
+								}
+							},
+							"rules": [
+								{
+									"verbs": ["get"],
+									"apiGroups": [""],
+									"resources": ["nodes"]
+								}
+							]
+						}`),
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.NoError(t, err)
+			assert.False(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, bundle)
+			smith_testing.AssertCondition(t, bundle, smith_v1.BundleReady, cond_v1.ConditionTrue)
+		},
+	}
+	tc.run(t)
+}
+
+// Should not manage cluster-scoped objects unless it is enabled
+func TestClusterScopedObjectsNotEnabled(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		appName:   testAppName,
+		namespace: testNamespace,
+		bundle:    clusterRoleBundle(),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["`+resCr1+`"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			resCond := smith_testing.AssertResourceCondition(t, bundle, resCr1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, "rbac.authorization.k8s.io/v1, Kind=ClusterRole is cluster-scoped but management of cluster-scoped objects is not enabled", resCond.Message)
+			}
+		},
+	}
+	tc.run(t)
+}
+
+// Should not manage cluster-scoped objects unless a policy that applies to the Bundle allows it
+func TestClusterScopedObjectsNotAllowedByPolicy(t *testing.T) {
+	t.Parallel()
+	policy := clusterScopedPolicy()
+	policy.Namespace = "other"
+	tc := testCase{
+		appName:              testAppName,
+		namespace:            testNamespace,
+		clusterScopedObjects: true,
+		policies:             true,
+		smithClientObjects:   []runtime.Object{policy},
+		bundle:               clusterRoleBundle(),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["`+resCr1+`"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			resCond := smith_testing.AssertResourceCondition(t, bundle, resCr1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, "rbac.authorization.k8s.io/v1, Kind=ClusterRole is cluster-scoped but no policy that applies to the Bundle allows cluster-scoped objects", resCond.Message)
+			}
+		},
+	}
+	tc.run(t)
+}
+
+// Should not touch cluster-scoped objects managed by other Bundles
+func TestClusterScopedObjectManagedByAnotherBundle(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		appName:              testAppName,
+		namespace:            testNamespace,
+		clusterScopedObjects: true,
+		policies:             true,
+		smithClientObjects:   []runtime.Object{clusterScopedPolicy()},
+		mainClientObjects: []runtime.Object{
+			clusterRoleManagedBy("another-bundle-uid"),
+		},
+		bundle: clusterRoleBundle(),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["`+resCr1+`"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			resCond := smith_testing.AssertResourceCondition(t, bundle, resCr1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, `cluster-scoped object is managed by Bundle "`+testNamespace+"/"+bundle1+`" (uid=another-bundle-uid), not by the Bundle (uid=`+string(bundle1uid)+`)`, resCond.Message)
+			}
+		},
+	}
+	tc.run(t)
+}
+
+// Should delete cluster-scoped objects of a deleted Bundle even if garbage collector deletes namespaced ones
+func TestClusterScopedObjectsDeletedWithForegroundDeletion(t *testing.T) {
+	t.Parallel()
+	now := meta_v1.Now()
+	bundle := clusterRoleBundle()
+	bundle.DeletionTimestamp = &now
+	bundle.Finalizers = []string{bundlec.FinalizerDeleteResources, meta_v1.FinalizerDeleteDependents}
+	tc := testCase{
+		appName:              testAppName,
+		namespace:            testNamespace,
+		clusterScopedObjects: true,
+		policies:             true,
+		smithClientObjects:   []runtime.Object{clusterScopedPolicy()},
+		mainClientObjects: []runtime.Object{
+			clusterRoleManagedBy(bundle1uid),
+			configMapNeedsDelete(),
+		},
+		bundle: bundle,
+		expectedActions: sets.NewString(
+			"DELETE=" + clusterRolesPath + "/" + cr1,
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "DELETE",
+					path:   clusterRolesPath + "/" + cr1,
+				}: {
+					statusCode: http.StatusOK,
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			tc.defaultTest(t, ctx, cntrlr)
+			bundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, bundle)
+			assert.Equal(t, []string{meta_v1.FinalizerDeleteDependents}, bundle.Finalizers)
+		},
+	}
+	tc.run(t)
+}
+
+// clusterScopedPolicy returns a BundlePolicy that allows Bundles in the test namespace to contain cluster-scoped objects.
+func clusterScopedPolicy() *smith_v1.BundlePolicy {
+	return &smith_v1.BundlePolicy{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cluster-scoped",
+			Namespace: testNamespace,
+		},
+		Spec: smith_v1.BundlePolicySpec{
+			AllowClusterScoped: true,
+		},
+	}
+}
@@ -0,0 +1,252 @@
+package bundlec_test
+
+import (
+	"context"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	"github.com/atlassian/smith/pkg/specchecker/builtin"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	resD1                  = "resD1"
+	d1                     = "d1"
+	d1uid        types.UID = "d1-uid"
+	rs1                    = "d1-5c689d88bb"
+	rs1uid       types.UID = "rs1-uid"
+	pod1                   = "d1-5c689d88bb-7xk2p"
+	emptyEnvHash           = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Should surface problems with Pods of a Deployment in the resource condition message
+func TestDeploymentDiagnosticsInProgress(t *testing.T) {
+	t.Parallel()
+
+	tc := testCase{
+		mainClientObjects: []runtime.Object{
+			stuckDeployment(),
+			stuckDeploymentReplicaSet(),
+			stuckDeploymentPod(core_v1.ContainerStateWaiting{
+				Reason:  "ImagePullBackOff",
+				Message: `Back-off pulling image "app:1"`,
+			}),
+		},
+		appName:   testAppName,
+		namespace: testNamespace,
+		bundle:    stuckDeploymentBundle(),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.NoError(t, err)
+			assert.False(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			smith_testing.AssertResourceCondition(t, bundle, resD1, smith_v1.ResourceInProgress, cond_v1.ConditionTrue)
+			smith_testing.AssertResourceConditionMessage(t, bundle, resD1, smith_v1.ResourceInProgress,
+				`Number of replicas converging. Available=0, Updated=1. Pod "`+pod1+`" container "app": ImagePullBackOff: Back-off pulling image "app:1"`)
+		},
+	}
+	tc.run(t)
+}
+
+// Should fail fast if an unrecoverable problem is detected and fail fast mode is enabled
+func TestDeploymentDiagnosticsFailFast(t *testing.T) {
+	t.Parallel()
+
+	tc := testCase{
+		mainClientObjects: []runtime.Object{
+			stuckDeployment(),
+			stuckDeploymentReplicaSet(),
+			stuckDeploymentPod(core_v1.ContainerStateWaiting{
+				Reason:  "InvalidImageName",
+				Message: `couldn't parse image reference "app:1"`,
+			}),
+		},
+		appName:   testAppName,
+		namespace: testNamespace,
+		failFast:  true,
+		bundle:    stuckDeploymentBundle(),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["`+resD1+`"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			smith_testing.AssertResourceCondition(t, bundle, resD1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			smith_testing.AssertResourceConditionMessage(t, bundle, resD1, smith_v1.ResourceError,
+				`deployment cannot make progress: Pod "`+pod1+`" container "app": InvalidImageName: couldn't parse image reference "app:1"`)
+		},
+	}
+	tc.run(t)
+}
+
+func stuckDeploymentBundle() *smith_v1.Bundle {
+	return &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       bundle1,
+			Namespace:  testNamespace,
+			UID:        bundle1uid,
+			Finalizers: []string{bundlec.FinalizerDeleteResources},
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: resD1,
+					Spec: smith_v1.ResourceSpec{
+						Object: &apps_v1.Deployment{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "Deployment",
+								APIVersion: apps_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: d1,
+							},
+							Spec: stuckDeploymentSpec(false),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func stuckDeploymentSpec(processed bool) apps_v1.DeploymentSpec {
+	var replicas int32 = 1
+	spec := apps_v1.DeploymentSpec{
+		Replicas: &replicas,
+		Selector: &meta_v1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app": d1,
+			},
+		},
+		Template: core_v1.PodTemplateSpec{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Labels: map[string]string{
+					"app": d1,
+				},
+			},
+			Spec: core_v1.PodSpec{
+				Containers: []core_v1.Container{
+					{
+						Name:  "app",
+						Image: "app:1",
+					},
+				},
+			},
+		},
+	}
+	if processed {
+		spec.Template.Annotations = map[string]string{
+			builtin.EnvRefHashAnnotation: emptyEnvHash,
+		}
+	}
+	return spec
+}
+
+func stuckDeployment() *apps_v1.Deployment {
+	tr := true
+	return &apps_v1.Deployment{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: apps_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       d1,
+			Namespace:  testNamespace,
+			UID:        d1uid,
+			Generation: 1,
+			Annotations: map[string]string{
+				builtin.LastAppliedReplicasAnnotation: "1",
+				"deployment.kubernetes.io/revision":   "1",
+			},
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion:         smith_v1.BundleResourceGroupVersion,
+					Kind:               smith_v1.BundleResourceKind,
+					Name:               bundle1,
+					UID:                bundle1uid,
+					Controller:         &tr,
+					BlockOwnerDeletion: &tr,
+				},
+			},
+		},
+		Spec: stuckDeploymentSpec(true),
+		Status: apps_v1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           1,
+			UpdatedReplicas:    1,
+		},
+	}
+}
+
+func stuckDeploymentReplicaSet() *apps_v1.ReplicaSet {
+	tr := true
+	return &apps_v1.ReplicaSet{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ReplicaSet",
+			APIVersion: apps_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      rs1,
+			Namespace: testNamespace,
+			UID:       rs1uid,
+			Annotations: map[string]string{
+				"deployment.kubernetes.io/revision": "1",
+			},
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion:         apps_v1.SchemeGroupVersion.String(),
+					Kind:               "Deployment",
+					Name:               d1,
+					UID:                d1uid,
+					Controller:         &tr,
+					BlockOwnerDeletion: &tr,
+				},
+			},
+		},
+	}
+}
+
+func stuckDeploymentPod(waiting core_v1.ContainerStateWaiting) *core_v1.Pod {
+	tr := true
+	return &core_v1.Pod{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      pod1,
+			Namespace: testNamespace,
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion:         apps_v1.SchemeGroupVersion.String(),
+					Kind:               "ReplicaSet",
+					Name:               rs1,
+					UID:                rs1uid,
+					Controller:         &tr,
+					BlockOwnerDeletion: &tr,
+				},
+			},
+		},
+		Status: core_v1.PodStatus{
+			Phase: core_v1.PodPending,
+			ContainerStatuses: []core_v1.ContainerStatus{
+				{
+					Name: "app",
+					State: core_v1.ContainerState{
+						Waiting: &waiting,
+					},
+				},
+			},
+		},
+	}
+}
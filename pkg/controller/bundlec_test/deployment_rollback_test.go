@@ -0,0 +1,197 @@
+package bundlec_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	"github.com/atlassian/smith/pkg/specchecker"
+	"github.com/atlassian/smith/pkg/specchecker/builtin"
+	speccheckertesting "github.com/atlassian/smith/pkg/specchecker/testing"
+	"github.com/atlassian/smith/pkg/util"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kube_testing "k8s.io/client-go/testing"
+)
+
+// Should roll the Deployment back to the last Ready pod template when progress deadline is exceeded
+func TestDeploymentRolledBackToLastReadyTemplate(t *testing.T) {
+	t.Parallel()
+
+	lastReadyTemplate := stuckDeploymentSpec(true).Template
+	lastReadyTemplateBytes, err := json.Marshal(&lastReadyTemplate)
+	require.NoError(t, err)
+
+	bundle := stuckDeploymentBundle()
+	bundleDeployment := bundle.Spec.Resources[0].Spec.Object.(*apps_v1.Deployment)
+	bundleDeployment.Annotations = map[string]string{
+		builtin.RollbackPolicyAnnotation: builtin.RollbackPolicyLastReady,
+	}
+	bundleDeployment.Spec.Template.Spec.Containers[0].Image = "app:2"
+
+	deployment := stuckDeployment()
+	deployment.Annotations[builtin.RollbackPolicyAnnotation] = builtin.RollbackPolicyLastReady
+	deployment.Annotations[builtin.LastReadyTemplateAnnotation] = string(lastReadyTemplateBytes)
+	deployment.Spec.Template.Spec.Containers[0].Image = "app:2"
+	deployment.Status.Conditions = []apps_v1.DeploymentCondition{
+		{
+			Type:   apps_v1.DeploymentProgressing,
+			Status: core_v1.ConditionFalse,
+			Reason: "ProgressDeadlineExceeded",
+		},
+	}
+
+	rolledBack := deployment.DeepCopy()
+	rolledBack.Spec.Template = lastReadyTemplate
+	rolledBack.Annotations[builtin.RolledBackTemplateHashAnnotation] = "hash"
+	rolledBackBytes, err := json.Marshal(rolledBack)
+	require.NoError(t, err)
+
+	tc := testCase{
+		mainClientObjects: []runtime.Object{
+			deployment,
+		},
+		appName:   testAppName,
+		namespace: testNamespace,
+		bundle:    bundle,
+		expectedActions: sets.NewString(
+			"PUT=/apis/apps/v1/namespaces/" + testNamespace + "/deployments/" + d1,
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "PUT",
+					path:   "/apis/apps/v1/namespaces/" + testNamespace + "/deployments/" + d1,
+				}: {
+					statusCode: http.StatusOK,
+					content:    rolledBackBytes,
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["`+resD1+`"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			smith_testing.AssertResourceCondition(t, bundle, resD1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			smith_testing.AssertResourceConditionMessage(t, bundle, resD1, smith_v1.ResourceError,
+				"pod template has been rolled back to the last Ready one: deployment exceeded its progress deadline")
+		},
+	}
+	tc.run(t)
+}
+
+// Should keep the rolled back pod template when the Bundle is processed again after the rollback
+func TestDeploymentRolledBackTemplateKeptOnResync(t *testing.T) {
+	t.Parallel()
+
+	lastReadyTemplate := stuckDeploymentSpec(true).Template
+	lastReadyTemplateBytes, err := json.Marshal(&lastReadyTemplate)
+	require.NoError(t, err)
+
+	bundle := stuckDeploymentBundle()
+	bundleDeployment := bundle.Spec.Resources[0].Spec.Object.(*apps_v1.Deployment)
+	bundleDeployment.Annotations = map[string]string{
+		builtin.RollbackPolicyAnnotation: builtin.RollbackPolicyLastReady,
+	}
+	bundleDeployment.Spec.Template.Spec.Containers[0].Image = "app:2"
+	bundleDeployment.Spec.Template.Spec.ServiceAccountName = "app"
+
+	deployment := stuckDeployment()
+	deployment.Annotations[builtin.RollbackPolicyAnnotation] = builtin.RollbackPolicyLastReady
+	deployment.Annotations[builtin.LastReadyTemplateAnnotation] = string(lastReadyTemplateBytes)
+	deployment.Spec.Template.Spec.Containers[0].Image = "app:2"
+	deployment.Spec.Template.Spec.ServiceAccountName = "app"
+	deployment.Spec.Template.Spec.DeprecatedServiceAccount = "app"
+	deployment.Status.Conditions = []apps_v1.DeploymentCondition{
+		{
+			Type:   apps_v1.DeploymentProgressing,
+			Status: core_v1.ConditionFalse,
+			Reason: "ProgressDeadlineExceeded",
+		},
+	}
+
+	bundleDeploymentUnstr, err := util.RuntimeToUnstructured(bundleDeployment)
+	require.NoError(t, err)
+	specHash, err := builtin.SpecPodTemplateHash(&specchecker.Context{
+		Logger: zaptest.NewLogger(t),
+		Store:  speccheckertesting.FakeStore{Namespace: testNamespace},
+	}, bundleDeploymentUnstr)
+	require.NoError(t, err)
+
+	rolledBack := deployment.DeepCopy()
+	rolledBack.Spec.Template = lastReadyTemplate
+	rolledBack.Annotations[builtin.RolledBackTemplateHashAnnotation] = specHash
+	rolledBackBytes, err := json.Marshal(rolledBack)
+	require.NoError(t, err)
+
+	deploymentPath := "/apis/apps/v1/namespaces/" + testNamespace + "/deployments/" + d1
+	tc := testCase{
+		mainClientObjects: []runtime.Object{
+			deployment,
+		},
+		appName:   testAppName,
+		namespace: testNamespace,
+		bundle:    bundle,
+		expectedActions: sets.NewString(
+			"PUT=" + deploymentPath,
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "PUT",
+					path:   deploymentPath,
+				}: {
+					statusCode: http.StatusOK,
+					content:    rolledBackBytes,
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			_, _, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["`+resD1+`"]`)
+			actions := tc.testHandler.getActions()
+			require.Len(t, actions, 1)
+			var sent apps_v1.Deployment
+			require.NoError(t, json.Unmarshal(actions[0].body, &sent))
+			assert.Equal(t, specHash, sent.Annotations[builtin.RolledBackTemplateHashAnnotation])
+
+			// The rolled back Deployment is observed by the informer
+			_, err = tc.mainFake.Invokes(kube_testing.NewUpdateAction(apps_v1.SchemeGroupVersion.WithResource("deployments"), testNamespace, rolledBack), nil)
+			require.NoError(t, err)
+			err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool /*done*/, error) {
+				obj, exists, err := cntrlr.Store.Get(apps_v1.SchemeGroupVersion.WithKind("Deployment"), testNamespace, d1)
+				if err != nil || !exists {
+					return false, err
+				}
+				return obj.(*apps_v1.Deployment).Annotations[builtin.RolledBackTemplateHashAnnotation] != "", nil
+			})
+			require.NoError(t, err)
+
+			// Rolled back pod template is not updated on resync
+			tc.smithFake.ClearActions()
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["`+resD1+`"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+			assert.Len(t, tc.testHandler.getActions(), 1)
+			bundle := tc.findBundleUpdate(t, true)
+			smith_testing.AssertResourceConditionMessage(t, bundle, resD1, smith_v1.ResourceError,
+				"new pod template failed to roll out and has been rolled back to the last Ready one, update the pod template to try again")
+		},
+	}
+	tc.run(t)
+}
@@ -0,0 +1,113 @@
+package bundlec_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	"github.com/atlassian/smith/pkg/store"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbac_v1 "k8s.io/api/rbac/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kube_testing "k8s.io/client-go/testing"
+)
+
+const (
+	rolesPath = "/apis/rbac.authorization.k8s.io/v1/namespaces/" + testNamespace + "/roles"
+)
+
+func roleBundle() *smith_v1.Bundle {
+	return &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       bundle1,
+			Namespace:  testNamespace,
+			UID:        bundle1uid,
+			Finalizers: []string{bundlec.FinalizerDeleteResources},
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "resRole1",
+					Spec: smith_v1.ResourceSpec{
+						Object: &rbac_v1.Role{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "Role",
+								APIVersion: rbac_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: "role1",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Should start an informer for a kind that is not watched otherwise and stop it when the Bundle is deleted
+func TestDynamicInformerStartedAndStopped(t *testing.T) {
+	t.Parallel()
+	roleGVK := rbac_v1.SchemeGroupVersion.WithKind("Role")
+	tc := testCase{
+		appName:          testAppName,
+		namespace:        testNamespace,
+		dynamicInformers: true,
+		bundle:           roleBundle(),
+		expectedActions: sets.NewString(
+			"GET="+rolesPath+"=limit=500&resourceVersion=0",
+			"GET="+rolesPath+"=watch",
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "GET",
+					path:   rolesPath,
+				}: {
+					statusCode: http.StatusOK,
+					content:    []byte(`{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "RoleList", "metadata": {"resourceVersion": "1"}, "items": []}`),
+				},
+				{
+					method: "GET",
+					path:   rolesPath,
+					watch:  true,
+				}: {
+					statusCode: http.StatusOK,
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			assert.False(t, cntrlr.Store.HasInformer(roleGVK))
+			tc.defaultTest(t, ctx, cntrlr)
+			bundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, bundle)
+			smith_testing.AssertResourceCondition(t, bundle, "resRole1", smith_v1.ResourceInProgress, cond_v1.ConditionTrue)
+			smith_testing.AssertResourceConditionMessage(t, bundle, "resRole1", smith_v1.ResourceInProgress,
+				"Waiting for informer for rbac.authorization.k8s.io/v1, Kind=Role to sync")
+
+			// Informer syncs
+			err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				inf, ok := cntrlr.Store.(*store.Multi).GetInformers()[roleGVK]
+				return ok && inf.HasSynced(), nil
+			})
+			require.NoError(t, err)
+
+			// Informer is stopped when the Bundle is deleted
+			_, err = tc.smithFake.Invokes(kube_testing.NewDeleteAction(smith_v1.BundleGVK.GroupVersion().WithResource(smith_v1.BundleResourcePlural), testNamespace, bundle1), nil)
+			require.NoError(t, err)
+			err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				return !cntrlr.Store.HasInformer(roleGVK), nil
+			})
+			require.NoError(t, err)
+		},
+	}
+	tc.run(t)
+}
@@ -0,0 +1,124 @@
+package bundlec_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	auth_v1 "k8s.io/api/authentication/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const bundleAuthor = "alice"
+
+// Should create objects on behalf of the author of the Bundle if impersonation is enabled
+func TestObjectsCreatedOnBehalfOfAuthor(t *testing.T) {
+	t.Parallel()
+	bundle := configMapBundle()
+	bundle.Spec.Author = &auth_v1.UserInfo{
+		Username: bundleAuthor,
+		Groups:   []string{"system:authenticated"},
+	}
+	tc := testCase{
+		appName:     testAppName,
+		namespace:   testNamespace,
+		impersonate: true,
+		bundle:      bundle,
+		expectedActions: sets.NewString(
+			"POST=/api/v1/namespaces/" + testNamespace + "/configmaps",
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "POST",
+					path:   "/api/v1/namespaces/" + testNamespace + "/configmaps",
+				}: {
+					statusCode: http.StatusCreated,
+					content: []byte(`{
+							"apiVersion": "v1",
+							"kind": "ConfigMap",
+							"metadata": {
+								"name": "` + m1 + `",
+								"namespace": "` + testNamespace + `",
+								"uid": "` + string(mapNeedsAnUpdateUid) + `",
+								"ownerReferences": [
+									{
+										"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+										"kind": "` + smith_v1.BundleResourceKind + `",
+										"name": "` + bundle1 + `",
+										"uid": "` + string(bundle1uid) + `",
+										"controller": true,
+										"blockOwnerDeletion": true
+									}
+								] }
+							}`),
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			_, _, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.NoError(t, err)
+			actions := tc.testHandler.getActions()
+			require.Len(t, actions, 1)
+			assert.Equal(t, bundleAuthor, actions[0].impersonatedUser)
+		},
+	}
+	tc.run(t)
+}
+
+// Should not process Bundle without an author if impersonation is enabled
+func TestBundleWithoutAuthorNotProcessedWithImpersonation(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		appName:     testAppName,
+		namespace:   testNamespace,
+		impersonate: true,
+		bundle:      configMapBundle(),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, "author of the Bundle is not set but is required for impersonation (is the mutating admission webhook configured?)")
+			assert.True(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			smith_testing.AssertCondition(t, bundle, smith_v1.BundleError, cond_v1.ConditionTrue)
+		},
+	}
+	tc.run(t)
+}
+
+func configMapBundle() *smith_v1.Bundle {
+	return &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       bundle1,
+			Namespace:  testNamespace,
+			UID:        bundle1uid,
+			Finalizers: []string{bundlec.FinalizerDeleteResources},
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: "resM1",
+					Spec: smith_v1.ResourceSpec{
+						Object: &core_v1.ConfigMap{
+							TypeMeta: meta_v1.TypeMeta{
+								Kind:       "ConfigMap",
+								APIVersion: core_v1.SchemeGroupVersion.String(),
+							},
+							ObjectMeta: meta_v1.ObjectMeta{
+								Name: m1,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
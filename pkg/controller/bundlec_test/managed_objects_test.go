@@ -0,0 +1,180 @@
+package bundlec_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/atlassian/smith"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	"github.com/atlassian/smith/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Should label created objects with the managed-by label if it is enabled
+func TestCreatedObjectsLabelledAsManaged(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		appName:        testAppName,
+		namespace:      testNamespace,
+		managedByLabel: true,
+		bundle:         configMapBundle(),
+		expectedActions: sets.NewString(
+			"POST=/api/v1/namespaces/" + testNamespace + "/configmaps",
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "POST",
+					path:   "/api/v1/namespaces/" + testNamespace + "/configmaps",
+				}: {
+					statusCode: http.StatusCreated,
+					content: []byte(`{
+							"apiVersion": "v1",
+							"kind": "ConfigMap",
+							"metadata": {
+								"name": "` + m1 + `",
+								"namespace": "` + testNamespace + `",
+								"uid": "` + string(mapNeedsAnUpdateUid) + `",
+								"labels": {
+									"` + smith.ManagedByLabel + `": "` + smith.ManagedByLabelValue + `"
+								},
+								"ownerReferences": [
+									{
+										"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+										"kind": "` + smith_v1.BundleResourceKind + `",
+										"name": "` + bundle1 + `",
+										"uid": "` + string(bundle1uid) + `",
+										"controller": true,
+										"blockOwnerDeletion": true
+									}
+								] }
+							}`),
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			tc.defaultTest(t, ctx, cntrlr)
+			actions := tc.testHandler.getActions()
+			require.Len(t, actions, 1)
+			var created core_v1.ConfigMap
+			require.NoError(t, json.Unmarshal(actions[0].body, &created))
+			assert.Equal(t, smith.ManagedByLabelValue, created.Labels[smith.ManagedByLabel])
+		},
+	}
+	tc.run(t)
+}
+
+// Should not watch objects without the managed-by label but still find referenced objects
+func TestUnlabelledObjectsLookedUp(t *testing.T) {
+	t.Parallel()
+	configMapGVK := core_v1.SchemeGroupVersion.WithKind("ConfigMap")
+	tc := testCase{
+		appName:            testAppName,
+		namespace:          testNamespace,
+		managedByLabel:     true,
+		managedObjectsOnly: true,
+		mainClientObjects: []runtime.Object{
+			configMapNeedsUpdate(),
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			multiStore := cntrlr.Store.(*store.Multi)
+			assert.Empty(t, multiStore.GetInformers()[configMapGVK].GetIndexer().List())
+			obj, exists, err := multiStore.Get(configMapGVK, testNamespace, mapNeedsAnUpdate)
+			require.NoError(t, err)
+			require.True(t, exists)
+			assert.Equal(t, configMapGVK, obj.GetObjectKind().GroupVersionKind())
+			_, exists, err = multiStore.Get(configMapGVK, testNamespace, "missing")
+			require.NoError(t, err)
+			assert.False(t, exists)
+		},
+	}
+	tc.run(t)
+}
+
+// Should update and label an existing object that is not watched because it does not have the managed-by label
+// rather than trying to create it again
+func TestUnlabelledExistingObjectUpdatedWhenManagedObjectsOnly(t *testing.T) {
+	t.Parallel()
+	m1Path := "/api/v1/namespaces/" + testNamespace + "/configmaps/" + m1
+	tc := testCase{
+		appName:            testAppName,
+		namespace:          testNamespace,
+		managedByLabel:     true,
+		managedObjectsOnly: true,
+		bundle:             configMapBundle(),
+		expectedActions: sets.NewString(
+			"POST=/api/v1/namespaces/"+testNamespace+"/configmaps",
+			"GET="+m1Path,
+			"PUT="+m1Path,
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "POST",
+					path:   "/api/v1/namespaces/" + testNamespace + "/configmaps",
+				}: {
+					statusCode: http.StatusConflict,
+					content: []byte(`{
+							"apiVersion": "v1",
+							"kind": "Status",
+							"status": "Failure",
+							"reason": "AlreadyExists",
+							"code": 409
+							}`),
+				},
+				{
+					method: "GET",
+					path:   m1Path,
+				}: existingConfigMapResponse(""),
+				{
+					method: "PUT",
+					path:   m1Path,
+				}: existingConfigMapResponse(`"` + smith.ManagedByLabel + `": "` + smith.ManagedByLabelValue + `"`),
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			tc.defaultTest(t, ctx, cntrlr)
+			for _, action := range tc.testHandler.getActions() {
+				if action.method != "PUT" {
+					continue
+				}
+				var updated core_v1.ConfigMap
+				require.NoError(t, json.Unmarshal(action.body, &updated))
+				assert.Equal(t, smith.ManagedByLabelValue, updated.Labels[smith.ManagedByLabel])
+			}
+		},
+	}
+	tc.run(t)
+}
+
+func existingConfigMapResponse(labels string) fakeResponse {
+	return fakeResponse{
+		statusCode: http.StatusOK,
+		content: []byte(`{
+							"apiVersion": "v1",
+							"kind": "ConfigMap",
+							"metadata": {
+								"name": "` + m1 + `",
+								"namespace": "` + testNamespace + `",
+								"uid": "` + string(mapNeedsAnUpdateUid) + `",
+								"labels": {` + labels + `},
+								"ownerReferences": [
+									{
+										"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+										"kind": "` + smith_v1.BundleResourceKind + `",
+										"name": "` + bundle1 + `",
+										"uid": "` + string(bundle1uid) + `",
+										"controller": true,
+										"blockOwnerDeletion": true
+									}
+								] }
+							}`),
+	}
+}
@@ -0,0 +1,146 @@
+package bundlec_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func multiObjectPluginBundle(spec map[string]interface{}) *smith_v1.Bundle {
+	return &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       bundle1,
+			Namespace:  testNamespace,
+			UID:        bundle1uid,
+			Finalizers: []string{bundlec.FinalizerDeleteResources},
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: resP1,
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name: pluginMultiObject,
+							Spec: spec,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func createdObjectResponse(apiVersion, kind, name string, uid string) fakeResponse {
+	return fakeResponse{
+		statusCode: http.StatusCreated,
+		content: []byte(`{
+			"apiVersion": "` + apiVersion + `",
+			"kind": "` + kind + `",
+			"metadata": {
+				"name": "` + name + `",
+				"namespace": "` + testNamespace + `",
+				"uid": "` + uid + `",
+				"ownerReferences": [
+					{
+						"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+						"kind": "` + smith_v1.BundleResourceKind + `",
+						"name": "` + bundle1 + `",
+						"uid": "` + string(bundle1uid) + `",
+						"controller": true,
+						"blockOwnerDeletion": true
+					}
+				] }
+			}`),
+	}
+}
+
+// Should create all objects produced by a plugin and record them in the resource status
+func TestMultiObjectPluginObjectsCreated(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		bundle: multiObjectPluginBundle(map[string]interface{}{
+			"configMap": m1,
+			"secret":    s1,
+		}),
+		appName:   testAppName,
+		namespace: testNamespace,
+		expectedActions: sets.NewString(
+			"POST=/api/v1/namespaces/"+testNamespace+"/configmaps",
+			"POST=/api/v1/namespaces/"+testNamespace+"/secrets",
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "POST",
+					path:   "/api/v1/namespaces/" + testNamespace + "/configmaps",
+				}: createdObjectResponse("v1", "ConfigMap", m1, "m1-uid"),
+				{
+					method: "POST",
+					path:   "/api/v1/namespaces/" + testNamespace + "/secrets",
+				}: createdObjectResponse("v1", "Secret", s1, string(s1uid)),
+			},
+		},
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginMultiObject: newMultiObjectPlugin,
+		},
+		pluginsShouldBeInvoked: sets.NewString(string(pluginMultiObject)),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.NoError(t, err)
+			assert.False(t, external)
+			assert.False(t, retriable)
+
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			smith_testing.AssertCondition(t, updateBundle, smith_v1.BundleReady, cond_v1.ConditionTrue)
+			smith_testing.AssertResourceCondition(t, updateBundle, resP1, smith_v1.ResourceReady, cond_v1.ConditionTrue)
+			_, resStatus := updateBundle.Status.GetResourceStatus(resP1)
+			require.NotNil(t, resStatus)
+			assert.Equal(t, []smith_v1.ResourceObject{
+				{Version: "v1", Kind: "ConfigMap", Name: m1},
+				{Version: "v1", Kind: "Secret", Name: s1},
+			}, resStatus.Objects)
+		},
+	}
+	tc.run(t)
+}
+
+// Should fail the resource if the plugin produces an object of a kind it has not declared
+func TestMultiObjectPluginUndeclaredKind(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		bundle: multiObjectPluginBundle(map[string]interface{}{
+			"configMap":  m1,
+			"secret":     s1,
+			"secretKind": "Pod",
+		}),
+		appName:   testAppName,
+		namespace: testNamespace,
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginMultiObject: newMultiObjectPlugin,
+		},
+		pluginsShouldBeInvoked: sets.NewString(string(pluginMultiObject)),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			_, _, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			assert.EqualError(t, err, `error processing resource(s): ["`+resP1+`"]`)
+
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			resCond := smith_testing.AssertResourceCondition(t, updateBundle, resP1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, smith_v1.ResourceReasonTerminalError, resCond.Reason)
+				assert.Equal(t, "unexpected GVK from plugin (wanted one of [/v1, Kind=ConfigMap /v1, Kind=Secret], got /v1, Kind=Pod)", resCond.Message)
+			}
+		},
+	}
+	tc.run(t)
+}
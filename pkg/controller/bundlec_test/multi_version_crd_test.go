@@ -0,0 +1,272 @@
+package bundlec_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	sleeper_v1 "github.com/atlassian/smith/examples/sleeper/pkg/apis/sleeper/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	"github.com/atlassian/smith/pkg/store"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kube_testing "k8s.io/client-go/testing"
+)
+
+const (
+	sleeperAlphaVersion = "v1alpha1"
+)
+
+// nonStorageVersionTestCase returns a test case with a Sleeper CRD that serves two versions and a Bundle that
+// uses the version that is not watched upfront.
+func nonStorageVersionTestCase() (*testCase, *apiext_v1.CustomResourceDefinition) {
+	crd := sleeperCrdWithStatus()
+	crd.Spec.Conversion = &apiext_v1.CustomResourceConversion{
+		Strategy: apiext_v1.WebhookConverter,
+	}
+	crd.Spec.Versions = []apiext_v1.CustomResourceDefinitionVersion{
+		{
+			Name:   sleeperAlphaVersion,
+			Served: true,
+			Schema: crd.Spec.Versions[0].Schema,
+		},
+		crd.Spec.Versions[0],
+	}
+	sleepersPath := "/apis/" + sleeper_v1.SleeperResourceGroupVersion + "/namespaces/" + testNamespace + "/" + sleeper_v1.SleeperResourcePlural
+	alphaSleepersPath := "/apis/" + sleeper_v1.GroupName + "/" + sleeperAlphaVersion + "/namespaces/" + testNamespace + "/" + sleeper_v1.SleeperResourcePlural
+
+	tc := &testCase{
+		apiExtClientObjects: []runtime.Object{
+			crd.DeepCopy(),
+		},
+		bundle: &smith_v1.Bundle{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:       bundle1,
+				Namespace:  testNamespace,
+				UID:        bundle1uid,
+				Finalizers: []string{bundlec.FinalizerDeleteResources},
+			},
+			Spec: smith_v1.BundleSpec{
+				Resources: []smith_v1.Resource{
+					{
+						Name: resSleeper1,
+						Spec: smith_v1.ResourceSpec{
+							Object: &sleeper_v1.Sleeper{
+								TypeMeta: meta_v1.TypeMeta{
+									Kind:       sleeper_v1.SleeperResourceKind,
+									APIVersion: sleeper_v1.GroupName + "/" + sleeperAlphaVersion,
+								},
+								ObjectMeta: meta_v1.ObjectMeta{
+									Name: sleeper1,
+								},
+								Spec: sleeper_v1.SleeperSpec{
+									SleepFor:      3,
+									WakeupMessage: "Hello there!",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		appName:   testAppName,
+		namespace: testNamespace,
+		expectedActions: sets.NewString(
+			"GET="+sleepersPath+"=limit=500&resourceVersion=0",
+			"GET="+sleepersPath+"=watch",
+			"GET="+alphaSleepersPath+"=limit=500&resourceVersion=0",
+			"GET="+alphaSleepersPath+"=watch",
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "GET",
+					path:   sleepersPath,
+				}: {
+					statusCode: http.StatusOK,
+					content: []byte(`{"kind": "List", "items": [{
+							"apiVersion": "` + sleeper_v1.SleeperResourceGroupVersion + `",
+							"kind": "` + sleeper_v1.SleeperResourceKind + `",
+							"metadata": {
+								"name": "` + sleeper1 + `",
+								"namespace": "` + testNamespace + `",
+								"uid": "` + string(sleeper1uid) + `",
+								"ownerReferences": [
+									{
+										"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+										"kind": "` + smith_v1.BundleResourceKind + `",
+										"name": "` + bundle1 + `",
+										"uid": "` + string(bundle1uid) + `",
+										"controller": true,
+										"blockOwnerDeletion": true
+									}
+								]
+							},
+							"spec": {
+								"sleepFor": 3,
+								"wakeupMessage": "Hello there!"
+							},
+							"status": {
+								"state": "` + string(sleeper_v1.Awake) + `"
+							}
+						}]}`),
+				},
+				{
+					method: "GET",
+					watch:  true,
+					path:   sleepersPath,
+				}: {
+					statusCode: http.StatusOK,
+				},
+				{
+					method: "GET",
+					path:   alphaSleepersPath,
+				}: {
+					statusCode: http.StatusOK,
+					content: []byte(`{"kind": "List", "items": [{
+							"apiVersion": "` + sleeper_v1.GroupName + "/" + sleeperAlphaVersion + `",
+							"kind": "` + sleeper_v1.SleeperResourceKind + `",
+							"metadata": {
+								"name": "` + sleeper1 + `",
+								"namespace": "` + testNamespace + `",
+								"uid": "` + string(sleeper1uid) + `",
+								"ownerReferences": [
+									{
+										"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+										"kind": "` + smith_v1.BundleResourceKind + `",
+										"name": "` + bundle1 + `",
+										"uid": "` + string(bundle1uid) + `",
+										"controller": true,
+										"blockOwnerDeletion": true
+									}
+								]
+							},
+							"spec": {
+								"sleepFor": 3,
+								"wakeupMessage": "Hello there!"
+							},
+							"status": {
+								"state": "` + string(sleeper_v1.Awake) + `"
+							}
+						}]}`),
+				},
+				{
+					method: "GET",
+					watch:  true,
+					path:   alphaSleepersPath,
+				}: {
+					statusCode: http.StatusOK,
+				},
+			},
+		},
+	}
+	return tc, crd
+}
+
+// Should watch CRs with the storage version and start an informer for another served version a Bundle uses.
+// The API server converts objects between versions so the CRD may use a conversion webhook.
+func TestResourceWithNonStorageVersionOfCrd(t *testing.T) {
+	t.Parallel()
+
+	alphaGVK := sleeper_v1.SleeperGVK.GroupKind().WithVersion(sleeperAlphaVersion)
+	tc, _ := nonStorageVersionTestCase()
+	tc.test = func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+		assert.False(t, cntrlr.Store.HasInformer(alphaGVK))
+		external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+		require.NoError(t, err)
+		assert.False(t, external)
+		assert.False(t, retriable)
+		bundle := tc.findBundleUpdate(t, true)
+		smith_testing.AssertResourceConditionMessage(t, bundle, resSleeper1, smith_v1.ResourceInProgress,
+			"Waiting for informer for "+alphaGVK.String()+" to sync")
+
+		// Informer for the version the Bundle uses syncs
+		err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool /*done*/, error) {
+			inf, ok := cntrlr.Store.(*store.Multi).GetInformers()[alphaGVK]
+			return ok && inf.HasSynced(), nil
+		})
+		require.NoError(t, err)
+		tc.bundle = bundle
+		tc.smithFake.ClearActions()
+		external, retriable, err = cntrlr.ProcessBundle(tc.logger, tc.bundle)
+		require.NoError(t, err)
+		assert.False(t, external)
+		assert.False(t, retriable)
+		bundle = tc.findBundleUpdate(t, true)
+		smith_testing.AssertResourceCondition(t, bundle, resSleeper1, smith_v1.ResourceReady, cond_v1.ConditionTrue)
+		assert.Empty(t, bundle.Status.ObjectsToDelete)
+	}
+	tc.run(t)
+}
+
+// Should not deadlock when a watch for a CRD is re-established while a Bundle starts a dynamic informer.
+func TestCrdUpdateConcurrentWithDynamicInformerStart(t *testing.T) {
+	t.Parallel()
+
+	tc, crd := nonStorageVersionTestCase()
+	tc.dynamicInformers = true
+	tc.test = func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+		crdGVR := apiext_v1.SchemeGroupVersion.WithResource("customresourcedefinitions")
+		withoutAlpha := crd.DeepCopy()
+		withoutAlpha.Spec.Versions = withoutAlpha.Spec.Versions[1:]
+
+		updatesDone := make(chan struct{})
+		var wg wait.Group
+		wg.Start(func() {
+			defer close(updatesDone)
+			// Fewer updates than the fake watch buffers so that updates do not block if the handler does
+			for i := 0; i < 90; i++ {
+				update := crd
+				if i%2 == 0 {
+					update = withoutAlpha
+				}
+				_, err := tc.apiExtFake.Invokes(kube_testing.NewRootUpdateAction(crdGVR, update.DeepCopy()), &apiext_v1.CustomResourceDefinition{})
+				assert.NoError(t, err)
+				time.Sleep(time.Millisecond)
+			}
+		})
+		wg.Start(func() {
+			for {
+				select {
+				case <-updatesDone:
+					return
+				default:
+				}
+				// Result depends on whether the watch for the CRD is established at the moment
+				cntrlr.ProcessBundle(tc.logger, tc.bundle) // nolint: errcheck
+			}
+		})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			wg.Wait()
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("CRD updates and Bundle processing are blocked")
+		}
+
+		// Informer for the version the Bundle uses is started once the CRD serves it again
+		alphaGVK := sleeper_v1.SleeperGVK.GroupKind().WithVersion(sleeperAlphaVersion)
+		err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool /*done*/, error) {
+			// Fails until the CRD informer has observed the last update
+			cntrlr.ProcessBundle(tc.logger, tc.bundle) // nolint: errcheck
+			inf, ok := cntrlr.Store.(*store.Multi).GetInformers()[alphaGVK]
+			return ok && inf.HasSynced(), nil
+		})
+		require.NoError(t, err)
+		// Statuses of Bundles processed concurrently are not checked
+		tc.smithFake.ClearActions()
+	}
+	tc.run(t)
+}
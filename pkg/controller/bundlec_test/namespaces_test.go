@@ -0,0 +1,99 @@
+package bundlec_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Should process Bundles in namespaces that match the namespace selector
+func TestBundleInSelectedNamespaceProcessed(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		appName:           testAppName,
+		namespace:         testNamespace,
+		namespaceSelector: "tenant=a",
+		mainClientObjects: []runtime.Object{
+			&core_v1.Namespace{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: testNamespace,
+					Labels: map[string]string{
+						"tenant": "a",
+					},
+				},
+			},
+		},
+		bundle: configMapBundle(),
+		expectedActions: sets.NewString(
+			"POST=/api/v1/namespaces/" + testNamespace + "/configmaps",
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "POST",
+					path:   "/api/v1/namespaces/" + testNamespace + "/configmaps",
+				}: {
+					statusCode: http.StatusCreated,
+					content: []byte(`{
+							"apiVersion": "v1",
+							"kind": "ConfigMap",
+							"metadata": {
+								"name": "` + m1 + `",
+								"namespace": "` + testNamespace + `",
+								"uid": "` + string(mapNeedsAnUpdateUid) + `",
+								"ownerReferences": [
+									{
+										"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+										"kind": "` + smith_v1.BundleResourceKind + `",
+										"name": "` + bundle1 + `",
+										"uid": "` + string(bundle1uid) + `",
+										"controller": true,
+										"blockOwnerDeletion": true
+									}
+								] }
+							}`),
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			assert.True(t, cntrlr.Namespaces.Matches(testNamespace))
+			tc.defaultTest(t, ctx, cntrlr)
+			bundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, bundle)
+		},
+	}
+	tc.run(t)
+}
+
+// Should ignore Bundles in namespaces that are not in the list of watched namespaces
+func TestBundleInNotWatchedNamespaceIgnored(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		appName:    testAppName,
+		namespace:  testNamespace,
+		namespaces: "ns1, ns2",
+		mainClientObjects: []runtime.Object{
+			configMapNeedsUpdate(),
+		},
+		bundle: configMapBundle(),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			assert.Equal(t, []string{"ns1", "ns2"}, cntrlr.Namespaces.List())
+			tc.defaultTest(t, ctx, cntrlr)
+			assert.Nil(t, tc.findBundleUpdate(t, false))
+			// Objects in namespaces that are not watched are not in the store
+			_, exists, err := cntrlr.Store.Get(core_v1.SchemeGroupVersion.WithKind("ConfigMap"), testNamespace, mapNeedsAnUpdate)
+			require.NoError(t, err)
+			assert.False(t, exists)
+		},
+	}
+	tc.run(t)
+}
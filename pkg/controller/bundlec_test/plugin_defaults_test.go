@@ -0,0 +1,74 @@
+package bundlec_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Should apply defaults from the spec schema before invoking the plugin and report the plugin version
+func TestPluginSpecDefaults(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		bundle:    pluginBundle(pluginDefaults),
+		appName:   testAppName,
+		namespace: testNamespace,
+		expectedActions: sets.NewString(
+			"POST=/api/v1/namespaces/" + testNamespace + "/configmaps",
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "POST",
+					path:   "/api/v1/namespaces/" + testNamespace + "/configmaps",
+				}: {
+					statusCode: http.StatusCreated,
+					content: []byte(`{
+						"apiVersion": "v1",
+						"kind": "ConfigMap",
+						"metadata": {
+							"name": "` + m1 + `",
+							"namespace": "` + testNamespace + `",
+							"uid": "m1-uid",
+							"ownerReferences": [
+								{
+									"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+									"kind": "` + smith_v1.BundleResourceKind + `",
+									"name": "` + bundle1 + `",
+									"uid": "` + string(bundle1uid) + `",
+									"controller": true,
+									"blockOwnerDeletion": true
+								}
+							] },
+						"data": {
+							"mode": "standalone"
+						}
+					}`),
+				},
+			},
+		},
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginDefaults: newDefaultsPlugin,
+		},
+		pluginsShouldBeInvoked: sets.NewString(string(pluginDefaults)),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			_, _, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.NoError(t, err)
+
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			smith_testing.AssertResourceCondition(t, updateBundle, resP1, smith_v1.ResourceReady, cond_v1.ConditionTrue)
+			require.Len(t, updateBundle.Status.PluginStatuses, 1)
+			assert.Equal(t, "1.0.0", updateBundle.Status.PluginStatuses[0].PluginVersion)
+		},
+	}
+	tc.run(t)
+}
@@ -0,0 +1,52 @@
+package bundlec_test
+
+import (
+	"context"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Should not process any resource if plugin inputs of one of them are not satisfied
+func TestPluginInputsCheckedBeforeProcessing(t *testing.T) {
+	t.Parallel()
+	bundle := configMapBundle()
+	bundle.Spec.Resources = append(bundle.Spec.Resources, smith_v1.Resource{
+		Name: resP1,
+		References: []smith_v1.Reference{
+			{Name: "binding", Resource: "resM1"},
+		},
+		Spec: smith_v1.ResourceSpec{
+			Plugin: &smith_v1.PluginSpec{
+				Name:       pluginInputs,
+				ObjectName: "m2",
+			},
+		},
+	})
+	tc := testCase{
+		bundle:    bundle,
+		appName:   testAppName,
+		namespace: testNamespace,
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginInputs: newInputsPlugin,
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			assert.EqualError(t, err, `resource "`+resP1+`": reference "binding" of plugin "pluginInputs" must refer to a resource of kind [servicecatalog.k8s.io/v1beta1, Kind=ServiceBinding], got /v1, Kind=ConfigMap`)
+			assert.True(t, external, "error should be an external error")
+			assert.False(t, retriable, "error should not be retriable")
+
+			// ConfigMap that does not depend on the plugin resource is not created either
+			assert.Empty(t, tc.testHandler.getActions())
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			smith_testing.AssertCondition(t, updateBundle, smith_v1.BundleError, cond_v1.ConditionTrue)
+		},
+	}
+	tc.run(t)
+}
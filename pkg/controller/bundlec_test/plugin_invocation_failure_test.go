@@ -0,0 +1,125 @@
+package bundlec_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func pluginBundle(pluginName smith_v1.PluginName) *smith_v1.Bundle {
+	return &smith_v1.Bundle{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       bundle1,
+			Namespace:  testNamespace,
+			UID:        bundle1uid,
+			Finalizers: []string{bundlec.FinalizerDeleteResources},
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: resP1,
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       pluginName,
+							ObjectName: m1,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Should turn a plugin panic into an internal error of the resource rather than crash the controller
+func TestPluginPanicIsolated(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		bundle:    pluginBundle(pluginPanicking),
+		namespace: testNamespace,
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginPanicking: newPanickingPlugin,
+		},
+		pluginsShouldBeInvoked: sets.NewString(string(pluginPanicking)),
+		pluginStatuses: map[smith_v1.PluginName]smith_v1.PluginStatusStr{
+			pluginPanicking: smith_v1.PluginStatusInvocationFailed,
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			assert.EqualError(t, err, `error processing resource(s): ["`+resP1+`"]`)
+			assert.False(t, external, "error should be an internal error")
+			assert.False(t, retriable, "error should not be a retriable error")
+
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			resCond := smith_testing.AssertResourceCondition(t, updateBundle, resP1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, smith_v1.ResourceReasonTerminalError, resCond.Reason)
+				assert.Equal(t, `plugin "pluginPanicking" panicked: plugin panicked as it should. BOOM!`, resCond.Message)
+			}
+			require.Len(t, updateBundle.Status.PluginStatuses, 1)
+			assert.Equal(t, resCond.Message, updateBundle.Status.PluginStatuses[0].Message)
+		},
+	}
+	tc.run(t)
+}
+
+// Should fail the resource with a retriable error if the plugin does not return within the deadline
+// and not invoke the plugin again until the invocation returns
+func TestPluginTimeout(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	defer close(release)
+	invoked := make(chan context.Context, 2)
+	tc := testCase{
+		bundle:    pluginBundle(pluginHanging),
+		namespace: testNamespace,
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginHanging: newHangingPlugin(release, invoked),
+		},
+		pluginsShouldBeInvoked: sets.NewString(string(pluginHanging)),
+		pluginTimeout:          100 * time.Millisecond,
+		pluginStatuses: map[smith_v1.PluginName]smith_v1.PluginStatusStr{
+			pluginHanging: smith_v1.PluginStatusInvocationFailed,
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			assert.EqualError(t, err, `error processing resource(s): ["`+resP1+`"]`)
+			assert.False(t, external, "error should be an internal error")
+			assert.True(t, retriable, "error should be a retriable error")
+
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			resCond := smith_testing.AssertResourceCondition(t, updateBundle, resP1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, smith_v1.ResourceReasonRetriableError, resCond.Reason)
+				assert.Equal(t, `plugin "pluginHanging" did not return within 100ms`, resCond.Message)
+			}
+			pluginCtx := <-invoked
+			assert.Equal(t, context.DeadlineExceeded, pluginCtx.Err())
+
+			// Plugin is still running
+			tc.smithFake.ClearActions()
+			_, retriable, err = cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			assert.EqualError(t, err, `error processing resource(s): ["`+resP1+`"]`)
+			assert.True(t, retriable, "error should be a retriable error")
+			assert.Empty(t, invoked, "plugin should not be invoked again")
+
+			updateBundle = tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			resCond = smith_testing.AssertResourceCondition(t, updateBundle, resP1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, `plugin "pluginHanging" cannot be invoked: 1 invocation(s) that did not return within the deadline are still running`, resCond.Message)
+			}
+		},
+	}
+	tc.run(t)
+}
@@ -0,0 +1,148 @@
+package bundlec_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Should let the plugin look up declared objects and record them in the resource status
+func TestPluginLookup(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		mainClientObjects: []runtime.Object{
+			&core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      mapPlatform,
+					Namespace: testNamespace,
+				},
+				Data: map[string]string{
+					"region": "us-east-1",
+				},
+			},
+		},
+		bundle:    pluginBundle(pluginLookup),
+		appName:   testAppName,
+		namespace: testNamespace,
+		expectedActions: sets.NewString(
+			"POST=/api/v1/namespaces/" + testNamespace + "/configmaps",
+		),
+		testHandler: fakeActionHandler{
+			response: map[path]fakeResponse{
+				{
+					method: "POST",
+					path:   "/api/v1/namespaces/" + testNamespace + "/configmaps",
+				}: {
+					statusCode: http.StatusCreated,
+					content: []byte(`{
+						"apiVersion": "v1",
+						"kind": "ConfigMap",
+						"metadata": {
+							"name": "` + m1 + `",
+							"namespace": "` + testNamespace + `",
+							"uid": "m1-uid",
+							"ownerReferences": [
+								{
+									"apiVersion": "` + smith_v1.BundleResourceGroupVersion + `",
+									"kind": "` + smith_v1.BundleResourceKind + `",
+									"name": "` + bundle1 + `",
+									"uid": "` + string(bundle1uid) + `",
+									"controller": true,
+									"blockOwnerDeletion": true
+								}
+							] },
+						"data": {
+							"region": "us-east-1"
+						}
+					}`),
+				},
+			},
+		},
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginLookup: newLookupPlugin,
+		},
+		pluginsShouldBeInvoked: sets.NewString(string(pluginLookup)),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			_, _, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.NoError(t, err)
+
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			smith_testing.AssertResourceCondition(t, updateBundle, resP1, smith_v1.ResourceReady, cond_v1.ConditionTrue)
+			_, resStatus := updateBundle.Status.GetResourceStatus(resP1)
+			require.NotNil(t, resStatus)
+			// Only the objects that were allowed to be looked up are recorded
+			assert.Equal(t, []smith_v1.LookedUpObject{
+				{Version: "v1", Kind: "ConfigMap", Namespace: testNamespace, Name: mapPlatform},
+			}, resStatus.Lookups)
+		},
+	}
+	tc.run(t)
+}
+
+// Should reject lookups if only objects managed by Smith are watched because changes to other objects are not noticed
+func TestPluginLookupRejectedIfManagedObjectsOnly(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		bundle:             pluginBundle(pluginLookup),
+		appName:            testAppName,
+		namespace:          testNamespace,
+		managedByLabel:     true,
+		managedObjectsOnly: true,
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginLookup: newLookupPlugin,
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["`+resP1+`"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			smith_testing.AssertResourceConditionMessage(t, updateBundle, resP1, smith_v1.ResourceError,
+				`plugin "pluginLookup" declared lookups but only objects managed by Smith are watched`)
+		},
+	}
+	tc.run(t)
+}
+
+// Should reject lookups in namespaces that are not watched because changes to objects there are not noticed
+func TestPluginLookupRejectedInNamespaceThatIsNotWatched(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		bundle:    pluginBundle(pluginLookup),
+		appName:   testAppName,
+		namespace: testNamespace,
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginLookup: newLookupPluginInNamespace("other"),
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["`+resP1+`"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			smith_testing.AssertResourceConditionMessage(t, updateBundle, resP1, smith_v1.ResourceError,
+				`plugin "pluginLookup" declared lookups of /v1, Kind=ConfigMap in namespace "other" that is not watched`)
+		},
+	}
+	tc.run(t)
+}
@@ -0,0 +1,46 @@
+package bundlec_test
+
+import (
+	"context"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Should not create an object if the plugin output does not match the output schema of the plugin
+func TestPluginOutputSchemaViolated(t *testing.T) {
+	t.Parallel()
+	bundle := pluginBundle(pluginOutputSchema)
+	bundle.Spec.Resources[0].Spec.Plugin.Spec = map[string]interface{}{
+		"otherKey": "value",
+	}
+	tc := testCase{
+		bundle:    bundle,
+		namespace: testNamespace,
+		plugins: map[smith_v1.PluginName]func(*testing.T) testingPlugin{
+			pluginOutputSchema: newOutputSchemaPlugin,
+		},
+		pluginsShouldBeInvoked: sets.NewString(string(pluginOutputSchema)),
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			assert.EqualError(t, err, `error processing resource(s): ["`+resP1+`"]`)
+			assert.False(t, external, "error should be an internal error")
+			assert.False(t, retriable, "error should not be a retriable error")
+
+			updateBundle := tc.findBundleUpdate(t, true)
+			require.NotNil(t, updateBundle)
+			resCond := smith_testing.AssertResourceCondition(t, updateBundle, resP1, smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, smith_v1.ResourceReasonTerminalError, resCond.Reason)
+				assert.Equal(t, `output of plugin "pluginOutputSchema" failed validation against schema: data: key is required`, resCond.Message)
+			}
+		},
+	}
+	tc.run(t)
+}
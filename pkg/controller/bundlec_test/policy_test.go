@@ -0,0 +1,83 @@
+package bundlec_test
+
+import (
+	"context"
+	"testing"
+
+	cond_v1 "github.com/atlassian/ctrl/apis/condition/v1"
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/controller/bundlec"
+	smith_testing "github.com/atlassian/smith/pkg/util/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Should not create objects of kinds denied by a policy
+func TestObjectDeniedByPolicy(t *testing.T) {
+	t.Parallel()
+	tc := testCase{
+		appName:   testAppName,
+		namespace: testNamespace,
+		policies:  true,
+		bundle:    configMapBundle(),
+		smithClientObjects: []runtime.Object{
+			&smith_v1.ClusterBundlePolicy{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: "no-configmaps",
+				},
+				Spec: smith_v1.BundlePolicySpec{
+					DeniedKinds: []smith_v1.KindSelector{
+						{Group: "", Kind: "ConfigMap"},
+					},
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `error processing resource(s): ["resM1"]`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			resCond := smith_testing.AssertResourceCondition(t, bundle, "resM1", smith_v1.ResourceError, cond_v1.ConditionTrue)
+			if resCond != nil {
+				assert.Equal(t, smith_v1.ResourceReasonTerminalError, resCond.Reason)
+				assert.Equal(t, `/v1, Kind=ConfigMap is denied by ClusterBundlePolicy "no-configmaps"`, resCond.Message)
+			}
+		},
+	}
+	tc.run(t)
+}
+
+// Should not process Bundles with more resources than a policy allows
+func TestTooManyResourcesForPolicy(t *testing.T) {
+	t.Parallel()
+	maxResources := int32(0)
+	tc := testCase{
+		appName:   testAppName,
+		namespace: testNamespace,
+		policies:  true,
+		bundle:    configMapBundle(),
+		smithClientObjects: []runtime.Object{
+			&smith_v1.BundlePolicy{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "empty",
+					Namespace: testNamespace,
+				},
+				Spec: smith_v1.BundlePolicySpec{
+					MaxResources: &maxResources,
+				},
+			},
+		},
+		test: func(t *testing.T, ctx context.Context, cntrlr *bundlec.Controller, tc *testCase) {
+			external, retriable, err := cntrlr.ProcessBundle(tc.logger, tc.bundle)
+			require.EqualError(t, err, `Bundle has 1 resources but BundlePolicy "`+testNamespace+`/empty" allows at most 0`)
+			assert.True(t, external)
+			assert.False(t, retriable)
+			bundle := tc.findBundleUpdate(t, true)
+			smith_testing.AssertCondition(t, bundle, smith_v1.BundleError, cond_v1.ConditionTrue)
+		},
+	}
+	tc.run(t)
+}
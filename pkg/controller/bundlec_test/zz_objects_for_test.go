@@ -8,17 +8,17 @@ import (
 	"github.com/atlassian/smith/pkg/specchecker/builtin"
 	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
 	core_v1 "k8s.io/api/core/v1"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
-func sleeperCrdWithStatus() *apiext_v1b1.CustomResourceDefinition {
+func sleeperCrdWithStatus() *apiext_v1.CustomResourceDefinition {
 	crd := sleeper.Crd()
-	crd.Status = apiext_v1b1.CustomResourceDefinitionStatus{
-		Conditions: []apiext_v1b1.CustomResourceDefinitionCondition{
-			{Type: apiext_v1b1.Established, Status: apiext_v1b1.ConditionTrue},
-			{Type: apiext_v1b1.NamesAccepted, Status: apiext_v1b1.ConditionTrue},
+	crd.Status = apiext_v1.CustomResourceDefinitionStatus{
+		Conditions: []apiext_v1.CustomResourceDefinitionCondition{
+			{Type: apiext_v1.Established, Status: apiext_v1.ConditionTrue},
+			{Type: apiext_v1.NamesAccepted, Status: apiext_v1.ConditionTrue},
 		},
 	}
 	return crd
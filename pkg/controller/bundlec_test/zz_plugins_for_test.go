@@ -1,6 +1,8 @@
 package bundlec_test
 
 import (
+	"context"
+	"sync/atomic"
 	"testing"
 
 	sleeper_v1 "github.com/atlassian/smith/examples/sleeper/pkg/apis/sleeper/v1"
@@ -11,6 +13,8 @@ import (
 	core_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 type testingPlugin interface {
@@ -57,23 +61,23 @@ func (p *configMapWithDeps) Describe() *plugin.Description {
 	}
 }
 
-func (p *configMapWithDeps) Process(pluginSpec map[string]interface{}, context *plugin.Context) plugin.ProcessResult {
+func (p *configMapWithDeps) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
 	p.wasInvoked = true
 	failed := p.t.Failed()
 
-	assert.Equal(p.t, testNamespace, context.Namespace)
+	assert.Equal(p.t, testNamespace, pctx.Namespace)
 
 	actualShouldExist, _ := pluginSpec["actualShouldExist"].(bool)
 	delete(pluginSpec, "actualShouldExist")
 	assert.Equal(p.t, map[string]interface{}{"p1": "v1", "p2": sb1}, pluginSpec)
 
 	if actualShouldExist {
-		assert.IsType(p.t, &core_v1.ConfigMap{}, context.Actual)
+		assert.IsType(p.t, &core_v1.ConfigMap{}, pctx.Actual)
 	} else {
-		assert.Nil(p.t, context.Actual)
+		assert.Nil(p.t, pctx.Actual)
 	}
 
-	bindingDep, ok := context.Dependencies[resSb1]
+	bindingDep, ok := pctx.Dependencies[resSb1]
 	if p.expectBinding && assert.True(p.t, ok) {
 		// Actual
 		if assert.IsType(p.t, &sc_v1b1.ServiceBinding{}, bindingDep.Actual) {
@@ -103,7 +107,7 @@ func (p *configMapWithDeps) Process(pluginSpec map[string]interface{}, context *
 			}
 		}
 	}
-	sleeperDep, ok := context.Dependencies[resSleeper1]
+	sleeperDep, ok := pctx.Dependencies[resSleeper1]
 	if p.expectSleeper && assert.True(p.t, ok) {
 		// Actual
 		if assert.IsType(p.t, &unstructured.Unstructured{}, sleeperDep.Actual) {
@@ -156,18 +160,18 @@ func (p *simpleConfigMap) Describe() *plugin.Description {
 	}
 }
 
-func (p *simpleConfigMap) Process(pluginSpec map[string]interface{}, context *plugin.Context) plugin.ProcessResult {
+func (p *simpleConfigMap) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
 	p.wasInvoked = true
 	failed := p.t.Failed()
 
-	assert.Equal(p.t, testNamespace, context.Namespace)
+	assert.Equal(p.t, testNamespace, pctx.Namespace)
 
 	actualShouldExist, _ := pluginSpec["actualShouldExist"].(bool)
 
 	if actualShouldExist {
-		assert.IsType(p.t, &core_v1.ConfigMap{}, context.Actual)
+		assert.IsType(p.t, &core_v1.ConfigMap{}, pctx.Actual)
 	} else {
-		assert.Nil(p.t, context.Actual)
+		assert.Nil(p.t, pctx.Actual)
 	}
 
 	if !failed && p.t.Failed() { // one of the assertions failed and it was the first failure in the test
@@ -212,18 +216,18 @@ func (p *mockConfigMap) Describe() *plugin.Description {
 	}
 }
 
-func (p *mockConfigMap) Process(pluginSpec map[string]interface{}, context *plugin.Context) plugin.ProcessResult {
+func (p *mockConfigMap) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
 	p.wasInvoked = true
 	failed := p.t.Failed()
 
-	assert.Equal(p.t, testNamespace, context.Namespace)
+	assert.Equal(p.t, testNamespace, pctx.Namespace)
 
 	actualShouldExist, _ := pluginSpec["actualShouldExist"].(bool)
 
 	if actualShouldExist {
-		assert.IsType(p.t, &core_v1.ConfigMap{}, context.Actual)
+		assert.IsType(p.t, &core_v1.ConfigMap{}, pctx.Actual)
 	} else {
-		assert.Nil(p.t, context.Actual)
+		assert.Nil(p.t, pctx.Actual)
 	}
 
 	if !failed && p.t.Failed() { // one of the assertions failed and it was the first failure in the test
@@ -259,9 +263,349 @@ func (p *failingPluginStruct) Describe() *plugin.Description {
 	}
 }
 
-func (p *failingPluginStruct) Process(pluginSpec map[string]interface{}, context *plugin.Context) plugin.ProcessResult {
+func (p *failingPluginStruct) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
 	p.wasInvoked = true
 	return &plugin.ProcessResultFailure{
 		Error: errors.New("plugin failed as it should. BOOM!"),
 	}
 }
+
+func newPanickingPlugin(t *testing.T) testingPlugin {
+	return &panickingPluginStruct{}
+}
+
+type panickingPluginStruct struct {
+	wasInvoked int32
+}
+
+func (p *panickingPluginStruct) WasInvoked() bool {
+	return atomic.LoadInt32(&p.wasInvoked) != 0
+}
+
+func (p *panickingPluginStruct) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: pluginPanicking,
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+	}
+}
+
+func (p *panickingPluginStruct) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	atomic.StoreInt32(&p.wasInvoked, 1)
+	panic("plugin panicked as it should. BOOM!")
+}
+
+// newHangingPlugin returns a plugin that blocks until release is closed, ignoring cancellation of the context.
+// Contexts of invocations are sent to invoked.
+func newHangingPlugin(release <-chan struct{}, invoked chan<- context.Context) func(*testing.T) testingPlugin {
+	return func(t *testing.T) testingPlugin {
+		return &hangingPluginStruct{
+			release: release,
+			invoked: invoked,
+		}
+	}
+}
+
+type hangingPluginStruct struct {
+	release    <-chan struct{}
+	invoked    chan<- context.Context
+	wasInvoked int32
+}
+
+func (p *hangingPluginStruct) WasInvoked() bool {
+	return atomic.LoadInt32(&p.wasInvoked) != 0
+}
+
+func (p *hangingPluginStruct) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: pluginHanging,
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+	}
+}
+
+func (p *hangingPluginStruct) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	atomic.StoreInt32(&p.wasInvoked, 1)
+	p.invoked <- pctx.Context
+	<-p.release
+	return &plugin.ProcessResultFailure{
+		Error: errors.New("plugin returned after the deadline"),
+	}
+}
+
+// newMultiObjectPlugin returns a plugin that produces a ConfigMap and a Secret named after the "configMap"
+// and "secret" keys of the spec. Kind of the Secret is overridden with the "secretKind" key if it is set.
+func newMultiObjectPlugin(t *testing.T) testingPlugin {
+	return &multiObjectPlugin{
+		t: t,
+	}
+}
+
+type multiObjectPlugin struct {
+	t          *testing.T
+	wasInvoked bool
+}
+
+func (p *multiObjectPlugin) WasInvoked() bool {
+	return p.wasInvoked
+}
+
+func (p *multiObjectPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: pluginMultiObject,
+		GVKs: []schema.GroupVersionKind{
+			core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+			core_v1.SchemeGroupVersion.WithKind("Secret"),
+		},
+	}
+}
+
+func (p *multiObjectPlugin) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	p.wasInvoked = true
+
+	assert.Equal(p.t, testNamespace, pctx.Namespace)
+	assert.Nil(p.t, pctx.Actual)
+
+	secretKind, ok := pluginSpec["secretKind"].(string)
+	if !ok {
+		secretKind = "Secret"
+	}
+	return &plugin.ProcessResultSuccess{
+		Objects: []runtime.Object{
+			// Not in the sorted order on purpose
+			&core_v1.Secret{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       secretKind,
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: pluginSpec["secret"].(string),
+				},
+			},
+			&core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: pluginSpec["configMap"].(string),
+				},
+			},
+		},
+	}
+}
+
+// newOutputSchemaPlugin returns a plugin that requires produced ConfigMaps to have the "key" key and
+// copies the "data" of the spec into the ConfigMap.
+func newOutputSchemaPlugin(t *testing.T) testingPlugin {
+	return &outputSchemaPlugin{}
+}
+
+type outputSchemaPlugin struct {
+	wasInvoked bool
+}
+
+func (p *outputSchemaPlugin) WasInvoked() bool {
+	return p.wasInvoked
+}
+
+func (p *outputSchemaPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: pluginOutputSchema,
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		OutputSchema: []byte(`{
+			"type": "object",
+			"properties": {
+				"data": {
+					"type": "object",
+					"required": ["key"]
+				}
+			},
+			"required": ["data"]
+		}`),
+	}
+}
+
+func (p *outputSchemaPlugin) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	p.wasInvoked = true
+	data := make(map[string]string)
+	for k, v := range pluginSpec {
+		data[k] = v.(string)
+	}
+	return &plugin.ProcessResultSuccess{
+		Object: &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			Data: data,
+		},
+	}
+}
+
+// newLookupPlugin returns a plugin that copies data of the ConfigMap it looks up into the produced ConfigMap.
+func newLookupPlugin(t *testing.T) testingPlugin {
+	return &lookupPlugin{
+		t: t,
+	}
+}
+
+// newLookupPluginInNamespace returns a lookup plugin that declares lookups in the namespace.
+func newLookupPluginInNamespace(namespace string) func(*testing.T) testingPlugin {
+	return func(t *testing.T) testingPlugin {
+		return &lookupPlugin{
+			t:         t,
+			namespace: namespace,
+		}
+	}
+}
+
+type lookupPlugin struct {
+	t          *testing.T
+	namespace  string
+	wasInvoked bool
+}
+
+func (p *lookupPlugin) WasInvoked() bool {
+	return p.wasInvoked
+}
+
+func (p *lookupPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: pluginLookup,
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		Lookups: []plugin.Lookup{
+			{
+				GVK:       core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+				Namespace: p.namespace,
+				Names:     []string{mapPlatform},
+			},
+		},
+	}
+}
+
+func (p *lookupPlugin) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	p.wasInvoked = true
+	configMapGVK := core_v1.SchemeGroupVersion.WithKind("ConfigMap")
+
+	// Objects that were not declared cannot be looked up
+	_, err := pctx.Lookup.Get(configMapGVK, pctx.Namespace, mapNeedsAnUpdate)
+	assert.EqualError(p.t, err, `plugin "pluginLookup" did not declare lookups of /v1, Kind=ConfigMap "`+mapNeedsAnUpdate+`" in namespace "`+testNamespace+`"`)
+
+	obj, err := pctx.Lookup.Get(configMapGVK, pctx.Namespace, mapPlatform)
+	if err != nil {
+		return &plugin.ProcessResultFailure{
+			Error: err,
+		}
+	}
+	if !assert.IsType(p.t, &core_v1.ConfigMap{}, obj) {
+		return &plugin.ProcessResultFailure{
+			Error: errors.New("plugin failed BOOM!"),
+		}
+	}
+	return &plugin.ProcessResultSuccess{
+		Object: &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			Data: obj.(*core_v1.ConfigMap).Data,
+		},
+	}
+}
+
+func newDefaultsPlugin(t *testing.T) testingPlugin {
+	return &defaultsPlugin{
+		t: t,
+	}
+}
+
+type defaultsPlugin struct {
+	t          *testing.T
+	wasInvoked bool
+}
+
+func (p *defaultsPlugin) WasInvoked() bool {
+	return p.wasInvoked
+}
+
+func (p *defaultsPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name:    pluginDefaults,
+		Version: "1.0.0",
+		GVK:     core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		SpecSchema: []byte(`{
+			"type": "object",
+			"required": ["data"],
+			"properties": {
+				"data": {
+					"type": "object",
+					"required": ["mode"],
+					"default": {},
+					"properties": {
+						"mode": {"type": "string", "default": "standalone"}
+					}
+				}
+			}
+		}`),
+	}
+}
+
+func (p *defaultsPlugin) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	p.wasInvoked = true
+	specData, ok := pluginSpec["data"].(map[string]interface{})
+	if !assert.True(p.t, ok, "defaults should have been applied to the spec") {
+		return &plugin.ProcessResultFailure{
+			Error: errors.New("plugin failed BOOM!"),
+		}
+	}
+	data := make(map[string]string, len(specData))
+	for k, v := range specData {
+		data[k] = v.(string)
+	}
+	return &plugin.ProcessResultSuccess{
+		Object: &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+			Data: data,
+		},
+	}
+}
+
+// newInputsPlugin returns a plugin that requires a reference to a ServiceBinding named "binding".
+func newInputsPlugin(t *testing.T) testingPlugin {
+	return &inputsPlugin{}
+}
+
+type inputsPlugin struct {
+	wasInvoked bool
+}
+
+func (p *inputsPlugin) WasInvoked() bool {
+	return p.wasInvoked
+}
+
+func (p *inputsPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: pluginInputs,
+		GVK:  core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		Inputs: []plugin.Input{
+			{
+				Name: "binding",
+				GVKs: []schema.GroupVersionKind{sc_v1b1.SchemeGroupVersion.WithKind("ServiceBinding")},
+			},
+		},
+	}
+}
+
+func (p *inputsPlugin) Process(pluginSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	p.wasInvoked = true
+	return &plugin.ProcessResultSuccess{
+		Object: &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: core_v1.SchemeGroupVersion.String(),
+			},
+		},
+	}
+}
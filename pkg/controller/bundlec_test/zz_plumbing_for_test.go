@@ -3,6 +3,7 @@ package bundlec_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -21,6 +22,7 @@ import (
 	"github.com/atlassian/smith/pkg/crd"
 	"github.com/atlassian/smith/pkg/plugin"
 	"github.com/atlassian/smith/pkg/util"
+	"github.com/atlassian/smith/pkg/webhook"
 	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
 	scClientset "github.com/kubernetes-sigs/service-catalog/pkg/client/clientset_generated/clientset"
 	scFake "github.com/kubernetes-sigs/service-catalog/pkg/client/clientset_generated/clientset/fake"
@@ -29,7 +31,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	admreg_v1 "k8s.io/api/admissionregistration/v1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiExtFake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
 	"k8s.io/apimachinery/pkg/api/meta"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -69,11 +72,24 @@ type testCase struct {
 
 	expectedActions        sets.String
 	enableServiceCatalog   bool
+	failFast               bool
+	impersonate            bool
+	accessReview           bool
+	policies               bool
+	clusterScopedObjects   bool
+	managedByLabel         bool
+	managedObjectsOnly     bool
+	dynamicInformers       bool
+	namespaces             string
+	namespaceSelector      string
 	testHandler            fakeActionHandler
 	test                   func(*testing.T, context.Context, *bundlec.Controller, *testCase)
 	plugins                map[smith_v1.PluginName]func(*testing.T) testingPlugin
 	pluginsShouldBeInvoked sets.String
-	testTimeout            time.Duration
+	pluginTimeout          time.Duration
+	// pluginStatuses overrides the expected status of constructed plugins, which is Ok by default.
+	pluginStatuses map[smith_v1.PluginName]smith_v1.PluginStatusStr
+	testTimeout    time.Duration
 
 	mainFake           *kube_testing.Fake
 	smithFake          *kube_testing.Fake
@@ -114,6 +130,8 @@ const (
 	mapNeedsAnUpdate              = "map-needs-update"
 	mapNeedsAnUpdateUid types.UID = "map-needs-update-uid"
 
+	mapPlatform = "platform-config"
+
 	mapNeedsDelete              = "map-not-in-the-bundle-anymore-needs-delete"
 	mapNeedsDeleteUid types.UID = "map-needs-delete-uid"
 
@@ -124,6 +142,13 @@ const (
 	pluginSimpleConfigMap   smith_v1.PluginName = "simpleConfigMap"
 	pluginConfigMapWithDeps smith_v1.PluginName = "configMapWithDeps"
 	pluginFailing           smith_v1.PluginName = "pluginFailing"
+	pluginPanicking         smith_v1.PluginName = "pluginPanicking"
+	pluginHanging           smith_v1.PluginName = "pluginHanging"
+	pluginMultiObject       smith_v1.PluginName = "pluginMultiObject"
+	pluginOutputSchema      smith_v1.PluginName = "pluginOutputSchema"
+	pluginLookup            smith_v1.PluginName = "pluginLookup"
+	pluginDefaults          smith_v1.PluginName = "pluginDefaults"
+	pluginInputs            smith_v1.PluginName = "pluginInputs"
 
 	serviceClassNameAndID    = "uid-1"
 	serviceClassExternalName = "database"
@@ -141,10 +166,14 @@ var (
 )
 
 func (tc *testCase) run(t *testing.T) {
-	mainClient := mainFake.NewSimpleClientset(tc.mainClientObjects...)
+	mainClientObjects := tc.mainClientObjects
+	if tc.impersonate || tc.accessReview {
+		mainClientObjects = append(mainClientObjects[:len(mainClientObjects):len(mainClientObjects)], bundleAuthorWebhook())
+	}
+	mainClient := mainFake.NewSimpleClientset(mainClientObjects...)
 	tc.mainFake = &mainClient.Fake
 	for _, reactor := range tc.mainReactors {
-		mainClient.AddReactor(reactor.verb, reactor.resource, reactor.reactor(t))
+		mainClient.PrependReactor(reactor.verb, reactor.resource, reactor.reactor(t))
 	}
 	if tc.bundle != nil {
 		tc.bundle.TypeMeta = meta_v1.TypeMeta{
@@ -158,7 +187,7 @@ func (tc *testCase) run(t *testing.T) {
 	smithClient := smithFake.NewSimpleClientset(tc.smithClientObjects...)
 	tc.smithFake = &smithClient.Fake
 	for _, reactor := range tc.smithReactors {
-		smithClient.AddReactor(reactor.verb, reactor.resource, reactor.reactor(t))
+		smithClient.PrependReactor(reactor.verb, reactor.resource, reactor.reactor(t))
 	}
 	scheme, err := app.FullScheme(tc.enableServiceCatalog)
 	require.NoError(t, err)
@@ -168,9 +197,20 @@ func (tc *testCase) run(t *testing.T) {
 		scheme.Default(object)
 	}
 	apiExtClient := apiExtFake.NewSimpleClientset(apiExtObjects...)
+	apiExtClient.Resources = []*meta_v1.APIResourceList{
+		{
+			GroupVersion: apiext_v1.SchemeGroupVersion.String(),
+			APIResources: []meta_v1.APIResource{
+				{
+					Name: "customresourcedefinitions",
+					Kind: "CustomResourceDefinition",
+				},
+			},
+		},
+	}
 	tc.apiExtFake = &apiExtClient.Fake
 	for _, reactor := range tc.apiExtReactors {
-		apiExtClient.AddReactor(reactor.verb, reactor.resource, reactor.reactor(t))
+		apiExtClient.PrependReactor(reactor.verb, reactor.resource, reactor.reactor(t))
 	}
 
 	var scClient scClientset.Interface
@@ -220,7 +260,7 @@ func (tc *testCase) run(t *testing.T) {
 		tc.scFake = &scClientFake.Fake
 		scClient = scClientFake
 		for _, reactor := range tc.scReactors {
-			scClientFake.AddReactor(reactor.verb, reactor.resource, reactor.reactor(t))
+			scClientFake.PrependReactor(reactor.verb, reactor.resource, reactor.reactor(t))
 		}
 	}
 
@@ -232,16 +272,18 @@ func (tc *testCase) run(t *testing.T) {
 	}
 
 	for _, object := range tc.apiExtClientObjects {
-		crd := object.(*apiext_v1b1.CustomResourceDefinition)
-		scheme.AddKnownTypeWithName(schema.GroupVersionKind{
-			Group:   crd.Spec.Group,
-			Version: crd.Spec.Versions[0].Name,
-			Kind:    crd.Spec.Names.Kind,
-			// obj: unstructured.Unstructured
-			// is here _only_ to keep rest scheme happy, we do not currently use scheme to deserialize
-		}, &unstructured.Unstructured{})
+		crd := object.(*apiext_v1.CustomResourceDefinition)
+		for _, version := range crd.Spec.Versions {
+			scheme.AddKnownTypeWithName(schema.GroupVersionKind{
+				Group:   crd.Spec.Group,
+				Version: version.Name,
+				Kind:    crd.Spec.Names.Kind,
+				// obj: unstructured.Unstructured
+				// is here _only_ to keep rest scheme happy, we do not currently use scheme to deserialize
+			}, &unstructured.Unstructured{})
+		}
 	}
-	restMapper := restMapperFromScheme(scheme)
+	restMapper := restMapperFromScheme(scheme, tc.apiExtClientObjects)
 
 	tc.logger = zaptest.NewLogger(t)
 	defer tc.logger.Sync()
@@ -259,9 +301,14 @@ func (tc *testCase) run(t *testing.T) {
 
 	// Controller
 	prometheusRegistry := prometheus.NewPedanticRegistry()
+	configNamespace := tc.namespace
+	if tc.namespaces != "" || tc.namespaceSelector != "" {
+		// Set of namespaces is watched instead
+		configNamespace = meta_v1.NamespaceAll
+	}
 	config := &ctrl.Config{
 		Logger:     tc.logger,
-		Namespace:  tc.namespace,
+		Namespace:  configNamespace,
 		RestConfig: clientConfig,
 		MainClient: mainClient,
 		AppName:    tc.appName,
@@ -272,6 +319,7 @@ func (tc *testCase) run(t *testing.T) {
 	bundleConstr := &app.BundleControllerConstructor{
 		Plugins:               plugins,
 		ServiceCatalogSupport: tc.enableServiceCatalog,
+		FailFast:              tc.failFast,
 		SmithClient:           smithClient,
 		SCClient:              scClient,
 		APIExtClient:          apiExtClient,
@@ -279,6 +327,22 @@ func (tc *testCase) run(t *testing.T) {
 			DynamicClient: dynamicClient,
 			RESTMapper:    restMapper,
 		},
+		Impersonate:              tc.impersonate,
+		ImpersonatingSmartClient: smart.NewImpersonatingClient(clientConfig, restMapper),
+		AccessReview:             tc.accessReview,
+		Policies:                 tc.policies,
+		ClusterScopedObjects:     tc.clusterScopedObjects,
+		Namespaces:               tc.namespaces,
+		NamespaceSelector:        tc.namespaceSelector,
+		ManagedByLabel:           tc.managedByLabel,
+		ManagedObjectsOnly:       tc.managedObjectsOnly,
+		DynamicInformers:         tc.dynamicInformers,
+		PluginTimeout:            tc.pluginTimeout,
+		RESTMapper:               restMapper,
+		AccessReviewer: &smart.AccessReviewer{
+			Client:     mainClient.AuthorizationV1(),
+			RESTMapper: restMapper,
+		},
 	}
 	generic, err := process.NewGeneric(config,
 		workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "multiqueue"),
@@ -389,8 +453,13 @@ nextPlugin:
 			if pluginStatus.Name != describe.Name {
 				continue
 			}
-			assert.Equal(t, smith_v1.PluginStatusOk, pluginStatus.Status)
-			assert.Equal(t, describe.GVK, schema.GroupVersionKind{
+			expectedStatus, ok := tc.pluginStatuses[describe.Name]
+			if !ok {
+				expectedStatus = smith_v1.PluginStatusOk
+			}
+			assert.Equal(t, expectedStatus, pluginStatus.Status)
+			assert.Equal(t, describe.Version, pluginStatus.PluginVersion)
+			assert.Equal(t, describe.OutputGVKs()[0], schema.GroupVersionKind{
 				Group:   pluginStatus.Group,
 				Version: pluginStatus.Version,
 				Kind:    pluginStatus.Kind,
@@ -425,14 +494,28 @@ func testServerAndClientConfig(handler http.Handler) (*httptest.Server, *rest.Co
 	srv := httptest.NewServer(handler)
 	config := &rest.Config{
 		Host: srv.URL,
+		// Tests that re-establish many watches should not be throttled by the default client-side rate limit
+		QPS:   1000,
+		Burst: 1000,
 	}
 	return srv, config
 }
 
-func restMapperFromScheme(scheme *runtime.Scheme) meta.RESTMapper {
+func restMapperFromScheme(scheme *runtime.Scheme, crds []runtime.Object) meta.RESTMapper {
+	clusterScopedKinds := sets.NewString("ClusterRole", "ClusterRoleBinding", "PriorityClass", "CustomResourceDefinition")
+	for _, object := range crds {
+		crd := object.(*apiext_v1.CustomResourceDefinition)
+		if crd.Spec.Scope == apiext_v1.ClusterScoped {
+			clusterScopedKinds.Insert(crd.Spec.Names.Kind)
+		}
+	}
 	rm := meta.NewDefaultRESTMapper(nil)
 	for gvk := range scheme.AllKnownTypes() {
-		rm.Add(gvk, meta.RESTScopeNamespace)
+		if clusterScopedKinds.Has(gvk.Kind) {
+			rm.Add(gvk, meta.RESTScopeRoot)
+		} else {
+			rm.Add(gvk, meta.RESTScopeNamespace)
+		}
 	}
 	return rm
 }
@@ -442,6 +525,9 @@ type fakeAction struct {
 	method string
 	path   string
 	query  string
+	// impersonatedUser is the value of the Impersonate-User header
+	impersonatedUser string
+	body             []byte
 }
 
 // String returns method=path to aid in testing
@@ -488,7 +574,14 @@ func (f *fakeActionHandler) ServeHTTP(response http.ResponseWriter, request *htt
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	f.actions = append(f.actions, fakeAction{method: request.Method, path: request.URL.Path, query: request.URL.RawQuery})
+	body, _ := ioutil.ReadAll(request.Body)
+	f.actions = append(f.actions, fakeAction{
+		method:           request.Method,
+		path:             request.URL.Path,
+		query:            request.URL.RawQuery,
+		impersonatedUser: request.Header.Get("Impersonate-User"),
+		body:             body,
+	})
 	key := path{method: request.Method, path: request.URL.Path, watch: strings.Contains(request.URL.RawQuery, "watch=true")}
 	fakeResp, ok := f.response[key]
 	if !ok {
@@ -512,3 +605,34 @@ func convertBundleResourcesToUnstrucutred(t *testing.T, bundle *smith_v1.Bundle,
 		}
 	}
 }
+
+func bundleAuthorWebhook() *admreg_v1.MutatingWebhookConfiguration {
+	path := webhook.MutateBundlePath
+	return &admreg_v1.MutatingWebhookConfiguration{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "smith",
+		},
+		Webhooks: []admreg_v1.MutatingWebhook{
+			{
+				Name: "bundles.smith.atlassian.com",
+				ClientConfig: admreg_v1.WebhookClientConfig{
+					Service: &admreg_v1.ServiceReference{
+						Namespace: "smith",
+						Name:      "smith",
+						Path:      &path,
+					},
+				},
+				Rules: []admreg_v1.RuleWithOperations{
+					{
+						Operations: []admreg_v1.OperationType{admreg_v1.Create, admreg_v1.Update},
+						Rule: admreg_v1.Rule{
+							APIGroups:   []string{smith_v1.SchemeGroupVersion.Group},
+							APIVersions: []string{smith_v1.BundleResourceVersion},
+							Resources:   []string{smith_v1.BundleResourcePlural},
+						},
+					},
+				},
+			},
+		},
+	}
+}
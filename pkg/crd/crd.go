@@ -1,355 +1,209 @@
 package crd
 
 import (
+	"reflect"
+
 	"github.com/atlassian/smith/pkg/apis/smith"
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiext_install "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/install"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
-func BundleCrd() *apiext_v1b1.CustomResourceDefinition {
-	// Schema is based on:
-	// https://github.com/kubernetes/community/blob/master/contributors/design-proposals/architecture/identifiers.md
-	// https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md
-	// https://github.com/kubernetes/community/blob/master/contributors/design-proposals/architecture/namespaces.md
-	// https://github.com/kubernetes/kubernetes/tree/master/api/openapi-spec
-
-	// definitions are not supported, do what we can :)
+func BundleCrd() *apiext_v1.CustomResourceDefinition {
+	// Schema is generated from the Go types, see SchemaFor.
+	// apiextensions.k8s.io/v1 requires the schema to be structural so every field has a type and
+	// arbitrary objects are marked with x-kubernetes-preserve-unknown-fields.
+	bundleSpec := SchemaFor(reflect.TypeOf(smith_v1.BundleSpec{}))
+	bundleStatus := SchemaFor(reflect.TypeOf(smith_v1.BundleStatus{}))
 
-	dnsSubdomain := apiext_v1b1.JSONSchemaProps{
-		Type:      "string",
-		MinLength: int64ptr(1),
-		MaxLength: int64ptr(253),
-		Pattern:   `^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`,
-	}
-	referenceName := apiext_v1b1.JSONSchemaProps{
-		Type:      "string",
-		MinLength: int64ptr(1),
-		MaxLength: int64ptr(253),
-		Pattern:   `^[a-zA-Z0-9]([-a-zA-Z0-9]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([-a-zA-Z0-9]*[a-zA-Z0-9])?)*$`,
-	}
-	resourceName := dnsSubdomain
-	apiVersion := apiext_v1b1.JSONSchemaProps{
-		Type:      "string",
-		MinLength: int64ptr(1),
-	}
-	kind := apiext_v1b1.JSONSchemaProps{
-		Type:      "string",
-		MinLength: int64ptr(1),
-	}
-	ownerReference := apiext_v1b1.JSONSchemaProps{
-		Type:     "object",
-		Required: []string{"apiVersion", "kind", "name"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"kind":       kind,
-			"apiVersion": apiVersion,
-			"name":       dnsSubdomain,
-			"blockOwnerDeletion": {
-				Type: "boolean",
-			},
-			"controller": {
-				Type: "boolean",
-			},
+	return &apiext_v1.CustomResourceDefinition{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "CustomResourceDefinition",
+			APIVersion: apiext_v1.SchemeGroupVersion.String(),
 		},
-	}
-	initializer := apiext_v1b1.JSONSchemaProps{
-		Type:     "object",
-		Required: []string{"name"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"name": {
-				Type: "string",
-			},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: smith_v1.BundleResourceName,
 		},
-	}
-	objectMeta := apiext_v1b1.JSONSchemaProps{
-		Description: "Schema for some fields of ObjectMeta",
-		Type:        "object",
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"name": dnsSubdomain,
-			"labels": {
-				Type: "object",
-				AdditionalProperties: &apiext_v1b1.JSONSchemaPropsOrBool{
-					Schema: &apiext_v1b1.JSONSchemaProps{
-						Type:      "string",
-						MaxLength: int64ptr(63),
-						Pattern:   "^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$",
-					},
-				},
-			},
-			"annotations": {
-				Type: "object",
-				AdditionalProperties: &apiext_v1b1.JSONSchemaPropsOrBool{
-					Schema: &apiext_v1b1.JSONSchemaProps{
-						Type: "string",
-					},
-				},
-			},
-			"ownerReferences": {
-				Type: "array",
-				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-					Schema: &ownerReference,
-				},
+		Spec: apiext_v1.CustomResourceDefinitionSpec{
+			Group: smith.GroupName,
+			Names: apiext_v1.CustomResourceDefinitionNames{
+				Plural:   smith_v1.BundleResourcePlural,
+				Singular: smith_v1.BundleResourceSingular,
+				Kind:     smith_v1.BundleResourceKind,
 			},
-			"initializers": {
-				Type:     "object",
-				Required: []string{"pending"},
-				Properties: map[string]apiext_v1b1.JSONSchemaProps{
-					"pending": {
-						Type: "array",
-						Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-							Schema: &initializer,
+			Scope: apiext_v1.NamespaceScoped,
+			Versions: []apiext_v1.CustomResourceDefinitionVersion{
+				{
+					Name:    smith_v1.BundleResourceVersion,
+					Served:  true,
+					Storage: true,
+					Schema: &apiext_v1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiext_v1.JSONSchemaProps{
+							Type:     "object",
+							Required: []string{"spec"},
+							Properties: map[string]apiext_v1.JSONSchemaProps{
+								"spec":   bundleSpec,
+								"status": bundleStatus,
+							},
 						},
 					},
-				},
-			},
-			"finalizers": {
-				Type: "array",
-				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-					Schema: &apiext_v1b1.JSONSchemaProps{
-						Type:      "string",
-						MinLength: int64ptr(1),
+					Subresources: &apiext_v1.CustomResourceSubresources{
+						Status: &apiext_v1.CustomResourceSubresourceStatus{},
 					},
-				},
-			},
-		},
-	}
-	objectSpec := apiext_v1b1.JSONSchemaProps{
-		Description: "Schema for a resource that describes an object",
-		Type:        "object",
-		Required:    []string{"apiVersion", "kind", "metadata"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"kind":       kind,
-			"apiVersion": apiVersion,
-			"metadata":   objectMeta,
-		},
-	}
-	pluginSpec := apiext_v1b1.JSONSchemaProps{
-		Description: "Schema for a resource that describes a plugin",
-		Type:        "object",
-		Required:    []string{"name", "objectName"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"name":       dnsSubdomain,
-			"objectName": dnsSubdomain,
-			"spec": {
-				Type: "object",
-			},
-		},
-	}
-	reference := apiext_v1b1.JSONSchemaProps{
-		Description: "A reference to a path in another resource",
-		Type:        "object",
-		Required:    []string{"resource"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"name":     referenceName,
-			"resource": resourceName,
-			"example": {
-				Description: "Example of how we expect reference to resolve. Used for validation",
-			},
-			"modifier": dnsSubdomain,
-			"path": {
-				Description: "JSONPath expression used to extract data from resource",
-				Type:        "string",
-			},
-		},
-	}
-	resource := apiext_v1b1.JSONSchemaProps{
-		Description: "Resource describes an object that should be provisioned",
-		Type:        "object",
-		Required:    []string{"name", "spec"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"name": resourceName,
-			"references": {
-				Type: "array",
-				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-					Schema: &reference,
-				},
-			},
-			"spec": {
-				Type: "object",
-				OneOf: []apiext_v1b1.JSONSchemaProps{
-					{
-						Required: []string{"object"},
-						Properties: map[string]apiext_v1b1.JSONSchemaProps{
-							"object": objectSpec,
+					AdditionalPrinterColumns: []apiext_v1.CustomResourceColumnDefinition{
+						{
+							Name:     "Ready",
+							Type:     "string",
+							JSONPath: `.status.conditions[?(@.type=="` + string(smith_v1.BundleReady) + `")].status`,
 						},
-					},
-					{
-						Required: []string{"plugin"},
-						Properties: map[string]apiext_v1b1.JSONSchemaProps{
-							"plugin": pluginSpec,
+						{
+							Name:     "Error",
+							Type:     "string",
+							JSONPath: `.status.conditions[?(@.type=="` + string(smith_v1.BundleError) + `")].reason`,
+						},
+						{
+							Name:        "Resources Ready",
+							Type:        "integer",
+							Description: "Number of resources in the Bundle that are Ready",
+							JSONPath:    ".status.resourcesReady",
+						},
+						{
+							Name:        "Resources",
+							Type:        "integer",
+							Description: "Number of resources in the Bundle",
+							JSONPath:    ".status.resourcesTotal",
+						},
+						{
+							Name:     "Age",
+							Type:     "date",
+							JSONPath: ".metadata.creationTimestamp",
 						},
 					},
 				},
 			},
 		},
 	}
-	bundleSpec := apiext_v1b1.JSONSchemaProps{
-		Type:     "object",
-		Required: []string{"resources"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"resources": {
-				Type: "array",
-				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-					Schema: &resource,
-				},
-			},
-		},
-	}
-	condition := apiext_v1b1.JSONSchemaProps{
-		Type:     "object",
-		Required: []string{"type", "status"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"type": {
-				Type:      "string",
-				MinLength: int64ptr(1),
-			},
-			"status": {
-				Type:      "string",
-				MinLength: int64ptr(1),
-			},
-			//"lastTransitionTime": Seem to be no way to express "string or null" constraint in schema
-			"reason": {
-				Type: "string",
-			},
-			"message": {
-				Type: "string",
-			},
-		},
-	}
-	resourceStatus := apiext_v1b1.JSONSchemaProps{
-		Type:     "object",
-		Required: []string{"name"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"name": {
-				Type:      "string",
-				MinLength: int64ptr(1),
-			},
-			"conditions": {
-				Type: "array",
-				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-					Schema: &condition,
-				},
-			},
-		},
-	}
-	objectToDelete := apiext_v1b1.JSONSchemaProps{
-		Type:     "object",
-		Required: []string{"group", "version", "kind", "name"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"group": {
-				Type: "string",
-			},
-			"version": {
-				Type:      "string",
-				MinLength: int64ptr(1),
-			},
-			"kind": {
-				Type:      "string",
-				MinLength: int64ptr(1),
-			},
-			"name": {
-				Type:      "string",
-				MinLength: int64ptr(1),
-			},
-		},
-	}
-	pluginStatus := apiext_v1b1.JSONSchemaProps{
-		Type:     "object",
-		Required: []string{"name", "group", "version", "kind"},
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"name": {
-				Type:      "string",
-				MinLength: int64ptr(1),
-			},
-			"group": {
-				Type: "string",
-			},
-			"version": {
-				Type:      "string",
-				MinLength: int64ptr(1),
-			},
-			"kind": {
-				Type:      "string",
-				MinLength: int64ptr(1),
-			},
-			"status": {
-				Type: "string",
-			},
-		},
-	}
-	bundleStatus := apiext_v1b1.JSONSchemaProps{
-		Type: "object",
-		Properties: map[string]apiext_v1b1.JSONSchemaProps{
-			"observedGeneration": {
-				Type:   "integer",
-				Format: "int64",
-			},
-			"conditions": {
-				Type: "array",
-				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-					Schema: &condition,
-				},
-			},
-			"resourceStatuses": {
-				Type: "array",
-				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-					Schema: &resourceStatus,
-				},
-			},
-			"objectsToDelete": {
-				Type: "array",
-				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-					Schema: &objectToDelete,
-				},
-			},
-			"pluginStatuses": {
-				Type: "array",
-				Items: &apiext_v1b1.JSONSchemaPropsOrArray{
-					Schema: &pluginStatus,
-				},
-			},
-		},
-	}
+}
+
+// BundlePolicyCrd returns the CRD for namespaced Bundle policies.
+func BundlePolicyCrd() *apiext_v1.CustomResourceDefinition {
+	return policyCrd(smith_v1.BundlePolicyResourceName, apiext_v1.NamespaceScoped, apiext_v1.CustomResourceDefinitionNames{
+		Plural:   smith_v1.BundlePolicyResourcePlural,
+		Singular: smith_v1.BundlePolicyResourceSingular,
+		Kind:     smith_v1.BundlePolicyResourceKind,
+	})
+}
+
+// ClusterBundlePolicyCrd returns the CRD for cluster-scoped Bundle policies.
+func ClusterBundlePolicyCrd() *apiext_v1.CustomResourceDefinition {
+	return policyCrd(smith_v1.ClusterBundlePolicyResourceName, apiext_v1.ClusterScoped, apiext_v1.CustomResourceDefinitionNames{
+		Plural:   smith_v1.ClusterBundlePolicyResourcePlural,
+		Singular: smith_v1.ClusterBundlePolicyResourceSingular,
+		Kind:     smith_v1.ClusterBundlePolicyResourceKind,
+	})
+}
+
+func policyCrd(name string, scope apiext_v1.ResourceScope, names apiext_v1.CustomResourceDefinitionNames) *apiext_v1.CustomResourceDefinition {
+	policySpec := SchemaFor(reflect.TypeOf(smith_v1.BundlePolicySpec{}))
 
-	return &apiext_v1b1.CustomResourceDefinition{
+	return &apiext_v1.CustomResourceDefinition{
 		TypeMeta: meta_v1.TypeMeta{
 			Kind:       "CustomResourceDefinition",
-			APIVersion: apiext_v1b1.SchemeGroupVersion.String(),
+			APIVersion: apiext_v1.SchemeGroupVersion.String(),
 		},
 		ObjectMeta: meta_v1.ObjectMeta{
-			Name: smith_v1.BundleResourceName,
+			Name: name,
 		},
-		Spec: apiext_v1b1.CustomResourceDefinitionSpec{
+		Spec: apiext_v1.CustomResourceDefinitionSpec{
 			Group: smith.GroupName,
-			Names: apiext_v1b1.CustomResourceDefinitionNames{
-				Plural:   smith_v1.BundleResourcePlural,
-				Singular: smith_v1.BundleResourceSingular,
-				Kind:     smith_v1.BundleResourceKind,
-			},
-			Scope: apiext_v1b1.NamespaceScoped,
-			Validation: &apiext_v1b1.CustomResourceValidation{
-				OpenAPIV3Schema: &apiext_v1b1.JSONSchemaProps{
-					Required: []string{"spec"},
-					Properties: map[string]apiext_v1b1.JSONSchemaProps{
-						"spec":   bundleSpec,
-						"status": bundleStatus,
-					},
-				},
-			},
-			Subresources: &apiext_v1b1.CustomResourceSubresources{
-				Status: &apiext_v1b1.CustomResourceSubresourceStatus{},
-			},
-			Versions: []apiext_v1b1.CustomResourceDefinitionVersion{
+			Names: names,
+			Scope: scope,
+			Versions: []apiext_v1.CustomResourceDefinitionVersion{
 				{
 					Name:    smith_v1.BundleResourceVersion,
 					Served:  true,
 					Storage: true,
+					Schema: &apiext_v1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiext_v1.JSONSchemaProps{
+							Type: "object",
+							Properties: map[string]apiext_v1.JSONSchemaProps{
+								"spec": policySpec,
+							},
+						},
+					},
+					AdditionalPrinterColumns: []apiext_v1.CustomResourceColumnDefinition{
+						{
+							Name:        "Max Resources",
+							Type:        "integer",
+							Description: "Maximum number of resources in a Bundle",
+							JSONPath:    ".spec.maxResources",
+						},
+						{
+							Name:     "Age",
+							Type:     "date",
+							JSONPath: ".metadata.creationTimestamp",
+						},
+					},
 				},
 			},
 		},
 	}
 }
 
+// BundleCrdV1beta1 returns the Bundle CRD as an apiextensions.k8s.io/v1beta1 object for clusters
+// that do not support apiextensions.k8s.io/v1 yet.
+func BundleCrdV1beta1() (*apiext_v1b1.CustomResourceDefinition, error) {
+	return ToV1beta1(BundleCrd())
+}
+
+// ToV1beta1 converts the CRD to an apiextensions.k8s.io/v1beta1 object.
+func ToV1beta1(crd *apiext_v1.CustomResourceDefinition) (*apiext_v1b1.CustomResourceDefinition, error) {
+	scheme := runtime.NewScheme()
+	apiext_install.Install(scheme)
+	// There are no direct conversions between versions, only via the internal version
+	var crdInternal apiext.CustomResourceDefinition
+	if err := scheme.Convert(crd, &crdInternal, nil); err != nil {
+		return nil, errors.Wrap(err, "failed to convert CRD to internal version")
+	}
+	var crdV1b1 apiext_v1b1.CustomResourceDefinition
+	if err := scheme.Convert(&crdInternal, &crdV1b1, nil); err != nil {
+		return nil, errors.Wrap(err, "failed to convert CRD to v1beta1")
+	}
+	crdV1b1.TypeMeta = meta_v1.TypeMeta{
+		Kind:       "CustomResourceDefinition",
+		APIVersion: apiext_v1b1.SchemeGroupVersion.String(),
+	}
+	return &crdV1b1, nil
+}
+
+// FromV1beta1 converts the apiextensions.k8s.io/v1beta1 CRD to a v1 object.
+func FromV1beta1(crd *apiext_v1b1.CustomResourceDefinition) (*apiext_v1.CustomResourceDefinition, error) {
+	scheme := runtime.NewScheme()
+	apiext_install.Install(scheme)
+	var crdInternal apiext.CustomResourceDefinition
+	if err := scheme.Convert(crd, &crdInternal, nil); err != nil {
+		return nil, errors.Wrap(err, "failed to convert CRD to internal version")
+	}
+	var crdV1 apiext_v1.CustomResourceDefinition
+	if err := scheme.Convert(&crdInternal, &crdV1, nil); err != nil {
+		return nil, errors.Wrap(err, "failed to convert CRD to v1")
+	}
+	crdV1.TypeMeta = meta_v1.TypeMeta{
+		Kind:       "CustomResourceDefinition",
+		APIVersion: apiext_v1.SchemeGroupVersion.String(),
+	}
+	return &crdV1, nil
+}
+
 func int64ptr(val int64) *int64 {
 	return &val
 }
+
+func boolptr(val bool) *bool {
+	return &val
+}
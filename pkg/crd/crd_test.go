@@ -0,0 +1,96 @@
+package crd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiext_install "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/install"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiext_validation "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/validation"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestBundleCrdIsValid(t *testing.T) {
+	t.Parallel()
+
+	crdV1b1, err := BundleCrdV1beta1()
+	require.NoError(t, err)
+
+	for _, crd := range []runtime.Object{BundleCrd(), crdV1b1} {
+		gv := crd.GetObjectKind().GroupVersionKind().GroupVersion()
+		t.Run(gv.Version, func(t *testing.T) {
+			assertCrdIsValid(t, crd)
+		})
+	}
+	assert.Equal(t, apiext_v1b1.SchemeGroupVersion.String(), crdV1b1.APIVersion)
+}
+
+func TestPolicyCrdsAreValid(t *testing.T) {
+	t.Parallel()
+
+	for _, crd := range []*apiext_v1.CustomResourceDefinition{BundlePolicyCrd(), ClusterBundlePolicyCrd()} {
+		crdV1b1, err := ToV1beta1(crd)
+		require.NoError(t, err)
+		for _, obj := range []runtime.Object{crd, crdV1b1} {
+			gv := obj.GetObjectKind().GroupVersionKind().GroupVersion()
+			t.Run(crd.Name+"/"+gv.Version, func(t *testing.T) {
+				assertCrdIsValid(t, obj)
+			})
+		}
+	}
+}
+
+func assertCrdIsValid(t *testing.T, crd runtime.Object) {
+	gv := crd.GetObjectKind().GroupVersionKind().GroupVersion()
+	scheme := runtime.NewScheme()
+	apiext_install.Install(scheme)
+	crd = crd.DeepCopyObject()
+	scheme.Default(crd)
+	var crdInternal apiext.CustomResourceDefinition
+	require.NoError(t, scheme.Convert(crd, &crdInternal, nil))
+
+	errs := apiext_validation.ValidateCustomResourceDefinition(&crdInternal, gv)
+	assert.Empty(t, errs)
+
+	props := crdInternal.Spec.Validation
+	if props == nil {
+		props = crdInternal.Spec.Versions[0].Schema
+	}
+	require.NotNil(t, props)
+	structural, err := schema.NewStructural(props.OpenAPIV3Schema)
+	require.NoError(t, err)
+	assert.Empty(t, schema.ValidateStructural(field.NewPath("openAPIV3Schema"), structural))
+}
+
+func TestBundleCrdSchemaMarkers(t *testing.T) {
+	t.Parallel()
+
+	crd := BundleCrd()
+	props := crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties
+	resource := props["spec"].Properties["resources"].Items.Schema
+	resourceSpec := resource.Properties["spec"]
+
+	assert.Equal(t, []apiext_v1.JSONSchemaProps{
+		{Required: []string{"object"}},
+		{Required: []string{"plugin"}},
+	}, resourceSpec.OneOf)
+	assert.Empty(t, resourceSpec.Required)
+
+	object := resourceSpec.Properties["object"]
+	assert.True(t, object.XEmbeddedResource)
+	require.NotNil(t, object.XPreserveUnknownFields)
+	assert.True(t, *object.XPreserveUnknownFields)
+
+	pluginSpec := resourceSpec.Properties["plugin"].Properties["spec"]
+	require.NotNil(t, pluginSpec.XPreserveUnknownFields)
+	assert.True(t, *pluginSpec.XPreserveUnknownFields)
+
+	assert.Equal(t, []string{"name", "spec"}, resource.Required)
+	assert.Equal(t, "object", props["status"].Type)
+	assert.Empty(t, props["status"].Required)
+}
@@ -0,0 +1,91 @@
+package crd
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiExtClientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apiext_v1inf "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions/apiextensions/v1"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewInformer returns an informer for apiextensions.k8s.io/v1 CRDs.
+// Servers that do not serve CRDs via apiextensions.k8s.io/v1 (Kubernetes 1.15 and older) are detected via discovery.
+// CRDs are watched via apiextensions.k8s.io/v1beta1 and converted to v1 objects on such servers.
+func NewInformer(client apiExtClientset.Interface, resyncPeriod time.Duration, indexers cache.Indexers) (cache.SharedIndexInformer, error) {
+	v1, err := servesV1(client)
+	if err != nil {
+		return nil, err
+	}
+	if v1 {
+		return apiext_v1inf.NewCustomResourceDefinitionInformer(client, resyncPeriod, indexers), nil
+	}
+	crds := client.ApiextensionsV1beta1().CustomResourceDefinitions()
+	lw := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			list, err := crds.List(options)
+			if err != nil {
+				return nil, err
+			}
+			result := &apiext_v1.CustomResourceDefinitionList{
+				ListMeta: list.ListMeta,
+				Items:    make([]apiext_v1.CustomResourceDefinition, 0, len(list.Items)),
+			}
+			for i := range list.Items {
+				crd, err := FromV1beta1(&list.Items[i])
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to convert CRD %q", list.Items[i].Name)
+				}
+				result.Items = append(result.Items, *crd)
+			}
+			return result, nil
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			w, err := crds.Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+				crdV1b1, ok := event.Object.(*apiext_v1b1.CustomResourceDefinition)
+				if !ok {
+					return event, true
+				}
+				crdV1, err := FromV1beta1(crdV1b1)
+				if err != nil {
+					return watch.Event{
+						Type:   watch.Error,
+						Object: &api_errors.NewInternalError(errors.Wrapf(err, "failed to convert CRD %q", crdV1b1.Name)).ErrStatus,
+					}, true
+				}
+				event.Object = crdV1
+				return event, true
+			}), nil
+		},
+	}
+	return cache.NewSharedIndexInformer(lw, &apiext_v1.CustomResourceDefinition{}, resyncPeriod, indexers), nil
+}
+
+// servesV1 returns true if the server serves CRDs via apiextensions.k8s.io/v1.
+func servesV1(client apiExtClientset.Interface) (bool, error) {
+	groups, err := client.Discovery().ServerGroups()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to discover API groups")
+	}
+	for _, group := range groups.Groups {
+		if group.Name != apiext_v1.GroupName {
+			continue
+		}
+		for _, version := range group.Versions {
+			if version.Version == apiext_v1.SchemeGroupVersion.Version {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
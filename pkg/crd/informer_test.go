@@ -0,0 +1,68 @@
+package crd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNewInformer(t *testing.T) {
+	t.Parallel()
+	crdV1b1, err := BundleCrdV1beta1()
+	require.NoError(t, err)
+	testCases := []struct {
+		name      string
+		resources []*meta_v1.APIResourceList
+		crd       runtime.Object
+	}{
+		{
+			name: "v1",
+			resources: []*meta_v1.APIResourceList{
+				{
+					GroupVersion: apiext_v1.SchemeGroupVersion.String(),
+					APIResources: []meta_v1.APIResource{
+						{
+							Name: "customresourcedefinitions",
+							Kind: "CustomResourceDefinition",
+						},
+					},
+				},
+			},
+			crd: BundleCrd(),
+		},
+		{
+			name: "v1beta1 fallback",
+			crd:  crdV1b1,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			client := fake.NewSimpleClientset(tc.crd)
+			client.Resources = tc.resources
+			inf, err := NewInformer(client, 0, cache.Indexers{})
+			require.NoError(t, err)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			go inf.Run(ctx.Done())
+			require.True(t, cache.WaitForCacheSync(ctx.Done(), inf.HasSynced))
+
+			objs := inf.GetStore().List()
+			require.Len(t, objs, 1)
+			crd := objs[0].(*apiext_v1.CustomResourceDefinition)
+			expected := BundleCrd()
+			assert.Equal(t, expected.Name, crd.Name)
+			assert.Equal(t, expected.Spec.Versions[0].Name, crd.Spec.Versions[0].Name)
+			assert.Equal(t, expected.Spec.Names, crd.Spec.Names)
+		})
+	}
+}
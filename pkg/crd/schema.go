@@ -0,0 +1,233 @@
+package crd
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Schemas are generated from Go types and their JSON representation. Things that cannot be derived from
+// the types are specified using markers in the "crd" struct tag. Multiple markers are separated by commas.
+// Supported markers:
+// - optional - field is not required even though it is not marked with "omitempty".
+// - oneOf - field is a member of a union. Exactly one member of the union must be specified.
+// - embeddedResource - field is a Kubernetes object with apiVersion, kind and metadata.
+// - preserveUnknownFields - field can contain arbitrary JSON, unknown fields are not pruned.
+// - minLength=<n> - minimum length of a string.
+// - dnsSubdomain - string is a DNS subdomain (RFC 1123).
+// - referenceName - string is a name of a reference.
+const (
+	markersTag = "crd"
+
+	markerOptional              = "optional"
+	markerOneOf                 = "oneOf"
+	markerEmbeddedResource      = "embeddedResource"
+	markerPreserveUnknownFields = "preserveUnknownFields"
+	markerMinLength             = "minLength"
+	markerDNSSubdomain          = "dnsSubdomain"
+	markerReferenceName         = "referenceName"
+)
+
+var (
+	runtimeObjectType = reflect.TypeOf((*runtime.Object)(nil)).Elem()
+	openAPITypeType   = reflect.TypeOf((*openAPIType)(nil)).Elem()
+)
+
+// openAPIType is implemented by types that are represented differently in JSON than their Go type suggests
+// e.g. meta_v1.Time. Same convention is used by kube-openapi.
+type openAPIType interface {
+	OpenAPISchemaType() []string
+	OpenAPISchemaFormat() string
+}
+
+// SchemaFor generates a structural OpenAPI v3 schema for the JSON representation of the provided type.
+func SchemaFor(t reflect.Type) apiext_v1.JSONSchemaProps {
+	return schemaForType(t, nil)
+}
+
+func schemaForType(t reflect.Type, markers map[string]string) apiext_v1.JSONSchemaProps {
+	var schema apiext_v1.JSONSchemaProps
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t.Implements(openAPITypeType):
+		v := reflect.Zero(t).Interface().(openAPIType)
+		schema.Type = v.OpenAPISchemaType()[0]
+		schema.Format = v.OpenAPISchemaFormat()
+		// Zero values of such types are usually serialized as null e.g. meta_v1.Time
+		schema.Nullable = true
+	case t.Kind() == reflect.Struct:
+		schema = schemaForStruct(t)
+	case t.Kind() == reflect.Interface:
+		// Arbitrary JSON
+		if t == runtimeObjectType {
+			schema.Type = "object"
+		}
+		schema.XPreserveUnknownFields = boolptr(true)
+	case t.Kind() == reflect.Map:
+		schema.Type = "object"
+		if t.Elem().Kind() == reflect.Interface {
+			schema.XPreserveUnknownFields = boolptr(true)
+		} else {
+			items := schemaForType(t.Elem(), nil)
+			schema.AdditionalProperties = &apiext_v1.JSONSchemaPropsOrBool{
+				Allows: true,
+				Schema: &items,
+			}
+		}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is serialized as base64 encoded string
+			schema.Type = "string"
+			schema.Format = "byte"
+			break
+		}
+		items := schemaForType(t.Elem(), nil)
+		schema.Type = "array"
+		schema.Items = &apiext_v1.JSONSchemaPropsOrArray{
+			Schema: &items,
+		}
+	case t.Kind() == reflect.String:
+		schema.Type = "string"
+	case t.Kind() == reflect.Bool:
+		schema.Type = "boolean"
+	case t.Kind() == reflect.Int32 || t.Kind() == reflect.Uint32:
+		schema.Type = "integer"
+		schema.Format = "int32"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema.Type = "integer"
+		schema.Format = "int64"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema.Type = "number"
+	default:
+		panic(errors.Errorf("unsupported type %s", t))
+	}
+	applyMarkers(&schema, markers)
+	return schema
+}
+
+func schemaForStruct(t reflect.Type) apiext_v1.JSONSchemaProps {
+	schema := apiext_v1.JSONSchemaProps{
+		Type:       "object",
+		Properties: map[string]apiext_v1.JSONSchemaProps{},
+	}
+	var oneOf []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			// Unexported field
+			continue
+		}
+		name, omitEmpty, inline := parseJSONTag(f)
+		if name == "-" {
+			continue
+		}
+		if inline {
+			inlined := schemaForStruct(f.Type)
+			for propName, prop := range inlined.Properties {
+				schema.Properties[propName] = prop
+			}
+			schema.Required = append(schema.Required, inlined.Required...)
+			continue
+		}
+		markers := parseMarkers(f.Tag.Get(markersTag))
+		schema.Properties[name] = schemaForType(f.Type, markers)
+		if _, ok := markers[markerOneOf]; ok {
+			oneOf = append(oneOf, name)
+			continue
+		}
+		if _, ok := markers[markerOptional]; !omitEmpty && !ok {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	for _, name := range oneOf {
+		schema.OneOf = append(schema.OneOf, apiext_v1.JSONSchemaProps{
+			Required: []string{name},
+		})
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+func applyMarkers(schema *apiext_v1.JSONSchemaProps, markers map[string]string) {
+	for marker, value := range markers {
+		switch marker {
+		case markerOptional, markerOneOf:
+			// Handled by the struct
+		case markerEmbeddedResource:
+			schema.Type = "object"
+			schema.XEmbeddedResource = true
+			schema.XPreserveUnknownFields = boolptr(true)
+		case markerPreserveUnknownFields:
+			schema.XPreserveUnknownFields = boolptr(true)
+		case markerMinLength:
+			minLength, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				panic(errors.Wrapf(err, "invalid %s marker value %q", marker, value))
+			}
+			schema.MinLength = &minLength
+		case markerDNSSubdomain:
+			schema.MinLength = int64ptr(1)
+			schema.MaxLength = int64ptr(253)
+			schema.Pattern = `^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+		case markerReferenceName:
+			schema.MinLength = int64ptr(1)
+			schema.MaxLength = int64ptr(253)
+			schema.Pattern = `^[a-zA-Z0-9]([-a-zA-Z0-9]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([-a-zA-Z0-9]*[a-zA-Z0-9])?)*$`
+		default:
+			panic(errors.Errorf("unknown marker %q", marker))
+		}
+	}
+}
+
+func parseJSONTag(f reflect.StructField) (string /*name*/, bool /*omitEmpty*/, bool /*inline*/) {
+	tag, ok := f.Tag.Lookup("json")
+	if !ok {
+		if f.Anonymous {
+			return "", false, true
+		}
+		return f.Name, false, false
+	}
+	parts := strings.Split(tag, ",")
+	omitEmpty := false
+	inline := false
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			omitEmpty = true
+		case "inline":
+			inline = true
+		}
+	}
+	name := parts[0]
+	if name == "" {
+		if f.Anonymous {
+			inline = true
+		} else {
+			name = f.Name
+		}
+	}
+	return name, omitEmpty, inline
+}
+
+func parseMarkers(tag string) map[string]string {
+	if tag == "" {
+		return nil
+	}
+	markers := make(map[string]string)
+	for _, marker := range strings.Split(tag, ",") {
+		nameValue := strings.SplitN(marker, "=", 2)
+		if len(nameValue) == 2 {
+			markers[nameValue[0]] = nameValue[1]
+		} else {
+			markers[nameValue[0]] = ""
+		}
+	}
+	return markers
+}
@@ -0,0 +1,145 @@
+// Package builtin contains plugins that are shipped with Smith.
+package builtin
+
+import (
+	"sort"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Version is the version of the built-in plugins that is reported in PluginStatus of Bundles.
+// It is set at build time.
+var Version = "dev"
+
+var (
+	configMapGVK = core_v1.SchemeGroupVersion.WithKind("ConfigMap")
+	secretGVK    = core_v1.SchemeGroupVersion.WithKind("Secret")
+)
+
+// Plugins returns constructors of all built-in plugins by name.
+func Plugins() map[smith_v1.PluginName]plugin.NewFunc {
+	return map[smith_v1.PluginName]plugin.NewFunc{
+		SecretFilterPluginName:              NewSecretFilter,
+		ConfigMapTemplatePluginName:         NewConfigMapTemplate,
+		SecretTemplatePluginName:            NewSecretTemplate,
+		ServiceInstanceParametersPluginName: NewServiceInstanceParameters,
+	}
+}
+
+// Names returns sorted names of all built-in plugins.
+func Names() []smith_v1.PluginName {
+	plugins := Plugins()
+	names := make([]smith_v1.PluginName, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// dependencyData contains data of a dependency.
+type dependencyData struct {
+	data map[string][]byte
+	// isSecret is true if the data comes from a Secret.
+	isSecret bool
+}
+
+// requireDependencyData returns data of a dependency. An error is returned if the dependency does not have data.
+func requireDependencyData(dependencies map[smith_v1.ResourceName]plugin.Dependency, name smith_v1.ResourceName) (dependencyData, error) {
+	dep, ok := dependencies[name]
+	if !ok {
+		return dependencyData{}, errors.Errorf("resource %q is not a dependency, it must be referenced", name)
+	}
+	data, ok, err := getDependencyData(dep)
+	if err != nil {
+		return dependencyData{}, errors.Wrapf(err, "dependency %q", name)
+	}
+	if !ok {
+		return dependencyData{}, errors.Errorf("dependency %q is not a Secret, a ConfigMap or a resource that produces a Secret", name)
+	}
+	return data, nil
+}
+
+// getDependencyData returns data of a dependency that is a Secret, a ConfigMap or a resource that produces
+// a Secret (e.g. a ServiceBinding).
+func getDependencyData(dep plugin.Dependency) (dependencyData, bool /*ok*/, error) {
+	data, ok, err := objectData(dep.Actual)
+	if err != nil || ok {
+		return data, ok, err
+	}
+	for _, output := range dep.Outputs {
+		data, ok, err = objectData(output)
+		if err != nil || (ok && data.isSecret) {
+			return data, ok, err
+		}
+	}
+	return dependencyData{}, false, nil
+}
+
+// objectData returns data of the object if it is a Secret or a ConfigMap.
+func objectData(obj runtime.Object) (dependencyData, bool /*ok*/, error) {
+	if obj == nil {
+		return dependencyData{}, false, nil
+	}
+	switch obj.GetObjectKind().GroupVersionKind() {
+	case secretGVK:
+		var secret core_v1.Secret
+		if err := toTyped(obj, &secret); err != nil {
+			return dependencyData{}, false, err
+		}
+		return dependencyData{
+			data:     secret.Data,
+			isSecret: true,
+		}, true, nil
+	case configMapGVK:
+		var configMap core_v1.ConfigMap
+		if err := toTyped(obj, &configMap); err != nil {
+			return dependencyData{}, false, err
+		}
+		data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+		for k, v := range configMap.BinaryData {
+			data[k] = v
+		}
+		for k, v := range configMap.Data {
+			data[k] = []byte(v)
+		}
+		return dependencyData{
+			data: data,
+		}, true, nil
+	default:
+		return dependencyData{}, false, nil
+	}
+}
+
+// toTyped converts a typed or an unstructured object into the typed object.
+func toTyped(obj runtime.Object, out interface{}) error {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u, out); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// decodeSpec converts the plugin spec into the typed spec.
+func decodeSpec(spec map[string]interface{}, out interface{}) *plugin.ProcessResultFailure {
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, out); err != nil {
+		return externalError(errors.Wrap(err, "failed to parse spec"))
+	}
+	return nil
+}
+
+func externalError(err error) *plugin.ProcessResultFailure {
+	return &plugin.ProcessResultFailure{
+		Error:           err,
+		IsExternalError: true,
+	}
+}
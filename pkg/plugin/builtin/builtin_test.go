@@ -0,0 +1,40 @@
+package builtin
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/plugin/plugintest"
+	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, core_v1.AddToScheme(scheme))
+	require.NoError(t, sc_v1b1.AddToScheme(scheme))
+	return scheme
+}
+
+func TestPlugins(t *testing.T) {
+	t.Parallel()
+	for name, newFunc := range Plugins() {
+		name := name
+		newFunc := newFunc
+		t.Run(string(name), func(t *testing.T) {
+			t.Parallel()
+			pluginContainer, err := plugin.NewContainer(newFunc)
+			require.NoError(t, err)
+			assert.Equal(t, name, pluginContainer.Plugin.Describe().Name)
+
+			// Each plugin has fixtures in a directory named after it
+			tr, err := plugintest.New(newFunc, testScheme(t))
+			require.NoError(t, err)
+			tr.RunDir(t, filepath.Join("testdata", string(name)))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package builtin
+
+import (
+	"sort"
+	"strings"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// SecretFilterPluginName is the name of the plugin that produces a Secret with keys picked from
+	// dependencies.
+	SecretFilterPluginName smith_v1.PluginName = "secret-filter"
+)
+
+const secretFilterSpecSchema = `{
+	"type": "object",
+	"required": ["sources"],
+	"additionalProperties": false,
+	"properties": {
+		"sources": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["resource"],
+				"additionalProperties": false,
+				"properties": {
+					"resource": {"type": "string", "minLength": 1},
+					"keys": {"type": "array", "uniqueItems": true, "items": {"type": "string", "minLength": 1}},
+					"prefix": {"type": "string"},
+					"rename": {"type": "object", "additionalProperties": {"type": "string", "minLength": 1}},
+					"addPrefix": {"type": "string"}
+				}
+			}
+		}
+	}
+}`
+
+type secretFilterSpec struct {
+	// Sources are the dependencies to take keys from. Keys must be unique across all sources.
+	Sources []secretSource `json:"sources"`
+}
+
+type secretSource struct {
+	// Resource is the name of the dependency. It must be a Secret, a ConfigMap or a resource that
+	// produces a Secret (e.g. a ServiceBinding).
+	Resource smith_v1.ResourceName `json:"resource"`
+	// Keys are the keys to take. All keys are taken if empty. Keys that are listed must exist.
+	Keys []string `json:"keys,omitempty"`
+	// Prefix restricts the keys to take to the keys that start with it.
+	Prefix string `json:"prefix,omitempty"`
+	// Rename maps keys of the dependency to the keys of the produced Secret.
+	Rename map[string]string `json:"rename,omitempty"`
+	// AddPrefix is prepended to the (renamed) keys.
+	AddPrefix string `json:"addPrefix,omitempty"`
+}
+
+// NewSecretFilter returns a plugin that produces a Secret with keys picked from one or more dependencies.
+func NewSecretFilter() (plugin.Plugin, error) {
+	return &secretFilter{}, nil
+}
+
+type secretFilter struct {
+}
+
+func (p *secretFilter) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name:       SecretFilterPluginName,
+		Version:    Version,
+		GVK:        secretGVK,
+		SpecSchema: []byte(secretFilterSpecSchema),
+	}
+}
+
+func (p *secretFilter) Process(rawSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	var spec secretFilterSpec
+	if failure := decodeSpec(rawSpec, &spec); failure != nil {
+		return failure
+	}
+	data := make(map[string][]byte)
+	keySources := make(map[string]smith_v1.ResourceName)
+	for _, source := range spec.Sources {
+		depData, err := requireDependencyData(pctx.Dependencies, source.Resource)
+		if err != nil {
+			return externalError(err)
+		}
+		keys := source.Keys
+		if len(keys) == 0 {
+			keys = make([]string, 0, len(depData.data))
+			for key := range depData.data {
+				keys = append(keys, key)
+			}
+			// Keys are processed in order so that the same error is returned if several keys clash
+			sort.Strings(keys)
+		} else {
+			for _, key := range keys {
+				if _, ok := depData.data[key]; !ok {
+					return externalError(errors.Errorf("key %q not found in dependency %q", key, source.Resource))
+				}
+			}
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, source.Prefix) {
+				continue
+			}
+			newKey, ok := source.Rename[key]
+			if !ok {
+				newKey = key
+			}
+			newKey = source.AddPrefix + newKey
+			if otherSource, ok := keySources[newKey]; ok {
+				return externalError(errors.Errorf("key %q is produced from both dependency %q and dependency %q", newKey, otherSource, source.Resource))
+			}
+			keySources[newKey] = source.Resource
+			data[newKey] = depData.data[key]
+		}
+	}
+	return &plugin.ProcessResultSuccess{
+		Object: &core_v1.Secret{
+			TypeMeta: meta_v1.TypeMeta{
+				APIVersion: secretGVK.GroupVersion().String(),
+				Kind:       secretGVK.Kind,
+			},
+			Data: data,
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package builtin
+
+import (
+	"encoding/json"
+	"sort"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ServiceInstanceParametersPluginName is the name of the plugin that produces a Secret with parameters
+	// for the parametersFrom field of a ServiceInstance.
+	ServiceInstanceParametersPluginName smith_v1.PluginName = "service-instance-parameters"
+
+	// DefaultParametersKey is the default key of the Secret to store parameters under.
+	DefaultParametersKey = "parameters"
+)
+
+const serviceInstanceParametersSpecSchema = `{
+	"type": "object",
+	"required": ["parameters"],
+	"additionalProperties": false,
+	"properties": {
+		"key": {"type": "string", "minLength": 1, "default": "` + DefaultParametersKey + `"},
+		"parameters": {
+			"type": "object",
+			"additionalProperties": {
+				"type": "object",
+				"required": ["resource", "key"],
+				"additionalProperties": false,
+				"properties": {
+					"resource": {"type": "string", "minLength": 1},
+					"key": {"type": "string", "minLength": 1}
+				}
+			}
+		}
+	}
+}`
+
+type serviceInstanceParametersSpec struct {
+	// Key is the key of the Secret to store the parameters under. DefaultParametersKey by default.
+	Key string `json:"key"`
+	// Parameters maps names of parameters to the keys of dependencies their values are taken from.
+	Parameters map[string]parameterSource `json:"parameters"`
+}
+
+type parameterSource struct {
+	// Resource is the name of the dependency. It must be a Secret, a ConfigMap or a resource that
+	// produces a Secret (e.g. a ServiceBinding).
+	Resource smith_v1.ResourceName `json:"resource"`
+	// Key is the key of the dependency to take the value from.
+	Key string `json:"key"`
+}
+
+// NewServiceInstanceParameters returns a plugin that produces a Secret with parameters taken from dependencies
+// in the form expected by the parametersFrom field of a ServiceInstance. Unlike references to Secrets in
+// the parameters field, values of parameters passed this way are not exposed in the ServiceInstance.
+func NewServiceInstanceParameters() (plugin.Plugin, error) {
+	return &serviceInstanceParameters{}, nil
+}
+
+type serviceInstanceParameters struct {
+}
+
+func (p *serviceInstanceParameters) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name:       ServiceInstanceParametersPluginName,
+		Version:    Version,
+		GVK:        secretGVK,
+		SpecSchema: []byte(serviceInstanceParametersSpecSchema),
+	}
+}
+
+func (p *serviceInstanceParameters) Process(rawSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	var spec serviceInstanceParametersSpec
+	if failure := decodeSpec(rawSpec, &spec); failure != nil {
+		return failure
+	}
+	// Parameters are processed in order so that the same error is returned if several of them are invalid
+	names := make([]string, 0, len(spec.Parameters))
+	for name := range spec.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parameters := make(map[string]string, len(spec.Parameters))
+	for _, name := range names {
+		source := spec.Parameters[name]
+		depData, err := requireDependencyData(pctx.Dependencies, source.Resource)
+		if err != nil {
+			return externalError(err)
+		}
+		value, ok := depData.data[source.Key]
+		if !ok {
+			return externalError(errors.Errorf("key %q not found in dependency %q", source.Key, source.Resource))
+		}
+		parameters[name] = string(value)
+	}
+	// Keys of maps are sorted by the encoder so the output is deterministic
+	parametersJSON, err := json.Marshal(parameters)
+	if err != nil {
+		return &plugin.ProcessResultFailure{
+			Error: errors.WithStack(err),
+		}
+	}
+	return &plugin.ProcessResultSuccess{
+		Object: &core_v1.Secret{
+			TypeMeta: meta_v1.TypeMeta{
+				APIVersion: secretGVK.GroupVersion().String(),
+				Kind:       secretGVK.Kind,
+			},
+			Data: map[string][]byte{
+				spec.Key: parametersJSON,
+			},
+		},
+	}
+}
@@ -0,0 +1,186 @@
+package builtin
+
+import (
+	"bytes"
+	"sort"
+	"text/template"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConfigMapTemplatePluginName is the name of the plugin that produces a ConfigMap from templates.
+	ConfigMapTemplatePluginName smith_v1.PluginName = "configmap-template"
+	// SecretTemplatePluginName is the name of the plugin that produces a Secret from templates.
+	SecretTemplatePluginName smith_v1.PluginName = "secret-template"
+)
+
+const templateSpecSchema = `{
+	"type": "object",
+	"required": ["data"],
+	"additionalProperties": false,
+	"properties": {
+		"data": {"type": "object", "additionalProperties": {"type": "string"}}
+	}
+}`
+
+type templateSpec struct {
+	// Data maps keys of the produced object to text/template templates of their values.
+	Data map[string]string `json:"data"`
+}
+
+// templateContext is the data templates are executed with.
+type templateContext struct {
+	// Namespace is the namespace of the Bundle.
+	Namespace string
+	// Data is data of dependencies that are ConfigMaps, Secrets or resources that produce Secrets,
+	// by dependency name. Keyed by string rather than smith_v1.ResourceName so that templates can use index.
+	Data map[string]map[string]string
+	// Objects are the actual objects of dependencies, by dependency name.
+	Objects map[string]map[string]interface{}
+}
+
+// NewConfigMapTemplate returns a plugin that produces a ConfigMap from templates.
+// Secrets are not available to the templates so that their data is not exposed in the ConfigMap.
+func NewConfigMapTemplate() (plugin.Plugin, error) {
+	return &templatePlugin{
+		name: ConfigMapTemplatePluginName,
+	}, nil
+}
+
+// NewSecretTemplate returns a plugin that produces a Secret from templates.
+func NewSecretTemplate() (plugin.Plugin, error) {
+	return &templatePlugin{
+		name:   SecretTemplatePluginName,
+		secret: true,
+	}, nil
+}
+
+type templatePlugin struct {
+	name smith_v1.PluginName
+	// secret is true if the plugin produces a Secret rather than a ConfigMap.
+	secret bool
+}
+
+func (p *templatePlugin) Describe() *plugin.Description {
+	gvk := configMapGVK
+	if p.secret {
+		gvk = secretGVK
+	}
+	return &plugin.Description{
+		Name:       p.name,
+		Version:    Version,
+		GVK:        gvk,
+		SpecSchema: []byte(templateSpecSchema),
+	}
+}
+
+func (p *templatePlugin) Process(rawSpec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	var spec templateSpec
+	if failure := decodeSpec(rawSpec, &spec); failure != nil {
+		return failure
+	}
+	tctx, err := p.templateContext(pctx)
+	if err != nil {
+		return &plugin.ProcessResultFailure{
+			Error: err,
+		}
+	}
+	// Keys are processed in order so that the same error is returned if several templates are invalid
+	keys := make([]string, 0, len(spec.Data))
+	for key := range spec.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	data := make(map[string]string, len(spec.Data))
+	for _, key := range keys {
+		text := spec.Data[key]
+		tmpl, err := template.New(key).Option("missingkey=error").Funcs(tctx.funcs()).Parse(text)
+		if err != nil {
+			return externalError(errors.Wrapf(err, "failed to parse template for key %q", key))
+		}
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, tctx); err != nil {
+			return externalError(errors.Wrapf(err, "failed to execute template for key %q", key))
+		}
+		data[key] = buf.String()
+	}
+
+	if !p.secret {
+		return &plugin.ProcessResultSuccess{
+			Object: &core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					APIVersion: configMapGVK.GroupVersion().String(),
+					Kind:       configMapGVK.Kind,
+				},
+				Data: data,
+			},
+		}
+	}
+	secretData := make(map[string][]byte, len(data))
+	for key, value := range data {
+		secretData[key] = []byte(value)
+	}
+	return &plugin.ProcessResultSuccess{
+		Object: &core_v1.Secret{
+			TypeMeta: meta_v1.TypeMeta{
+				APIVersion: secretGVK.GroupVersion().String(),
+				Kind:       secretGVK.Kind,
+			},
+			Data: secretData,
+		},
+	}
+}
+
+func (p *templatePlugin) templateContext(pctx *plugin.Context) (*templateContext, error) {
+	tctx := &templateContext{
+		Namespace: pctx.Namespace,
+		Data:      make(map[string]map[string]string),
+		Objects:   make(map[string]map[string]interface{}, len(pctx.Dependencies)),
+	}
+	for name, dep := range pctx.Dependencies {
+		if dep.Actual != nil && (p.secret || !util.IsSecret(dep.Actual)) {
+			object, err := util.RuntimeToUnstructured(dep.Actual)
+			if err != nil {
+				return nil, errors.Wrapf(err, "dependency %q", name)
+			}
+			tctx.Objects[string(name)] = object.Object
+		}
+		depData, ok, err := getDependencyData(dep)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dependency %q", name)
+		}
+		if !ok || (depData.isSecret && !p.secret) {
+			continue
+		}
+		data := make(map[string]string, len(depData.data))
+		for key, value := range depData.data {
+			data[key] = string(value)
+		}
+		tctx.Data[string(name)] = data
+	}
+	return tctx, nil
+}
+
+// funcs returns functions available to templates.
+func (tctx *templateContext) funcs() template.FuncMap {
+	return template.FuncMap{
+		// data returns the value of the key of the dependency. Unlike index it fails if the key does not exist.
+		"data": func(dependency, key string) (string, error) {
+			depData, ok := tctx.Data[dependency]
+			if !ok {
+				return "", errors.Errorf("data of dependency %q is not available", dependency)
+			}
+			value, ok := depData[key]
+			if !ok {
+				return "", errors.Errorf("key %q not found in dependency %q", key, dependency)
+			}
+			return value, nil
+		},
+	}
+}
@@ -0,0 +1,157 @@
+package plugin
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+// maxDefaultingDepth limits how deep defaults are applied so that recursive schemas cannot cause
+// infinite recursion.
+const maxDefaultingDepth = 64
+
+// AppliedDefault is a default value from the spec schema that was set in a plugin spec.
+type AppliedDefault struct {
+	// Path is the path of the field that was set, e.g. "sources[0].prefix".
+	Path  string
+	Value interface{}
+}
+
+// specDefaulter applies default values from a JSON schema.
+type specDefaulter struct {
+	// root is the whole schema, local references are resolved against it.
+	root map[string]interface{}
+}
+
+func newSpecDefaulter(schema []byte) (*specDefaulter, error) {
+	var root map[string]interface{}
+	// util/json decodes numbers as int64 where possible, like specs of Bundles are decoded
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &specDefaulter{
+		root: root,
+	}, nil
+}
+
+// ApplyDefaults sets default values from the spec schema for fields that are missing in the plugin spec.
+// Defaults are applied to properties of objects, including objects that were set from defaults, and to
+// items of arrays. The spec is modified in place unless it is nil, the resulting spec is returned.
+func (pc *Container) ApplyDefaults(pluginSpec map[string]interface{}) (map[string]interface{}, []AppliedDefault) {
+	if pc.defaulter == nil {
+		return pluginSpec, nil
+	}
+	var applied []AppliedDefault
+	var spec interface{} = pluginSpec
+	if pluginSpec == nil {
+		spec = map[string]interface{}{}
+	}
+	result := pc.defaulter.apply(pc.defaulter.root, spec, "", 0, &applied)
+	if len(applied) == 0 {
+		return pluginSpec, nil
+	}
+	return result.(map[string]interface{}), applied
+}
+
+func (d *specDefaulter) apply(schema map[string]interface{}, value interface{}, path string, depth int, applied *[]AppliedDefault) interface{} {
+	if depth > maxDefaultingDepth {
+		return value
+	}
+	schema = d.resolve(schema)
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			if subSchema, ok := s.(map[string]interface{}); ok {
+				value = d.apply(subSchema, value, path, depth+1, applied)
+			}
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		// Iterate in order so that applied defaults are reported deterministically
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propSchema, ok := properties[name].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			propSchema = d.resolve(propSchema)
+			propPath := joinPath(path, name)
+			if _, exists := v[name]; !exists {
+				defaultValue, ok := propSchema["default"]
+				if !ok {
+					continue
+				}
+				v[name] = runtime.DeepCopyJSONValue(defaultValue)
+				*applied = append(*applied, AppliedDefault{
+					Path:  propPath,
+					Value: defaultValue,
+				})
+			}
+			v[name] = d.apply(propSchema, v[name], propPath, depth+1, applied)
+		}
+		if additionalProperties, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				if _, ok := properties[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				v[key] = d.apply(additionalProperties, v[key], joinPath(path, key), depth+1, applied)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i := range v {
+				v[i] = d.apply(items, v[i], path+"["+strconv.Itoa(i)+"]", depth+1, applied)
+			}
+		}
+	}
+	return value
+}
+
+// resolve returns the schema a local reference (e.g. "#/definitions/source") points to.
+// Schemas without a reference and references that cannot be resolved are returned as is.
+func (d *specDefaulter) resolve(schema map[string]interface{}) map[string]interface{} {
+	for i := 0; i < maxDefaultingDepth; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#") {
+			return schema
+		}
+		var current interface{} = d.root
+		for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+			if token == "" {
+				continue
+			}
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return schema
+			}
+			current = m[token]
+		}
+		resolved, ok := current.(map[string]interface{})
+		if !ok {
+			return schema
+		}
+		schema = resolved
+	}
+	return schema
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+type schemaPlugin struct {
+	schema string
+}
+
+func (p *schemaPlugin) Describe() *Description {
+	return &Description{
+		Name:       smith_v1.PluginName("schema"),
+		GVK:        core_v1.SchemeGroupVersion.WithKind("ConfigMap"),
+		SpecSchema: []byte(p.schema),
+	}
+}
+
+func (p *schemaPlugin) Process(map[string]interface{}, *Context) ProcessResult {
+	return &ProcessResultFailure{}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	t.Parallel()
+	const schema = `{
+		"type": "object",
+		"definitions": {
+			"source": {
+				"type": "object",
+				"properties": {
+					"prefix": {"type": "string", "default": "FOO_"}
+				}
+			}
+		},
+		"properties": {
+			"replicas": {"type": "integer", "default": 1},
+			"enabled": {"type": "boolean", "default": true},
+			"options": {
+				"type": "object",
+				"default": {},
+				"properties": {
+					"mode": {"type": "string", "default": "fast"}
+				}
+			},
+			"sources": {
+				"type": "array",
+				"items": {"$ref": "#/definitions/source"}
+			},
+			"labels": {
+				"type": "object",
+				"additionalProperties": {
+					"type": "object",
+					"properties": {
+						"value": {"type": "string", "default": "x"}
+					}
+				}
+			}
+		}
+	}`
+	pluginContainer, err := NewContainer(func() (Plugin, error) {
+		return &schemaPlugin{schema: schema}, nil
+	})
+	require.NoError(t, err)
+
+	spec, applied := pluginContainer.ApplyDefaults(map[string]interface{}{
+		"enabled": false, // Set values are not overridden
+		"sources": []interface{}{
+			map[string]interface{}{},
+			map[string]interface{}{"prefix": "BAR_"},
+		},
+		"labels": map[string]interface{}{
+			"a": map[string]interface{}{},
+		},
+	})
+	assert.Equal(t, map[string]interface{}{
+		"replicas": int64(1),
+		"enabled":  false,
+		"options": map[string]interface{}{
+			"mode": "fast",
+		},
+		"sources": []interface{}{
+			map[string]interface{}{"prefix": "FOO_"},
+			map[string]interface{}{"prefix": "BAR_"},
+		},
+		"labels": map[string]interface{}{
+			"a": map[string]interface{}{"value": "x"},
+		},
+	}, spec)
+	assert.Equal(t, []AppliedDefault{
+		{Path: "labels.a.value", Value: "x"},
+		{Path: "options", Value: map[string]interface{}{}},
+		{Path: "options.mode", Value: "fast"},
+		{Path: "replicas", Value: int64(1)},
+		{Path: "sources[0].prefix", Value: "FOO_"},
+	}, applied)
+
+	// Validation sees the defaulted spec
+	validationResult, err := pluginContainer.ValidateSpec(spec)
+	require.NoError(t, err)
+	assert.Empty(t, validationResult.Errors)
+}
+
+func TestApplyDefaultsToNilSpec(t *testing.T) {
+	t.Parallel()
+	pluginContainer, err := NewContainer(func() (Plugin, error) {
+		return &schemaPlugin{schema: `{"type": "object", "properties": {"key": {"type": "string", "default": "value"}}}`}, nil
+	})
+	require.NoError(t, err)
+
+	spec, applied := pluginContainer.ApplyDefaults(nil)
+	assert.Equal(t, map[string]interface{}{"key": "value"}, spec)
+	assert.Len(t, applied, 1)
+}
+
+func TestApplyDefaultsWithoutDefaults(t *testing.T) {
+	t.Parallel()
+	pluginContainer, err := NewContainer(func() (Plugin, error) {
+		return &schemaPlugin{schema: `{"type": "object", "properties": {"key": {"type": "string"}}}`}, nil
+	})
+	require.NoError(t, err)
+
+	spec, applied := pluginContainer.ApplyDefaults(nil)
+	assert.Nil(t, spec)
+	assert.Empty(t, applied)
+}
@@ -1,13 +1,18 @@
 package plugin
 
 import (
+	"io"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/pkg/errors"
 	"github.com/xeipuuv/gojsonschema"
 )
 
 type Container struct {
-	Plugin Plugin
-	schema *gojsonschema.Schema
+	Plugin       Plugin
+	schema       *gojsonschema.Schema
+	defaulter    *specDefaulter
+	outputSchema *gojsonschema.Schema
 }
 
 type ValidationResult struct {
@@ -19,21 +24,69 @@ func NewContainer(newPlugin NewFunc) (Container, error) {
 	if err != nil {
 		return Container{}, errors.Wrap(err, "failed to instantiate plugin")
 	}
+	pc, err := newContainer(plugin)
+	if err != nil {
+		if closer, ok := plugin.(io.Closer); ok {
+			closer.Close() // nolint: errcheck, gosec
+		}
+		return Container{}, err
+	}
+	return pc, nil
+}
+
+func newContainer(plugin Plugin) (Container, error) {
 	description := plugin.Describe()
+	if description.IsMultiObject() && !description.GVK.Empty() {
+		return Container{}, errors.Errorf("plugin %q cannot declare both GVK and GVKs", description.Name)
+	}
 	var schema *gojsonschema.Schema
+	var defaulter *specDefaulter
+	var err error
 	if description.SpecSchema != nil {
 		schema, err = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(description.SpecSchema))
 		if err != nil {
 			return Container{}, errors.Wrapf(err, "can't use plugin %q due to invalid schema", description.Name)
 		}
+		defaulter, err = newSpecDefaulter(description.SpecSchema)
+		if err != nil {
+			return Container{}, errors.Wrapf(err, "can't use plugin %q due to invalid schema", description.Name)
+		}
+	}
+	var outputSchema *gojsonschema.Schema
+	if description.OutputSchema != nil {
+		outputSchema, err = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(description.OutputSchema))
+		if err != nil {
+			return Container{}, errors.Wrapf(err, "can't use plugin %q due to invalid output schema", description.Name)
+		}
+	}
+	inputNames := make(map[smith_v1.ReferenceName]struct{}, len(description.Inputs))
+	for _, input := range description.Inputs {
+		if input.Name == "" {
+			return Container{}, errors.Errorf("plugin %q declares an input without a name", description.Name)
+		}
+		if _, ok := inputNames[input.Name]; ok {
+			return Container{}, errors.Errorf("plugin %q declares input %q more than once", description.Name, input.Name)
+		}
+		inputNames[input.Name] = struct{}{}
 	}
 
 	return Container{
-		Plugin: plugin,
-		schema: schema,
+		Plugin:       plugin,
+		schema:       schema,
+		defaulter:    defaulter,
+		outputSchema: outputSchema,
 	}, nil
 }
 
+// Close releases resources held by the plugin if it implements io.Closer, e.g. stops the process of an
+// out-of-process plugin.
+func (pc *Container) Close() error {
+	if closer, ok := pc.Plugin.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 func (pc *Container) ValidateSpec(pluginSpec map[string]interface{}) (ValidationResult, error) {
 	if pc.schema == nil {
 		return ValidationResult{}, nil
@@ -43,7 +96,23 @@ func (pc *Container) ValidateSpec(pluginSpec map[string]interface{}) (Validation
 	if err != nil {
 		return ValidationResult{}, errors.Wrap(err, "error validating plugin spec")
 	}
+	return validationResult(result), nil
+}
+
+// ValidateOutput validates an object produced by the plugin against the output schema of the plugin.
+func (pc *Container) ValidateOutput(object map[string]interface{}) (ValidationResult, error) {
+	if pc.outputSchema == nil {
+		return ValidationResult{}, nil
+	}
+
+	result, err := pc.outputSchema.Validate(gojsonschema.NewGoLoader(object))
+	if err != nil {
+		return ValidationResult{}, errors.Wrap(err, "error validating plugin output")
+	}
+	return validationResult(result), nil
+}
 
+func validationResult(result *gojsonschema.Result) ValidationResult {
 	if !result.Valid() {
 		validationErrors := result.Errors()
 		errs := make([]error, 0, len(validationErrors))
@@ -52,8 +121,8 @@ func (pc *Container) ValidateSpec(pluginSpec map[string]interface{}) (Validation
 			errs = append(errs, errors.New(validationErr.String()))
 		}
 
-		return ValidationResult{errs}, nil
+		return ValidationResult{errs}
 	}
 
-	return ValidationResult{}, nil
+	return ValidationResult{}
 }
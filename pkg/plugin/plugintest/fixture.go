@@ -0,0 +1,63 @@
+package plugintest
+
+import (
+	"io/ioutil"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Fixture describes an invocation of a plugin.
+type Fixture struct {
+	// Namespace is the namespace of the Bundle. "default" if empty.
+	Namespace string `json:"namespace,omitempty"`
+	// Spec is the plugin specification with references already resolved.
+	Spec map[string]interface{} `json:"spec,omitempty"`
+	// Actual is the object produced by a previous invocation, see plugin.Context.
+	Actual *unstructured.Unstructured `json:"actual,omitempty"`
+	// ActualObjects are the objects produced by a previous invocation of a plugin that produces multiple objects.
+	ActualObjects []*unstructured.Unstructured `json:"actualObjects,omitempty"`
+	// Dependencies are the dependencies of the resource, keyed by resource name.
+	Dependencies map[smith_v1.ResourceName]Dependency `json:"dependencies,omitempty"`
+	// Lookups are the objects in the cluster that the plugin can look up if it declared them.
+	Lookups []*unstructured.Unstructured `json:"lookups,omitempty"`
+}
+
+// Dependency describes a dependency of the resource, see plugin.Dependency.
+type Dependency struct {
+	Spec      smith_v1.Resource            `json:"spec,omitempty"`
+	Actual    *unstructured.Unstructured   `json:"actual,omitempty"`
+	Objects   []*unstructured.Unstructured `json:"objects,omitempty"`
+	Outputs   []*unstructured.Unstructured `json:"outputs,omitempty"`
+	Auxiliary []*unstructured.Unstructured `json:"auxiliary,omitempty"`
+}
+
+// Result is the result of processing a fixture. It is what golden files contain.
+type Result struct {
+	// Object is the object produced by a plugin that produces a single object.
+	Object *unstructured.Unstructured `json:"object,omitempty"`
+	// Objects are the objects produced by a plugin that produces multiple objects, in the order they were returned.
+	Objects []*unstructured.Unstructured `json:"objects,omitempty"`
+	// SpecErrors are the errors of validation of the spec against the spec schema of the plugin.
+	// The plugin is not invoked if the spec is invalid.
+	SpecErrors []string `json:"specErrors,omitempty"`
+	// Error is the error returned by the plugin.
+	Error            string `json:"error,omitempty"`
+	IsExternalError  bool   `json:"isExternalError,omitempty"`
+	IsRetriableError bool   `json:"isRetriableError,omitempty"`
+}
+
+// LoadFixture loads a fixture from a YAML file. Unknown fields are rejected to catch typos.
+func LoadFixture(filename string) (*Fixture, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read fixture")
+	}
+	var fixture Fixture
+	if err = yaml.UnmarshalStrict(data, &fixture); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse fixture %q", filename)
+	}
+	return &fixture, nil
+}
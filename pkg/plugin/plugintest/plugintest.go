@@ -0,0 +1,383 @@
+// Package plugintest helps to test Smith plugins.
+//
+// An invocation of a plugin is described by a fixture - a YAML file with the plugin spec and the dependencies,
+// see Fixture. The plugin is invoked the same way Smith invokes it: defaults from the spec schema are applied,
+// the spec is validated against the spec schema and the output is checked against the declared kinds and
+// the output schema. The plugin is invoked several times
+// to check that it is deterministic and the result is compared with a golden file next to the fixture.
+// Golden files are (re)generated by running tests with the -plugintest.update flag.
+package plugintest
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultInvocations is the default number of times a plugin is invoked for each fixture.
+	DefaultInvocations = 3
+
+	// FixtureSuffix is the suffix of fixture files.
+	FixtureSuffix = ".yaml"
+	// GoldenSuffix is the suffix of golden files. The golden file of "foo.yaml" is "foo.golden.yaml".
+	GoldenSuffix = ".golden.yaml"
+)
+
+var update = flag.Bool("plugintest.update", false, "update golden files of plugin fixtures instead of comparing results with them")
+
+// Tester invokes a plugin with fixtures.
+type Tester struct {
+	// Invocations is the number of times the plugin is invoked for each fixture. All invocations must
+	// produce the same result.
+	Invocations int
+
+	container   plugin.Container
+	description *plugin.Description
+	scheme      *runtime.Scheme
+}
+
+// New returns a Tester for the plugin. Objects of fixtures are passed to the plugin as typed objects if
+// the scheme recognizes them, like Smith does, and as *unstructured.Unstructured otherwise. scheme may be nil.
+func New(newPlugin plugin.NewFunc, scheme *runtime.Scheme) (*Tester, error) {
+	container, err := plugin.NewContainer(newPlugin)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == nil {
+		scheme = runtime.NewScheme()
+	}
+	return &Tester{
+		Invocations: DefaultInvocations,
+		container:   container,
+		description: container.Plugin.Describe(),
+		scheme:      scheme,
+	}, nil
+}
+
+// RunDir runs each fixture in the directory as a subtest named after the fixture file.
+func (tr *Tester) RunDir(t *testing.T, dir string) {
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*"+FixtureSuffix))
+	require.NoError(t, err)
+	found := false
+	for _, fixture := range fixtures {
+		if strings.HasSuffix(fixture, GoldenSuffix) {
+			continue
+		}
+		found = true
+		fixture := fixture
+		t.Run(strings.TrimSuffix(filepath.Base(fixture), FixtureSuffix), func(t *testing.T) {
+			tr.RunFixture(t, fixture)
+		})
+	}
+	if !found {
+		t.Errorf("no fixtures found in %q", dir)
+	}
+}
+
+// RunFixture processes the fixture and compares the result with the golden file of the fixture.
+func (tr *Tester) RunFixture(t *testing.T, filename string) {
+	fixture, err := LoadFixture(filename)
+	require.NoError(t, err)
+	result, err := tr.Process(fixture)
+	require.NoError(t, err)
+	actual, err := yaml.Marshal(result)
+	require.NoError(t, err)
+
+	goldenFile := strings.TrimSuffix(filename, FixtureSuffix) + GoldenSuffix
+	if *update {
+		require.NoError(t, ioutil.WriteFile(goldenFile, actual, 0644))
+		return
+	}
+	expected, err := ioutil.ReadFile(goldenFile)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden file %q does not exist, run tests with -plugintest.update to create it", goldenFile)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual), "result does not match golden file %q", goldenFile)
+}
+
+// Process invokes the plugin with the fixture and returns the result.
+// An error is returned if the plugin breaks the plugin contract: returns an object of a kind it did not declare,
+// returns output that does not match the output schema or returns different results for the same fixture.
+func (tr *Tester) Process(fixture *Fixture) (*Result, error) {
+	spec := fixture.Spec
+	if spec != nil {
+		spec = runtime.DeepCopyJSON(spec)
+	}
+	spec, _ = tr.container.ApplyDefaults(spec)
+	validationResult, err := tr.container.ValidateSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(validationResult.Errors) > 0 {
+		specErrors := make([]string, 0, len(validationResult.Errors))
+		for _, validationErr := range validationResult.Errors {
+			specErrors = append(specErrors, validationErr.Error())
+		}
+		return &Result{
+			SpecErrors: specErrors,
+		}, nil
+	}
+
+	invocations := tr.Invocations
+	if invocations < 1 {
+		invocations = 1
+	}
+	var first *Result
+	for i := 0; i < invocations; i++ {
+		// Each invocation gets its own copies of the objects so that a plugin mutating them cannot affect
+		// subsequent invocations
+		pctx, err := tr.context(fixture)
+		if err != nil {
+			return nil, err
+		}
+		result, err := tr.invoke(spec, pctx)
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = result
+			continue
+		}
+		if !reflect.DeepEqual(first, result) {
+			return nil, errors.Errorf("plugin %q is not deterministic, invocation %d returned a different result: %s",
+				tr.description.Name, i+1, diff.ObjectReflectDiff(first, result))
+		}
+	}
+	return first, nil
+}
+
+func (tr *Tester) invoke(spec map[string]interface{}, pctx *plugin.Context) (*Result, error) {
+	processResult := tr.container.Plugin.Process(runtime.DeepCopyJSON(spec), pctx)
+	switch r := processResult.(type) {
+	case *plugin.ProcessResultSuccess:
+		if tr.description.IsMultiObject() {
+			objects, err := tr.checkObjects(r.Objects)
+			if err != nil {
+				return nil, err
+			}
+			return &Result{
+				Objects: objects,
+			}, nil
+		}
+		if r.Object == nil {
+			return nil, errors.Errorf("plugin %q returned no object", tr.description.Name)
+		}
+		object, err := tr.checkObject(r.Object, []schema.GroupVersionKind{tr.description.GVK})
+		if err != nil {
+			return nil, err
+		}
+		return &Result{
+			Object: object,
+		}, nil
+	case *plugin.ProcessResultFailure:
+		if r.Error == nil {
+			return nil, errors.Errorf("plugin %q returned a failure without an error", tr.description.Name)
+		}
+		return &Result{
+			Error:            r.Error.Error(),
+			IsExternalError:  r.IsExternalError,
+			IsRetriableError: r.IsRetriableError,
+		}, nil
+	default:
+		return nil, errors.Errorf("plugin %q returned unexpected result %T", tr.description.Name, processResult)
+	}
+}
+
+func (tr *Tester) checkObjects(objects []runtime.Object) ([]*unstructured.Unstructured, error) {
+	type objectRef struct {
+		schema.GroupVersionKind
+		Name string
+	}
+	seen := make(map[objectRef]struct{}, len(objects))
+	result := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, obj := range objects {
+		object, err := tr.checkObject(obj, tr.description.GVKs)
+		if err != nil {
+			return nil, err
+		}
+		gvk := object.GroupVersionKind()
+		name := object.GetName()
+		if name == "" {
+			return nil, errors.Errorf("plugin %q returned %s without a name", tr.description.Name, gvk.Kind)
+		}
+		ref := objectRef{
+			GroupVersionKind: gvk,
+			Name:             name,
+		}
+		if _, ok := seen[ref]; ok {
+			return nil, errors.Errorf("plugin %q returned %s %q more than once", tr.description.Name, gvk.Kind, name)
+		}
+		seen[ref] = struct{}{}
+		result = append(result, object)
+	}
+	return result, nil
+}
+
+func (tr *Tester) checkObject(obj runtime.Object, allowedGVKs []schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	object, err := util.RuntimeToUnstructured(obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "output of plugin %q cannot be converted from runtime.Object", tr.description.Name)
+	}
+	gvk := object.GroupVersionKind()
+	allowed := false
+	for _, allowedGVK := range allowedGVKs {
+		if gvk == allowedGVK {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, errors.Errorf("unexpected GVK from plugin %q (wanted one of %s, got %s)", tr.description.Name, allowedGVKs, gvk)
+	}
+	validationResult, err := tr.container.ValidateOutput(object.Object)
+	if err != nil {
+		return nil, err
+	}
+	if len(validationResult.Errors) > 0 {
+		return nil, errors.Errorf("output of plugin %q failed validation against schema: %v", tr.description.Name, validationResult.Errors)
+	}
+	return object, nil
+}
+
+// context builds the context of an invocation from the fixture.
+func (tr *Tester) context(fixture *Fixture) (*plugin.Context, error) {
+	namespace := fixture.Namespace
+	if namespace == "" {
+		namespace = meta_v1.NamespaceDefault
+	}
+	pctx := &plugin.Context{
+		Context:   context.Background(),
+		Namespace: namespace,
+	}
+	var err error
+	if fixture.Actual != nil {
+		if pctx.Actual, err = tr.toTypedObject(fixture.Actual); err != nil {
+			return nil, err
+		}
+	}
+	if pctx.ActualObjects, err = tr.toTypedObjects(fixture.ActualObjects); err != nil {
+		return nil, err
+	}
+	if len(fixture.Dependencies) > 0 {
+		pctx.Dependencies = make(map[smith_v1.ResourceName]plugin.Dependency, len(fixture.Dependencies))
+		for name, dep := range fixture.Dependencies {
+			dependency := plugin.Dependency{
+				Spec: *dep.Spec.DeepCopy(),
+			}
+			if dep.Actual != nil {
+				if dependency.Actual, err = tr.toTypedObject(dep.Actual); err != nil {
+					return nil, errors.Wrapf(err, "dependency %q", name)
+				}
+			}
+			if dependency.Objects, err = tr.toTypedObjects(dep.Objects); err != nil {
+				return nil, errors.Wrapf(err, "dependency %q", name)
+			}
+			if dependency.Outputs, err = tr.toTypedObjects(dep.Outputs); err != nil {
+				return nil, errors.Wrapf(err, "dependency %q", name)
+			}
+			if dependency.Auxiliary, err = tr.toTypedObjects(dep.Auxiliary); err != nil {
+				return nil, errors.Wrapf(err, "dependency %q", name)
+			}
+			pctx.Dependencies[name] = dependency
+		}
+	}
+	if len(tr.description.Lookups) > 0 {
+		pctx.Lookup = &objectLookup{
+			tester:    tr,
+			namespace: namespace,
+			objects:   fixture.Lookups,
+		}
+	}
+	return pctx, nil
+}
+
+func (tr *Tester) toTypedObjects(objs []*unstructured.Unstructured) ([]runtime.Object, error) {
+	if len(objs) == 0 {
+		return nil, nil
+	}
+	result := make([]runtime.Object, 0, len(objs))
+	for _, obj := range objs {
+		typed, err := tr.toTypedObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, typed)
+	}
+	return result, nil
+}
+
+// toTypedObject converts a copy of the object into a typed object if the scheme recognizes its kind.
+func (tr *Tester) toTypedObject(obj *unstructured.Unstructured) (runtime.Object, error) {
+	objCopy := obj.DeepCopy()
+	gvk := objCopy.GroupVersionKind()
+	if !tr.scheme.Recognizes(gvk) {
+		return objCopy, nil
+	}
+	typed, err := tr.scheme.ConvertToVersion(objCopy, gvk.GroupVersion())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	typed.GetObjectKind().SetGroupVersionKind(gvk)
+	return typed, nil
+}
+
+// objectLookup serves objects of a fixture to the plugin. Like in Smith, only objects the plugin declared
+// can be looked up.
+type objectLookup struct {
+	tester    *Tester
+	namespace string
+	objects   []*unstructured.Unstructured
+}
+
+func (l *objectLookup) Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, error) {
+	if !l.isDeclared(gvk, namespace, name) {
+		return nil, errors.Errorf("plugin %q did not declare lookups of %s %q in namespace %q", l.tester.description.Name, gvk, name, namespace)
+	}
+	for _, obj := range l.objects {
+		if obj.GroupVersionKind() == gvk && obj.GetNamespace() == namespace && obj.GetName() == name {
+			return l.tester.toTypedObject(obj)
+		}
+	}
+	return nil, nil
+}
+
+func (l *objectLookup) isDeclared(gvk schema.GroupVersionKind, namespace, name string) bool {
+	for _, lookup := range l.tester.description.Lookups {
+		if lookup.GVK != gvk {
+			continue
+		}
+		// Fixtures do not say which kinds are cluster-scoped so an empty namespace is accepted for any kind
+		if namespace != meta_v1.NamespaceNone && namespace != lookup.Namespace && (lookup.Namespace != "" || namespace != l.namespace) {
+			continue
+		}
+		if len(lookup.Names) == 0 {
+			return true
+		}
+		for _, declaredName := range lookup.Names {
+			if declaredName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package plugintest
+
+import (
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	testPluginName smith_v1.PluginName = "test"
+	platformConfig                     = "platform-config"
+)
+
+var configMapGVK = core_v1.SchemeGroupVersion.WithKind("ConfigMap")
+
+func testScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := core_v1.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+	return scheme
+}
+
+// testPlugin produces a ConfigMap with the "key" from the spec, data of the ConfigMap dependency "dep"
+// and data of the looked up platform ConfigMap.
+type testPlugin struct {
+	// mutate is called with the produced ConfigMap before it is returned.
+	mutate func(*core_v1.ConfigMap)
+	// gvk overrides the declared GVK.
+	gvk schema.GroupVersionKind
+}
+
+func (p *testPlugin) Describe() *plugin.Description {
+	gvk := configMapGVK
+	if !p.gvk.Empty() {
+		gvk = p.gvk
+	}
+	return &plugin.Description{
+		Name:         testPluginName,
+		GVK:          gvk,
+		SpecSchema:   []byte(`{"type":"object","required":["key"],"properties":{"key":{"type":"string"}}}`),
+		OutputSchema: []byte(`{"type":"object","required":["data"]}`),
+		Lookups: []plugin.Lookup{
+			{
+				GVK:   configMapGVK,
+				Names: []string{platformConfig},
+			},
+		},
+	}
+}
+
+func (p *testPlugin) Process(spec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	key := spec["key"].(string)
+	if key == "fail" {
+		return &plugin.ProcessResultFailure{
+			Error:           errors.New("failed as requested"),
+			IsExternalError: true,
+		}
+	}
+	data := map[string]string{
+		"key":       key,
+		"namespace": pctx.Namespace,
+	}
+	if dep, ok := pctx.Dependencies["dep"]; ok {
+		for k, v := range dep.Actual.(*core_v1.ConfigMap).Data {
+			data[k] = v
+		}
+	}
+	platform, err := pctx.Lookup.Get(configMapGVK, pctx.Namespace, platformConfig)
+	if err != nil {
+		return &plugin.ProcessResultFailure{
+			Error: err,
+		}
+	}
+	if platform != nil {
+		data["region"] = platform.(*core_v1.ConfigMap).Data["region"]
+	}
+	configMap := &core_v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			APIVersion: configMapGVK.GroupVersion().String(),
+			Kind:       configMapGVK.Kind,
+		},
+		Data: data,
+	}
+	if p.mutate != nil {
+		p.mutate(configMap)
+	}
+	return &plugin.ProcessResultSuccess{
+		Object: configMap,
+	}
+}
+
+func newTester(t *testing.T, p *testPlugin) *Tester {
+	tr, err := New(func() (plugin.Plugin, error) {
+		return p, nil
+	}, testScheme())
+	require.NoError(t, err)
+	return tr
+}
+
+func TestFixtures(t *testing.T) {
+	t.Parallel()
+	newTester(t, &testPlugin{}).RunDir(t, "testdata")
+}
+
+func TestNotDeterministic(t *testing.T) {
+	t.Parallel()
+	counter := 0
+	tr := newTester(t, &testPlugin{
+		mutate: func(configMap *core_v1.ConfigMap) {
+			counter++
+			if counter > 1 {
+				configMap.Data["timestamp"] = "now"
+			}
+		},
+	})
+	_, err := tr.Process(&Fixture{
+		Spec: map[string]interface{}{"key": "value"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `plugin "test" is not deterministic, invocation 2 returned a different result`)
+}
+
+func TestUnexpectedGVK(t *testing.T) {
+	t.Parallel()
+	tr := newTester(t, &testPlugin{
+		gvk: core_v1.SchemeGroupVersion.WithKind("Secret"),
+	})
+	_, err := tr.Process(&Fixture{
+		Spec: map[string]interface{}{"key": "value"},
+	})
+	assert.EqualError(t, err, `unexpected GVK from plugin "test" (wanted one of [/v1, Kind=Secret], got /v1, Kind=ConfigMap)`)
+}
+
+func TestOutputSchemaViolated(t *testing.T) {
+	t.Parallel()
+	tr := newTester(t, &testPlugin{
+		mutate: func(configMap *core_v1.ConfigMap) {
+			configMap.Data = nil
+		},
+	})
+	_, err := tr.Process(&Fixture{
+		Spec: map[string]interface{}{"key": "value"},
+	})
+	assert.EqualError(t, err, `output of plugin "test" failed validation against schema: [(root): data is required]`)
+}
+
+func TestUndeclaredLookup(t *testing.T) {
+	t.Parallel()
+	tr := newTester(t, &testPlugin{})
+	pctx, err := tr.context(&Fixture{})
+	require.NoError(t, err)
+	_, err = pctx.Lookup.Get(configMapGVK, meta_v1.NamespaceDefault, "other")
+	assert.EqualError(t, err, `plugin "test" did not declare lookups of /v1, Kind=ConfigMap "other" in namespace "default"`)
+	_, err = pctx.Lookup.Get(configMapGVK, "other-namespace", platformConfig)
+	assert.EqualError(t, err, `plugin "test" did not declare lookups of /v1, Kind=ConfigMap "platform-config" in namespace "other-namespace"`)
+}
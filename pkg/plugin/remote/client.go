@@ -0,0 +1,266 @@
+package remote
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultTimeout is the default timeout for requests to plugins.
+	DefaultTimeout = 10 * time.Second
+	// DefaultStartupTimeout is the default time to wait for a plugin to become healthy on Smith startup.
+	DefaultStartupTimeout = 1 * time.Minute
+	// DefaultHealthCheckInterval is the default interval between health checks of running plugins.
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	unixScheme = "unix://"
+	// startupPollInterval is the interval between health checks while waiting for a plugin to start.
+	startupPollInterval = 500 * time.Millisecond
+	// maxHealthCheckFailures is how many health checks in a row may fail before the plugin is reconnected to
+	// or, if it is a child process, restarted.
+	maxHealthCheckFailures = 3
+)
+
+// Config describes an out-of-process plugin.
+// Exactly one of Address and Command must be set.
+type Config struct {
+	// Address of a running plugin, e.g. of a sidecar container, in the "unix:///path/to/socket" format.
+	Address string `json:"address,omitempty"`
+	// Command is executed to start the plugin as a child process of Smith.
+	// The plugin must serve on its standard input and output, see ServeFromEnv.
+	// The process is restarted if it exits or stops responding to health checks.
+	Command []string `json:"command,omitempty"`
+	// Timeout for requests to the plugin. DefaultTimeout is used if not set.
+	Timeout meta_v1.Duration `json:"timeout,omitempty"`
+	// StartupTimeout is how long to wait for the plugin to become healthy on Smith startup.
+	// DefaultStartupTimeout is used if not set.
+	StartupTimeout meta_v1.Duration `json:"startupTimeout,omitempty"`
+	// HealthCheckInterval is the interval between health checks of the running plugin.
+	// DefaultHealthCheckInterval is used if not set.
+	HealthCheckInterval meta_v1.Duration `json:"healthCheckInterval,omitempty"`
+}
+
+// NewFunc returns a plugin.NewFunc for an out-of-process plugin.
+// The returned function waits for the plugin to become healthy and fetches its description.
+// The plugin must be closed to stop health checks and the child process, if any.
+func NewFunc(config Config) plugin.NewFunc {
+	return func() (plugin.Plugin, error) {
+		return newPlugin(config)
+	}
+}
+
+// Plugin proxies calls to an out-of-process plugin.
+type Plugin struct {
+	address     string
+	connect     func() (*conn, error)
+	timeout     time.Duration
+	description *plugin.Description
+
+	lock      sync.Mutex
+	conn      *conn
+	lastStart time.Time
+	closed    bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newPlugin(config Config) (*Plugin, error) {
+	p := &Plugin{
+		address: config.Address,
+		timeout: config.Timeout.Duration,
+	}
+	switch {
+	case config.Address != "" && len(config.Command) > 0:
+		return nil, errors.New("plugin address and command cannot be both specified")
+	case len(config.Command) > 0:
+		p.address = config.Command[0]
+		p.connect = func() (*conn, error) {
+			return startProcess(config.Command)
+		}
+	case strings.HasPrefix(config.Address, unixScheme):
+		p.connect = func() (*conn, error) {
+			return dialSocket(strings.TrimPrefix(config.Address, unixScheme))
+		}
+	case config.Address == "":
+		return nil, errors.New("plugin address or command must be specified")
+	default:
+		return nil, errors.Errorf("unsupported plugin address %q", config.Address)
+	}
+	if p.timeout == 0 {
+		p.timeout = DefaultTimeout
+	}
+	startupTimeout := config.StartupTimeout.Duration
+	if startupTimeout == 0 {
+		startupTimeout = DefaultStartupTimeout
+	}
+	healthCheckInterval := config.HealthCheckInterval.Duration
+	if healthCheckInterval == 0 {
+		healthCheckInterval = DefaultHealthCheckInterval
+	}
+	if err := p.start(startupTimeout); err != nil {
+		p.Close() // nolint: errcheck, gosec
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.wg.Add(1)
+	go p.monitorHealth(ctx, healthCheckInterval)
+	return p, nil
+}
+
+// start waits for the plugin to become healthy and fetches its description.
+func (p *Plugin) start(startupTimeout time.Duration) error {
+	var lastErr error
+	err := wait.PollImmediate(startupPollInterval, startupTimeout, func() (bool /*done*/, error) {
+		lastErr = p.Healthy()
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return errors.Wrapf(lastErr, "plugin at %q did not become healthy", p.address)
+	}
+	var desc DescribeResponse
+	if err = p.call(context.Background(), MethodDescribe, nil, &desc); err != nil {
+		return errors.Wrapf(err, "failed to describe plugin at %q", p.address)
+	}
+	if !desc.isComplete() {
+		return errors.Errorf("plugin at %q returned an incomplete description", p.address)
+	}
+	p.description = desc.description()
+	return nil
+}
+
+// monitorHealth checks health of the plugin until the context is done. A plugin that fails several health checks
+// in a row is disconnected from, which stops the process of a plugin started by Smith. A new connection is made or
+// a new process is started by the next call.
+func (p *Plugin) monitorHealth(ctx context.Context, interval time.Duration) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if p.Healthy() == nil {
+			failures = 0
+			continue
+		}
+		failures++
+		if failures >= maxHealthCheckFailures {
+			failures = 0
+			p.disconnect(errors.Errorf("plugin at %q failed %d health checks in a row", p.address, maxHealthCheckFailures))
+		}
+	}
+}
+
+// Describe returns the description fetched from the plugin on startup.
+func (p *Plugin) Describe() *plugin.Description {
+	return p.description
+}
+
+// Process sends the spec and the context to the plugin.
+// Failures to communicate with the plugin are retriable. The call is abandoned once pctx.Context is done.
+func (p *Plugin) Process(spec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	ctx := pctx.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := processRequest(spec, pctx)
+	if err != nil {
+		return &plugin.ProcessResultFailure{
+			Error: errors.Wrap(err, "failed to encode plugin request"),
+		}
+	}
+	var resp ProcessResponse
+	if err = p.call(ctx, MethodProcess, req, &resp); err != nil {
+		return &plugin.ProcessResultFailure{
+			Error:            errors.Wrapf(err, "failed to invoke plugin %q", p.description.Name),
+			IsRetriableError: true,
+		}
+	}
+	return resp.result(p.description.IsMultiObject())
+}
+
+// Healthy returns nil if the plugin responds to health checks.
+func (p *Plugin) Healthy() error {
+	return p.call(context.Background(), MethodHealth, nil, nil)
+}
+
+// Close stops health checks and disconnects from the plugin. Plugin processes started by Smith are stopped
+// and waited for. Calls made after Close fail.
+func (p *Plugin) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closed = true
+	if p.conn == nil {
+		return nil
+	}
+	return p.conn.close(errors.New("plugin is closed"))
+}
+
+func (p *Plugin) call(ctx context.Context, method string, params, result interface{}) error {
+	c, err := p.connection()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return c.call(ctx, method, params, result)
+}
+
+// connection returns the current connection to the plugin or makes a new one if it was closed.
+// New connections are not made more often than once in restartDelay to not restart crashing plugin
+// processes in a tight loop.
+func (p *Plugin) connection() (*conn, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil, errors.New("plugin is closed")
+	}
+	if p.conn != nil && !p.conn.isClosed() {
+		return p.conn, nil
+	}
+	if p.conn != nil && time.Since(p.lastStart) < restartDelay {
+		return nil, errors.Wrap(p.conn.closeErr(), "waiting to reconnect to plugin")
+	}
+	c, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+	p.conn = c
+	p.lastStart = time.Now()
+	return c, nil
+}
+
+func (p *Plugin) disconnect(err error) {
+	p.lock.Lock()
+	c := p.conn
+	p.lock.Unlock()
+	if c != nil {
+		c.close(err) // nolint: errcheck, gosec
+	}
+}
+
+// dialSocket connects to a plugin serving on a Unix socket.
+func dialSocket(socket string) (*conn, error) {
+	netConn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return newConn(netConn, netConn, netConn.Close), nil
+}
@@ -0,0 +1,26 @@
+package remote
+
+import (
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// ConfigFile is the format of the file with out-of-process plugins to load on Smith startup.
+type ConfigFile struct {
+	Plugins []Config `json:"plugins"`
+}
+
+// LoadConfigFile reads configuration of out-of-process plugins from a YAML or JSON file.
+func LoadConfigFile(filename string) ([]Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read plugins configuration")
+	}
+	var file ConfigFile
+	if err = yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse plugins configuration %q", filename)
+	}
+	return file.Plugins, nil
+}
@@ -0,0 +1,138 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// conn sends requests to a plugin over a stream and dispatches responses to the callers.
+type conn struct {
+	writeLock sync.Mutex
+	enc       *json.Encoder
+	// closer releases the underlying stream. It is called once.
+	closer func() error
+
+	lock    sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan<- *Response
+	err     error // set once the connection is closed
+	done    chan struct{}
+}
+
+func newConn(r io.Reader, w io.Writer, closer func() error) *conn {
+	c := &conn{
+		enc:     json.NewEncoder(w),
+		closer:  closer,
+		pending: make(map[uint64]chan<- *Response),
+		done:    make(chan struct{}),
+	}
+	go c.readResponses(r)
+	return c
+}
+
+func (c *conn) readResponses(r io.Reader) {
+	dec := json.NewDecoder(r)
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			if err == io.EOF {
+				err = errors.New("plugin closed the connection")
+			}
+			c.close(errors.Wrap(err, "failed to read plugin response")) // nolint: errcheck
+			return
+		}
+		c.lock.Lock()
+		respCh, ok := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.lock.Unlock()
+		if ok {
+			respCh <- &resp
+		}
+		// Responses to requests that have been abandoned due to a timeout are dropped
+	}
+}
+
+// call sends a request and decodes the result into result unless it is nil.
+func (c *conn) call(ctx context.Context, method string, params, result interface{}) error {
+	req := Request{
+		Method: method,
+	}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode plugin request")
+		}
+		req.Params = data
+	}
+	respCh := make(chan *Response, 1)
+	c.lock.Lock()
+	if c.err != nil {
+		c.lock.Unlock()
+		return c.err
+	}
+	c.nextID++
+	req.ID = c.nextID
+	c.pending[req.ID] = respCh
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		delete(c.pending, req.ID)
+		c.lock.Unlock()
+	}()
+
+	c.writeLock.Lock()
+	err := c.enc.Encode(&req)
+	c.writeLock.Unlock()
+	if err != nil {
+		err = errors.Wrap(err, "failed to send plugin request")
+		c.close(err) // nolint: errcheck
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	case <-c.done:
+		return c.closeErr()
+	case resp := <-respCh:
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		if result == nil {
+			return nil
+		}
+		return errors.Wrap(json.Unmarshal(resp.Result, result), "failed to decode plugin response")
+	}
+}
+
+// close fails pending and future calls with the error and releases the stream.
+// Only the first call has an effect.
+func (c *conn) close(err error) error {
+	c.lock.Lock()
+	if c.err != nil {
+		c.lock.Unlock()
+		return nil
+	}
+	c.err = err
+	close(c.done)
+	c.lock.Unlock()
+	return c.closer()
+}
+
+func (c *conn) closeErr() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+func (c *conn) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,65 @@
+package remote
+
+import (
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// restartDelay is the minimum time between starts of a plugin process.
+	restartDelay = 5 * time.Second
+	// stopTimeout is how long a plugin process has to exit after its standard input is closed before it is killed.
+	stopTimeout = 5 * time.Second
+)
+
+// startProcess starts the command as a child process that serves a plugin on its standard input and output.
+// Closing the returned connection stops the process and waits for it to exit.
+func startProcess(command []string) (*conn, error) {
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close() // nolint: errcheck, gosec
+		stdinW.Close() // nolint: errcheck, gosec
+		return nil, errors.WithStack(err)
+	}
+	cmd := exec.Command(command[0], command[1:]...) // nolint: gosec
+	cmd.Stdin = stdinR
+	cmd.Stdout = stdoutW
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	// The child has its own copies of these ends
+	stdinR.Close()  // nolint: errcheck, gosec
+	stdoutW.Close() // nolint: errcheck, gosec
+	if err != nil {
+		stdinW.Close()  // nolint: errcheck, gosec
+		stdoutR.Close() // nolint: errcheck, gosec
+		return nil, errors.Wrapf(err, "failed to start plugin %q", command[0])
+	}
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		cmd.Wait() // nolint: errcheck, gosec
+	}()
+	c := newConn(stdoutR, stdinW, func() error {
+		// Closing the standard input asks the plugin to exit
+		stdinW.Close() // nolint: errcheck, gosec
+		select {
+		case <-exited:
+		case <-time.After(stopTimeout):
+			cmd.Process.Kill() // nolint: errcheck, gosec
+			<-exited
+		}
+		return errors.WithStack(stdoutR.Close())
+	})
+	go func() {
+		<-exited
+		c.close(errors.Errorf("plugin process %q exited", command[0])) // nolint: errcheck
+	}()
+	return c, nil
+}
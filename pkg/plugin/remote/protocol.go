@@ -0,0 +1,406 @@
+package remote
+
+import (
+	"encoding/json"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Out-of-process plugins exchange JSON messages with Smith, one message per line. Plugins started by Smith
+// read requests from standard input and write responses to standard output. Plugins running in sidecar
+// containers serve the same messages on each connection to a Unix socket.
+// Requests are processed concurrently so responses may be sent in a different order than the requests.
+// The protocol mirrors the plugin.Plugin interface.
+// See docs/design/plugins.md#out-of-process-plugins
+const (
+	// MethodHealth requests have no parameters. Plugins respond with an empty result once they are ready
+	// to process requests.
+	MethodHealth = "health"
+	// MethodDescribe requests have no parameters. The result is a DescribeResponse.
+	MethodDescribe = "describe"
+	// MethodProcess requests have a ProcessRequest as parameters. The result is a ProcessResponse.
+	MethodProcess = "process"
+
+	// AddressEnvVar is the environment variable with the address of a Unix socket to serve the plugin on.
+	// ServeFromEnv serves the plugin on the standard input and output if it is not set.
+	AddressEnvVar = "SMITH_PLUGIN_ADDRESS"
+)
+
+// Request is a message from Smith to a plugin.
+type Request struct {
+	// ID is unique among requests sent over a connection.
+	ID     uint64          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a message from a plugin to Smith.
+// Error is set if the request could not be handled, Result otherwise.
+type Response struct {
+	// ID is the ID of the request.
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// DescribeResponse mirrors plugin.Description.
+type DescribeResponse struct {
+	Name smith_v1.PluginName `json:"name"`
+	// PluginVersion is the version of the plugin. Version is the version of the produced objects.
+	PluginVersion string `json:"pluginVersion,omitempty"`
+	Group         string `json:"group"`
+	Version       string `json:"version"`
+	Kind          string `json:"kind,omitempty"`
+	// Kinds is set instead of Group, Version and Kind by plugins that produce multiple objects.
+	Kinds []Kind `json:"kinds,omitempty"`
+	// SpecSchema is a JSON schema for the spec of the plugin.
+	SpecSchema json.RawMessage `json:"specSchema,omitempty"`
+	// Inputs are the dependencies the plugin expects.
+	Inputs []Input `json:"inputs,omitempty"`
+	// OutputSchema is a JSON schema for the produced object(s).
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+}
+
+// Input mirrors plugin.Input.
+type Input struct {
+	Name     smith_v1.ReferenceName `json:"name"`
+	Kinds    []Kind                 `json:"kinds,omitempty"`
+	Outputs  []Kind                 `json:"outputs,omitempty"`
+	Optional bool                   `json:"optional,omitempty"`
+}
+
+// Kind is a kind of objects a plugin produces.
+type Kind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// ProcessRequest mirrors the arguments of plugin.Plugin.Process.
+type ProcessRequest struct {
+	Spec          map[string]interface{}                `json:"spec,omitempty"`
+	Namespace     string                                `json:"namespace"`
+	Actual        *unstructured.Unstructured            `json:"actual,omitempty"`
+	ActualObjects []*unstructured.Unstructured          `json:"actualObjects,omitempty"`
+	Dependencies  map[smith_v1.ResourceName]*Dependency `json:"dependencies,omitempty"`
+}
+
+// Dependency mirrors plugin.Dependency.
+type Dependency struct {
+	Spec      smith_v1.Resource            `json:"spec"`
+	Actual    *unstructured.Unstructured   `json:"actual,omitempty"`
+	Objects   []*unstructured.Unstructured `json:"objects,omitempty"`
+	Outputs   []*unstructured.Unstructured `json:"outputs,omitempty"`
+	Auxiliary []*unstructured.Unstructured `json:"auxiliary,omitempty"`
+}
+
+// ProcessResponse mirrors plugin.ProcessResult.
+// Processing failed if Error is not empty, otherwise Object or Objects is the result.
+type ProcessResponse struct {
+	Object           *unstructured.Unstructured   `json:"object,omitempty"`
+	Objects          []*unstructured.Unstructured `json:"objects,omitempty"`
+	Error            string                       `json:"error,omitempty"`
+	IsExternalError  bool                         `json:"isExternalError,omitempty"`
+	IsRetriableError bool                         `json:"isRetriableError,omitempty"`
+}
+
+func describeResponse(description *plugin.Description) *DescribeResponse {
+	resp := &DescribeResponse{
+		Name:          description.Name,
+		PluginVersion: description.Version,
+		Group:         description.GVK.Group,
+		Version:       description.GVK.Version,
+		Kind:          description.GVK.Kind,
+		Kinds:         toKinds(description.GVKs),
+		SpecSchema:    description.SpecSchema,
+		OutputSchema:  description.OutputSchema,
+	}
+	for _, input := range description.Inputs {
+		resp.Inputs = append(resp.Inputs, Input{
+			Name:     input.Name,
+			Kinds:    toKinds(input.GVKs),
+			Outputs:  toKinds(input.Outputs),
+			Optional: input.Optional,
+		})
+	}
+	return resp
+}
+
+func (r *DescribeResponse) isComplete() bool {
+	if r.Name == "" {
+		return false
+	}
+	if len(r.Kinds) == 0 {
+		return r.Version != "" && r.Kind != ""
+	}
+	if r.Kind != "" {
+		return false
+	}
+	for _, kind := range r.Kinds {
+		if kind.Version == "" || kind.Kind == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func toKinds(gvks []schema.GroupVersionKind) []Kind {
+	var kinds []Kind
+	for _, gvk := range gvks {
+		kinds = append(kinds, Kind{
+			Group:   gvk.Group,
+			Version: gvk.Version,
+			Kind:    gvk.Kind,
+		})
+	}
+	return kinds
+}
+
+func fromKinds(kinds []Kind) []schema.GroupVersionKind {
+	var gvks []schema.GroupVersionKind
+	for _, kind := range kinds {
+		gvks = append(gvks, schema.GroupVersionKind{
+			Group:   kind.Group,
+			Version: kind.Version,
+			Kind:    kind.Kind,
+		})
+	}
+	return gvks
+}
+
+func (r *DescribeResponse) description() *plugin.Description {
+	var specSchema []byte
+	if len(r.SpecSchema) > 0 {
+		specSchema = r.SpecSchema
+	}
+	description := &plugin.Description{
+		Name:       r.Name,
+		Version:    r.PluginVersion,
+		SpecSchema: specSchema,
+	}
+	if len(r.Kinds) == 0 {
+		description.GVK = schema.GroupVersionKind{
+			Group:   r.Group,
+			Version: r.Version,
+			Kind:    r.Kind,
+		}
+	}
+	description.GVKs = fromKinds(r.Kinds)
+	if len(r.OutputSchema) > 0 {
+		description.OutputSchema = r.OutputSchema
+	}
+	for _, input := range r.Inputs {
+		description.Inputs = append(description.Inputs, plugin.Input{
+			Name:     input.Name,
+			GVKs:     fromKinds(input.Kinds),
+			Outputs:  fromKinds(input.Outputs),
+			Optional: input.Optional,
+		})
+	}
+	return description
+}
+
+func processRequest(spec map[string]interface{}, pctx *plugin.Context) (*ProcessRequest, error) {
+	actual, err := toUnstructured(pctx.Actual)
+	if err != nil {
+		return nil, errors.Wrap(err, "actual object")
+	}
+	actualObjects, err := toUnstructuredList(pctx.ActualObjects)
+	if err != nil {
+		return nil, errors.Wrap(err, "actual objects")
+	}
+	req := &ProcessRequest{
+		Spec:          spec,
+		Namespace:     pctx.Namespace,
+		Actual:        actual,
+		ActualObjects: actualObjects,
+	}
+	if len(pctx.Dependencies) > 0 {
+		req.Dependencies = make(map[smith_v1.ResourceName]*Dependency, len(pctx.Dependencies))
+	}
+	for name, dependency := range pctx.Dependencies {
+		dep := &Dependency{
+			Spec: dependency.Spec,
+		}
+		dep.Actual, err = toUnstructured(dependency.Actual)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dependency %q", name)
+		}
+		dep.Objects, err = toUnstructuredList(dependency.Objects)
+		if err != nil {
+			return nil, errors.Wrapf(err, "objects of dependency %q", name)
+		}
+		dep.Outputs, err = toUnstructuredList(dependency.Outputs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "outputs of dependency %q", name)
+		}
+		dep.Auxiliary, err = toUnstructuredList(dependency.Auxiliary)
+		if err != nil {
+			return nil, errors.Wrapf(err, "auxiliary objects of dependency %q", name)
+		}
+		req.Dependencies[name] = dep
+	}
+	return req, nil
+}
+
+// pluginContext converts the request into a plugin.Context.
+// Objects of kinds the scheme recognizes are converted into typed objects. scheme may be nil.
+func (r *ProcessRequest) pluginContext(scheme *runtime.Scheme) (*plugin.Context, error) {
+	actual, err := fromUnstructured(r.Actual, scheme)
+	if err != nil {
+		return nil, errors.Wrap(err, "actual object")
+	}
+	actualObjects, err := fromUnstructuredList(r.ActualObjects, scheme)
+	if err != nil {
+		return nil, errors.Wrap(err, "actual objects")
+	}
+	pctx := &plugin.Context{
+		Namespace:     r.Namespace,
+		Actual:        actual,
+		ActualObjects: actualObjects,
+		Dependencies:  make(map[smith_v1.ResourceName]plugin.Dependency, len(r.Dependencies)),
+	}
+	for name, dep := range r.Dependencies {
+		dependency := plugin.Dependency{
+			Spec: dep.Spec,
+		}
+		dependency.Actual, err = fromUnstructured(dep.Actual, scheme)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dependency %q", name)
+		}
+		dependency.Objects, err = fromUnstructuredList(dep.Objects, scheme)
+		if err != nil {
+			return nil, errors.Wrapf(err, "objects of dependency %q", name)
+		}
+		dependency.Outputs, err = fromUnstructuredList(dep.Outputs, scheme)
+		if err != nil {
+			return nil, errors.Wrapf(err, "outputs of dependency %q", name)
+		}
+		dependency.Auxiliary, err = fromUnstructuredList(dep.Auxiliary, scheme)
+		if err != nil {
+			return nil, errors.Wrapf(err, "auxiliary objects of dependency %q", name)
+		}
+		pctx.Dependencies[name] = dependency
+	}
+	return pctx, nil
+}
+
+func processResponse(result plugin.ProcessResult) *ProcessResponse {
+	switch res := result.(type) {
+	case *plugin.ProcessResultSuccess:
+		object, err := toUnstructured(res.Object)
+		if err != nil {
+			return &ProcessResponse{
+				Error: errors.Wrap(err, "plugin output cannot be converted from runtime.Object").Error(),
+			}
+		}
+		objects, err := toUnstructuredList(res.Objects)
+		if err != nil {
+			return &ProcessResponse{
+				Error: errors.Wrap(err, "plugin output cannot be converted from runtime.Object").Error(),
+			}
+		}
+		return &ProcessResponse{
+			Object:  object,
+			Objects: objects,
+		}
+	case *plugin.ProcessResultFailure:
+		msg := "unknown error"
+		if res.Error != nil {
+			msg = res.Error.Error()
+		}
+		return &ProcessResponse{
+			Error:            msg,
+			IsExternalError:  res.IsExternalError,
+			IsRetriableError: res.IsRetriableError,
+		}
+	default:
+		return &ProcessResponse{
+			Error: errors.Errorf("unexpected plugin result type %q", result.StatusType()).Error(),
+		}
+	}
+}
+
+// result converts the response into a plugin.ProcessResult.
+// Plugins that produce multiple objects may legitimately return no objects.
+func (r *ProcessResponse) result(multiObject bool) plugin.ProcessResult {
+	if r.Error != "" {
+		return &plugin.ProcessResultFailure{
+			Error:            errors.New(r.Error),
+			IsExternalError:  r.IsExternalError,
+			IsRetriableError: r.IsRetriableError,
+		}
+	}
+	if r.Object == nil && !multiObject {
+		return &plugin.ProcessResultFailure{
+			Error: errors.New("plugin returned neither an object nor an error"),
+		}
+	}
+	result := &plugin.ProcessResultSuccess{
+		Object: r.Object,
+	}
+	for _, obj := range r.Objects {
+		result.Objects = append(result.Objects, obj)
+	}
+	return result
+}
+
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	return util.RuntimeToUnstructured(obj)
+}
+
+func toUnstructuredList(objs []runtime.Object) ([]*unstructured.Unstructured, error) {
+	if len(objs) == 0 {
+		return nil, nil
+	}
+	result := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+func fromUnstructured(u *unstructured.Unstructured, scheme *runtime.Scheme) (runtime.Object, error) {
+	if u == nil {
+		return nil, nil
+	}
+	gvk := u.GroupVersionKind()
+	if scheme == nil || !scheme.Recognizes(gvk) {
+		return u, nil
+	}
+	// Convert to typed object if we recognize the type, like Smith does for in-process plugins
+	obj, err := scheme.ConvertToVersion(u, gvk.GroupVersion())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return obj, nil
+}
+
+func fromUnstructuredList(us []*unstructured.Unstructured, scheme *runtime.Scheme) ([]runtime.Object, error) {
+	if len(us) == 0 {
+		return nil, nil
+	}
+	result := make([]runtime.Object, 0, len(us))
+	for _, u := range us {
+		obj, err := fromUnstructured(u, scheme)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, obj)
+	}
+	return result, nil
+}
@@ -0,0 +1,529 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	testPluginName     smith_v1.PluginName = "test"
+	testPluginChildEnv                     = "SMITH_TEST_PLUGIN_CHILD"
+	// testPluginPIDFileEnv is the file the child process writes its PID to.
+	testPluginPIDFileEnv = "SMITH_TEST_PLUGIN_PID_FILE"
+
+	childServe  = "serve"
+	childSilent = "silent"
+)
+
+var (
+	configMapGVK = core_v1.SchemeGroupVersion.WithKind("ConfigMap")
+	secretGVK    = core_v1.SchemeGroupVersion.WithKind("Secret")
+)
+
+// TestMain serves the test plugin if the test binary is started as a plugin process.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(testPluginChildEnv); mode != "" {
+		os.Exit(runTestPluginChild(mode))
+	}
+	os.Exit(m.Run())
+}
+
+func runTestPluginChild(mode string) int {
+	if pidFile := os.Getenv(testPluginPIDFileEnv); pidFile != "" {
+		if err := ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+			return 1
+		}
+	}
+	switch mode {
+	case childServe:
+		if err := ServeFromEnv(context.Background(), newTestPlugin, testScheme()); err != nil {
+			return 1
+		}
+	case childSilent:
+		// Never respond, exit once Smith closes the standard input
+		io.Copy(ioutil.Discard, os.Stdin) // nolint: errcheck, gosec
+	}
+	return 0
+}
+
+func testScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := core_v1.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+	return scheme
+}
+
+func newTestPlugin() (plugin.Plugin, error) {
+	return &testPlugin{}, nil
+}
+
+// testPlugin copies data of the ConfigMap dependency "dep" and the "key" from the spec into a ConfigMap.
+type testPlugin struct{}
+
+func (p *testPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name:       testPluginName,
+		Version:    "1.2.3",
+		GVK:        configMapGVK,
+		SpecSchema: []byte(`{"type":"object"}`),
+		Inputs: []plugin.Input{
+			{
+				Name:     "dep",
+				GVKs:     []schema.GroupVersionKind{configMapGVK},
+				Optional: true,
+			},
+		},
+		OutputSchema: []byte(`{"type":"object","required":["data"]}`),
+	}
+}
+
+func (p *testPlugin) Process(spec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	if _, ok := spec["panic"]; ok {
+		panic("panicked as requested")
+	}
+	if _, ok := spec["fail"]; ok {
+		return &plugin.ProcessResultFailure{
+			Error:           errors.New("failed as requested"),
+			IsExternalError: true,
+		}
+	}
+	data := map[string]string{
+		"key":       spec["key"].(string),
+		"namespace": pctx.Namespace,
+	}
+	if dep, ok := pctx.Dependencies["dep"]; ok {
+		// Typed object is expected because the scheme recognizes ConfigMaps
+		for k, v := range dep.Actual.(*core_v1.ConfigMap).Data {
+			data[k] = v
+		}
+	}
+	return &plugin.ProcessResultSuccess{
+		Object: &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				APIVersion: configMapGVK.GroupVersion().String(),
+				Kind:       configMapGVK.Kind,
+			},
+			Data: data,
+		},
+	}
+}
+
+func dependencyContext() *plugin.Context {
+	return &plugin.Context{
+		Namespace: "ns",
+		Dependencies: map[smith_v1.ResourceName]plugin.Dependency{
+			"dep": {
+				Actual: &core_v1.ConfigMap{
+					TypeMeta: meta_v1.TypeMeta{
+						APIVersion: configMapGVK.GroupVersion().String(),
+						Kind:       configMapGVK.Kind,
+					},
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      "dep",
+						Namespace: "ns",
+					},
+					Data: map[string]string{
+						"depKey": "depValue",
+					},
+				},
+			},
+		},
+	}
+}
+
+func assertProcessed(t *testing.T, p plugin.Plugin) {
+	result := p.Process(map[string]interface{}{"key": "value"}, dependencyContext())
+	require.IsType(t, &plugin.ProcessResultSuccess{}, result)
+	obj := result.(*plugin.ProcessResultSuccess).Object.(*unstructured.Unstructured)
+	assert.Equal(t, configMapGVK, obj.GroupVersionKind())
+	data, _, err := unstructured.NestedStringMap(obj.Object, "data")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"key":       "value",
+		"namespace": "ns",
+		"depKey":    "depValue",
+	}, data)
+}
+
+func serveOnSocket(t *testing.T, p plugin.Plugin) (string /*address*/, func()) {
+	dir, err := ioutil.TempDir("", "smith-plugin-test")
+	require.NoError(t, err)
+	address := unixScheme + filepath.Join(dir, "plugin.sock")
+	l, err := Listen(address)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ServeListener(ctx, l, p, testScheme()) // nolint: errcheck
+	}()
+	return address, func() {
+		cancel()
+		<-done
+		os.RemoveAll(dir) // nolint: errcheck
+	}
+}
+
+func TestPluginOverUnixSocket(t *testing.T) {
+	t.Parallel()
+	address, stop := serveOnSocket(t, &testPlugin{})
+	defer stop()
+
+	p, err := NewFunc(Config{Address: address})()
+	require.NoError(t, err)
+	defer p.(*Plugin).Close() // nolint: errcheck
+	assert.Equal(t, (&testPlugin{}).Describe(), p.Describe())
+	assertProcessed(t, p)
+
+	// Failures are passed through
+	result := p.Process(map[string]interface{}{"fail": true}, &plugin.Context{})
+	require.IsType(t, &plugin.ProcessResultFailure{}, result)
+	failure := result.(*plugin.ProcessResultFailure)
+	assert.EqualError(t, failure.Error, "failed as requested")
+	assert.True(t, failure.IsExternalError)
+	assert.False(t, failure.IsRetriableError)
+
+	// Panics are returned as failures and the plugin keeps serving
+	result = p.Process(map[string]interface{}{"panic": true}, &plugin.Context{})
+	require.IsType(t, &plugin.ProcessResultFailure{}, result)
+	failure = result.(*plugin.ProcessResultFailure)
+	assert.EqualError(t, failure.Error, "plugin panicked: panicked as requested")
+	assert.False(t, failure.IsRetriableError)
+	assertProcessed(t, p)
+}
+
+func childCommand(t *testing.T, mode string) ([]string, string /*pidFile*/) {
+	f, err := ioutil.TempFile("", "smith-plugin-pid")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return []string{"env", testPluginChildEnv + "=" + mode, testPluginPIDFileEnv + "=" + f.Name(), os.Args[0]}, f.Name()
+}
+
+// assertExited asserts that the process from the PID file has exited and has been reaped.
+func assertExited(t *testing.T, pidFile string) {
+	data, err := ioutil.ReadFile(pidFile)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(string(data))
+	require.NoError(t, err)
+	assert.Equal(t, syscall.ESRCH, syscall.Kill(pid, 0))
+}
+
+func TestPluginProcess(t *testing.T) {
+	t.Parallel()
+	command, pidFile := childCommand(t, childServe)
+	defer os.Remove(pidFile) // nolint: errcheck
+	p, err := NewFunc(Config{
+		Command: command,
+	})()
+	require.NoError(t, err)
+	assertProcessed(t, p)
+
+	require.NoError(t, p.(*Plugin).Close())
+	assertExited(t, pidFile)
+	result := p.Process(map[string]interface{}{"key": "value"}, &plugin.Context{})
+	require.IsType(t, &plugin.ProcessResultFailure{}, result)
+	assert.EqualError(t, result.(*plugin.ProcessResultFailure).Error, `failed to invoke plugin "test": plugin is closed`)
+}
+
+func TestPluginProcessNotHealthyIsStopped(t *testing.T) {
+	t.Parallel()
+	command, pidFile := childCommand(t, childSilent)
+	defer os.Remove(pidFile) // nolint: errcheck
+	_, err := NewFunc(Config{
+		Command:        command,
+		Timeout:        meta_v1.Duration{Duration: 100 * time.Millisecond},
+		StartupTimeout: meta_v1.Duration{Duration: time.Second},
+	})()
+	assert.EqualError(t, err, `plugin at "env" did not become healthy: context deadline exceeded`)
+	assertExited(t, pidFile)
+}
+
+// fakeServer responds to requests over a Unix socket like a plugin built from the SDK.
+// It only responds to health checks while it is healthy and never responds to process requests.
+type fakeServer struct {
+	healthy     int32 // atomic
+	connections int32 // atomic
+	closed      int32 // atomic, number of connections closed by the client
+}
+
+func (s *fakeServer) serve(t *testing.T) (string /*address*/, func()) {
+	dir, err := ioutil.TempDir("", "smith-plugin-test")
+	require.NoError(t, err)
+	address := unixScheme + filepath.Join(dir, "plugin.sock")
+	l, err := Listen(address)
+	require.NoError(t, err)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.connections, 1)
+			go s.serveConn(c)
+		}
+	}()
+	return address, func() {
+		l.Close()         // nolint: errcheck
+		os.RemoveAll(dir) // nolint: errcheck
+	}
+}
+
+func (s *fakeServer) serveConn(c net.Conn) {
+	defer c.Close() // nolint: errcheck
+	dec := json.NewDecoder(c)
+	enc := json.NewEncoder(c)
+	for {
+		var req Request
+		if err := dec.Decode(&req); err != nil {
+			atomic.AddInt32(&s.closed, 1)
+			return
+		}
+		resp := Response{ID: req.ID}
+		switch req.Method {
+		case MethodHealth:
+			if atomic.LoadInt32(&s.healthy) == 0 {
+				continue
+			}
+		case MethodDescribe:
+			resp.Result, _ = json.Marshal(describeResponse((&testPlugin{}).Describe()))
+		default:
+			continue
+		}
+		enc.Encode(&resp) // nolint: errcheck
+	}
+}
+
+func TestPluginTimeoutIsRetriable(t *testing.T) {
+	t.Parallel()
+	s := &fakeServer{healthy: 1}
+	address, stop := s.serve(t)
+	defer stop()
+
+	p, err := NewFunc(Config{
+		Address: address,
+		Timeout: meta_v1.Duration{Duration: 100 * time.Millisecond},
+	})()
+	require.NoError(t, err)
+	defer p.(*Plugin).Close() // nolint: errcheck
+	result := p.Process(map[string]interface{}{"key": "value"}, &plugin.Context{})
+	require.IsType(t, &plugin.ProcessResultFailure{}, result)
+	assert.True(t, result.(*plugin.ProcessResultFailure).IsRetriableError)
+}
+
+func TestPluginProcessIsAbandonedOnceContextIsDone(t *testing.T) {
+	t.Parallel()
+	s := &fakeServer{healthy: 1}
+	address, stop := s.serve(t)
+	defer stop()
+
+	p, err := NewFunc(Config{
+		Address: address,
+		Timeout: meta_v1.Duration{Duration: time.Minute},
+	})()
+	require.NoError(t, err)
+	defer p.(*Plugin).Close() // nolint: errcheck
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	result := p.Process(map[string]interface{}{"key": "value"}, &plugin.Context{
+		Context: ctx,
+	})
+	require.IsType(t, &plugin.ProcessResultFailure{}, result)
+	assert.True(t, result.(*plugin.ProcessResultFailure).IsRetriableError)
+}
+
+func TestPluginNotHealthy(t *testing.T) {
+	t.Parallel()
+	s := &fakeServer{}
+	address, stop := s.serve(t)
+	defer stop()
+
+	_, err := NewFunc(Config{
+		Address:        address,
+		Timeout:        meta_v1.Duration{Duration: 100 * time.Millisecond},
+		StartupTimeout: meta_v1.Duration{Duration: time.Second},
+	})()
+	assert.EqualError(t, err, `plugin at "`+address+`" did not become healthy: context deadline exceeded`)
+}
+
+func TestPluginHealthIsMonitored(t *testing.T) {
+	t.Parallel()
+	s := &fakeServer{healthy: 1}
+	address, stop := s.serve(t)
+	defer stop()
+
+	p, err := NewFunc(Config{
+		Address:             address,
+		Timeout:             meta_v1.Duration{Duration: 50 * time.Millisecond},
+		HealthCheckInterval: meta_v1.Duration{Duration: 50 * time.Millisecond},
+	})()
+	require.NoError(t, err)
+	defer p.(*Plugin).Close() // nolint: errcheck
+	assert.EqualValues(t, 1, atomic.LoadInt32(&s.connections))
+
+	// Plugin is disconnected from once it stops responding to health checks
+	atomic.StoreInt32(&s.healthy, 0)
+	require.NoError(t, wait.PollImmediate(50*time.Millisecond, 5*time.Second, func() (bool /*done*/, error) {
+		return atomic.LoadInt32(&s.closed) > 0, nil
+	}))
+	assert.Error(t, p.(*Plugin).Healthy())
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Parallel()
+	f, err := ioutil.TempFile("", "smith-plugins")
+	require.NoError(t, err)
+	defer os.Remove(f.Name()) // nolint: errcheck
+	_, err = f.WriteString(`
+plugins:
+- address: unix:///var/run/plugins/a.sock
+  timeout: 5s
+- command: ["/plugins/b", "-v"]
+`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	configs, err := LoadConfigFile(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, []Config{
+		{
+			Address: "unix:///var/run/plugins/a.sock",
+			Timeout: meta_v1.Duration{Duration: 5 * time.Second},
+		},
+		{
+			Command: []string{"/plugins/b", "-v"},
+		},
+	}, configs)
+}
+
+// multiObjectTestPlugin produces a ConfigMap per key in the spec and passes through names of actual objects.
+type multiObjectTestPlugin struct{}
+
+func (p *multiObjectTestPlugin) Describe() *plugin.Description {
+	return &plugin.Description{
+		Name: testPluginName,
+		GVKs: []schema.GroupVersionKind{configMapGVK, secretGVK},
+	}
+}
+
+func (p *multiObjectTestPlugin) Process(spec map[string]interface{}, pctx *plugin.Context) plugin.ProcessResult {
+	var objects []runtime.Object
+	for key := range spec {
+		objects = append(objects, &core_v1.ConfigMap{
+			TypeMeta: meta_v1.TypeMeta{
+				APIVersion: configMapGVK.GroupVersion().String(),
+				Kind:       configMapGVK.Kind,
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: key,
+			},
+		})
+	}
+	for _, actual := range pctx.ActualObjects {
+		objects = append(objects, &core_v1.Secret{
+			TypeMeta: meta_v1.TypeMeta{
+				APIVersion: secretGVK.GroupVersion().String(),
+				Kind:       secretGVK.Kind,
+			},
+			ObjectMeta: meta_v1.ObjectMeta{
+				// Typed object is expected because the scheme recognizes ConfigMaps
+				Name: actual.(*core_v1.ConfigMap).Name + "-secret",
+			},
+		})
+	}
+	return &plugin.ProcessResultSuccess{
+		Objects: objects,
+	}
+}
+
+func TestMultiObjectPluginOverUnixSocket(t *testing.T) {
+	t.Parallel()
+	address, stop := serveOnSocket(t, &multiObjectTestPlugin{})
+	defer stop()
+
+	p, err := NewFunc(Config{Address: address})()
+	require.NoError(t, err)
+	defer p.(*Plugin).Close() // nolint: errcheck
+	assert.Equal(t, (&multiObjectTestPlugin{}).Describe(), p.Describe())
+
+	result := p.Process(map[string]interface{}{"a": true}, &plugin.Context{
+		ActualObjects: []runtime.Object{
+			&core_v1.ConfigMap{
+				TypeMeta: meta_v1.TypeMeta{
+					APIVersion: configMapGVK.GroupVersion().String(),
+					Kind:       configMapGVK.Kind,
+				},
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name: "b",
+				},
+			},
+		},
+	})
+	require.IsType(t, &plugin.ProcessResultSuccess{}, result)
+	objects := result.(*plugin.ProcessResultSuccess).Objects
+	require.Len(t, objects, 2)
+	assert.Equal(t, configMapGVK, objects[0].GetObjectKind().GroupVersionKind())
+	assert.Equal(t, "a", objects[0].(*unstructured.Unstructured).GetName())
+	assert.Equal(t, secretGVK, objects[1].GetObjectKind().GroupVersionKind())
+	assert.Equal(t, "b-secret", objects[1].(*unstructured.Unstructured).GetName())
+
+	// No objects is a valid result
+	result = p.Process(map[string]interface{}{}, &plugin.Context{})
+	require.IsType(t, &plugin.ProcessResultSuccess{}, result)
+	assert.Empty(t, result.(*plugin.ProcessResultSuccess).Objects)
+}
+
+// lookupTestPlugin declares lookups which cannot be served out of process.
+type lookupTestPlugin struct {
+	testPlugin
+}
+
+func (p *lookupTestPlugin) Describe() *plugin.Description {
+	description := p.testPlugin.Describe()
+	description.Lookups = []plugin.Lookup{
+		{
+			GVK:   configMapGVK,
+			Names: []string{"platform-config"},
+		},
+	}
+	return description
+}
+
+func TestPluginWithLookupsIsNotServed(t *testing.T) {
+	t.Parallel()
+	r, w := io.Pipe()
+	defer r.Close() // nolint: errcheck
+	defer w.Close() // nolint: errcheck
+	err := Serve(context.Background(), r, w, &lookupTestPlugin{}, testScheme())
+	assert.EqualError(t, err, `plugin "test" declares lookups which are not supported by out-of-process plugins`)
+
+	dir, err := ioutil.TempDir("", "smith-plugin-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	l, err := Listen(unixScheme + filepath.Join(dir, "plugin.sock"))
+	require.NoError(t, err)
+	err = ServeListener(context.Background(), l, &lookupTestPlugin{}, testScheme())
+	assert.EqualError(t, err, `plugin "test" declares lookups which are not supported by out-of-process plugins`)
+}
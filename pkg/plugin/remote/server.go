@@ -0,0 +1,205 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/atlassian/smith/pkg/plugin"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type handler struct {
+	plugin plugin.Plugin
+	scheme *runtime.Scheme
+}
+
+func (h *handler) handle(ctx context.Context, req *Request) (interface{}, error) {
+	switch req.Method {
+	case MethodHealth:
+		return nil, nil
+	case MethodDescribe:
+		return describeResponse(h.plugin.Describe()), nil
+	case MethodProcess:
+		var params ProcessRequest
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, errors.Wrap(err, "failed to decode request")
+		}
+		pctx, err := params.pluginContext(h.scheme)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode request")
+		}
+		pctx.Context = ctx
+		return processResponse(h.plugin.Process(params.Spec, pctx)), nil
+	default:
+		return nil, errors.Errorf("unknown method %q", req.Method)
+	}
+}
+
+// handleRecover handles the request like handle does but recovers from panics of the plugin so that a single
+// request cannot crash the plugin process. A panic while processing is returned as a failure ProcessResponse.
+func (h *handler) handleRecover(ctx context.Context, req *Request) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "Plugin panicked while handling %q request: %v\n%s", req.Method, r, debug.Stack()) // nolint: errcheck, gosec
+			panicErr := errors.Errorf("plugin panicked: %v", r)
+			if req.Method == MethodProcess {
+				result, err = &ProcessResponse{
+					Error: panicErr.Error(),
+				}, nil
+			} else {
+				result, err = nil, panicErr
+			}
+		}
+	}()
+	return h.handle(ctx, req)
+}
+
+// checkServable returns an error if the plugin cannot be served out of process.
+// Lookups are not supported by the protocol so plugins that declare them are rejected rather than
+// being served with a nil Context.Lookup.
+func checkServable(p plugin.Plugin) error {
+	description := p.Describe()
+	if len(description.Lookups) > 0 {
+		return errors.Errorf("plugin %q declares lookups which are not supported by out-of-process plugins", description.Name)
+	}
+	return nil
+}
+
+// Serve serves the plugin over a stream until the context is done or the stream is closed.
+// scheme is optional. If set, objects of kinds it recognizes are passed to the plugin as typed objects,
+// like Smith does for in-process plugins. Otherwise all objects are *unstructured.Unstructured.
+// Plugins that declare lookups cannot be served.
+func Serve(ctx context.Context, r io.Reader, w io.Writer, p plugin.Plugin, scheme *runtime.Scheme) error {
+	if err := checkServable(p); err != nil {
+		return err
+	}
+	h := &handler{
+		plugin: p,
+		scheme: scheme,
+	}
+	reqs := make(chan *Request)
+	errCh := make(chan error, 1)
+	go func() {
+		dec := json.NewDecoder(r)
+		for {
+			var req Request
+			if err := dec.Decode(&req); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				errCh <- errors.Wrap(err, "failed to read request")
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case reqs <- &req:
+			}
+		}
+	}()
+	var writeLock sync.Mutex
+	enc := json.NewEncoder(w)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errCh:
+			return err
+		case req := <-reqs:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp := Response{
+					ID: req.ID,
+				}
+				result, err := h.handleRecover(ctx, req)
+				if err == nil && result != nil {
+					resp.Result, err = json.Marshal(result)
+				}
+				if err != nil {
+					resp.Error = err.Error()
+				}
+				writeLock.Lock()
+				defer writeLock.Unlock()
+				enc.Encode(&resp) // nolint: errcheck, gosec
+			}()
+		}
+	}
+}
+
+// Listen listens on an address in the format of Config.Address.
+// A stale Unix socket left by a previous process is removed.
+func Listen(address string) (net.Listener, error) {
+	if !strings.HasPrefix(address, unixScheme) {
+		return nil, errors.Errorf("unsupported plugin address %q", address)
+	}
+	socket := strings.TrimPrefix(address, unixScheme)
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to remove stale socket %q", socket)
+	}
+	l, err := net.Listen("unix", socket)
+	return l, errors.WithStack(err)
+}
+
+// ServeListener serves the plugin on each connection accepted from the listener until the context is done.
+// See Serve for the meaning of scheme. The listener is closed if the plugin cannot be served.
+func ServeListener(ctx context.Context, l net.Listener, p plugin.Plugin, scheme *runtime.Scheme) error {
+	if err := checkServable(p); err != nil {
+		l.Close() // nolint: errcheck, gosec
+		return err
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	go func() {
+		<-ctx.Done()
+		l.Close() // nolint: errcheck, gosec
+	}()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.WithStack(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer c.Close()             // nolint: errcheck
+			Serve(ctx, c, c, p, scheme) // nolint: errcheck, gosec
+		}()
+	}
+}
+
+// ServeFromEnv instantiates the plugin and serves it until the context is done. The plugin is served on the Unix
+// socket from the SMITH_PLUGIN_ADDRESS environment variable if it is set, e.g. in a sidecar container. Otherwise it
+// is served on the standard input and output, as expected by Smith for plugins it starts, and returns once the
+// standard input is closed. Plugins served on the standard output must log to the standard error.
+// Existing plugins can be served out of process by calling it from main():
+//
+//	err := remote.ServeFromEnv(ctx, myplugin.New, scheme)
+func ServeFromEnv(ctx context.Context, newPlugin plugin.NewFunc, scheme *runtime.Scheme) error {
+	p, err := newPlugin()
+	if err != nil {
+		return errors.Wrap(err, "failed to instantiate plugin")
+	}
+	address := os.Getenv(AddressEnvVar)
+	if address == "" {
+		return Serve(ctx, os.Stdin, os.Stdout, p, scheme)
+	}
+	l, err := Listen(address)
+	if err != nil {
+		return err
+	}
+	return ServeListener(ctx, l, p, scheme)
+}
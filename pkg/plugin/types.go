@@ -1,10 +1,8 @@
 package plugin
 
-// TODO refactor so we have more detail on plugin?
-// (i.e. name, output types, so we can fail early if bundle is incorrect)
-// OR have plugin validate itself
-
 import (
+	"context"
+
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -31,20 +29,92 @@ type Plugin interface {
 
 type Description struct {
 	Name smith_v1.PluginName
-	GVK  schema.GroupVersionKind
-	// gojsonschema supported schema for the spec (first argument of Process)
+	// Version is the version of the plugin, e.g. a release or a commit it was built from.
+	// It is reported in PluginStatus of Bundles. Optional.
+	Version string
+	// GVK is the kind of the object the plugin produces.
+	GVK schema.GroupVersionKind
+	// GVKs are the kinds of objects a plugin that produces multiple objects may return.
+	// Either GVK or GVKs must be set. Plugins that set GVKs return ProcessResultSuccess with Objects set.
+	GVKs []schema.GroupVersionKind
+	// gojsonschema supported schema for the spec (first argument of Process).
+	// Default values from the schema are set in the spec before it is validated and passed to Process.
 	SpecSchema []byte
+	// Inputs are the dependencies the plugin expects. Bundles that do not satisfy them are rejected
+	// before the plugin is invoked.
+	Inputs []Input
+	// gojsonschema supported schema for the produced object(s). Output that does not match the schema
+	// is not created.
+	OutputSchema []byte
+	// Lookups are the objects the plugin may look up via Context.Lookup.
+	Lookups []Lookup
+}
+
+// Lookup declares objects a plugin may look up.
+type Lookup struct {
+	// GVK is the kind of the objects.
+	GVK schema.GroupVersionKind
+	// Namespace is the namespace of the objects. The namespace of the Bundle if empty.
+	// Ignored for cluster-scoped kinds.
+	Namespace string
+	// Names are the names of the objects. Any object of the kind in the namespace may be looked up if empty.
+	Names []string
+}
+
+// ObjectLookup provides read-only access to objects in the cluster.
+type ObjectLookup interface {
+	// Get returns a copy of the object or nil if it does not exist.
+	// Only objects declared in Description.Lookups can be looked up. namespace must be empty for
+	// cluster-scoped objects. Plugins must not depend on anything else than the returned object so
+	// that they stay deterministic.
+	Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, error)
+}
+
+// Input is a dependency a plugin expects. It is matched with a reference of the resource by name.
+type Input struct {
+	// Name is the name of the reference to the dependency.
+	Name smith_v1.ReferenceName
+	// GVKs are the kinds the dependency may be of. Any kind is accepted if empty.
+	GVKs []schema.GroupVersionKind
+	// Outputs are the kinds of output objects the dependency must produce (e.g. a Secret of a ServiceBinding).
+	// See Dependency.Outputs.
+	Outputs []schema.GroupVersionKind
+	// Optional is true if the reference can be omitted.
+	Optional bool
+}
+
+// IsMultiObject returns true if the plugin produces multiple objects.
+func (d *Description) IsMultiObject() bool {
+	return len(d.GVKs) > 0
+}
+
+// OutputGVKs returns kinds of objects the plugin produces.
+func (d *Description) OutputGVKs() []schema.GroupVersionKind {
+	if d.IsMultiObject() {
+		return d.GVKs
+	}
+	return []schema.GroupVersionKind{d.GVK}
 }
 
 // Context contains contextual information for the Process() call.
 type Context struct {
+	// Context is done when the invocation times out or Smith is shutting down. Plugins that do I/O
+	// should return once it is done. Always set by Smith.
+	Context context.Context
 	// Namespace is the namespace where the returned object will be created.
 	Namespace string
 	// Actual is the actual object that will be updated if it exists already.
 	// nil if the object does not exist.
 	Actual runtime.Object
+	// ActualObjects are the actual objects produced by a previous invocation of a plugin that produces
+	// multiple objects. Objects that do not exist are not included. Not set for other plugins.
+	ActualObjects []runtime.Object
 	// Dependencies is the map from dependency name to a description of that dependency.
 	Dependencies map[smith_v1.ResourceName]Dependency
+	// Lookup provides access to objects declared in Description.Lookups.
+	// Objects that were looked up are watched and the Bundle is re-processed when they change.
+	// nil if the plugin did not declare any lookups.
+	Lookup ObjectLookup
 }
 
 // Dependency contains information about a dependency of a resource that a plugin is processing.
@@ -52,7 +122,10 @@ type Dependency struct {
 	// Spec is the specification of the resource as specified in the Bundle.
 	Spec smith_v1.Resource
 	// Actual is the actual dependency object.
+	// nil if the dependency is a plugin that produces multiple objects, see Objects.
 	Actual runtime.Object
+	// Objects are the actual objects of a dependency that is a plugin that produces multiple objects.
+	Objects []runtime.Object
 	// Outputs are objects produced by the actual object.
 	Outputs []runtime.Object
 	// Auxiliary are objects that somehow relate to the actual object.
@@ -67,6 +140,10 @@ type ProcessResult interface {
 type ProcessResultSuccess struct {
 	// Object is the object that should be created/updated.
 	Object runtime.Object
+	// Objects are the objects that should be created/updated by a plugin that produces multiple objects.
+	// Each object must have a name that is unique among objects of its kind.
+	// Objects that were produced previously but are not returned anymore are deleted.
+	Objects []runtime.Object
 }
 
 type ProcessResultFailure struct {
@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Policy is a BundlePolicy or a ClusterBundlePolicy.
+type Policy struct {
+	// Name identifies the policy in error messages.
+	Name string
+	Spec *smith_v1.BundlePolicySpec
+}
+
+func FromBundlePolicy(p *smith_v1.BundlePolicy) Policy {
+	return Policy{
+		Name: fmt.Sprintf("%s %q", smith_v1.BundlePolicyResourceKind, p.Namespace+"/"+p.Name),
+		Spec: &p.Spec,
+	}
+}
+
+func FromClusterBundlePolicy(p *smith_v1.ClusterBundlePolicy) Policy {
+	return Policy{
+		Name: fmt.Sprintf("%s %q", smith_v1.ClusterBundlePolicyResourceKind, p.Name),
+		Spec: &p.Spec,
+	}
+}
+
+// ClusterScopedAllowed returns true if at least one of the policies allows cluster-scoped objects.
+func ClusterScopedAllowed(policies []Policy) bool {
+	for i := range policies {
+		if policies[i].Spec.AllowClusterScoped {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckResourceCount returns an error if a Bundle with the number of resources violates the policy.
+func (p *Policy) CheckResourceCount(count int) error {
+	if p.Spec.MaxResources != nil && int64(count) > int64(*p.Spec.MaxResources) {
+		return errors.Errorf("Bundle has %d resources but %s allows at most %d", count, p.Name, *p.Spec.MaxResources)
+	}
+	return nil
+}
+
+// CheckPlugin returns an error if the plugin is not allowed by the policy.
+func (p *Policy) CheckPlugin(name smith_v1.PluginName) error {
+	if len(p.Spec.AllowedPlugins) == 0 {
+		return nil
+	}
+	for _, allowed := range p.Spec.AllowedPlugins {
+		if allowed == name {
+			return nil
+		}
+	}
+	return errors.Errorf("plugin %q is not allowed by %s", name, p.Name)
+}
+
+// CheckKind returns an error if the kind of the object is not allowed by the policy.
+func (p *Policy) CheckKind(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	for _, denied := range p.Spec.DeniedKinds {
+		if denied.Matches(gvk) {
+			return errors.Errorf("%s is denied by %s", gvk, p.Name)
+		}
+	}
+	if len(p.Spec.AllowedKinds) == 0 {
+		return nil
+	}
+	for _, allowed := range p.Spec.AllowedKinds {
+		if allowed.Matches(gvk) {
+			return nil
+		}
+	}
+	return errors.Errorf("%s is not allowed by %s", gvk, p.Name)
+}
+
+// CheckFields returns an error if the object sets a field forbidden by the policy.
+func (p *Policy) CheckFields(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	for _, forbidden := range p.Spec.ForbiddenFields {
+		if !selected(forbidden.Kinds, gvk) {
+			continue
+		}
+		set, err := isFieldSet(obj, &forbidden)
+		if err != nil {
+			return errors.Wrapf(err, "invalid forbidden field %q in %s", forbidden.Path, p.Name)
+		}
+		if !set {
+			continue
+		}
+		if forbidden.Value == nil {
+			return errors.Errorf("field %s is forbidden by %s", forbidden.Path, p.Name)
+		}
+		value, err := json.Marshal(forbidden.Value)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.Errorf("field %s must not be set to %s by %s", forbidden.Path, value, p.Name)
+	}
+	return nil
+}
+
+// CheckObject returns an error if the object violates the policy.
+func (p *Policy) CheckObject(obj *unstructured.Unstructured) error {
+	if err := p.CheckKind(obj); err != nil {
+		return err
+	}
+	return p.CheckFields(obj)
+}
+
+func selected(kinds []smith_v1.KindSelector, gvk schema.GroupVersionKind) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, kind := range kinds {
+		if kind.Matches(gvk) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFieldSet returns true if the field is set in the object. If the value is specified in the forbidden field,
+// the field is considered set only if it is set to that value. If the path matches multiple fields
+// (e.g. using a wildcard), it is enough for one of them to match.
+func isFieldSet(obj *unstructured.Unstructured, forbidden *smith_v1.ForbiddenField) (bool, error) {
+	j := jsonpath.New("ForbiddenField")
+	j.AllowMissingKeys(true)
+	if err := j.Parse(forbidden.Path); err != nil {
+		return false, errors.WithStack(err)
+	}
+	results, err := j.FindResults(obj.Object)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	var forbiddenValue []byte
+	if forbidden.Value != nil {
+		forbiddenValue, err = json.Marshal(forbidden.Value)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+	}
+	for _, result := range results {
+		for _, value := range result {
+			if !value.IsValid() || !value.CanInterface() {
+				continue
+			}
+			if forbiddenValue == nil {
+				return true, nil
+			}
+			// Values are compared using their JSON representation because numbers may be represented
+			// using different Go types
+			actualValue, err := json.Marshal(value.Interface())
+			if err != nil {
+				return false, errors.WithStack(err)
+			}
+			if bytes.Equal(forbiddenValue, actualValue) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,253 @@
+package policy
+
+import (
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func deployment(podSpec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name": "d",
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": podSpec,
+				},
+			},
+		},
+	}
+}
+
+func configMap() *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name": "cm",
+			},
+		},
+	}
+}
+
+func clusterPolicy(spec smith_v1.BundlePolicySpec) Policy {
+	return FromClusterBundlePolicy(&smith_v1.ClusterBundlePolicy{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "p",
+		},
+		Spec: spec,
+	})
+}
+
+func TestCheckKind(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name   string
+		spec   smith_v1.BundlePolicySpec
+		obj    *unstructured.Unstructured
+		errMsg string
+	}{
+		{
+			name: "no restrictions",
+			obj:  configMap(),
+		},
+		{
+			name: "allowed",
+			spec: smith_v1.BundlePolicySpec{
+				AllowedKinds: []smith_v1.KindSelector{
+					{Group: "", Kind: "ConfigMap"},
+				},
+			},
+			obj: configMap(),
+		},
+		{
+			name: "not allowed",
+			spec: smith_v1.BundlePolicySpec{
+				AllowedKinds: []smith_v1.KindSelector{
+					{Group: "", Kind: "ConfigMap"},
+				},
+			},
+			obj:    deployment(nil),
+			errMsg: `apps/v1, Kind=Deployment is not allowed by ClusterBundlePolicy "p"`,
+		},
+		{
+			name: "allowed by wildcard",
+			spec: smith_v1.BundlePolicySpec{
+				AllowedKinds: []smith_v1.KindSelector{
+					{Group: "apps", Kind: smith_v1.KindSelectorWildcard},
+				},
+			},
+			obj: deployment(nil),
+		},
+		{
+			name: "version mismatch",
+			spec: smith_v1.BundlePolicySpec{
+				AllowedKinds: []smith_v1.KindSelector{
+					{Group: "apps", Version: "v1beta2", Kind: "Deployment"},
+				},
+			},
+			obj:    deployment(nil),
+			errMsg: `apps/v1, Kind=Deployment is not allowed by ClusterBundlePolicy "p"`,
+		},
+		{
+			name: "denied takes precedence",
+			spec: smith_v1.BundlePolicySpec{
+				AllowedKinds: []smith_v1.KindSelector{
+					{Group: smith_v1.KindSelectorWildcard, Kind: smith_v1.KindSelectorWildcard},
+				},
+				DeniedKinds: []smith_v1.KindSelector{
+					{Group: "apps", Kind: "Deployment"},
+				},
+			},
+			obj:    deployment(nil),
+			errMsg: `apps/v1, Kind=Deployment is denied by ClusterBundlePolicy "p"`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p := clusterPolicy(tc.spec)
+			err := p.CheckKind(tc.obj)
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestCheckFields(t *testing.T) {
+	t.Parallel()
+	p := clusterPolicy(smith_v1.BundlePolicySpec{
+		ForbiddenFields: []smith_v1.ForbiddenField{
+			{
+				Kinds: []smith_v1.KindSelector{
+					{Group: "apps", Kind: "Deployment"},
+				},
+				Path: "{.spec.template.spec.hostNetwork}",
+			},
+			{
+				Path:  "{.spec.template.spec.containers[*].securityContext.privileged}",
+				Value: true,
+			},
+			{
+				Path:  "{.spec.template.spec.priority}",
+				Value: int64(1000),
+			},
+		},
+	})
+	testCases := []struct {
+		name   string
+		obj    *unstructured.Unstructured
+		errMsg string
+	}{
+		{
+			name: "no fields set",
+			obj: deployment(map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{
+						"name": "c",
+					},
+				},
+			}),
+		},
+		{
+			name: "other kind",
+			obj:  configMap(),
+		},
+		{
+			name: "forbidden field set",
+			obj: deployment(map[string]interface{}{
+				"hostNetwork": false,
+			}),
+			errMsg: `field {.spec.template.spec.hostNetwork} is forbidden by ClusterBundlePolicy "p"`,
+		},
+		{
+			name: "allowed value",
+			obj: deployment(map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{
+						"name": "c",
+						"securityContext": map[string]interface{}{
+							"privileged": false,
+						},
+					},
+				},
+			}),
+		},
+		{
+			name: "forbidden value in one of the elements",
+			obj: deployment(map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{
+						"name": "c1",
+					},
+					map[string]interface{}{
+						"name": "c2",
+						"securityContext": map[string]interface{}{
+							"privileged": true,
+						},
+					},
+				},
+			}),
+			errMsg: `field {.spec.template.spec.containers[*].securityContext.privileged} must not be set to true by ClusterBundlePolicy "p"`,
+		},
+		{
+			name: "numbers of different types",
+			obj: deployment(map[string]interface{}{
+				"priority": float64(1000),
+			}),
+			errMsg: `field {.spec.template.spec.priority} must not be set to 1000 by ClusterBundlePolicy "p"`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := p.CheckFields(tc.obj)
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestCheckResourceCountAndPlugin(t *testing.T) {
+	t.Parallel()
+	maxResources := int32(2)
+	p := clusterPolicy(smith_v1.BundlePolicySpec{
+		MaxResources:   &maxResources,
+		AllowedPlugins: []smith_v1.PluginName{"a"},
+	})
+	assert.NoError(t, p.CheckResourceCount(2))
+	assert.EqualError(t, p.CheckResourceCount(3), `Bundle has 3 resources but ClusterBundlePolicy "p" allows at most 2`)
+	assert.NoError(t, p.CheckPlugin("a"))
+	assert.EqualError(t, p.CheckPlugin("b"), `plugin "b" is not allowed by ClusterBundlePolicy "p"`)
+
+	unrestricted := clusterPolicy(smith_v1.BundlePolicySpec{})
+	assert.NoError(t, unrestricted.CheckResourceCount(100))
+	assert.NoError(t, unrestricted.CheckPlugin("b"))
+}
+
+func TestClusterScopedAllowed(t *testing.T) {
+	t.Parallel()
+	unrestricted := clusterPolicy(smith_v1.BundlePolicySpec{})
+	allowing := clusterPolicy(smith_v1.BundlePolicySpec{
+		AllowClusterScoped: true,
+	})
+	assert.False(t, ClusterScopedAllowed(nil))
+	assert.False(t, ClusterScopedAllowed([]Policy{unrestricted}))
+	assert.True(t, ClusterScopedAllowed([]Policy{unrestricted, allowing}))
+}
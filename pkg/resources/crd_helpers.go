@@ -11,13 +11,14 @@ import (
 	"github.com/atlassian/smith/pkg/util"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiExtClientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
-	apiext_lst_v1b1 "k8s.io/apiextensions-apiserver/pkg/client/listers/apiextensions/v1beta1"
+	apiext_lst_v1 "k8s.io/apiextensions-apiserver/pkg/client/listers/apiextensions/v1"
 	api_errors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/version"
 	"sigs.k8s.io/yaml"
 )
 
@@ -47,7 +48,7 @@ func PrintCleanedObject(sink io.Writer, format string, obj runtime.Object) error
 	}
 }
 
-func EnsureCrdExistsAndIsEstablished(ctx context.Context, logger *zap.Logger, apiExtClient apiExtClientset.Interface, crdLister apiext_lst_v1b1.CustomResourceDefinitionLister, crd *apiext_v1b1.CustomResourceDefinition) error {
+func EnsureCrdExistsAndIsEstablished(ctx context.Context, logger *zap.Logger, apiExtClient apiExtClientset.Interface, crdLister apiext_lst_v1.CustomResourceDefinitionLister, crd *apiext_v1.CustomResourceDefinition) error {
 	err := EnsureCrdExists(ctx, logger, apiExtClient, crdLister, crd)
 	if err != nil {
 		return err
@@ -56,7 +57,7 @@ func EnsureCrdExistsAndIsEstablished(ctx context.Context, logger *zap.Logger, ap
 	return WaitForCrdToBecomeEstablished(ctx, crdLister, crd)
 }
 
-func EnsureCrdExists(ctx context.Context, logger *zap.Logger, apiExtClient apiExtClientset.Interface, crdLister apiext_lst_v1b1.CustomResourceDefinitionLister, crd *apiext_v1b1.CustomResourceDefinition) error {
+func EnsureCrdExists(ctx context.Context, logger *zap.Logger, apiExtClient apiExtClientset.Interface, crdLister apiext_lst_v1.CustomResourceDefinitionLister, crd *apiext_v1.CustomResourceDefinition) error {
 	for {
 		obj, err := crdLister.Get(crd.Name)
 		notFound := api_errors.IsNotFound(err)
@@ -65,7 +66,7 @@ func EnsureCrdExists(ctx context.Context, logger *zap.Logger, apiExtClient apiEx
 		}
 		if notFound {
 			logger.Info("Creating CustomResourceDefinition", ctrlLogz.Object(crd))
-			_, err = apiExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Create(crd)
+			_, err = apiExtClient.ApiextensionsV1().CustomResourceDefinitions().Create(crd)
 			if err == nil {
 				logger.Info("CustomResourceDefinition created", ctrlLogz.Object(crd))
 				return nil
@@ -84,8 +85,8 @@ func EnsureCrdExists(ctx context.Context, logger *zap.Logger, apiExtClient apiEx
 			obj.Annotations = crd.Annotations
 			obj.Labels = crd.Labels
 			// TODO erasing the status is only necessary because there is no support for generation/observedGeneration at the moment
-			obj.Status = apiext_v1b1.CustomResourceDefinitionStatus{}
-			_, err = apiExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Update(obj) // This is a CAS
+			obj.Status = apiext_v1.CustomResourceDefinitionStatus{}
+			_, err = apiExtClient.ApiextensionsV1().CustomResourceDefinitions().Update(obj) // This is a CAS
 			if err == nil {
 				logger.Info("CustomResourceDefinition updated", ctrlLogz.Object(crd))
 				return nil
@@ -102,7 +103,7 @@ func EnsureCrdExists(ctx context.Context, logger *zap.Logger, apiExtClient apiEx
 	}
 }
 
-func WaitForCrdToBecomeEstablished(ctx context.Context, crdLister apiext_lst_v1b1.CustomResourceDefinitionLister, crd *apiext_v1b1.CustomResourceDefinition) error {
+func WaitForCrdToBecomeEstablished(ctx context.Context, crdLister apiext_lst_v1.CustomResourceDefinitionLister, crd *apiext_v1.CustomResourceDefinition) error {
 	return wait.PollUntil(100*time.Millisecond, func() (done bool, err error) {
 		obj, err := crdLister.Get(crd.Name)
 		if err != nil {
@@ -115,12 +116,12 @@ func WaitForCrdToBecomeEstablished(ctx context.Context, crdLister apiext_lst_v1b
 		established := false
 		for _, cond := range obj.Status.Conditions {
 			switch cond.Type {
-			case apiext_v1b1.Established:
-				if cond.Status == apiext_v1b1.ConditionTrue {
+			case apiext_v1.Established:
+				if cond.Status == apiext_v1.ConditionTrue {
 					established = true
 				}
-			case apiext_v1b1.NamesAccepted:
-				if cond.Status == apiext_v1b1.ConditionFalse {
+			case apiext_v1.NamesAccepted:
+				if cond.Status == apiext_v1.ConditionFalse {
 					return false, errors.Errorf("failed to create CRD %s: name conflict: %s", crd.Name, cond.Reason)
 				}
 			}
@@ -130,12 +131,12 @@ func WaitForCrdToBecomeEstablished(ctx context.Context, crdLister apiext_lst_v1b
 }
 
 // IsCrdConditionTrue indicates if the condition is present and strictly true
-func IsCrdConditionTrue(crd *apiext_v1b1.CustomResourceDefinition, conditionType apiext_v1b1.CustomResourceDefinitionConditionType) bool {
-	return IsCrdConditionPresentAndEqual(crd, conditionType, apiext_v1b1.ConditionTrue)
+func IsCrdConditionTrue(crd *apiext_v1.CustomResourceDefinition, conditionType apiext_v1.CustomResourceDefinitionConditionType) bool {
+	return IsCrdConditionPresentAndEqual(crd, conditionType, apiext_v1.ConditionTrue)
 }
 
 // IsCrdConditionPresentAndEqual indicates if the condition is present and equal to the arg
-func IsCrdConditionPresentAndEqual(crd *apiext_v1b1.CustomResourceDefinition, conditionType apiext_v1b1.CustomResourceDefinitionConditionType, status apiext_v1b1.ConditionStatus) bool {
+func IsCrdConditionPresentAndEqual(crd *apiext_v1.CustomResourceDefinition, conditionType apiext_v1.CustomResourceDefinitionConditionType, status apiext_v1.ConditionStatus) bool {
 	for _, condition := range crd.Status.Conditions {
 		if condition.Type == conditionType {
 			return condition.Status == status
@@ -144,12 +145,42 @@ func IsCrdConditionPresentAndEqual(crd *apiext_v1b1.CustomResourceDefinition, co
 	return false
 }
 
-func IsEqualCrd(a, b *apiext_v1b1.CustomResourceDefinition) bool {
+// CrdWatchVersion returns the version of the CRD that custom resources should be watched with.
+// This is the storage version if it is served, otherwise the preferred served version (same ordering
+// as in API discovery). Returns an empty string if no version is served.
+func CrdWatchVersion(crd *apiext_v1.CustomResourceDefinition) string {
+	var preferred string
+	for _, ver := range crd.Spec.Versions {
+		if !ver.Served {
+			continue
+		}
+		if ver.Storage {
+			return ver.Name
+		}
+		if preferred == "" || version.CompareKubeAwareVersionStrings(ver.Name, preferred) > 0 {
+			preferred = ver.Name
+		}
+	}
+	return preferred
+}
+
+// CrdServedVersions returns names of all served versions of the CRD.
+func CrdServedVersions(crd *apiext_v1.CustomResourceDefinition) []string {
+	versions := make([]string, 0, len(crd.Spec.Versions))
+	for _, ver := range crd.Spec.Versions {
+		if ver.Served {
+			versions = append(versions, ver.Name)
+		}
+	}
+	return versions
+}
+
+func IsEqualCrd(a, b *apiext_v1.CustomResourceDefinition) bool {
 	a = a.DeepCopy()
 	b = b.DeepCopy()
 
-	apiext_v1b1.SetDefaults_CustomResourceDefinitionSpec(&a.Spec)
-	apiext_v1b1.SetDefaults_CustomResourceDefinitionSpec(&b.Spec)
+	apiext_v1.SetDefaults_CustomResourceDefinitionSpec(&a.Spec)
+	apiext_v1.SetDefaults_CustomResourceDefinitionSpec(&b.Spec)
 
 	// Ignoring labels
 	as := a.Spec
@@ -157,30 +188,22 @@ func IsEqualCrd(a, b *apiext_v1b1.CustomResourceDefinition) bool {
 	return as.Group == bs.Group &&
 		isEqualCrdNames(as.Names, bs.Names) &&
 		as.Scope == bs.Scope &&
-		isEqualValidation(as.Validation, bs.Validation) &&
-		isEqualSubresources(as.Subresources, bs.Subresources) &&
 		isEqualVersions(as.Versions, bs.Versions) &&
-		isEqualAdditionalPrinterColumns(as.AdditionalPrinterColumns, bs.AdditionalPrinterColumns) &&
+		isEqualConversion(as.Conversion, bs.Conversion) &&
+		as.PreserveUnknownFields == bs.PreserveUnknownFields &&
 		isEqualAnnotations(a.Annotations, b.Annotations)
 }
 
-func isEqualCrdNames(a, b apiext_v1b1.CustomResourceDefinitionNames) bool {
-	return reflect.DeepEqual(a, b)
-}
-
-func isEqualValidation(a, b *apiext_v1b1.CustomResourceValidation) bool {
-	return reflect.DeepEqual(a, b)
-}
-
-func isEqualSubresources(a, b *apiext_v1b1.CustomResourceSubresources) bool {
+func isEqualCrdNames(a, b apiext_v1.CustomResourceDefinitionNames) bool {
 	return reflect.DeepEqual(a, b)
 }
 
-func isEqualVersions(a, b []apiext_v1b1.CustomResourceDefinitionVersion) bool {
+// isEqualVersions compares versions including their schemas, subresources and printer columns.
+func isEqualVersions(a, b []apiext_v1.CustomResourceDefinitionVersion) bool {
 	return reflect.DeepEqual(a, b)
 }
 
-func isEqualAdditionalPrinterColumns(a, b []apiext_v1b1.CustomResourceColumnDefinition) bool {
+func isEqualConversion(a, b *apiext_v1.CustomResourceConversion) bool {
 	return reflect.DeepEqual(a, b)
 }
 
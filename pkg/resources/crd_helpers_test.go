@@ -0,0 +1,55 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestCrdWatchVersion(t *testing.T) {
+	t.Parallel()
+	testcases := []struct {
+		name     string
+		versions []apiext_v1.CustomResourceDefinitionVersion
+		expected string
+	}{
+		{
+			name: "storage version is served",
+			versions: []apiext_v1.CustomResourceDefinitionVersion{
+				{Name: "v1beta1", Served: true},
+				{Name: "v1alpha1", Served: true, Storage: true},
+			},
+			expected: "v1alpha1",
+		},
+		{
+			name: "storage version is not served",
+			versions: []apiext_v1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true},
+				{Name: "v1", Served: false, Storage: true},
+				{Name: "v1beta2", Served: true},
+				{Name: "v1beta1", Served: true},
+			},
+			expected: "v1beta2",
+		},
+		{
+			name: "no versions are served",
+			versions: []apiext_v1.CustomResourceDefinitionVersion{
+				{Name: "v1", Served: false, Storage: true},
+			},
+			expected: "",
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			crd := &apiext_v1.CustomResourceDefinition{
+				Spec: apiext_v1.CustomResourceDefinitionSpec{
+					Versions: tc.versions,
+				},
+			}
+			assert.Equal(t, tc.expected, CrdWatchVersion(crd))
+		})
+	}
+}
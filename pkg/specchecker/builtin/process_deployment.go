@@ -3,6 +3,7 @@ package builtin
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"hash"
 	"strconv"
 	"strings"
@@ -23,6 +24,18 @@ const (
 	// LastAppliedReplicasAnnotation is the name of annotation which stores last applied replicas for deployment
 	LastAppliedReplicasAnnotation = smith.Domain + "/LastAppliedReplicas"
 	EnvRefHashAnnotation          = smith.Domain + "/envRefHash"
+
+	// RollbackPolicyAnnotation is the name of annotation which defines what should happen when a Deployment
+	// exceeds its progress deadline.
+	RollbackPolicyAnnotation = smith.Domain + "/rollbackPolicy"
+	// RollbackPolicyLastReady makes Smith roll the Deployment back to the pod template it last saw Ready.
+	RollbackPolicyLastReady = "LastReady"
+	// LastReadyTemplateAnnotation is the name of annotation which stores the pod template of the Deployment
+	// that was last seen Ready. Only maintained if the rollback policy is set.
+	LastReadyTemplateAnnotation = smith.Domain + "/LastReadyTemplate"
+	// RolledBackTemplateHashAnnotation is the name of annotation which stores the hash of the pod template
+	// from the specification that was rolled back. The specification is not applied again until it changes.
+	RolledBackTemplateHashAnnotation = smith.Domain + "/RolledBackTemplateHash"
 )
 
 type deployment struct {
@@ -55,14 +68,16 @@ func (d deployment) ApplySpec(ctx *specchecker.Context, spec, actual *unstructur
 		return nil, err
 	}
 
-	deploymentSpec.Spec.Template.Spec.DeprecatedServiceAccount = deploymentSpec.Spec.Template.Spec.ServiceAccountName
-
 	if deploymentSpec.Annotations == nil {
 		deploymentSpec.Annotations = make(map[string]string)
 	}
 
 	d.setLastAppliedReplicasAnnotation(ctx, &deploymentSpec, &deploymentActual)
-	err := d.setConfigurationHashAnnotation(ctx, &deploymentSpec)
+	err := d.applyTemplate(ctx, &deploymentSpec)
+	if err != nil {
+		return nil, err
+	}
+	err = d.keepRolledBackTemplate(&deploymentSpec, &deploymentActual)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +85,57 @@ func (d deployment) ApplySpec(ctx *specchecker.Context, spec, actual *unstructur
 	return &deploymentSpec, nil
 }
 
+// applyTemplate pre-processes the pod template of the specification the way it is applied to the actual object.
+func (d deployment) applyTemplate(ctx *specchecker.Context, spec *apps_v1.Deployment) error {
+	spec.Spec.Template.Spec.DeprecatedServiceAccount = spec.Spec.Template.Spec.ServiceAccountName
+	return d.setConfigurationHashAnnotation(ctx, spec)
+}
+
+// SpecPodTemplateHash returns the hash of the pod template of a Deployment specification, pre-processed the same
+// way ApplySpec does it. The hash must be stored in RolledBackTemplateHashAnnotation when the Deployment is rolled
+// back so that the rolled back pod template is not applied again until the specification changes.
+func SpecPodTemplateHash(ctx *specchecker.Context, spec *unstructured.Unstructured) (string, error) {
+	var deploymentSpec apps_v1.Deployment
+	if err := util.ConvertType(appsV1Scheme, spec, &deploymentSpec); err != nil {
+		return "", err
+	}
+	if err := (deployment{}).applyTemplate(ctx, &deploymentSpec); err != nil {
+		return "", err
+	}
+	return PodTemplateHash(&deploymentSpec.Spec.Template)
+}
+
+// keepRolledBackTemplate retains the pod template of the actual object if the pod template from the specification
+// has been rolled back. Once the pod template in the specification changes the rollback marker is cleared and
+// the new pod template is rolled out.
+func (deployment) keepRolledBackTemplate(spec, actual *apps_v1.Deployment) error {
+	rolledBackHash := actual.Annotations[RolledBackTemplateHashAnnotation]
+	if rolledBackHash == "" {
+		return nil
+	}
+	specHash, err := PodTemplateHash(&spec.Spec.Template)
+	if err != nil {
+		return err
+	}
+	if specHash == rolledBackHash {
+		spec.Spec.Template = actual.Spec.Template
+		spec.Annotations[RolledBackTemplateHashAnnotation] = rolledBackHash
+	} else {
+		spec.Annotations[RolledBackTemplateHashAnnotation] = ""
+	}
+	return nil
+}
+
+// PodTemplateHash returns a hash of a pod template.
+func PodTemplateHash(template *core_v1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	hashBytes := sha256.Sum256(data)
+	return hex.EncodeToString(hashBytes[:]), nil
+}
+
 // setLastAppliedReplicasAnnotation updates replicas based on LastAppliedReplicas annotation and running config
 // to avoid conflicts with other controllers like HPA.
 // actual may be nil.
@@ -1020,3 +1020,79 @@ func runtimeToUnstructured(t *testing.T, obj runtime.Object) *unstructured.Unstr
 	require.NoError(t, err)
 	return out
 }
+
+func TestRolledBackTemplateIsKept(t *testing.T) {
+	t.Parallel()
+
+	deploymentSpec := rollbackTestDeployment("app:2")
+	processedTemplate := deploymentSpec.Spec.Template.DeepCopy()
+	processedTemplate.Annotations = map[string]string{
+		EnvRefHashAnnotation: nullSha256,
+	}
+	specHash, err := PodTemplateHash(processedTemplate)
+	require.NoError(t, err)
+
+	deploymentActual := rollbackTestDeployment("app:1")
+	deploymentActual.Annotations = map[string]string{
+		RolledBackTemplateHashAnnotation: specHash,
+	}
+
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync() // nolint: errcheck
+	store := speccheckertesting.FakeStore{Namespace: testNs}
+
+	updatedSpec, err := deployment{}.ApplySpec(&specchecker.Context{Logger: logger, Store: store},
+		runtimeToUnstructured(t, deploymentSpec), runtimeToUnstructured(t, deploymentActual))
+	require.NoError(t, err)
+
+	deploymentCheck := updatedSpec.(*apps_v1.Deployment)
+	assert.Equal(t, "app:1", deploymentCheck.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, specHash, deploymentCheck.Annotations[RolledBackTemplateHashAnnotation])
+}
+
+func TestRolledBackTemplateIsReplacedWhenSpecChanges(t *testing.T) {
+	t.Parallel()
+
+	deploymentSpec := rollbackTestDeployment("app:3")
+	deploymentActual := rollbackTestDeployment("app:1")
+	deploymentActual.Annotations = map[string]string{
+		RolledBackTemplateHashAnnotation: "hash-of-app:2",
+	}
+
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync() // nolint: errcheck
+	store := speccheckertesting.FakeStore{Namespace: testNs}
+
+	updatedSpec, err := deployment{}.ApplySpec(&specchecker.Context{Logger: logger, Store: store},
+		runtimeToUnstructured(t, deploymentSpec), runtimeToUnstructured(t, deploymentActual))
+	require.NoError(t, err)
+
+	deploymentCheck := updatedSpec.(*apps_v1.Deployment)
+	assert.Equal(t, "app:3", deploymentCheck.Spec.Template.Spec.Containers[0].Image)
+	require.Contains(t, deploymentCheck.Annotations, RolledBackTemplateHashAnnotation)
+	assert.Empty(t, deploymentCheck.Annotations[RolledBackTemplateHashAnnotation])
+}
+
+func rollbackTestDeployment(image string) *apps_v1.Deployment {
+	return &apps_v1.Deployment{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: apps_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: testNs,
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{
+					Containers: []core_v1.Container{
+						{
+							Name:  "app",
+							Image: image,
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package builtin
+
+import (
+	"github.com/atlassian/smith"
+	"github.com/atlassian/smith/pkg/specchecker"
+	"github.com/atlassian/smith/pkg/util"
+	"github.com/pkg/errors"
+	batch_v1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// JobTemplateChangePolicyAnnotation is the name of annotation which defines what should happen when the pod
+	// template of a Job is changed. Pod template of a Job is immutable so the Job has to be replaced to
+	// run it with the new template.
+	JobTemplateChangePolicyAnnotation = smith.Domain + "/templateChangePolicy"
+	// JobTemplateChangePolicyReplace makes Smith delete the Job and create it again with the new template.
+	JobTemplateChangePolicyReplace = "Replace"
+	// JobTemplateHashAnnotation is the name of annotation which stores the hash of the pod template of the Job
+	// specification the Job was created from. The actual pod template is defaulted by the server so it
+	// cannot be compared with the specification directly.
+	JobTemplateHashAnnotation = smith.Domain + "/templateHash"
+)
+
+type job struct {
+}
+
+func (job) BeforeCreate(ctx *specchecker.Context, spec *unstructured.Unstructured) (runtime.Object /*updatedSpec*/, error) {
+	var jobSpec batch_v1.Job
+	if err := util.ConvertType(batchV1Scheme, spec, &jobSpec); err != nil {
+		return nil, err
+	}
+	templateHash, err := PodTemplateHash(&jobSpec.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	if jobSpec.Annotations == nil {
+		jobSpec.Annotations = make(map[string]string)
+	}
+	jobSpec.Annotations[JobTemplateHashAnnotation] = templateHash
+	return &jobSpec, nil
+}
+
+func (job) ApplySpec(ctx *specchecker.Context, spec, actual *unstructured.Unstructured) (runtime.Object, error) {
+	var jobSpec batch_v1.Job
+	if err := util.ConvertType(batchV1Scheme, spec, &jobSpec); err != nil {
+		return nil, err
+	}
+	var jobActual batch_v1.Job
+	if err := util.ConvertType(batchV1Scheme, actual, &jobActual); err != nil {
+		return nil, err
+	}
+
+	templateHash, err := PodTemplateHash(&jobSpec.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	// Jobs created before the hash was recorded do not have the annotation. Their template is assumed
+	// to be unchanged and the hash is recorded by the update.
+	actualHash := jobActual.Annotations[JobTemplateHashAnnotation]
+	if actualHash != "" && actualHash != templateHash {
+		if jobSpec.Annotations[JobTemplateChangePolicyAnnotation] != JobTemplateChangePolicyReplace {
+			return nil, errors.Errorf("pod template of a Job cannot be updated, set annotation %q to %q to re-run the Job with the new template",
+				JobTemplateChangePolicyAnnotation, JobTemplateChangePolicyReplace)
+		}
+		return nil, &specchecker.RecreateRequiredError{
+			Reason: "pod template of the Job has changed",
+		}
+	}
+	if jobSpec.Annotations == nil {
+		jobSpec.Annotations = make(map[string]string)
+	}
+	jobSpec.Annotations[JobTemplateHashAnnotation] = templateHash
+
+	// Pod template is immutable and has been defaulted by the server
+	jobSpec.Spec.Template = jobActual.Spec.Template
+
+	if jobSpec.Spec.ManualSelector == nil || !*jobSpec.Spec.ManualSelector {
+		// Selector is generated by the Job controller
+		jobSpec.Spec.Selector = jobActual.Spec.Selector
+		jobSpec.Spec.ManualSelector = jobActual.Spec.ManualSelector
+	}
+
+	err = setEmptyFieldsFromActual(&jobSpec.Spec, &jobActual.Spec,
+		// defaulted by the server
+		"Parallelism",
+		"Completions",
+		"BackoffLimit",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobSpec, nil
+}
@@ -0,0 +1,243 @@
+package builtin
+
+import (
+	"testing"
+
+	"github.com/atlassian/smith/pkg/specchecker"
+	speccheckertesting "github.com/atlassian/smith/pkg/specchecker/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	batch_v1 "k8s.io/api/batch/v1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestJobCopiesGeneratedSelectorAndLabels(t *testing.T) {
+	t.Parallel()
+
+	jobSpec := testJob("image:1")
+	jobActual := createdJob(t, jobSpec)
+	jobActual.Spec.Selector = &meta_v1.LabelSelector{
+		MatchLabels: map[string]string{
+			"controller-uid": "some-uid",
+		},
+	}
+	jobActual.Spec.ManualSelector = nil
+	jobActual.Spec.Template.Labels = map[string]string{
+		"controller-uid": "some-uid",
+		"job-name":       "job1",
+	}
+	var backoffLimit int32 = 6
+	jobActual.Spec.BackoffLimit = &backoffLimit
+
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync() // nolint: errcheck
+
+	updatedSpec, err := job{}.ApplySpec(
+		&specchecker.Context{Logger: logger, Store: speccheckertesting.FakeStore{Namespace: testNs}},
+		runtimeToUnstructured(t, jobSpec), runtimeToUnstructured(t, jobActual))
+	require.NoError(t, err)
+
+	jobCheck := updatedSpec.(*batch_v1.Job)
+	assert.Equal(t, jobActual.Spec.Selector, jobCheck.Spec.Selector)
+	assert.Equal(t, jobActual.Spec.Template.Labels, jobCheck.Spec.Template.Labels)
+	require.NotNil(t, jobCheck.Spec.BackoffLimit)
+	assert.Equal(t, backoffLimit, *jobCheck.Spec.BackoffLimit)
+}
+
+func TestJobTemplateChangeWithoutPolicyIsAnError(t *testing.T) {
+	t.Parallel()
+
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync() // nolint: errcheck
+
+	_, err := job{}.ApplySpec(
+		&specchecker.Context{Logger: logger, Store: speccheckertesting.FakeStore{Namespace: testNs}},
+		runtimeToUnstructured(t, testJob("image:2")), runtimeToUnstructured(t, createdJob(t, testJob("image:1"))))
+	require.Error(t, err)
+	assert.False(t, specchecker.IsRecreateRequired(err))
+	assert.Contains(t, err.Error(), JobTemplateChangePolicyAnnotation)
+}
+
+func TestJobTemplateChangeWithReplacePolicyRequiresRecreate(t *testing.T) {
+	t.Parallel()
+
+	jobSpec := testJob("image:2")
+	jobSpec.Annotations = map[string]string{
+		JobTemplateChangePolicyAnnotation: JobTemplateChangePolicyReplace,
+	}
+
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync() // nolint: errcheck
+
+	_, err := job{}.ApplySpec(
+		&specchecker.Context{Logger: logger, Store: speccheckertesting.FakeStore{Namespace: testNs}},
+		runtimeToUnstructured(t, jobSpec), runtimeToUnstructured(t, createdJob(t, testJob("image:1"))))
+	require.Error(t, err)
+	assert.True(t, specchecker.IsRecreateRequired(err))
+}
+
+func TestJobTemplateDefaultedByServerIsNotAChange(t *testing.T) {
+	t.Parallel()
+
+	jobSpec := testJob("image:1")
+	jobSpec.Spec.Template.Spec.ServiceAccountName = "job"
+	jobSpec.Spec.Template.Spec.Containers[0].Ports = []core_v1.ContainerPort{
+		{
+			ContainerPort: 8080,
+		},
+	}
+	jobSpec.Spec.Template.Spec.Containers[0].Resources = core_v1.ResourceRequirements{
+		Limits: core_v1.ResourceList{
+			core_v1.ResourceCPU: resource.MustParse("1"),
+		},
+	}
+	jobSpec.Spec.Template.Spec.Containers[0].Env = []core_v1.EnvVar{
+		{
+			Name: "POD_NAME",
+			ValueFrom: &core_v1.EnvVarSource{
+				FieldRef: &core_v1.ObjectFieldSelector{
+					FieldPath: "metadata.name",
+				},
+			},
+		},
+	}
+	jobSpec.Spec.Template.Spec.Containers[0].ReadinessProbe = &core_v1.Probe{
+		Handler: core_v1.Handler{
+			Exec: &core_v1.ExecAction{
+				Command: []string{"true"},
+			},
+		},
+	}
+	jobSpec.Spec.Template.Spec.Volumes = []core_v1.Volume{
+		{
+			Name: "config",
+			VolumeSource: core_v1.VolumeSource{
+				ConfigMap: &core_v1.ConfigMapVolumeSource{
+					LocalObjectReference: core_v1.LocalObjectReference{
+						Name: "config",
+					},
+				},
+			},
+		},
+	}
+	jobActual := createdJob(t, jobSpec)
+	serverDefaultPodTemplate(&jobActual.Spec.Template)
+
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync() // nolint: errcheck
+
+	updatedSpec, err := job{}.ApplySpec(
+		&specchecker.Context{Logger: logger, Store: speccheckertesting.FakeStore{Namespace: testNs}},
+		runtimeToUnstructured(t, jobSpec), runtimeToUnstructured(t, jobActual))
+	require.NoError(t, err)
+
+	jobCheck := updatedSpec.(*batch_v1.Job)
+	assert.Equal(t, jobActual.Spec.Template, jobCheck.Spec.Template)
+	assert.Equal(t, jobActual.Annotations[JobTemplateHashAnnotation], jobCheck.Annotations[JobTemplateHashAnnotation])
+}
+
+func TestJobWithoutTemplateHashIsNotAChange(t *testing.T) {
+	t.Parallel()
+
+	jobSpec := testJob("image:1")
+	// Created before the hash was recorded
+	jobActual := testJob("image:1")
+	serverDefaultPodTemplate(&jobActual.Spec.Template)
+
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync() // nolint: errcheck
+
+	updatedSpec, err := job{}.ApplySpec(
+		&specchecker.Context{Logger: logger, Store: speccheckertesting.FakeStore{Namespace: testNs}},
+		runtimeToUnstructured(t, jobSpec), runtimeToUnstructured(t, jobActual))
+	require.NoError(t, err)
+
+	expectedHash, err := PodTemplateHash(&jobSpec.Spec.Template)
+	require.NoError(t, err)
+	jobCheck := updatedSpec.(*batch_v1.Job)
+	assert.Equal(t, jobActual.Spec.Template, jobCheck.Spec.Template)
+	assert.Equal(t, expectedHash, jobCheck.Annotations[JobTemplateHashAnnotation])
+}
+
+// createdJob returns the Job as it is created from the specification.
+func createdJob(t *testing.T, spec *batch_v1.Job) *batch_v1.Job {
+	logger := zaptest.NewLogger(t)
+	defer logger.Sync() // nolint: errcheck
+
+	created, err := job{}.BeforeCreate(
+		&specchecker.Context{Logger: logger, Store: speccheckertesting.FakeStore{Namespace: testNs}},
+		runtimeToUnstructured(t, spec))
+	require.NoError(t, err)
+	return created.(*batch_v1.Job)
+}
+
+// serverDefaultPodTemplate sets fields the way the server defaults them.
+func serverDefaultPodTemplate(template *core_v1.PodTemplateSpec) {
+	var gracePeriod int64 = core_v1.DefaultTerminationGracePeriodSeconds
+	var defaultMode int32 = core_v1.ConfigMapVolumeSourceDefaultMode
+	tr := true
+	podSpec := &template.Spec
+	podSpec.DeprecatedServiceAccount = podSpec.ServiceAccountName
+	podSpec.TerminationGracePeriodSeconds = &gracePeriod
+	podSpec.DNSPolicy = core_v1.DNSClusterFirst
+	podSpec.SecurityContext = &core_v1.PodSecurityContext{}
+	podSpec.SchedulerName = core_v1.DefaultSchedulerName
+	podSpec.EnableServiceLinks = &tr
+	for i := range podSpec.Volumes {
+		if configMap := podSpec.Volumes[i].ConfigMap; configMap != nil {
+			configMap.DefaultMode = &defaultMode
+		}
+	}
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		container.TerminationMessagePath = core_v1.TerminationMessagePathDefault
+		container.TerminationMessagePolicy = core_v1.TerminationMessageReadFile
+		container.ImagePullPolicy = core_v1.PullIfNotPresent
+		for j := range container.Ports {
+			container.Ports[j].Protocol = core_v1.ProtocolTCP
+		}
+		for j := range container.Env {
+			if valueFrom := container.Env[j].ValueFrom; valueFrom != nil && valueFrom.FieldRef != nil {
+				valueFrom.FieldRef.APIVersion = "v1"
+			}
+		}
+		if probe := container.ReadinessProbe; probe != nil {
+			probe.TimeoutSeconds = 1
+			probe.PeriodSeconds = 10
+			probe.SuccessThreshold = 1
+			probe.FailureThreshold = 3
+		}
+		if container.Resources.Requests == nil && container.Resources.Limits != nil {
+			container.Resources.Requests = container.Resources.Limits.DeepCopy()
+		}
+	}
+}
+
+func testJob(image string) *batch_v1.Job {
+	return &batch_v1.Job{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: batch_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "job1",
+			Namespace: testNs,
+		},
+		Spec: batch_v1.JobSpec{
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{
+					RestartPolicy: core_v1.RestartPolicyNever,
+					Containers: []core_v1.Container{
+						{
+							Name:  "job",
+							Image: image,
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,42 @@
+package builtin
+
+import (
+	"github.com/atlassian/smith/pkg/specchecker"
+	"github.com/atlassian/smith/pkg/util"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type persistentVolumeClaim struct {
+}
+
+func (persistentVolumeClaim) BeforeCreate(ctx *specchecker.Context, spec *unstructured.Unstructured) (runtime.Object /*updatedSpec*/, error) {
+	return spec, nil
+}
+
+func (persistentVolumeClaim) ApplySpec(ctx *specchecker.Context, spec, actual *unstructured.Unstructured) (runtime.Object, error) {
+	var pvcSpec core_v1.PersistentVolumeClaim
+	if err := util.ConvertType(coreV1Scheme, spec, &pvcSpec); err != nil {
+		return nil, err
+	}
+	var pvcActual core_v1.PersistentVolumeClaim
+	if err := util.ConvertType(coreV1Scheme, actual, &pvcActual); err != nil {
+		return nil, err
+	}
+
+	err := setEmptyFieldsFromActual(&pvcSpec.Spec, &pvcActual.Spec,
+		// set by the volume binder
+		"VolumeName",
+		// set by the DefaultStorageClass admission plugin
+		"StorageClassName",
+		// defaulted by the server
+		"VolumeMode",
+	)
+	if err != nil {
+		return nil, err
+	}
+	pvcSpec.Status = pvcActual.Status
+
+	return &pvcSpec, nil
+}
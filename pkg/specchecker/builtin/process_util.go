@@ -6,19 +6,22 @@ import (
 	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
 	"github.com/pkg/errors"
 	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
 	core_v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 )
 
 var (
-	appsV1Scheme = runtime.NewScheme()
-	scV1B1Scheme = runtime.NewScheme()
-	coreV1Scheme = runtime.NewScheme()
+	appsV1Scheme  = runtime.NewScheme()
+	batchV1Scheme = runtime.NewScheme()
+	scV1B1Scheme  = runtime.NewScheme()
+	coreV1Scheme  = runtime.NewScheme()
 )
 
 func init() {
 	utilruntime.Must(apps_v1.SchemeBuilder.AddToScheme(appsV1Scheme))
+	utilruntime.Must(batch_v1.SchemeBuilder.AddToScheme(batchV1Scheme))
 	utilruntime.Must(sc_v1b1.SchemeBuilder.AddToScheme(scV1B1Scheme))
 	utilruntime.Must(core_v1.SchemeBuilder.AddToScheme(coreV1Scheme))
 }
@@ -11,7 +11,7 @@ import (
 )
 
 func HashSecretRef(store Store, namespace, name string, filter sets.String, optional *bool, h hash.Hash) error {
-	secret, exists, err := store.Get(core_v1.SchemeGroupVersion.WithKind("Secret"), namespace, name)
+	secret, exists, err := store.GetReadOnly(core_v1.SchemeGroupVersion.WithKind("Secret"), namespace, name)
 	if err != nil {
 		return errors.Wrapf(err, "failure retrieving Secret %q", name)
 	}
@@ -31,7 +31,7 @@ func HashSecretRef(store Store, namespace, name string, filter sets.String, opti
 }
 
 func HashConfigMapRef(store Store, namespace, name string, filter sets.String, optional *bool, h hash.Hash) error {
-	configmap, exists, err := store.Get(core_v1.SchemeGroupVersion.WithKind("ConfigMap"), namespace, name)
+	configmap, exists, err := store.GetReadOnly(core_v1.SchemeGroupVersion.WithKind("ConfigMap"), namespace, name)
 	if err != nil {
 		return errors.Wrapf(err, "failure retrieving ConfigMap %q", name)
 	}
@@ -18,3 +18,7 @@ func (f FakeStore) Get(gvk schema.GroupVersionKind, namespace, name string) (run
 	v, ok := f.Responses[name]
 	return v, ok, nil
 }
+
+func (f FakeStore) GetReadOnly(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, bool /*exists*/, error) {
+	return f.Get(gvk, namespace, name)
+}
@@ -15,6 +15,8 @@ type ObjectProcessor interface {
 
 type Store interface {
 	Get(gvk schema.GroupVersionKind, namespace, name string) (obj runtime.Object, exists bool, err error)
+	// GetReadOnly is like Get but does not copy the object so it must not be modified.
+	GetReadOnly(gvk schema.GroupVersionKind, namespace, name string) (obj runtime.Object, exists bool, err error)
 }
 
 // Context includes objects used by different cleanup functions
@@ -22,3 +24,20 @@ type Context struct {
 	Logger *zap.Logger
 	Store  Store
 }
+
+// RecreateRequiredError is returned by an ObjectProcessor when the actual object cannot be updated to match
+// the specification (e.g. because the fields that have changed are immutable) and has to be deleted and then
+// created again.
+type RecreateRequiredError struct {
+	Reason string
+}
+
+func (e *RecreateRequiredError) Error() string {
+	return "object has to be re-created: " + e.Reason
+}
+
+// IsRecreateRequired returns true if the error is a RecreateRequiredError.
+func IsRecreateRequired(err error) bool {
+	_, ok := err.(*RecreateRequiredError)
+	return ok
+}
@@ -0,0 +1,188 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atlassian/smith/pkg/statuschecker"
+	"github.com/pkg/errors"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// Annotation set by the Deployment controller on Deployments and ReplicaSets.
+	deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+	// Maximum number of problems included into the status message.
+	maxDiagnosticProblems = 3
+)
+
+var (
+	replicaSetGVK = apps_v1.SchemeGroupVersion.WithKind("ReplicaSet")
+	podGVK        = core_v1.SchemeGroupVersion.WithKind("Pod")
+
+	// Reasons for a container to be waiting that indicate a problem.
+	containerProblemReasons = sets.NewString(
+		"ImagePullBackOff",
+		"ErrImagePull",
+		"ErrImageNeverPull",
+		"InvalidImageName",
+		"CrashLoopBackOff",
+		"CreateContainerConfigError",
+		"CreateContainerError",
+		"RunContainerError",
+	)
+	// Reasons for a container to be waiting that will not go away without changing the pod template.
+	containerUnrecoverableReasons = sets.NewString(
+		"ErrImageNeverPull",
+		"InvalidImageName",
+	)
+)
+
+// deploymentInProgress returns an in progress result with the provided message and problems with
+// Deployment's ReplicaSet/Pods, if any are found. If fail fast mode is enabled and an unrecoverable
+// problem has been found, an error is returned instead.
+func deploymentInProgress(ctx *statuschecker.Context, deployment *apps_v1.Deployment, message string) statuschecker.ObjectStatusResult {
+	diagnostics, unrecoverable := diagnoseDeployment(ctx, deployment)
+	if diagnostics == "" {
+		return statuschecker.ObjectStatusInProgress{
+			Message: message,
+		}
+	}
+	if unrecoverable && ctx.FailFast {
+		return statuschecker.ObjectStatusError{
+			ExternalError:  true,
+			RetriableError: false,
+			Error:          errors.Errorf("deployment cannot make progress: %s", diagnostics),
+		}
+	}
+	return statuschecker.ObjectStatusInProgress{
+		Message: fmt.Sprintf("%s. %s", message, diagnostics),
+	}
+}
+
+// diagnoseDeployment looks at the current ReplicaSet of the Deployment and its Pods to find out why
+// the Deployment is not making progress. Returns a description of the found problems and whether
+// any of them are unrecoverable. Diagnostics are best effort so any errors are ignored.
+func diagnoseDeployment(ctx *statuschecker.Context, deployment *apps_v1.Deployment) (string /*diagnostics*/, bool /*unrecoverable*/) {
+	if ctx == nil || ctx.Store == nil {
+		return "", false
+	}
+	rs := currentReplicaSet(ctx, deployment)
+	if rs == nil {
+		return "", false
+	}
+	var problems []string
+	unrecoverable := false
+	for _, cond := range rs.Status.Conditions {
+		if cond.Type == apps_v1.ReplicaSetReplicaFailure && cond.Status == core_v1.ConditionTrue {
+			// e.g. FailedCreate because quota has been exceeded
+			problems = append(problems, fmt.Sprintf("ReplicaSet %q: %s: %s", rs.Name, cond.Reason, cond.Message))
+		}
+	}
+	// Objects are not modified so they are not copied
+	objs, err := ctx.Store.ObjectsOfKindControlledBy(podGVK, rs.Namespace, rs.UID)
+	if err != nil {
+		return strings.Join(problems, "; "), false
+	}
+	var pods []*core_v1.Pod
+	for _, obj := range objs {
+		if pod, ok := obj.(*core_v1.Pod); ok {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	seen := sets.NewString()
+	for _, pod := range pods {
+		podProblems, podUnrecoverable := diagnosePod(pod)
+		unrecoverable = unrecoverable || podUnrecoverable
+		for _, problem := range podProblems {
+			// Same problem is usually shared by all Pods so only report it once
+			if seen.Has(problem.key) {
+				continue
+			}
+			seen.Insert(problem.key)
+			problems = append(problems, fmt.Sprintf("Pod %q %s", pod.Name, problem.message))
+		}
+	}
+	if len(problems) > maxDiagnosticProblems {
+		problems = append(problems[:maxDiagnosticProblems], fmt.Sprintf("and %d more", len(problems)-maxDiagnosticProblems))
+	}
+	return strings.Join(problems, "; "), unrecoverable
+}
+
+// currentReplicaSet finds the ReplicaSet that corresponds to the current revision of the Deployment.
+func currentReplicaSet(ctx *statuschecker.Context, deployment *apps_v1.Deployment) *apps_v1.ReplicaSet {
+	objs, err := ctx.Store.ObjectsOfKindControlledBy(replicaSetGVK, deployment.Namespace, deployment.UID)
+	if err != nil {
+		return nil
+	}
+	revision := deployment.Annotations[deploymentRevisionAnnotation]
+	var newest *apps_v1.ReplicaSet
+	newestRevision := int64(-1)
+	for _, obj := range objs {
+		rs, ok := obj.(*apps_v1.ReplicaSet)
+		if !ok {
+			continue
+		}
+		rsRevision := rs.Annotations[deploymentRevisionAnnotation]
+		if revision != "" && rsRevision == revision {
+			return rs
+		}
+		r, err := strconv.ParseInt(rsRevision, 10, 64)
+		if err != nil {
+			continue
+		}
+		if r > newestRevision {
+			newest = rs
+			newestRevision = r
+		}
+	}
+	return newest
+}
+
+type podProblem struct {
+	// key identifies the problem regardless of the Pod it was found in.
+	key     string
+	message string
+}
+
+func diagnosePod(pod *core_v1.Pod) ([]podProblem, bool /*unrecoverable*/) {
+	var problems []podProblem
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == core_v1.PodScheduled && cond.Status == core_v1.ConditionFalse && cond.Reason == core_v1.PodReasonUnschedulable {
+			problems = append(problems, podProblem{
+				key:     "FailedScheduling",
+				message: fmt.Sprintf("FailedScheduling: %s", cond.Message),
+			})
+		}
+	}
+	unrecoverable := false
+	containerStatuses := append(pod.Status.InitContainerStatuses[:len(pod.Status.InitContainerStatuses):len(pod.Status.InitContainerStatuses)],
+		pod.Status.ContainerStatuses...)
+	for _, status := range containerStatuses {
+		waiting := status.State.Waiting
+		if waiting == nil || !containerProblemReasons.Has(waiting.Reason) {
+			continue
+		}
+		message := fmt.Sprintf("container %q: %s", status.Name, waiting.Reason)
+		if waiting.Message != "" {
+			message += ": " + waiting.Message
+		}
+		if terminated := status.LastTerminationState.Terminated; terminated != nil && waiting.Reason == "CrashLoopBackOff" {
+			message += fmt.Sprintf(" (last exit code %d, reason %s)", terminated.ExitCode, terminated.Reason)
+		}
+		problems = append(problems, podProblem{
+			key:     status.Name + "|" + waiting.Reason,
+			message: message,
+		})
+		unrecoverable = unrecoverable || containerUnrecoverableReasons.Has(waiting.Reason)
+	}
+	return problems, unrecoverable
+}
@@ -5,15 +5,22 @@ import (
 	"strings"
 	"time"
 
+	"github.com/atlassian/smith/pkg/resources"
 	"github.com/atlassian/smith/pkg/statuschecker"
 	"github.com/atlassian/smith/pkg/util"
 	sc_v1b1 "github.com/kubernetes-sigs/service-catalog/pkg/apis/servicecatalog/v1beta1"
 	"github.com/pkg/errors"
 	apps_v1 "k8s.io/api/apps/v1"
 	autoscaling_v2b1 "k8s.io/api/autoscaling/v2beta1"
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1b1 "k8s.io/api/batch/v1beta1"
 	core_v1 "k8s.io/api/core/v1"
 	net_v1b1 "k8s.io/api/networking/v1beta1"
 	policy_v1 "k8s.io/api/policy/v1beta1"
+	rbac_v1 "k8s.io/api/rbac/v1"
+	scheduling_v1 "k8s.io/api/scheduling/v1"
+	storage_v1 "k8s.io/api/storage/v1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -26,27 +33,46 @@ const (
 	// before being considered a failure
 	hpaScalingActiveTimeout = 5 * time.Minute
 	timedOutReason          = "ProgressDeadlineExceeded"
+
+	jobBackoffLimitExceededReason = "BackoffLimitExceeded"
 )
 
 var (
 	MainKnownTypes = map[schema.GroupKind]statuschecker.ObjectStatusChecker{
 		{Group: core_v1.GroupName, Kind: "ConfigMap"}:             alwaysReady,
 		{Group: core_v1.GroupName, Kind: "Secret"}:                alwaysReady,
-		{Group: core_v1.GroupName, Kind: "Service"}:               alwaysReady,
+		{Group: core_v1.GroupName, Kind: "Service"}:               isServiceReady,
 		{Group: core_v1.GroupName, Kind: "ServiceAccount"}:        alwaysReady,
+		{Group: core_v1.GroupName, Kind: "PersistentVolumeClaim"}: isPersistentVolumeClaimReady,
 		{Group: apps_v1.GroupName, Kind: "Deployment"}:            isDeploymentReady,
-		{Group: net_v1b1.GroupName, Kind: "Ingress"}:              alwaysReady,
+		{Group: batch_v1.GroupName, Kind: "Job"}:                  isJobReady,
+		{Group: batch_v1b1.GroupName, Kind: "CronJob"}:            isCronJobReady,
+		{Group: net_v1b1.GroupName, Kind: "Ingress"}:              isIngressReady,
 		{Group: policy_v1.GroupName, Kind: "PodDisruptionBudget"}: alwaysReady,
 
 		{Group: autoscaling_v2b1.GroupName, Kind: "HorizontalPodAutoscaler"}: isHorizontalPodAutoscalerReady,
+
+		// Cluster-scoped types
+		{Group: rbac_v1.GroupName, Kind: "ClusterRole"}:                alwaysReady,
+		{Group: rbac_v1.GroupName, Kind: "ClusterRoleBinding"}:         alwaysReady,
+		{Group: scheduling_v1.GroupName, Kind: "PriorityClass"}:        alwaysReady,
+		{Group: apiext_v1.GroupName, Kind: "CustomResourceDefinition"}: isCustomResourceDefinitionReady,
 	}
 	ServiceCatalogKnownTypes = map[schema.GroupKind]statuschecker.ObjectStatusChecker{
 		{Group: sc_v1b1.GroupName, Kind: "ServiceBinding"}:  isScServiceBindingReady,
 		{Group: sc_v1b1.GroupName, Kind: "ServiceInstance"}: isScServiceInstanceReady,
 	}
 	appsV1Scheme          = runtime.NewScheme()
+	coreV1Scheme          = runtime.NewScheme()
+	netV1B1Scheme         = runtime.NewScheme()
 	scV1B1Scheme          = runtime.NewScheme()
 	autoscalingV2B1Scheme = runtime.NewScheme()
+	batchV1Scheme         = runtime.NewScheme()
+	batchV1B1Scheme       = runtime.NewScheme()
+	apiextV1Scheme        = runtime.NewScheme()
+	storageV1Scheme       = runtime.NewScheme()
+
+	storageClassGVK = storage_v1.SchemeGroupVersion.WithKind("StorageClass")
 )
 
 var scNonErrorReasons = sets.NewString(
@@ -61,11 +87,17 @@ var scNonErrorReasons = sets.NewString(
 
 func init() {
 	utilruntime.Must(apps_v1.SchemeBuilder.AddToScheme(appsV1Scheme))
+	utilruntime.Must(core_v1.SchemeBuilder.AddToScheme(coreV1Scheme))
+	utilruntime.Must(net_v1b1.SchemeBuilder.AddToScheme(netV1B1Scheme))
 	utilruntime.Must(sc_v1b1.SchemeBuilder.AddToScheme(scV1B1Scheme))
 	utilruntime.Must(autoscaling_v2b1.SchemeBuilder.AddToScheme(autoscalingV2B1Scheme))
+	utilruntime.Must(batch_v1.SchemeBuilder.AddToScheme(batchV1Scheme))
+	utilruntime.Must(batch_v1b1.SchemeBuilder.AddToScheme(batchV1B1Scheme))
+	utilruntime.Must(apiext_v1.SchemeBuilder.AddToScheme(apiextV1Scheme))
+	utilruntime.Must(storage_v1.SchemeBuilder.AddToScheme(storageV1Scheme))
 }
 
-func alwaysReady(_ runtime.Object) statuschecker.ObjectStatusResult {
+func alwaysReady(_ *statuschecker.Context, _ runtime.Object) statuschecker.ObjectStatusResult {
 	return statuschecker.ObjectStatusReady{}
 }
 
@@ -81,7 +113,7 @@ func getDeploymentCondition(deployment *apps_v1.Deployment, condType apps_v1.Dep
 }
 
 // Works according to https://kubernetes.io/docs/user-guide/deployments/#the-status-of-a-deployment
-func isDeploymentReady(obj runtime.Object) statuschecker.ObjectStatusResult {
+func isDeploymentReady(ctx *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
 	var deployment apps_v1.Deployment
 	if err := util.ConvertType(appsV1Scheme, obj, &deployment); err != nil {
 		return statuschecker.ObjectStatusError{
@@ -99,28 +131,29 @@ func isDeploymentReady(obj runtime.Object) statuschecker.ObjectStatusResult {
 	if generation <= observedGeneration {
 		progressingCond := getDeploymentCondition(&deployment, apps_v1.DeploymentProgressing)
 		if progressingCond != nil && progressingCond.Reason == timedOutReason {
+			err := errors.New("deployment exceeded its progress deadline")
+			if diagnostics, _ := diagnoseDeployment(ctx, &deployment); diagnostics != "" {
+				err = errors.Errorf("%v: %s", err, diagnostics)
+			}
 			return statuschecker.ObjectStatusError{
 				ExternalError:  true,
 				RetriableError: false,
-				Error:          errors.Errorf("deployment exceeded its progress deadline"),
+				Error:          err,
 			}
 		}
 		if replicas != nil && updatedReplicas < *replicas {
-			return statuschecker.ObjectStatusInProgress{
-				Message: fmt.Sprintf("Number of replicas converging. Requested=%d, Updated=%d", *replicas, updatedReplicas),
-			}
+			return deploymentInProgress(ctx, &deployment,
+				fmt.Sprintf("Number of replicas converging. Requested=%d, Updated=%d", *replicas, updatedReplicas))
 		}
 
 		if deployment.Status.Replicas > updatedReplicas {
-			return statuschecker.ObjectStatusInProgress{
-				Message: fmt.Sprintf("Number of replicas converging. Replicas=%d, Updated=%d", deployment.Status.Replicas, updatedReplicas),
-			}
+			return deploymentInProgress(ctx, &deployment,
+				fmt.Sprintf("Number of replicas converging. Replicas=%d, Updated=%d", deployment.Status.Replicas, updatedReplicas))
 		}
 
 		if availableReplicas < updatedReplicas {
-			return statuschecker.ObjectStatusInProgress{
-				Message: fmt.Sprintf("Number of replicas converging. Available=%d, Updated=%d", availableReplicas, updatedReplicas),
-			}
+			return deploymentInProgress(ctx, &deployment,
+				fmt.Sprintf("Number of replicas converging. Available=%d, Updated=%d", availableReplicas, updatedReplicas))
 		}
 
 		return statuschecker.ObjectStatusReady{}
@@ -131,7 +164,203 @@ func isDeploymentReady(obj runtime.Object) statuschecker.ObjectStatusResult {
 	}
 }
 
-func isHorizontalPodAutoscalerReady(obj runtime.Object) statuschecker.ObjectStatusResult {
+func getJobCondition(job *batch_v1.Job, condType batch_v1.JobConditionType) *batch_v1.JobCondition {
+	for i := range job.Status.Conditions {
+		c := job.Status.Conditions[i]
+		if c.Type == condType {
+			return &c
+		}
+	}
+	return nil
+}
+
+// A Job is considered Ready once it has completed successfully i.e. objects that depend on it are only
+// processed after the Job has finished. A failed Job (e.g. backoff limit has been reached) is a terminal error.
+func isJobReady(_ *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
+	var job batch_v1.Job
+	if err := util.ConvertType(batchV1Scheme, obj, &job); err != nil {
+		return statuschecker.ObjectStatusError{
+			Error: err,
+		}
+	}
+
+	completeCond := getJobCondition(&job, batch_v1.JobComplete)
+	if completeCond != nil && completeCond.Status == core_v1.ConditionTrue {
+		return statuschecker.ObjectStatusReady{
+			Message: fmt.Sprintf("Job completed. Succeeded=%d", job.Status.Succeeded),
+		}
+	}
+	failedCond := getJobCondition(&job, batch_v1.JobFailed)
+	if failedCond != nil && failedCond.Status == core_v1.ConditionTrue {
+		var err error
+		if failedCond.Reason == jobBackoffLimitExceededReason && job.Spec.BackoffLimit != nil {
+			err = errors.Errorf("%s: %s (backoffLimit=%d, failed=%d)", failedCond.Reason, failedCond.Message, *job.Spec.BackoffLimit, job.Status.Failed)
+		} else {
+			err = errors.Errorf("%s: %s", failedCond.Reason, failedCond.Message)
+		}
+		return statuschecker.ObjectStatusError{
+			ExternalError:  true,
+			RetriableError: false,
+			Error:          err,
+		}
+	}
+
+	return statuschecker.ObjectStatusInProgress{
+		Message: fmt.Sprintf("Job in progress. Active=%d, Succeeded=%d, Failed=%d", job.Status.Active, job.Status.Succeeded, job.Status.Failed),
+	}
+}
+
+// A CronJob is Ready as soon as it exists - it only defines a schedule. Jobs it spawns are not tracked.
+func isCronJobReady(_ *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
+	var cronJob batch_v1b1.CronJob
+	if err := util.ConvertType(batchV1B1Scheme, obj, &cronJob); err != nil {
+		return statuschecker.ObjectStatusError{
+			Error: err,
+		}
+	}
+	var message string
+	switch {
+	case cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend:
+		message = "CronJob is suspended"
+	case cronJob.Status.LastScheduleTime != nil:
+		message = fmt.Sprintf("Last scheduled at %s", cronJob.Status.LastScheduleTime.UTC().Format(time.RFC3339))
+	}
+	return statuschecker.ObjectStatusReady{
+		Message: message,
+	}
+}
+
+// A Service of LoadBalancer type is only Ready once the load balancer has been provisioned and its address
+// has been published in the status. Services of other types are Ready as soon as they exist.
+func isServiceReady(_ *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
+	var service core_v1.Service
+	if err := util.ConvertType(coreV1Scheme, obj, &service); err != nil {
+		return statuschecker.ObjectStatusError{
+			Error: err,
+		}
+	}
+	if service.Spec.Type != core_v1.ServiceTypeLoadBalancer {
+		return statuschecker.ObjectStatusReady{}
+	}
+	return loadBalancerStatus(&service.Status.LoadBalancer)
+}
+
+// An Ingress is Ready once the ingress controller has published the address of the load balancer in the status.
+func isIngressReady(_ *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
+	var ingress net_v1b1.Ingress
+	if err := util.ConvertType(netV1B1Scheme, obj, &ingress); err != nil {
+		return statuschecker.ObjectStatusError{
+			Error: err,
+		}
+	}
+	return loadBalancerStatus(&ingress.Status.LoadBalancer)
+}
+
+func loadBalancerStatus(lbStatus *core_v1.LoadBalancerStatus) statuschecker.ObjectStatusResult {
+	for _, lbIngress := range lbStatus.Ingress {
+		if lbIngress.Hostname != "" {
+			return statuschecker.ObjectStatusReady{
+				Message: fmt.Sprintf("Hostname=%s", lbIngress.Hostname),
+			}
+		}
+		if lbIngress.IP != "" {
+			return statuschecker.ObjectStatusReady{
+				Message: fmt.Sprintf("IP=%s", lbIngress.IP),
+			}
+		}
+	}
+	return statuschecker.ObjectStatusInProgress{
+		Message: "Waiting for load balancer address to be assigned",
+	}
+}
+
+// A CustomResourceDefinition is Ready once it has been established and its names have been accepted
+// i.e. custom resources can be created.
+func isCustomResourceDefinitionReady(_ *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
+	var crd apiext_v1.CustomResourceDefinition
+	if err := util.ConvertType(apiextV1Scheme, obj, &crd); err != nil {
+		return statuschecker.ObjectStatusError{
+			Error: err,
+		}
+	}
+	if !resources.IsCrdConditionTrue(&crd, apiext_v1.NamesAccepted) {
+		return statuschecker.ObjectStatusInProgress{
+			Message: "Waiting for names to be accepted",
+		}
+	}
+	if !resources.IsCrdConditionTrue(&crd, apiext_v1.Established) {
+		return statuschecker.ObjectStatusInProgress{
+			Message: "Waiting to be established",
+		}
+	}
+	return statuschecker.ObjectStatusReady{}
+}
+
+// A PersistentVolumeClaim is Ready once it is bound. A Pending claim of a StorageClass with the WaitForFirstConsumer
+// binding mode is also Ready because it is only bound once a Pod that uses it is scheduled, and that Pod may
+// depend on the claim in the same Bundle.
+func isPersistentVolumeClaimReady(ctx *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
+	var pvc core_v1.PersistentVolumeClaim
+	if err := util.ConvertType(coreV1Scheme, obj, &pvc); err != nil {
+		return statuschecker.ObjectStatusError{
+			Error: err,
+		}
+	}
+	switch pvc.Status.Phase {
+	case core_v1.ClaimPending:
+		waits, err := waitsForFirstConsumer(ctx, &pvc)
+		if err != nil {
+			return statuschecker.ObjectStatusError{
+				RetriableError: true,
+				Error:          err,
+			}
+		}
+		if waits {
+			return statuschecker.ObjectStatusReady{
+				Message: fmt.Sprintf("Waiting for the first consumer to be created before binding (StorageClass %q)", *pvc.Spec.StorageClassName),
+			}
+		}
+		return statuschecker.ObjectStatusInProgress{
+			Message: "Waiting for the claim to be bound",
+		}
+	case core_v1.ClaimBound:
+		return statuschecker.ObjectStatusReady{
+			Message: fmt.Sprintf("Bound to %s", pvc.Spec.VolumeName),
+		}
+	case core_v1.ClaimLost:
+		return statuschecker.ObjectStatusError{
+			ExternalError:  true,
+			RetriableError: false,
+			Error:          errors.Errorf("claim lost its underlying PersistentVolume %q", pvc.Spec.VolumeName),
+		}
+	default:
+		return statuschecker.ObjectStatusInProgress{
+			Message: "Waiting for the claim to be bound",
+		}
+	}
+}
+
+// waitsForFirstConsumer returns true if the StorageClass of the claim delays binding until a Pod that uses the claim
+// is scheduled. Claims are assumed to be bound immediately if StorageClasses cannot be looked up.
+func waitsForFirstConsumer(ctx *statuschecker.Context, pvc *core_v1.PersistentVolumeClaim) (bool, error) {
+	if ctx == nil || ctx.Store == nil || pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false, nil
+	}
+	obj, exists, err := ctx.Store.Get(storageClassGVK, meta_v1.NamespaceNone, *pvc.Spec.StorageClassName)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get StorageClass %q", *pvc.Spec.StorageClassName)
+	}
+	if !exists {
+		return false, nil
+	}
+	var storageClass storage_v1.StorageClass
+	if err = util.ConvertType(storageV1Scheme, obj, &storageClass); err != nil {
+		return false, err
+	}
+	return storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storage_v1.VolumeBindingWaitForFirstConsumer, nil
+}
+
+func isHorizontalPodAutoscalerReady(_ *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
 	var hpa autoscaling_v2b1.HorizontalPodAutoscaler
 	if err := util.ConvertType(autoscalingV2B1Scheme, obj, &hpa); err != nil {
 		return statuschecker.ObjectStatusError{
@@ -189,7 +418,7 @@ func isHorizontalPodAutoscalerReady(obj runtime.Object) statuschecker.ObjectStat
 	}
 }
 
-func isScServiceBindingReady(obj runtime.Object) statuschecker.ObjectStatusResult {
+func isScServiceBindingReady(_ *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
 	var sic sc_v1b1.ServiceBinding
 	if err := util.ConvertType(scV1B1Scheme, obj, &sic); err != nil {
 		return statuschecker.ObjectStatusError{
@@ -233,7 +462,7 @@ func isScServiceBindingReady(obj runtime.Object) statuschecker.ObjectStatusResul
 	}
 }
 
-func isScServiceInstanceReady(obj runtime.Object) statuschecker.ObjectStatusResult {
+func isScServiceInstanceReady(_ *statuschecker.Context, obj runtime.Object) statuschecker.ObjectStatusResult {
 	var instance sc_v1b1.ServiceInstance
 	if err := util.ConvertType(scV1B1Scheme, obj, &instance); err != nil {
 		return statuschecker.ObjectStatusError{
@@ -0,0 +1,345 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atlassian/smith/pkg/statuschecker"
+	"github.com/stretchr/testify/assert"
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1b1 "k8s.io/api/batch/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+	storage_v1 "k8s.io/api/storage/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestJobStatus(t *testing.T) {
+	t.Parallel()
+
+	var backoffLimit int32 = 2
+	inputs := []struct {
+		name     string
+		status   batch_v1.JobStatus
+		expected statuschecker.ObjectStatusResult
+	}{
+		{
+			name: "complete",
+			status: batch_v1.JobStatus{
+				Succeeded: 1,
+				Conditions: []batch_v1.JobCondition{
+					{
+						Type:   batch_v1.JobComplete,
+						Status: core_v1.ConditionTrue,
+					},
+				},
+			},
+			expected: statuschecker.ObjectStatusReady{
+				Message: "Job completed. Succeeded=1",
+			},
+		},
+		{
+			name: "failed",
+			status: batch_v1.JobStatus{
+				Failed: 3,
+				Conditions: []batch_v1.JobCondition{
+					{
+						Type:    batch_v1.JobFailed,
+						Status:  core_v1.ConditionTrue,
+						Reason:  jobBackoffLimitExceededReason,
+						Message: "Job has reached the specified backoff limit",
+					},
+				},
+			},
+			expected: statuschecker.ObjectStatusError{
+				ExternalError: true,
+			},
+		},
+		{
+			name: "failed with deadline exceeded",
+			status: batch_v1.JobStatus{
+				Conditions: []batch_v1.JobCondition{
+					{
+						Type:    batch_v1.JobFailed,
+						Status:  core_v1.ConditionTrue,
+						Reason:  "DeadlineExceeded",
+						Message: "Job was active longer than specified deadline",
+					},
+				},
+			},
+			expected: statuschecker.ObjectStatusError{
+				ExternalError: true,
+			},
+		},
+		{
+			name: "in progress",
+			status: batch_v1.JobStatus{
+				Active: 1,
+				Failed: 1,
+			},
+			expected: statuschecker.ObjectStatusInProgress{
+				Message: "Job in progress. Active=1, Succeeded=0, Failed=1",
+			},
+		},
+		{
+			name: "in progress with false conditions",
+			status: batch_v1.JobStatus{
+				Active: 1,
+				Conditions: []batch_v1.JobCondition{
+					{
+						Type:   batch_v1.JobComplete,
+						Status: core_v1.ConditionFalse,
+					},
+					{
+						Type:   batch_v1.JobFailed,
+						Status: core_v1.ConditionFalse,
+					},
+				},
+			},
+			expected: statuschecker.ObjectStatusInProgress{
+				Message: "Job in progress. Active=1, Succeeded=0, Failed=0",
+			},
+		},
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input.name, func(t *testing.T) {
+			t.Parallel()
+			job := &batch_v1.Job{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "Job",
+					APIVersion: batch_v1.SchemeGroupVersion.String(),
+				},
+				Spec: batch_v1.JobSpec{
+					BackoffLimit: &backoffLimit,
+				},
+				Status: input.status,
+			}
+			result := isJobReady(nil, job)
+			if expectedErr, ok := input.expected.(statuschecker.ObjectStatusError); ok {
+				// Error is checked separately
+				resultErr, ok := result.(statuschecker.ObjectStatusError)
+				if assert.True(t, ok, "unexpected result %#v", result) {
+					assert.Equal(t, expectedErr.ExternalError, resultErr.ExternalError)
+					assert.Equal(t, expectedErr.RetriableError, resultErr.RetriableError)
+					assert.Error(t, resultErr.Error)
+				}
+				return
+			}
+			assert.Equal(t, input.expected, result)
+		})
+	}
+}
+
+func TestJobStatusFailedErrorMessage(t *testing.T) {
+	t.Parallel()
+
+	var backoffLimit int32 = 2
+	job := &batch_v1.Job{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: batch_v1.SchemeGroupVersion.String(),
+		},
+		Spec: batch_v1.JobSpec{
+			BackoffLimit: &backoffLimit,
+		},
+		Status: batch_v1.JobStatus{
+			Failed: 3,
+			Conditions: []batch_v1.JobCondition{
+				{
+					Type:    batch_v1.JobFailed,
+					Status:  core_v1.ConditionTrue,
+					Reason:  jobBackoffLimitExceededReason,
+					Message: "Job has reached the specified backoff limit",
+				},
+			},
+		},
+	}
+	result, ok := isJobReady(nil, job).(statuschecker.ObjectStatusError)
+	if assert.True(t, ok) {
+		assert.EqualError(t, result.Error, "BackoffLimitExceeded: Job has reached the specified backoff limit (backoffLimit=2, failed=3)")
+	}
+}
+
+func TestCronJobStatus(t *testing.T) {
+	t.Parallel()
+
+	tr := true
+	fa := false
+	lastSchedule := meta_v1.NewTime(time.Date(2019, 10, 1, 12, 30, 0, 0, time.UTC))
+	inputs := []struct {
+		name     string
+		suspend  *bool
+		status   batch_v1b1.CronJobStatus
+		expected statuschecker.ObjectStatusResult
+	}{
+		{
+			name:     "never scheduled",
+			expected: statuschecker.ObjectStatusReady{},
+		},
+		{
+			name:    "scheduled",
+			suspend: &fa,
+			status: batch_v1b1.CronJobStatus{
+				LastScheduleTime: &lastSchedule,
+			},
+			expected: statuschecker.ObjectStatusReady{
+				Message: "Last scheduled at 2019-10-01T12:30:00Z",
+			},
+		},
+		{
+			name:    "suspended",
+			suspend: &tr,
+			status: batch_v1b1.CronJobStatus{
+				LastScheduleTime: &lastSchedule,
+			},
+			expected: statuschecker.ObjectStatusReady{
+				Message: "CronJob is suspended",
+			},
+		},
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input.name, func(t *testing.T) {
+			t.Parallel()
+			cronJob := &batch_v1b1.CronJob{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "CronJob",
+					APIVersion: batch_v1b1.SchemeGroupVersion.String(),
+				},
+				Spec: batch_v1b1.CronJobSpec{
+					Schedule: "*/5 * * * *",
+					Suspend:  input.suspend,
+				},
+				Status: input.status,
+			}
+			assert.Equal(t, input.expected, isCronJobReady(nil, cronJob))
+		})
+	}
+}
+
+// fakeStore returns objects by name regardless of the GVK and the namespace.
+type fakeStore map[string]runtime.Object
+
+func (s fakeStore) Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, bool, error) {
+	obj, ok := s[name]
+	return obj, ok, nil
+}
+
+func (s fakeStore) ObjectsControlledBy(namespace string, uid types.UID) ([]runtime.Object, error) {
+	return nil, nil
+}
+
+func (s fakeStore) ObjectsOfKindControlledBy(gvk schema.GroupVersionKind, namespace string, uid types.UID) ([]runtime.Object, error) {
+	return nil, nil
+}
+
+func storageClass(name string, mode storage_v1.VolumeBindingMode) *storage_v1.StorageClass {
+	return &storage_v1.StorageClass{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "StorageClass",
+			APIVersion: storage_v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: name,
+		},
+		VolumeBindingMode: &mode,
+	}
+}
+
+func TestPersistentVolumeClaimStatus(t *testing.T) {
+	t.Parallel()
+
+	store := fakeStore{
+		"immediate": storageClass("immediate", storage_v1.VolumeBindingImmediate),
+		"delayed":   storageClass("delayed", storage_v1.VolumeBindingWaitForFirstConsumer),
+	}
+	inputs := []struct {
+		name         string
+		storageClass *string
+		phase        core_v1.PersistentVolumeClaimPhase
+		expected     statuschecker.ObjectStatusResult
+	}{
+		{
+			name:     "bound",
+			phase:    core_v1.ClaimBound,
+			expected: statuschecker.ObjectStatusReady{Message: "Bound to pv1"},
+		},
+		{
+			name:     "pending without StorageClass",
+			phase:    core_v1.ClaimPending,
+			expected: statuschecker.ObjectStatusInProgress{Message: "Waiting for the claim to be bound"},
+		},
+		{
+			name:         "pending with Immediate StorageClass",
+			storageClass: strPtr("immediate"),
+			phase:        core_v1.ClaimPending,
+			expected:     statuschecker.ObjectStatusInProgress{Message: "Waiting for the claim to be bound"},
+		},
+		{
+			name:         "pending with WaitForFirstConsumer StorageClass",
+			storageClass: strPtr("delayed"),
+			phase:        core_v1.ClaimPending,
+			expected: statuschecker.ObjectStatusReady{
+				Message: `Waiting for the first consumer to be created before binding (StorageClass "delayed")`,
+			},
+		},
+		{
+			name:         "pending with missing StorageClass",
+			storageClass: strPtr("missing"),
+			phase:        core_v1.ClaimPending,
+			expected:     statuschecker.ObjectStatusInProgress{Message: "Waiting for the claim to be bound"},
+		},
+		{
+			name:         "bound with WaitForFirstConsumer StorageClass",
+			storageClass: strPtr("delayed"),
+			phase:        core_v1.ClaimBound,
+			expected:     statuschecker.ObjectStatusReady{Message: "Bound to pv1"},
+		},
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input.name, func(t *testing.T) {
+			t.Parallel()
+			pvc := &core_v1.PersistentVolumeClaim{
+				TypeMeta: meta_v1.TypeMeta{
+					Kind:       "PersistentVolumeClaim",
+					APIVersion: core_v1.SchemeGroupVersion.String(),
+				},
+				Spec: core_v1.PersistentVolumeClaimSpec{
+					StorageClassName: input.storageClass,
+					VolumeName:       "pv1",
+				},
+				Status: core_v1.PersistentVolumeClaimStatus{
+					Phase: input.phase,
+				},
+			}
+			assert.Equal(t, input.expected, isPersistentVolumeClaimReady(&statuschecker.Context{Store: store}, pvc))
+		})
+	}
+}
+
+func TestPersistentVolumeClaimStatusWithoutStore(t *testing.T) {
+	t.Parallel()
+
+	delayed := "delayed"
+	pvc := &core_v1.PersistentVolumeClaim{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: core_v1.SchemeGroupVersion.String(),
+		},
+		Spec: core_v1.PersistentVolumeClaimSpec{
+			StorageClassName: &delayed,
+		},
+		Status: core_v1.PersistentVolumeClaimStatus{
+			Phase: core_v1.ClaimPending,
+		},
+	}
+	assert.Equal(t, statuschecker.ObjectStatusInProgress{Message: "Waiting for the claim to be bound"}, isPersistentVolumeClaimReady(nil, pvc))
+}
+
+func strPtr(s string) *string {
+	return &s
+}
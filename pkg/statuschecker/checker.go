@@ -6,10 +6,11 @@ import (
 	"github.com/atlassian/smith"
 	"github.com/atlassian/smith/pkg/resources"
 	"github.com/pkg/errors"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type ObjectStatusType string
@@ -58,12 +59,30 @@ func (o ObjectStatusError) StatusType() ObjectStatusType {
 
 // ObjectStatusChecker checks object's status.
 // Function is responsible for handling different versions of objects by itself.
-type ObjectStatusChecker func(runtime.Object) ObjectStatusResult
+type ObjectStatusChecker func(*Context, runtime.Object) ObjectStatusResult
+
+// Store is a typed cache for Kubernetes objects.
+type Store interface {
+	Get(gvk schema.GroupVersionKind, namespace, name string) (obj runtime.Object, exists bool, err error)
+	ObjectsControlledBy(namespace string, uid types.UID) ([]runtime.Object, error)
+	// ObjectsOfKindControlledBy returns objects of the GVK controlled by the UID without copying them.
+	// Returned objects must not be modified.
+	ObjectsOfKindControlledBy(gvk schema.GroupVersionKind, namespace string, uid types.UID) ([]runtime.Object, error)
+}
+
+// Context includes objects used by different status checking functions.
+type Context struct {
+	// Store can be used to look up objects related to the object being checked e.g. Pods of a Deployment.
+	Store Store
+	// FailFast makes checkers report clearly unrecoverable problems as errors straight away rather
+	// than reporting the object as in progress until some deadline is exceeded.
+	FailFast bool
+}
 
 // CRDStore gets a CRD definition for a Group and Kind of the resource (CRD instance).
 // Returns nil if CRD definition was not found.
 type CRDStore interface {
-	Get(resource schema.GroupKind) (*apiext_v1b1.CustomResourceDefinition, error)
+	Get(resource schema.GroupKind) (*apiext_v1.CustomResourceDefinition, error)
 }
 
 type Interface interface {
@@ -71,11 +90,12 @@ type Interface interface {
 }
 
 type Checker struct {
+	Context    *Context
 	Store      CRDStore
 	KnownTypes map[schema.GroupKind]ObjectStatusChecker
 }
 
-func New(store CRDStore, kts ...map[schema.GroupKind]ObjectStatusChecker) (*Checker, error) {
+func New(ctx *Context, store CRDStore, kts ...map[schema.GroupKind]ObjectStatusChecker) (*Checker, error) {
 	kt := make(map[schema.GroupKind]ObjectStatusChecker)
 	for _, knownTypes := range kts {
 		for knownGK, f := range knownTypes {
@@ -86,6 +106,7 @@ func New(store CRDStore, kts ...map[schema.GroupKind]ObjectStatusChecker) (*Chec
 		}
 	}
 	return &Checker{
+		Context:    ctx,
 		Store:      store,
 		KnownTypes: kt,
 	}, nil
@@ -103,7 +124,7 @@ func (c *Checker) CheckStatus(obj *unstructured.Unstructured) ObjectStatusResult
 
 	// 1. Check if it is a known built-in resource
 	if isObjectReady, ok := c.KnownTypes[gk]; ok {
-		return isObjectReady(obj)
+		return isObjectReady(c.Context, obj)
 	}
 
 	// 2. Check if it is a CRD with path/value annotation
@@ -131,17 +152,17 @@ func (c *Checker) CheckStatus(obj *unstructured.Unstructured) ObjectStatusResult
 	return ObjectStatusInProgress{}
 }
 
-func (c *Checker) crdWithKindGroupVersionAnnotation(gk schema.GroupKind) (*apiext_v1b1.CustomResourceDefinition, error) {
+func (c *Checker) crdWithKindGroupVersionAnnotation(gk schema.GroupKind) (*apiext_v1.CustomResourceDefinition, error) {
 	// Not yet implemented
 	return nil, nil
 }
 
-func (c *Checker) checkForInstance(crd *apiext_v1b1.CustomResourceDefinition, obj *unstructured.Unstructured) ObjectStatusResult {
+func (c *Checker) checkForInstance(crd *apiext_v1.CustomResourceDefinition, obj *unstructured.Unstructured) ObjectStatusResult {
 	// Not yet implemented
 	return ObjectStatusInProgress{}
 }
 
-func (c *Checker) crdWithPathValueAnnotation(gk schema.GroupKind) (*apiext_v1b1.CustomResourceDefinition, error) {
+func (c *Checker) crdWithPathValueAnnotation(gk schema.GroupKind) (*apiext_v1.CustomResourceDefinition, error) {
 	crd, err := c.Store.Get(gk)
 	if err != nil {
 		return nil, err
@@ -157,7 +178,7 @@ func (c *Checker) crdWithPathValueAnnotation(gk schema.GroupKind) (*apiext_v1b1.
 	return crd, nil
 }
 
-func (c *Checker) checkPathValue(crd *apiext_v1b1.CustomResourceDefinition, obj *unstructured.Unstructured) ObjectStatusResult {
+func (c *Checker) checkPathValue(crd *apiext_v1.CustomResourceDefinition, obj *unstructured.Unstructured) ObjectStatusResult {
 	path := crd.Annotations[smith.CrFieldPathAnnotation]
 	value := crd.Annotations[smith.CrFieldValueAnnotation]
 	actualValue, err := resources.GetJSONPathString(obj.Object, path)
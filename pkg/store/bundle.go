@@ -6,7 +6,7 @@ import (
 
 	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
 	"github.com/atlassian/smith/pkg/plugin"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -16,6 +16,8 @@ import (
 const (
 	byCrdGroupKindIndexName = "ByCrdGroupKind"
 	byObjectIndexName       = "ByObject"
+	byObjectNameIndexName   = "ByObjectName"
+	byLookupIndexName       = "ByLookup"
 )
 
 type ByNameStore interface {
@@ -25,6 +27,7 @@ type ByNameStore interface {
 type BundleStore struct {
 	store            ByNameStore
 	bundleByIndex    func(indexName, indexKey string) ([]interface{}, error)
+	bundleList       func() []interface{}
 	pluginContainers map[smith_v1.PluginName]plugin.Container
 }
 
@@ -32,11 +35,15 @@ func NewBundle(bundleInf cache.SharedIndexInformer, store ByNameStore, pluginCon
 	bs := &BundleStore{
 		store:            store,
 		bundleByIndex:    bundleInf.GetIndexer().ByIndex,
+		bundleList:       bundleInf.GetIndexer().List,
 		pluginContainers: pluginContainers,
 	}
 	err := bundleInf.AddIndexers(cache.Indexers{
 		byCrdGroupKindIndexName: bs.byCrdGroupKindIndex,
 		byObjectIndexName:       bs.byObjectIndex,
+		byObjectNameIndexName:   bs.byObjectNameIndex,
+		byLookupIndexName:       byLookupIndex,
+		cache.NamespaceIndex:    cache.MetaNamespaceIndexFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -55,36 +62,57 @@ func (s *BundleStore) Get(namespace, bundleName string) (*smith_v1.Bundle, error
 }
 
 // GetBundlesByCrd returns Bundles which have a resource defined by CRD.
-func (s *BundleStore) GetBundlesByCrd(crd *apiext_v1b1.CustomResourceDefinition) ([]*smith_v1.Bundle, error) {
+func (s *BundleStore) GetBundlesByCrd(crd *apiext_v1.CustomResourceDefinition) ([]*smith_v1.Bundle, error) {
 	return s.getBundles(byCrdGroupKindIndexName, byCrdGroupKindIndexKey(crd.Spec.Group, crd.Spec.Names.Kind))
 }
 
 // GetBundlesByObject returns bundles where a resource with specified GVK, namespace and name is defined.
+// Cluster-scoped objects do not have a namespace so Bundles from all namespaces are returned for them.
 func (s *BundleStore) GetBundlesByObject(gk schema.GroupKind, namespace, name string) ([]*smith_v1.Bundle, error) {
+	if namespace == meta_v1.NamespaceNone {
+		return s.getBundles(byObjectNameIndexName, byObjectNameIndexKey(gk, name))
+	}
 	return s.getBundles(byObjectIndexName, byObjectIndexKey(gk, namespace, name))
 }
 
+// GetBundlesByLookup returns Bundles with plugins that looked up the object with specified GK, namespace and name.
+func (s *BundleStore) GetBundlesByLookup(gk schema.GroupKind, namespace, name string) ([]*smith_v1.Bundle, error) {
+	return s.getBundles(byLookupIndexName, byObjectIndexKey(gk, namespace, name))
+}
+
+// GetBundlesByNamespace returns Bundles in the namespace. All Bundles are returned if the namespace is empty.
+func (s *BundleStore) GetBundlesByNamespace(namespace string) ([]*smith_v1.Bundle, error) {
+	if namespace == meta_v1.NamespaceAll {
+		return copyBundles(s.bundleList()), nil
+	}
+	return s.getBundles(cache.NamespaceIndex, namespace)
+}
+
 func (s *BundleStore) getBundles(indexName, indexKey string) ([]*smith_v1.Bundle, error) {
 	bundles, err := s.bundleByIndex(indexName, indexKey)
 	if err != nil {
 		return nil, err
 	}
+	return copyBundles(bundles), nil
+}
+
+func copyBundles(bundles []interface{}) []*smith_v1.Bundle {
 	result := make([]*smith_v1.Bundle, 0, len(bundles))
 	for _, bundle := range bundles {
 		result = append(result, bundle.(*smith_v1.Bundle).DeepCopy())
 	}
-	return result, nil
+	return result
 }
 
 func (s *BundleStore) byCrdGroupKindIndex(obj interface{}) ([]string, error) {
 	bundle := obj.(*smith_v1.Bundle)
 	var result []string
 	for _, resource := range bundle.Spec.Resources {
-		var gvk schema.GroupVersionKind
+		var gvks []schema.GroupVersionKind
 
 		switch {
 		case resource.Spec.Object != nil:
-			gvk = resource.Spec.Object.GetObjectKind().GroupVersionKind()
+			gvks = []schema.GroupVersionKind{resource.Spec.Object.GetObjectKind().GroupVersionKind()}
 
 		case resource.Spec.Plugin != nil:
 			p, ok := s.pluginContainers[resource.Spec.Plugin.Name]
@@ -92,18 +120,20 @@ func (s *BundleStore) byCrdGroupKindIndex(obj interface{}) ([]string, error) {
 				// Unknown plugin. Do not return error to avoid informer panicking
 				continue
 			}
-			gvk = p.Plugin.Describe().GVK
+			gvks = p.Plugin.Describe().OutputGVKs()
 
 		default:
 			// Invalid object, ignore
 			continue
 		}
-		if strings.IndexByte(gvk.Group, '.') == -1 {
-			// CRD names are of form <plural>.<domain>.<tld> so there should be at least
-			// one dot between domain and tld
-			continue
+		for _, gvk := range gvks {
+			if strings.IndexByte(gvk.Group, '.') == -1 {
+				// CRD names are of form <plural>.<domain>.<tld> so there should be at least
+				// one dot between domain and tld
+				continue
+			}
+			result = append(result, byCrdGroupKindIndexKey(gvk.Group, gvk.Kind))
 		}
-		result = append(result, byCrdGroupKindIndexKey(gvk.Group, gvk.Kind))
 	}
 	return result, nil
 }
@@ -115,6 +145,37 @@ func byCrdGroupKindIndexKey(group, kind string) string {
 func (s *BundleStore) byObjectIndex(obj interface{}) ([]string, error) {
 	bundle := obj.(*smith_v1.Bundle)
 	result := make([]string, 0, len(bundle.Spec.Resources))
+	s.forEachObject(bundle, func(gk schema.GroupKind, name string) {
+		result = append(result, byObjectIndexKey(gk, bundle.Namespace, name))
+	})
+	return result, nil
+}
+
+func (s *BundleStore) byObjectNameIndex(obj interface{}) ([]string, error) {
+	bundle := obj.(*smith_v1.Bundle)
+	result := make([]string, 0, len(bundle.Spec.Resources))
+	s.forEachObject(bundle, func(gk schema.GroupKind, name string) {
+		result = append(result, byObjectNameIndexKey(gk, name))
+	})
+	return result, nil
+}
+
+func byLookupIndex(obj interface{}) ([]string, error) {
+	bundle := obj.(*smith_v1.Bundle)
+	var result []string
+	for _, resStatus := range bundle.Status.ResourceStatuses {
+		for _, lookup := range resStatus.Lookups {
+			gk := schema.GroupKind{Group: lookup.Group, Kind: lookup.Kind}
+			result = append(result, byObjectIndexKey(gk, lookup.Namespace, lookup.Name))
+		}
+	}
+	return result, nil
+}
+
+// forEachObject invokes f with group, kind and name of each object defined in the Bundle.
+// Objects of plugins that produce multiple objects are taken from the Bundle status as their names are
+// only known once the plugin has been invoked.
+func (s *BundleStore) forEachObject(bundle *smith_v1.Bundle, f func(gk schema.GroupKind, name string)) {
 	for _, resource := range bundle.Spec.Resources {
 		var gvk schema.GroupVersionKind
 		var name string
@@ -128,17 +189,30 @@ func (s *BundleStore) byObjectIndex(obj interface{}) ([]string, error) {
 				// Unknown plugin. Do not return error to avoid informer panicking
 				continue
 			}
-			gvk = p.Plugin.Describe().GVK
+			description := p.Plugin.Describe()
+			if description.IsMultiObject() {
+				_, resStatus := bundle.Status.GetResourceStatus(resource.Name)
+				if resStatus != nil {
+					for _, obj := range resStatus.Objects {
+						f(schema.GroupKind{Group: obj.Group, Kind: obj.Kind}, obj.Name)
+					}
+				}
+				continue
+			}
+			gvk = description.GVK
 			name = resource.Spec.Plugin.ObjectName
 		default:
 			// Invalid object, ignore
 			continue
 		}
-		result = append(result, byObjectIndexKey(gvk.GroupKind(), bundle.Namespace, name))
+		f(gvk.GroupKind(), name)
 	}
-	return result, nil
 }
 
 func byObjectIndexKey(gk schema.GroupKind, namespace, name string) string {
 	return fmt.Sprintf("%s/%s/%s/%s", gk.Group, gk.Kind, namespace, name)
 }
+
+func byObjectNameIndexKey(gk schema.GroupKind, name string) string {
+	return fmt.Sprintf("%s/%s/%s", gk.Group, gk.Kind, name)
+}
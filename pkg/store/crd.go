@@ -2,7 +2,7 @@ package store
 
 import (
 	"github.com/pkg/errors"
-	apiext_v1b1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/cache"
 )
@@ -28,7 +28,7 @@ func NewCrd(crdInf cache.SharedIndexInformer) (*Crd, error) {
 }
 
 // Get returns the CRD that defines the resource of provided group and kind.
-func (s *Crd) Get(resource schema.GroupKind) (*apiext_v1b1.CustomResourceDefinition, error) {
+func (s *Crd) Get(resource schema.GroupKind) (*apiext_v1.CustomResourceDefinition, error) {
 	objs, err := s.byIndex(byGroupKindIndexName, byGroupKindIndexKey(resource.Group, resource.Kind))
 	if err != nil {
 		return nil, err
@@ -37,10 +37,10 @@ func (s *Crd) Get(resource schema.GroupKind) (*apiext_v1b1.CustomResourceDefinit
 	case 0:
 		return nil, nil
 	case 1:
-		crd := objs[0].(*apiext_v1b1.CustomResourceDefinition).DeepCopy()
+		crd := objs[0].(*apiext_v1.CustomResourceDefinition).DeepCopy()
 		// Objects from type-specific informers don't have GVK set
 		crd.Kind = "CustomResourceDefinition"
-		crd.APIVersion = apiext_v1b1.SchemeGroupVersion.String()
+		crd.APIVersion = apiext_v1.SchemeGroupVersion.String()
 		return crd, nil
 	default:
 		// Must never happen
@@ -49,7 +49,7 @@ func (s *Crd) Get(resource schema.GroupKind) (*apiext_v1b1.CustomResourceDefinit
 }
 
 func byGroupKindIndex(obj interface{}) ([]string, error) {
-	crd := obj.(*apiext_v1b1.CustomResourceDefinition)
+	crd := obj.(*apiext_v1.CustomResourceDefinition)
 	return []string{byGroupKindIndexKey(crd.Spec.Group, crd.Spec.Names.Kind)}, nil
 }
 
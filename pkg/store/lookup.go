@@ -0,0 +1,70 @@
+package store
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+// LookupFunc fetches an object from the API server.
+type LookupFunc func(namespace, name string) (obj runtime.Object, exists bool, e error)
+
+type lookupResult struct {
+	obj    runtime.Object
+	exists bool
+}
+
+// Lookup fetches objects that are not in the informers' caches e.g. ConfigMaps and Secrets
+// that are referenced by managed objects but are not managed by Smith themselves.
+// Results, including missing objects, are cached for the TTL.
+type Lookup struct {
+	lookup LookupFunc
+	ttl    time.Duration
+	cache  *cache.LRUExpireCache
+}
+
+func NewLookup(lookup LookupFunc, maxSize int, ttl time.Duration) *Lookup {
+	return &Lookup{
+		lookup: lookup,
+		ttl:    ttl,
+		cache:  cache.NewLRUExpireCache(maxSize),
+	}
+}
+
+// Get returns a deep copy of the object with the GVK set.
+func (l *Lookup) Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, bool /*exists*/, error) {
+	obj, exists, err := l.GetReadOnly(gvk, namespace, name)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	ro := obj.DeepCopyObject()
+	ro.GetObjectKind().SetGroupVersionKind(gvk) // Objects from typed clients don't have GVK set
+	return ro, true, nil
+}
+
+// GetReadOnly returns the cached object without copying it.
+// The object must not be modified. GVK of the object may not be set.
+func (l *Lookup) GetReadOnly(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, bool /*exists*/, error) {
+	key := ByNamespaceAndNameIndexKey(namespace, name)
+	var result lookupResult
+	if cached, ok := l.cache.Get(key); ok {
+		result = cached.(lookupResult)
+	} else {
+		obj, exists, err := l.lookup(namespace, name)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to look up %s %q", gvk, key)
+		}
+		result = lookupResult{
+			obj:    obj,
+			exists: exists,
+		}
+		l.cache.Add(key, result, l.ttl)
+	}
+	if !result.exists {
+		return nil, false, nil
+	}
+	return result.obj, true, nil
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"github.com/atlassian/smith"
 	"github.com/pkg/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -45,13 +46,23 @@ func (s *Multi) AddInformer(gvk schema.GroupVersionKind, informer cache.SharedIn
 	return nil
 }
 
+// ObjectsControlledBy returns objects in the namespace that have a controller owner reference with the UID.
+// Cluster-scoped objects cannot be controlled by namespaced objects so cluster-scoped objects
+// labelled with the UID of the Bundle are returned too.
 func (s *Multi) ObjectsControlledBy(namespace string, uid types.UID) ([]runtime.Object, error) {
 	var result []runtime.Object
-	indexKey := ByNamespaceAndControllerUIDIndexKey(namespace, uid)
+	indexKeys := []string{ByNamespaceAndControllerUIDIndexKey(namespace, uid)}
+	if namespace != meta_v1.NamespaceNone {
+		indexKeys = append(indexKeys, ByNamespaceAndControllerUIDIndexKey(meta_v1.NamespaceNone, uid))
+	}
 	for gvk, inf := range s.GetInformers() {
-		objs, err := inf.GetIndexer().ByIndex(ByNamespaceAndControllerUIDIndex, indexKey)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get objects for bundle from %s informer", gvk)
+		var objs []interface{}
+		for _, indexKey := range indexKeys {
+			objsForKey, err := inf.GetIndexer().ByIndex(ByNamespaceAndControllerUIDIndex, indexKey)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get objects for bundle from %s informer", gvk)
+			}
+			objs = append(objs, objsForKey...)
 		}
 		for _, obj := range objs {
 			ro := obj.(runtime.Object).DeepCopyObject()
@@ -62,6 +73,27 @@ func (s *Multi) ObjectsControlledBy(namespace string, uid types.UID) ([]runtime.
 	return result, nil
 }
 
+// ObjectsOfKindControlledBy is like ObjectsControlledBy but only returns objects of the GVK.
+// Objects are returned from the cache without copying them so they must not be modified.
+// GVK of returned objects may not be set.
+func (s *Multi) ObjectsOfKindControlledBy(gvk schema.GroupVersionKind, namespace string, uid types.UID) ([]runtime.Object, error) {
+	s.mx.RLock()
+	inf := s.informers[gvk]
+	s.mx.RUnlock()
+	if inf == nil {
+		return nil, errors.Errorf("no informer for %s is registered", gvk)
+	}
+	objs, err := inf.GetIndexer().ByIndex(ByNamespaceAndControllerUIDIndex, ByNamespaceAndControllerUIDIndexKey(namespace, uid))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get objects for bundle from %s informer", gvk)
+	}
+	result := make([]runtime.Object, 0, len(objs))
+	for _, obj := range objs {
+		result = append(result, obj.(runtime.Object))
+	}
+	return result, nil
+}
+
 func byNamespaceAndControllerUIDIndex(obj interface{}) ([]string, error) {
 	if key, ok := obj.(cache.ExplicitKey); ok {
 		return []string{string(key)}, nil
@@ -71,6 +103,11 @@ func byNamespaceAndControllerUIDIndex(obj interface{}) ([]string, error) {
 	if ref != nil {
 		return []string{ByNamespaceAndControllerUIDIndexKey(m.GetNamespace(), ref.UID)}, nil
 	}
+	if m.GetNamespace() == meta_v1.NamespaceNone {
+		if uid, ok := m.GetLabels()[smith.BundleUIDLabel]; ok {
+			return []string{ByNamespaceAndControllerUIDIndexKey(meta_v1.NamespaceNone, types.UID(uid))}, nil
+		}
+	}
 	return nil, nil
 }
 
@@ -11,13 +11,15 @@ import (
 )
 
 type MultiBasic struct {
-	mx        sync.RWMutex // protects the map
+	mx        sync.RWMutex // protects the maps
 	informers map[schema.GroupVersionKind]cache.SharedIndexInformer
+	lookups   map[schema.GroupVersionKind]*Lookup
 }
 
 func NewMultiBasic() *MultiBasic {
 	return &MultiBasic{
 		informers: make(map[schema.GroupVersionKind]cache.SharedIndexInformer),
+		lookups:   make(map[schema.GroupVersionKind]*Lookup),
 	}
 }
 
@@ -32,6 +34,27 @@ func (s *MultiBasic) AddInformer(gvk schema.GroupVersionKind, informer cache.Sha
 	return nil
 }
 
+// AddLookup makes Get use the Lookup for objects of the GVK that are not found in the Informer.
+// This is useful if the Informer only watches a subset of objects. If there is no Informer for the GVK,
+// all objects of the GVK are fetched using the Lookup.
+func (s *MultiBasic) AddLookup(gvk schema.GroupVersionKind, lookup *Lookup) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if _, ok := s.lookups[gvk]; ok {
+		return errors.New("lookup is already registered")
+	}
+	s.lookups[gvk] = lookup
+	return nil
+}
+
+// HasInformer returns true if there is an Informer for the GVK.
+func (s *MultiBasic) HasInformer(gvk schema.GroupVersionKind) bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	_, ok := s.informers[gvk]
+	return ok
+}
+
 func (s *MultiBasic) RemoveInformer(gvk schema.GroupVersionKind) bool {
 	s.mx.Lock()
 	defer s.mx.Unlock()
@@ -54,28 +77,50 @@ func (s *MultiBasic) GetInformers() map[schema.GroupVersionKind]cache.SharedInde
 }
 
 // Get looks up object of specified GVK in the specified namespace by name.
+// If the object is not found in the Informer and there is a Lookup for the GVK, the Lookup is used.
 // A deep copy of the object is returned so it is safe to modify it.
 func (s *MultiBasic) Get(gvk schema.GroupVersionKind, namespace, name string) (obj runtime.Object, exists bool, e error) {
+	obj, exists, err := s.get(gvk, namespace, name)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	ro := obj.DeepCopyObject()
+	ro.GetObjectKind().SetGroupVersionKind(gvk) // Objects from type-specific informers don't have GVK set
+	return ro, true, nil
+}
+
+// GetReadOnly is like Get but returns the object from the cache without copying it.
+// The object must not be modified. GVK of the object may not be set.
+func (s *MultiBasic) GetReadOnly(gvk schema.GroupVersionKind, namespace, name string) (obj runtime.Object, exists bool, e error) {
+	return s.get(gvk, namespace, name)
+}
+
+func (s *MultiBasic) get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, bool /*exists */, error) {
 	var informer cache.SharedIndexInformer
+	var lookup *Lookup
 	func() {
 		s.mx.RLock()
 		defer s.mx.RUnlock()
 		informer = s.informers[gvk]
+		lookup = s.lookups[gvk]
 	}()
 	if informer == nil {
+		if lookup != nil {
+			return lookup.GetReadOnly(gvk, namespace, name)
+		}
 		return nil, false, errors.Errorf("no informer for %s is registered", gvk)
 	}
-	return s.getFromIndexer(informer.GetIndexer(), gvk, namespace, name)
-}
-
-func (s *MultiBasic) getFromIndexer(indexer cache.Indexer, gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, bool /*exists */, error) {
-	obj, exists, err := indexer.GetByKey(ByNamespaceAndNameIndexKey(namespace, name))
-	if err != nil || !exists {
-		return nil, exists, err
+	obj, exists, err := informer.GetIndexer().GetByKey(ByNamespaceAndNameIndexKey(namespace, name))
+	if err != nil {
+		return nil, false, errors.WithStack(err)
 	}
-	ro := obj.(runtime.Object).DeepCopyObject()
-	ro.GetObjectKind().SetGroupVersionKind(gvk) // Objects from type-specific informers don't have GVK set
-	return ro, true, nil
+	if exists {
+		return obj.(runtime.Object), true, nil
+	}
+	if lookup == nil {
+		return nil, false, nil
+	}
+	return lookup.GetReadOnly(gvk, namespace, name)
 }
 
 func ByNamespaceAndNameIndexKey(namespace, name string) string {
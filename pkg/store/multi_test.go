@@ -0,0 +1,121 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	benchNamespace = "ns"
+	// Number of ReplicaSets and Pods per ReplicaSet in the benchmark namespace.
+	benchReplicaSets = 100
+	benchPods        = 50
+)
+
+// largeNamespaceStore returns a store with many Pods controlled by ReplicaSets in a single namespace.
+// transform is applied to each Pod before it is added to the store.
+func largeNamespaceStore(b *testing.B, transform TransformFunc) *Multi {
+	store := NewMulti()
+	podInf := cache.NewSharedIndexInformer(&cache.ListWatch{}, &core_v1.Pod{}, 0, cache.Indexers{})
+	require.NoError(b, store.AddInformer(podGVK, podInf))
+	for i := 0; i < benchReplicaSets; i++ {
+		rsUID := types.UID(fmt.Sprintf("rs%d", i))
+		for j := 0; j < benchPods; j++ {
+			p := pod(benchNamespace, fmt.Sprintf("pod-%d-%d", i, j), rsUID)
+			transform(p)
+			require.NoError(b, podInf.GetIndexer().Add(p))
+		}
+	}
+	return store
+}
+
+func TestGetUsesLookupWithoutInformer(t *testing.T) {
+	t.Parallel()
+	store := NewMulti()
+	lookups := 0
+	require.NoError(t, store.AddLookup(podGVK, NewLookup(func(namespace, name string) (runtime.Object, bool, error) {
+		lookups++
+		return pod(namespace, name, "uid"), name == "p1", nil
+	}, 10, time.Minute)))
+
+	obj, exists, err := store.Get(podGVK, "ns", "p1")
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.Equal(t, podGVK, obj.GetObjectKind().GroupVersionKind())
+	_, exists, err = store.Get(podGVK, "ns", "p2")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	_, _, err = store.Get(podGVK, "ns", "p1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, lookups)
+
+	_, _, err = store.Get(core_v1.SchemeGroupVersion.WithKind("ConfigMap"), "ns", "c1")
+	assert.EqualError(t, err, "no informer for /v1, Kind=ConfigMap is registered")
+}
+
+func noTransform(obj runtime.Object) {
+}
+
+var benchTransforms = []struct {
+	name      string
+	transform TransformFunc
+}{
+	{name: "full", transform: noTransform},
+	{name: "stripped", transform: TransformFor(podGVK)},
+}
+
+func BenchmarkGet(b *testing.B) {
+	for _, bt := range benchTransforms {
+		store := largeNamespaceStore(b, bt.transform)
+		b.Run(bt.name+"/copy", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, exists, err := store.Get(podGVK, benchNamespace, "pod-1-1")
+				if err != nil || !exists {
+					b.Fatal(exists, err)
+				}
+			}
+		})
+		b.Run(bt.name+"/readOnly", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, exists, err := store.GetReadOnly(podGVK, benchNamespace, "pod-1-1")
+				if err != nil || !exists {
+					b.Fatal(exists, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkObjectsControlledBy(b *testing.B) {
+	for _, bt := range benchTransforms {
+		store := largeNamespaceStore(b, bt.transform)
+		b.Run(bt.name+"/copy", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				objs, err := store.ObjectsControlledBy(benchNamespace, "rs1")
+				if err != nil || len(objs) != benchPods {
+					b.Fatal(len(objs), err)
+				}
+			}
+		})
+		b.Run(bt.name+"/readOnly", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				objs, err := store.ObjectsOfKindControlledBy(podGVK, benchNamespace, "rs1")
+				if err != nil || len(objs) != benchPods {
+					b.Fatal(len(objs), err)
+				}
+			}
+		})
+	}
+}
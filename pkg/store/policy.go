@@ -0,0 +1,52 @@
+package store
+
+import (
+	"sort"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/atlassian/smith/pkg/policy"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PolicyStore provides BundlePolicies and ClusterBundlePolicies that apply to Bundles.
+type PolicyStore struct {
+	policies        cache.Indexer
+	clusterPolicies cache.Indexer
+}
+
+func NewPolicy(policyInf, clusterPolicyInf cache.SharedIndexInformer) (*PolicyStore, error) {
+	err := policyInf.AddIndexers(cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &PolicyStore{
+		policies:        policyInf.GetIndexer(),
+		clusterPolicies: clusterPolicyInf.GetIndexer(),
+	}, nil
+}
+
+// PoliciesFor returns policies that apply to Bundles in the namespace.
+// Policies are sorted by name so that violations are reported deterministically.
+func (s *PolicyStore) PoliciesFor(namespace string) ([]policy.Policy, error) {
+	var result []policy.Policy
+	err := cache.ListAll(s.clusterPolicies, labels.Everything(), func(obj interface{}) {
+		result = append(result, policy.FromClusterBundlePolicy(obj.(*smith_v1.ClusterBundlePolicy)))
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	err = cache.ListAllByNamespace(s.policies, namespace, labels.Everything(), func(obj interface{}) {
+		result = append(result, policy.FromBundlePolicy(obj.(*smith_v1.BundlePolicy)))
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
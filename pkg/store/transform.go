@@ -0,0 +1,124 @@
+package store
+
+import (
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// TransformFunc modifies an object before it is put into an informer's cache.
+// The object has just been received from the API server so it can be modified in place.
+type TransformFunc func(obj runtime.Object)
+
+// See docs/design/informer-transforms.md
+var readOnlyTransforms = map[schema.GroupVersionKind]TransformFunc{
+	apps_v1.SchemeGroupVersion.WithKind("ReplicaSet"): StripReplicaSet,
+	core_v1.SchemeGroupVersion.WithKind("Pod"):        StripPod,
+}
+
+// TransformFor returns the TransformFunc for objects of the GVK.
+// Objects of all kinds have managedFields removed. Objects that Smith never updates
+// have more fields removed, see StripReplicaSet and StripPod.
+func TransformFor(gvk schema.GroupVersionKind) TransformFunc {
+	if transform, ok := readOnlyTransforms[gvk]; ok {
+		return transform
+	}
+	return StripManagedFields
+}
+
+// StripManagedFields removes managedFields from the object.
+// Smith never reads them and they are often larger than the rest of the object.
+// Objects without managedFields can still be updated because the API server keeps
+// existing managedFields if they are omitted from the request.
+func StripManagedFields(obj runtime.Object) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	m.SetManagedFields(nil)
+}
+
+// StripReadOnly removes managedFields and the last-applied-configuration annotation from the object.
+// Must only be used for objects that are never updated using the cached version because
+// the annotation would be removed by such an update.
+func StripReadOnly(obj runtime.Object) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	m.SetManagedFields(nil)
+	annotations := m.GetAnnotations()
+	if _, ok := annotations[core_v1.LastAppliedConfigAnnotation]; ok {
+		delete(annotations, core_v1.LastAppliedConfigAnnotation)
+		m.SetAnnotations(annotations)
+	}
+}
+
+// StripReplicaSet removes the pod template from a ReplicaSet in addition to what StripReadOnly removes.
+// Only metadata and status of ReplicaSets are used to diagnose Deployments.
+func StripReplicaSet(obj runtime.Object) {
+	StripReadOnly(obj)
+	if rs, ok := obj.(*apps_v1.ReplicaSet); ok {
+		rs.Spec.Template = core_v1.PodTemplateSpec{}
+	}
+}
+
+// StripPod removes the spec from a Pod in addition to what StripReadOnly removes.
+// Only metadata and status of Pods are used to diagnose Deployments.
+func StripPod(obj runtime.Object) {
+	StripReadOnly(obj)
+	if pod, ok := obj.(*core_v1.Pod); ok {
+		pod.Spec = core_v1.PodSpec{}
+	}
+}
+
+// NewTransformingListerWatcher returns a ListerWatcher that applies the TransformFunc to all
+// objects returned by lw. It is used to reduce the size of objects in informers' caches.
+func NewTransformingListerWatcher(lw cache.ListerWatcher, transform TransformFunc) cache.ListerWatcher {
+	return &transformingListerWatcher{
+		lw:        lw,
+		transform: transform,
+	}
+}
+
+type transformingListerWatcher struct {
+	lw        cache.ListerWatcher
+	transform TransformFunc
+}
+
+func (t *transformingListerWatcher) List(options meta_v1.ListOptions) (runtime.Object, error) {
+	list, err := t.lw.List(options)
+	if err != nil {
+		return nil, err
+	}
+	// Items are returned as pointers into the list so they are modified in place
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		t.transform(item)
+	}
+	return list, nil
+}
+
+func (t *transformingListerWatcher) Watch(options meta_v1.ListOptions) (watch.Interface, error) {
+	w, err := t.lw.Watch(options)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		if event.Type != watch.Error {
+			// Watch events may share objects with other watchers e.g. with fake clientsets
+			// so the object is copied before it is modified.
+			event.Object = event.Object.DeepCopyObject()
+			t.transform(event.Object)
+		}
+		return event, true
+	}), nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	podGVK        = core_v1.SchemeGroupVersion.WithKind("Pod")
+	replicaSetGVK = apps_v1.SchemeGroupVersion.WithKind("ReplicaSet")
+)
+
+func pod(namespace, name string, controller types.UID) *core_v1.Pod {
+	trueVar := true
+	return &core_v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       types.UID(name + "-uid"),
+			Annotations: map[string]string{
+				"some":                              "annotation",
+				core_v1.LastAppliedConfigAnnotation: strings.Repeat("x", 2048),
+			},
+			OwnerReferences: []meta_v1.OwnerReference{
+				{
+					APIVersion: replicaSetGVK.GroupVersion().String(),
+					Kind:       replicaSetGVK.Kind,
+					Name:       string(controller),
+					UID:        controller,
+					Controller: &trueVar,
+				},
+			},
+			ManagedFields: []meta_v1.ManagedFieldsEntry{
+				{
+					Manager:    "kube-controller-manager",
+					Operation:  meta_v1.ManagedFieldsOperationUpdate,
+					APIVersion: "v1",
+					FieldsType: "FieldsV1",
+					FieldsV1:   &meta_v1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{}}}`)},
+				},
+			},
+		},
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{
+					Name:  "app",
+					Image: "app:1.0",
+					Env: []core_v1.EnvVar{
+						{
+							Name:  "ENV",
+							Value: strings.Repeat("y", 1024),
+						},
+					},
+				},
+			},
+		},
+		Status: core_v1.PodStatus{
+			Phase: core_v1.PodRunning,
+		},
+	}
+}
+
+func TestTransformingListerWatcherStripsObjects(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset(pod("ns1", "pod1", "rs1"))
+	lw := NewTransformingListerWatcher(&cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Pods(meta_v1.NamespaceAll).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Pods(meta_v1.NamespaceAll).Watch(options)
+		},
+	}, TransformFor(podGVK))
+
+	list, err := lw.List(meta_v1.ListOptions{})
+	require.NoError(t, err)
+	pods := list.(*core_v1.PodList).Items
+	require.Len(t, pods, 1)
+	assertStripped(t, &pods[0])
+
+	w, err := lw.Watch(meta_v1.ListOptions{})
+	require.NoError(t, err)
+	defer w.Stop()
+	_, err = client.CoreV1().Pods("ns1").Create(pod("ns1", "pod2", "rs1"))
+	require.NoError(t, err)
+	select {
+	case event := <-w.ResultChan():
+		require.Equal(t, watch.Added, event.Type)
+		assertStripped(t, event.Object.(*core_v1.Pod))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for watch event")
+	}
+}
+
+func TestStripManagedFieldsKeepsAnnotations(t *testing.T) {
+	t.Parallel()
+	p := pod("ns1", "pod1", "rs1")
+	StripManagedFields(p)
+	assert.Empty(t, p.ManagedFields)
+	assert.Contains(t, p.Annotations, core_v1.LastAppliedConfigAnnotation)
+	assert.NotEmpty(t, p.Spec.Containers)
+}
+
+func assertStripped(t *testing.T, p *core_v1.Pod) {
+	assert.Empty(t, p.ManagedFields)
+	assert.NotContains(t, p.Annotations, core_v1.LastAppliedConfigAnnotation)
+	assert.Equal(t, "annotation", p.Annotations["some"])
+	assert.Empty(t, p.Spec.Containers)
+	assert.Equal(t, core_v1.PodRunning, p.Status.Phase)
+	assert.Len(t, p.OwnerReferences, 1)
+}
@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"net/url"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	admreg_v1 "k8s.io/api/admissionregistration/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	admreg_v1client "k8s.io/client-go/kubernetes/typed/admissionregistration/v1"
+)
+
+// CheckBundleAuthorWebhook makes sure the mutating webhook that records authors of Bundles is configured and
+// cannot be skipped. Authors of Bundles are set by users otherwise so anyone could forge them.
+func CheckBundleAuthorWebhook(client admreg_v1client.MutatingWebhookConfigurationsGetter) error {
+	configs, err := client.MutatingWebhookConfigurations().List(meta_v1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list MutatingWebhookConfigurations")
+	}
+	for _, config := range configs.Items {
+		for i := range config.Webhooks {
+			if isBundleAuthorWebhook(&config.Webhooks[i]) {
+				return nil
+			}
+		}
+	}
+	return errors.Errorf("no MutatingWebhookConfiguration calls %s for CREATE and UPDATE of Bundles "+
+		"with failurePolicy Fail and without an objectSelector, see docs/deployment/4-webhooks.yaml", MutateBundlePath)
+}
+
+func isBundleAuthorWebhook(wh *admreg_v1.MutatingWebhook) bool {
+	if wh.FailurePolicy != nil && *wh.FailurePolicy != admreg_v1.Fail {
+		return false
+	}
+	// Labels of Bundles are set by users so the webhook could be skipped
+	if wh.ObjectSelector != nil && (len(wh.ObjectSelector.MatchLabels) > 0 || len(wh.ObjectSelector.MatchExpressions) > 0) {
+		return false
+	}
+	switch {
+	case wh.ClientConfig.Service != nil:
+		if wh.ClientConfig.Service.Path == nil || *wh.ClientConfig.Service.Path != MutateBundlePath {
+			return false
+		}
+	case wh.ClientConfig.URL != nil:
+		u, err := url.Parse(*wh.ClientConfig.URL)
+		if err != nil || u.Path != MutateBundlePath {
+			return false
+		}
+	default:
+		return false
+	}
+	create, update := false, false
+	for _, rule := range wh.Rules {
+		if !matchesBundles(rule.Rule) {
+			continue
+		}
+		for _, op := range rule.Operations {
+			switch op {
+			case admreg_v1.OperationAll:
+				create, update = true, true
+			case admreg_v1.Create:
+				create = true
+			case admreg_v1.Update:
+				update = true
+			}
+		}
+	}
+	return create && update
+}
+
+func matchesBundles(rule admreg_v1.Rule) bool {
+	return contains(rule.APIGroups, smith_v1.BundleGVK.Group) &&
+		contains(rule.APIVersions, smith_v1.BundleGVK.Version) &&
+		contains(rule.Resources, smith_v1.BundleResourcePlural)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admreg_v1 "k8s.io/api/admissionregistration/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckBundleAuthorWebhook(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name   string
+		mutate func(*admreg_v1.MutatingWebhook)
+		valid  bool
+	}{
+		{
+			name:   "valid",
+			mutate: func(*admreg_v1.MutatingWebhook) {},
+			valid:  true,
+		},
+		{
+			name: "wildcards",
+			mutate: func(wh *admreg_v1.MutatingWebhook) {
+				wh.Rules[0].APIGroups = []string{"*"}
+				wh.Rules[0].Operations = []admreg_v1.OperationType{admreg_v1.OperationAll}
+			},
+			valid: true,
+		},
+		{
+			name: "URL",
+			mutate: func(wh *admreg_v1.MutatingWebhook) {
+				u := "https://smith.example.com" + MutateBundlePath
+				wh.ClientConfig = admreg_v1.WebhookClientConfig{URL: &u}
+			},
+			valid: true,
+		},
+		{
+			name: "failure policy Ignore",
+			mutate: func(wh *admreg_v1.MutatingWebhook) {
+				ignore := admreg_v1.Ignore
+				wh.FailurePolicy = &ignore
+			},
+		},
+		{
+			name: "object selector",
+			mutate: func(wh *admreg_v1.MutatingWebhook) {
+				wh.ObjectSelector = &meta_v1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				}
+			},
+		},
+		{
+			name: "no UPDATE",
+			mutate: func(wh *admreg_v1.MutatingWebhook) {
+				wh.Rules[0].Operations = []admreg_v1.OperationType{admreg_v1.Create}
+			},
+		},
+		{
+			name: "other resource",
+			mutate: func(wh *admreg_v1.MutatingWebhook) {
+				wh.Rules[0].Resources = []string{"bundlepolicies"}
+			},
+		},
+		{
+			name: "other path",
+			mutate: func(wh *admreg_v1.MutatingWebhook) {
+				path := ValidateBundlePath
+				wh.ClientConfig.Service.Path = &path
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			path := MutateBundlePath
+			fail := admreg_v1.Fail
+			wh := admreg_v1.MutatingWebhook{
+				Name:          "bundles.smith.atlassian.com",
+				FailurePolicy: &fail,
+				ClientConfig: admreg_v1.WebhookClientConfig{
+					Service: &admreg_v1.ServiceReference{
+						Namespace: "smith",
+						Name:      "smith",
+						Path:      &path,
+					},
+				},
+				Rules: []admreg_v1.RuleWithOperations{
+					{
+						Operations: []admreg_v1.OperationType{admreg_v1.Create, admreg_v1.Update},
+						Rule: admreg_v1.Rule{
+							APIGroups:   []string{"smith.atlassian.com"},
+							APIVersions: []string{"v1"},
+							Resources:   []string{"bundles"},
+						},
+					},
+				},
+			}
+			tc.mutate(&wh)
+			client := fake.NewSimpleClientset([]runtime.Object{
+				&admreg_v1.MutatingWebhookConfiguration{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name: "smith",
+					},
+					Webhooks: []admreg_v1.MutatingWebhook{wh},
+				},
+			}...)
+			err := CheckBundleAuthorWebhook(client.AdmissionregistrationV1())
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckBundleAuthorWebhookMissing(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	assert.EqualError(t, CheckBundleAuthorWebhook(client.AdmissionregistrationV1()), "no MutatingWebhookConfiguration calls "+
+		MutateBundlePath+" for CREATE and UPDATE of Bundles with failurePolicy Fail and without an objectSelector, see docs/deployment/4-webhooks.yaml")
+}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"encoding/json"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	admission_v1 "k8s.io/api/admission/v1"
+	auth_v1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// jsonPatchOperation is an operation of a JSON Patch (RFC 6902).
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// stampBundleAuthor records the identity of the user who creates a Bundle or changes its spec in the Bundle.
+// Author cannot be set by the user. Objects of the Bundle are managed with privileges of the author so whoever
+// changes the spec becomes the author. Updates that do not change the spec (e.g. finalizers and status updates
+// by Smith) keep the author.
+func (s *Server) stampBundleAuthor(req *admission_v1.AdmissionRequest) *admission_v1.AdmissionResponse {
+	if req.Kind.Group != smith_v1.BundleGVK.Group || req.Kind.Kind != smith_v1.BundleGVK.Kind {
+		return badRequest(errors.Errorf("unexpected kind %s", req.Kind))
+	}
+	bundle, err := decodeBundle(req.Object)
+	if err != nil {
+		return badRequest(err)
+	}
+	var author *auth_v1.UserInfo
+	switch req.Operation {
+	case admission_v1.Create:
+		author = req.UserInfo.DeepCopy()
+	case admission_v1.Update:
+		oldBundle, err := decodeBundle(req.OldObject) // nolint: vetshadow
+		if err != nil {
+			return badRequest(err)
+		}
+		if specChanged(&oldBundle.Spec, &bundle.Spec) {
+			author = req.UserInfo.DeepCopy()
+		} else {
+			// Bundles created before the webhook was enabled do not have an author
+			author = oldBundle.Spec.Author
+		}
+	default:
+		return allowed()
+	}
+	if equality.Semantic.DeepEqual(bundle.Spec.Author, author) {
+		return allowed()
+	}
+	patch, err := authorPatch(req.Object.Raw, author)
+	if err != nil {
+		return badRequest(err)
+	}
+	if author != nil {
+		s.Logger.Debug("Setting author of the Bundle", zap.String("namespace", req.Namespace),
+			zap.String("name", bundle.Name), zap.String("author", author.Username))
+	}
+	patchType := admission_v1.PatchTypeJSONPatch
+	return &admission_v1.AdmissionResponse{
+		Allowed:   true,
+		Patch:     patch,
+		PatchType: &patchType,
+	}
+}
+
+// specChanged returns true if the specs differ in anything but the author.
+func specChanged(oldSpec, newSpec *smith_v1.BundleSpec) bool {
+	oldSpec = oldSpec.DeepCopy()
+	newSpec = newSpec.DeepCopy()
+	oldSpec.Author = nil
+	newSpec.Author = nil
+	return !equality.Semantic.DeepEqual(oldSpec, newSpec)
+}
+
+// authorPatch constructs a JSON Patch that sets the author of the Bundle to the provided value or
+// removes it if the value is nil.
+func authorPatch(raw []byte, author *auth_v1.UserInfo) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, errors.Wrap(err, "failed to decode Bundle")
+	}
+	var op jsonPatchOperation
+	switch {
+	case author == nil:
+		op = jsonPatchOperation{
+			Op:   "remove",
+			Path: "/spec/author",
+		}
+	case obj["spec"] == nil || string(obj["spec"]) == "null":
+		op = jsonPatchOperation{
+			Op:   "add",
+			Path: "/spec",
+			Value: map[string]interface{}{
+				"author": author,
+			},
+		}
+	default:
+		op = jsonPatchOperation{
+			Op:    "add",
+			Path:  "/spec/author",
+			Value: author,
+		}
+	}
+	patch, err := json.Marshal([]jsonPatchOperation{op})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return patch, nil
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admission_v1 "k8s.io/api/admission/v1"
+	auth_v1 "k8s.io/api/authentication/v1"
+)
+
+func TestStampBundleAuthorOnCreate(t *testing.T) {
+	t.Parallel()
+	newBundle := bundle("a")
+	newBundle.Spec.Author = &auth_v1.UserInfo{
+		Username: "spoofed",
+	}
+	response := reviewPath(t, MutateBundlePath, nil, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Create,
+		Namespace: "ns",
+		Object:    bundleRaw(t, newBundle),
+		UserInfo: auth_v1.UserInfo{
+			Username: "alice",
+			Groups:   []string{"devs"},
+		},
+	})
+	assert.True(t, response.Allowed)
+	if assert.NotNil(t, response.PatchType) {
+		assert.Equal(t, admission_v1.PatchTypeJSONPatch, *response.PatchType)
+	}
+	assert.JSONEq(t, `[{"op":"add","path":"/spec/author","value":{"username":"alice","groups":["devs"]}}]`, string(response.Patch))
+}
+
+func TestStampBundleAuthorKeepsAuthorOnUpdate(t *testing.T) {
+	t.Parallel()
+	oldBundle := bundle("a")
+	oldBundle.Spec.Author = &auth_v1.UserInfo{
+		Username: "alice",
+	}
+
+	// Unchanged spec (e.g. finalizers updated by Smith)
+	newBundle := bundle("a")
+	newBundle.Finalizers = []string{"finalizer"}
+	newBundle.Spec.Author = oldBundle.Spec.Author.DeepCopy()
+	response := reviewPath(t, MutateBundlePath, nil, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Update,
+		Namespace: "ns",
+		Object:    bundleRaw(t, newBundle),
+		OldObject: bundleRaw(t, oldBundle),
+		UserInfo: auth_v1.UserInfo{
+			Username: "bob",
+		},
+	})
+	assert.True(t, response.Allowed)
+	assert.Nil(t, response.Patch)
+
+	// Attempt to change only the author
+	newBundle.Spec.Author.Username = "bob"
+	response = reviewPath(t, MutateBundlePath, nil, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Update,
+		Namespace: "ns",
+		Object:    bundleRaw(t, newBundle),
+		OldObject: bundleRaw(t, oldBundle),
+		UserInfo: auth_v1.UserInfo{
+			Username: "bob",
+		},
+	})
+	assert.True(t, response.Allowed)
+	assert.JSONEq(t, `[{"op":"add","path":"/spec/author","value":{"username":"alice"}}]`, string(response.Patch))
+
+	// Attempt to set author of a Bundle without an author
+	oldBundle.Spec.Author = nil
+	response = reviewPath(t, MutateBundlePath, nil, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Update,
+		Namespace: "ns",
+		Object:    bundleRaw(t, newBundle),
+		OldObject: bundleRaw(t, oldBundle),
+		UserInfo: auth_v1.UserInfo{
+			Username: "bob",
+		},
+	})
+	assert.True(t, response.Allowed)
+	assert.JSONEq(t, `[{"op":"remove","path":"/spec/author"}]`, string(response.Patch))
+}
+
+func TestStampBundleAuthorOnSpecUpdate(t *testing.T) {
+	t.Parallel()
+	oldBundle := bundle("a")
+	oldBundle.Spec.Author = &auth_v1.UserInfo{
+		Username: "alice",
+		Groups:   []string{"admins"},
+	}
+
+	// Whoever changes the spec becomes the author so that the change is made with their privileges
+	newBundle := bundle("b")
+	newBundle.Spec.Author = oldBundle.Spec.Author.DeepCopy()
+	response := reviewPath(t, MutateBundlePath, nil, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Update,
+		Namespace: "ns",
+		Object:    bundleRaw(t, newBundle),
+		OldObject: bundleRaw(t, oldBundle),
+		UserInfo: auth_v1.UserInfo{
+			Username: "bob",
+			Groups:   []string{"devs"},
+		},
+	})
+	assert.True(t, response.Allowed)
+	assert.JSONEq(t, `[{"op":"add","path":"/spec/author","value":{"username":"bob","groups":["devs"]}}]`, string(response.Patch))
+
+	// Same for Bundles without an author
+	oldBundle.Spec.Author = nil
+	newBundle.Spec.Author = nil
+	response = reviewPath(t, MutateBundlePath, nil, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Update,
+		Namespace: "ns",
+		Object:    bundleRaw(t, newBundle),
+		OldObject: bundleRaw(t, oldBundle),
+		UserInfo: auth_v1.UserInfo{
+			Username: "bob",
+		},
+	})
+	assert.True(t, response.Allowed)
+	assert.JSONEq(t, `[{"op":"add","path":"/spec/author","value":{"username":"bob"}}]`, string(response.Patch))
+}
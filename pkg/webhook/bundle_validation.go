@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"encoding/json"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	admission_v1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	api_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// BundleValidator validates Bundles.
+type BundleValidator interface {
+	Validate(*smith_v1.Bundle) field.ErrorList
+}
+
+func (s *Server) validateBundle(req *admission_v1.AdmissionRequest) *admission_v1.AdmissionResponse {
+	if req.Kind.Group != smith_v1.BundleGVK.Group || req.Kind.Kind != smith_v1.BundleGVK.Kind {
+		return badRequest(errors.Errorf("unexpected kind %s", req.Kind))
+	}
+	if req.Operation != admission_v1.Create && req.Operation != admission_v1.Update {
+		return allowed()
+	}
+	bundle, err := decodeBundle(req.Object)
+	if err != nil {
+		return badRequest(err)
+	}
+	if bundle.Namespace == "" {
+		// Namespace may not be set in the object on create
+		bundle.Namespace = req.Namespace
+	}
+	if bundle.DeletionTimestamp != nil {
+		// Bundle is being deleted, do not prevent finalizers from being removed
+		return allowed()
+	}
+	if req.Operation == admission_v1.Update {
+		oldBundle, err := decodeBundle(req.OldObject) // nolint: vetshadow
+		if err != nil {
+			return badRequest(err)
+		}
+		if equality.Semantic.DeepEqual(oldBundle.Spec, bundle.Spec) {
+			// Only spec is validated. Do not prevent updates of metadata of Bundles that are invalid already
+			// e.g. Bundles that refer to a plugin that is no longer available.
+			return allowed()
+		}
+	}
+	logger := s.Logger.With(zap.String("namespace", bundle.Namespace), zap.String("name", bundle.Name))
+	allErrs := s.BundleValidator.Validate(bundle)
+	if len(allErrs) == 0 {
+		return allowed()
+	}
+	logger.Debug("Rejecting invalid Bundle", zap.Error(allErrs.ToAggregate()))
+	status := api_errors.NewInvalid(smith_v1.BundleGVK.GroupKind(), bundle.Name, allErrs).ErrStatus
+	return &admission_v1.AdmissionResponse{
+		Result: &status,
+	}
+}
+
+func decodeBundle(raw runtime.RawExtension) (*smith_v1.Bundle, error) {
+	var bundle smith_v1.Bundle
+	if err := json.Unmarshal(raw.Raw, &bundle); err != nil {
+		return nil, errors.Wrap(err, "failed to decode Bundle")
+	}
+	return &bundle, nil
+}
@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	smith_v1 "github.com/atlassian/smith/pkg/apis/smith/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admission_v1 "k8s.io/api/admission/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const reviewUID types.UID = "review-uid"
+
+type fakeValidator struct {
+	errs      field.ErrorList
+	validated []*smith_v1.Bundle
+}
+
+func (v *fakeValidator) Validate(bundle *smith_v1.Bundle) field.ErrorList {
+	v.validated = append(v.validated, bundle)
+	return v.errs
+}
+
+func TestValidateBundleRejectsInvalidBundle(t *testing.T) {
+	t.Parallel()
+	validator := &fakeValidator{
+		errs: field.ErrorList{
+			field.Duplicate(field.NewPath("spec", "resources").Index(1).Child("name"), "a"),
+			field.NotFound(field.NewPath("spec", "resources").Index(2).Child("spec", "plugin", "name"), "p"),
+		},
+	}
+	response := review(t, validator, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Create,
+		Namespace: "ns",
+		Object:    bundleRaw(t, bundle("a")),
+	})
+	assert.False(t, response.Allowed)
+	require.NotNil(t, response.Result)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, response.Result.Code)
+	assert.Equal(t, meta_v1.StatusReasonInvalid, response.Result.Reason)
+	assert.Equal(t, `Bundle.smith.atlassian.com "bundle1" is invalid: [spec.resources[1].name: Duplicate value: "a", spec.resources[2].spec.plugin.name: Not found: "p"]`, response.Result.Message)
+	require.Len(t, validator.validated, 1)
+	assert.Equal(t, "ns", validator.validated[0].Namespace)
+}
+
+func TestValidateBundleAllowsValidBundle(t *testing.T) {
+	t.Parallel()
+	validator := &fakeValidator{}
+	response := review(t, validator, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Create,
+		Namespace: "ns",
+		Object:    bundleRaw(t, bundle("a")),
+	})
+	assert.True(t, response.Allowed)
+	assert.Len(t, validator.validated, 1)
+}
+
+func TestValidateBundleSkipsUpdateWithoutSpecChange(t *testing.T) {
+	t.Parallel()
+	validator := &fakeValidator{
+		errs: field.ErrorList{
+			field.NotFound(field.NewPath("spec", "resources").Index(0).Child("spec", "plugin", "name"), "p"),
+		},
+	}
+	oldBundle := bundle("a")
+	newBundle := bundle("a")
+	newBundle.Finalizers = []string{"some-finalizer"}
+	response := review(t, validator, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Update,
+		Namespace: "ns",
+		Object:    bundleRaw(t, newBundle),
+		OldObject: bundleRaw(t, oldBundle),
+	})
+	assert.True(t, response.Allowed)
+	assert.Empty(t, validator.validated)
+
+	newBundle = bundle("b")
+	response = review(t, validator, &admission_v1.AdmissionRequest{
+		Operation: admission_v1.Update,
+		Namespace: "ns",
+		Object:    bundleRaw(t, newBundle),
+		OldObject: bundleRaw(t, oldBundle),
+	})
+	assert.False(t, response.Allowed)
+	assert.Len(t, validator.validated, 1)
+}
+
+func review(t *testing.T, validator BundleValidator, req *admission_v1.AdmissionRequest) *admission_v1.AdmissionResponse {
+	return reviewPath(t, ValidateBundlePath, validator, req)
+}
+
+func reviewPath(t *testing.T, path string, validator BundleValidator, req *admission_v1.AdmissionRequest) *admission_v1.AdmissionResponse {
+	srv := &Server{
+		Logger:          zap.NewNop(),
+		BundleValidator: validator,
+	}
+	req.UID = reviewUID
+	req.Kind = meta_v1.GroupVersionKind{
+		Group:   smith_v1.BundleGVK.Group,
+		Version: smith_v1.BundleGVK.Version,
+		Kind:    smith_v1.BundleGVK.Kind,
+	}
+	data, err := json.Marshal(&admission_v1.AdmissionReview{
+		TypeMeta: meta_v1.TypeMeta{
+			APIVersion: "admission.k8s.io/v1beta1",
+			Kind:       "AdmissionReview",
+		},
+		Request: req,
+	})
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var result admission_v1.AdmissionReview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, "admission.k8s.io/v1beta1", result.APIVersion)
+	require.NotNil(t, result.Response)
+	assert.Equal(t, reviewUID, result.Response.UID)
+	return result.Response
+}
+
+func bundle(resourceName smith_v1.ResourceName) *smith_v1.Bundle {
+	return &smith_v1.Bundle{
+		TypeMeta: meta_v1.TypeMeta{
+			APIVersion: smith_v1.BundleResourceGroupVersion,
+			Kind:       smith_v1.BundleResourceKind,
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "bundle1",
+		},
+		Spec: smith_v1.BundleSpec{
+			Resources: []smith_v1.Resource{
+				{
+					Name: resourceName,
+					Spec: smith_v1.ResourceSpec{
+						Plugin: &smith_v1.PluginSpec{
+							Name:       "p",
+							ObjectName: "obj",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func bundleRaw(t *testing.T, bundle *smith_v1.Bundle) runtime.RawExtension {
+	data, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	return runtime.RawExtension{Raw: data}
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/atlassian/ctrl/process"
+	"go.uber.org/zap"
+	admission_v1 "k8s.io/api/admission/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ValidateBundlePath is the path the Bundle validating webhook is served on.
+	ValidateBundlePath = "/validate-bundle"
+	// MutateBundlePath is the path the Bundle mutating webhook is served on.
+	MutateBundlePath = "/mutate-bundle"
+
+	shutdownTimeout = 3 * time.Second
+	// Kubernetes API server does not accept requests bigger than 3MiB so reviews should never be that big.
+	maxReviewBytes = 3 * 1024 * 1024
+)
+
+// Server serves admission webhooks for Bundles.
+type Server struct {
+	Logger *zap.Logger
+	// Addr is the address to listen on.
+	Addr string
+	// CertFile and KeyFile are paths to the TLS certificate and key. Webhooks must be served over HTTPS.
+	CertFile string
+	KeyFile  string
+	// Middleware wraps the handler of the server.
+	Middleware      func(http.Handler) http.Handler
+	BundleValidator BundleValidator
+}
+
+// Run starts the server and blocks until the context is done.
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:    s.Addr,
+		Handler: s.Handler(),
+	}
+	s.Logger.Sugar().Infof("Serving webhooks on %s", s.Addr)
+	return process.StartStopTLSServer(ctx, srv, shutdownTimeout, s.CertFile, s.KeyFile)
+}
+
+// Handler returns the http.Handler that serves all webhooks.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(ValidateBundlePath, s.reviewHandler(s.validateBundle))
+	mux.Handle(MutateBundlePath, s.reviewHandler(s.stampBundleAuthor))
+	if s.Middleware == nil {
+		return mux
+	}
+	return s.Middleware(mux)
+}
+
+// reviewHandler decodes an AdmissionReview, passes the request to the provided function and encodes
+// the response it returns. Both v1 and v1beta1 AdmissionReviews have the same structure so both are
+// supported. Response is sent using the same version the request used.
+func (s *Server) reviewHandler(admit func(*admission_v1.AdmissionRequest) *admission_v1.AdmissionResponse) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST method is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+			http.Error(w, "unsupported content type "+contentType, http.StatusUnsupportedMediaType)
+			return
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxReviewBytes))
+		if err != nil {
+			s.Logger.Info("Failed to read AdmissionReview", zap.Error(err))
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		var review admission_v1.AdmissionReview
+		if err = json.Unmarshal(data, &review); err != nil {
+			http.Error(w, "failed to decode AdmissionReview: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if review.Request == nil {
+			http.Error(w, "AdmissionReview does not contain a request", http.StatusBadRequest)
+			return
+		}
+		response := admit(review.Request)
+		response.UID = review.Request.UID
+		data, err = json.Marshal(&admission_v1.AdmissionReview{
+			TypeMeta: review.TypeMeta,
+			Response: response,
+		})
+		if err != nil {
+			s.Logger.Error("Failed to encode AdmissionReview", zap.Error(err))
+			http.Error(w, "failed to encode AdmissionReview", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err = w.Write(data); err != nil {
+			s.Logger.Debug("Failed to write AdmissionReview", zap.Error(err))
+		}
+	})
+}
+
+func allowed() *admission_v1.AdmissionResponse {
+	return &admission_v1.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+func badRequest(err error) *admission_v1.AdmissionResponse {
+	return &admission_v1.AdmissionResponse{
+		Result: &meta_v1.Status{
+			Status:  meta_v1.StatusFailure,
+			Code:    http.StatusBadRequest,
+			Reason:  meta_v1.StatusReasonBadRequest,
+			Message: err.Error(),
+		},
+	}
+}